@@ -3,14 +3,23 @@ package main
 
 import (
 	"access-proxy/internal/config"
+	"access-proxy/internal/redact"
 	"access-proxy/internal/server"
 	"access-proxy/internal/types"
 	"fmt"
+	"os"
 
 	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(runReplay(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "mock-idp" {
+		os.Exit(runMockIdP(os.Args[2:]))
+	}
+
 	cfg := config.LoadConfig()
 
 	var log logger.Logger
@@ -25,30 +34,46 @@ func main() {
 	fmt.Printf("=== CONFIGURATION ===\n")
 	fmt.Printf("Target: %s\n", cfg.Target)
 	fmt.Printf("Port: %d\n", cfg.Port)
-	fmt.Printf("Rate Limit: %d/min\n", cfg.RateLimitPerMinute)
+	fmt.Printf("Rate Limit: %d/%v (burst %d)\n", cfg.RateLimit.Limit, cfg.RateLimit.Window, cfg.RateLimit.Burst)
 	fmt.Printf("Log Requests: %t\n", cfg.LogRequests)
 	fmt.Printf("Allowed Domains: %v\n", cfg.AllowedDomains)
 	fmt.Printf("Blocked Methods: %v\n", cfg.BlockedMethods)
+	fmt.Printf("Metrics: %t (%s)\n", cfg.Metrics.Enabled, cfg.Metrics.Path)
 	fmt.Printf("=====================\n")
 	
-	proxy := server.NewProxyServer(cfg.Target, log)
+	redactionHeaders := cfg.Redaction.Headers
+	redactionParams := cfg.Redaction.QueryParams
+	if cfg.APIKeys.Enabled {
+		// Ключи не должны попадать в логи, даже если правила их не перечисляют
+		if len(redactionHeaders) == 0 {
+			redactionHeaders = redact.DefaultHeaders
+		}
+		redactionHeaders = append(append([]string{}, redactionHeaders...), cfg.APIKeys.Header)
+		if cfg.APIKeys.QueryParam != "" {
+			redactionParams = append(redactionParams, cfg.APIKeys.QueryParam)
+		}
+	}
+
+	redactor, err := redact.New(redact.Rules{
+		Headers:     redactionHeaders,
+		QueryParams: redactionParams,
+		JSONFields:  cfg.Redaction.JSONFields,
+		Patterns:    cfg.Redaction.Patterns,
+		Mask:        cfg.Redaction.Mask,
+	})
+	if err != nil {
+		log.Fatalf("❌ Invalid redaction rules: %v", err)
+	}
+
+	proxy := server.NewProxyServer(cfg.Target, log, redactor)
 	
-	ser := server.NewHttpServer(
-		proxy, 
-		cfg.Port, 
-		log, 
-		cfg.RateLimitPerMinute,
-		cfg.Target,
-		cfg.LogRequests,
-		cfg.AllowedDomains,
-		cfg.BlockedMethods,
-	)
+	ser := server.NewHttpServer(proxy, cfg, log, redactor)
 
 	ser.RegisterEndpoints()
 	
 	log.Infof("🚀 Proxy server starting: %s -> :%d", cfg.Target, cfg.Port)
-	if cfg.RateLimitPerMinute > 0 {
-		log.Infof("🔒 Rate limiting enabled: %d requests per minute", cfg.RateLimitPerMinute)
+	if cfg.RateLimit.Limit > 0 {
+		log.Infof("🔒 Rate limiting enabled: %d requests per %v", cfg.RateLimit.Limit, cfg.RateLimit.Window)
 	}
 	if cfg.LogRequests {
 		log.Info("📝 Request logging enabled")
@@ -0,0 +1,54 @@
+// mockidp.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"access-proxy/internal/oidc"
+	"access-proxy/internal/types"
+)
+
+// runMockIdP реализует подкоманду "access-proxy mock-idp" — локальный
+// OpenID провайдер для проверки входа через OIDC без настоящего IdP
+func runMockIdP(args []string) int {
+	fs := flag.NewFlagSet("mock-idp", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:9000", "Адрес для прослушивания")
+	issuer := fs.String("issuer", "", "Issuer (по умолчанию http://<addr>)")
+	clientID := fs.String("client-id", "access-proxy", "client_id прокси")
+	clientSecret := fs.String("client-secret", "secret", "client_secret прокси")
+	email := fs.String("email", "dev@example.com", "Email пользователя")
+	name := fs.String("name", "Dev User", "Имя пользователя")
+	tokenTTL := fs.Duration("token-ttl", 5*time.Minute, "Срок жизни выданных токенов")
+	var groups types.StringSlice
+	fs.Var(&groups, "groups", "Группы пользователя (через запятую)")
+	fs.Parse(args)
+
+	if *issuer == "" {
+		*issuer = "http://" + *addr
+	}
+
+	idp, err := oidc.NewMockIdP(oidc.MockOptions{
+		Issuer:       *issuer,
+		ClientID:     *clientID,
+		ClientSecret: *clientSecret,
+		Email:        *email,
+		Name:         *name,
+		Groups:       groups,
+		TokenTTL:     *tokenTTL,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mock-idp: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Mock IdP %s listening on %s (user %s, groups %v)\n", *issuer, *addr, *email, []string(groups))
+	if err := http.ListenAndServe(*addr, idp); err != nil {
+		fmt.Fprintf(os.Stderr, "mock-idp: %v\n", err)
+		return 1
+	}
+	return 0
+}
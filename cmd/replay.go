@@ -0,0 +1,102 @@
+// replay.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"access-proxy/internal/capture"
+	"access-proxy/internal/replay"
+	"access-proxy/internal/types"
+)
+
+// headerFlags повторяемый флаг -H "Имя: значение"
+type headerFlags http.Header
+
+func (h headerFlags) String() string {
+	return fmt.Sprintf("%v", http.Header(h))
+}
+
+func (h headerFlags) Set(value string) error {
+	name, v, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("header must look like \"Name: value\", got %q", value)
+	}
+	http.Header(h).Add(strings.TrimSpace(name), strings.TrimSpace(v))
+	return nil
+}
+
+// runReplay реализует подкоманду "access-proxy replay"
+func runReplay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	target := fs.String("target", "", "URL, против которого воспроизводится трафик")
+	rate := fs.Float64("rate", 0, "Запросов в секунду (0 — без ограничения)")
+	concurrency := fs.Int("concurrency", 4, "Число параллельных запросов")
+	timeout := fs.Duration("timeout", 30*time.Second, "Таймаут одного запроса")
+	report := fs.String("report", "", "Путь для JSON отчёта")
+	headers := headerFlags{}
+	fs.Var(headers, "H", "Заголовок, заменяющий записанный: \"Имя: значение\" (можно повторять)")
+	var ignore types.StringSlice
+	fs.Var(&ignore, "ignore-fields", "Ключи JSON, которые не сравниваются (через запятую)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: access-proxy replay -target URL [flags] capture.har|capture.jsonl ...\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *target == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	targetURL, err := url.Parse(*target)
+	if err != nil || targetURL.Scheme == "" || targetURL.Host == "" {
+		fmt.Fprintf(os.Stderr, "❌ Invalid target URL: %s\n", *target)
+		return 2
+	}
+
+	var entries []capture.Entry
+	for _, path := range fs.Args() {
+		loaded, err := replay.Load(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Failed to load %s: %v\n", path, err)
+			return 1
+		}
+		entries = append(entries, loaded...)
+	}
+	fmt.Printf("▶️  Replaying %d requests against %s\n", len(entries), targetURL)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	result := replay.Run(ctx, entries, replay.Options{
+		Target:       targetURL,
+		Rate:         *rate,
+		Concurrency:  *concurrency,
+		Headers:      http.Header(headers),
+		IgnoreFields: ignore,
+		Timeout:      *timeout,
+	})
+	result.WriteText(os.Stdout)
+
+	if *report != "" {
+		data, _ := json.MarshalIndent(result, "", "  ")
+		if err := os.WriteFile(*report, data, 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Failed to write report: %v\n", err)
+			return 1
+		}
+		fmt.Printf("📄 Report written to %s\n", *report)
+	}
+
+	if result.Failed() {
+		return 1
+	}
+	return 0
+}
@@ -2,7 +2,14 @@ module access-proxy
 
 go 1.24.2
 
+require (
+	github.com/redis/go-redis/v9 v9.17.2
+	golang.org/x/crypto v0.40.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
 require (
 	github.com/Freyzan2006/go-logger-lib v1.0.2 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 )
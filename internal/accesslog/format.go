@@ -0,0 +1,234 @@
+// internal/accesslog/format.go
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Поддерживаемые форматы журнала доступа
+const (
+	FormatJSON     = "json"
+	FormatCommon   = "common"
+	FormatCombined = "combined"
+	FormatTemplate = "template"
+)
+
+// Entry одна запись журнала доступа
+type Entry struct {
+	Time             time.Time
+	RequestID        string
+	ClientIP         string
+	User             string
+	Method           string
+	Path             string
+	Query            string
+	Proto            string
+	Status           int
+	Bytes            int64
+	Duration         time.Duration
+	Route            string
+	Upstream         string
+	UpstreamDuration time.Duration
+	Decision         string
+	DenyReason       string
+	UserAgent        string
+	Referer          string
+}
+
+// Formatter превращает запись в строку без перевода строки
+type Formatter interface {
+	Format(e *Entry) []byte
+}
+
+// NewFormatter создаёт форматтер по имени формата
+func NewFormatter(format, template string) (Formatter, error) {
+	switch strings.ToLower(format) {
+	case "", FormatJSON:
+		return jsonFormatter{}, nil
+	case FormatCommon:
+		return commonFormatter{}, nil
+	case FormatCombined:
+		return commonFormatter{combined: true}, nil
+	case FormatTemplate:
+		return newTemplateFormatter(template)
+	}
+	return nil, fmt.Errorf("unknown access log format %q", format)
+}
+
+type jsonEntry struct {
+	Time               string  `json:"time"`
+	RequestID          string  `json:"request_id,omitempty"`
+	ClientIP           string  `json:"client_ip"`
+	User               string  `json:"user,omitempty"`
+	Method             string  `json:"method"`
+	Path               string  `json:"path"`
+	Query              string  `json:"query,omitempty"`
+	Proto              string  `json:"proto"`
+	Status             int     `json:"status"`
+	Bytes              int64   `json:"bytes"`
+	DurationMs         float64 `json:"duration_ms"`
+	Route              string  `json:"route,omitempty"`
+	Upstream           string  `json:"upstream,omitempty"`
+	UpstreamDurationMs float64 `json:"upstream_duration_ms,omitempty"`
+	Decision           string  `json:"decision"`
+	DenyReason         string  `json:"deny_reason,omitempty"`
+	UserAgent          string  `json:"user_agent,omitempty"`
+	Referer            string  `json:"referer,omitempty"`
+}
+
+type jsonFormatter struct{}
+
+func (jsonFormatter) Format(e *Entry) []byte {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(jsonEntry{
+		Time:               e.Time.Format(time.RFC3339Nano),
+		RequestID:          e.RequestID,
+		ClientIP:           e.ClientIP,
+		User:               e.User,
+		Method:             e.Method,
+		Path:               e.Path,
+		Query:              e.Query,
+		Proto:              e.Proto,
+		Status:             e.Status,
+		Bytes:              e.Bytes,
+		DurationMs:         milliseconds(e.Duration),
+		Route:              e.Route,
+		Upstream:           e.Upstream,
+		UpstreamDurationMs: milliseconds(e.UpstreamDuration),
+		Decision:           e.Decision,
+		DenyReason:         e.DenyReason,
+		UserAgent:          e.UserAgent,
+		Referer:            e.Referer,
+	})
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+}
+
+// commonFormatter NCSA Common / Combined Log Format
+type commonFormatter struct {
+	combined bool
+}
+
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+func (f commonFormatter) Format(e *Entry) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, `%s - %s [%s] "%s %s %s" %d %s`,
+		dash(e.ClientIP), dash(e.User), e.Time.Format(clfTimeLayout),
+		e.Method, requestURI(e), e.Proto, e.Status, clfBytes(e.Bytes))
+	if f.combined {
+		fmt.Fprintf(&b, ` "%s" "%s"`, dash(quoteEscape(e.Referer)), dash(quoteEscape(e.UserAgent)))
+	}
+	return []byte(b.String())
+}
+
+// templateFormatter подставляет поля записи в шаблон вида "{method} {path} {status}"
+type templateFormatter struct {
+	parts []func(e *Entry) string
+}
+
+var templateFields = map[string]func(e *Entry) string{
+	"time":                 func(e *Entry) string { return e.Time.Format(time.RFC3339) },
+	"time_clf":             func(e *Entry) string { return e.Time.Format(clfTimeLayout) },
+	"request_id":           func(e *Entry) string { return dash(e.RequestID) },
+	"client_ip":            func(e *Entry) string { return dash(e.ClientIP) },
+	"user":                 func(e *Entry) string { return dash(e.User) },
+	"method":               func(e *Entry) string { return e.Method },
+	"path":                 func(e *Entry) string { return e.Path },
+	"query":                func(e *Entry) string { return e.Query },
+	"uri":                  requestURI,
+	"proto":                func(e *Entry) string { return e.Proto },
+	"status":               func(e *Entry) string { return strconv.Itoa(e.Status) },
+	"bytes":                func(e *Entry) string { return strconv.FormatInt(e.Bytes, 10) },
+	"duration_ms":          func(e *Entry) string { return formatMs(e.Duration) },
+	"route":                func(e *Entry) string { return dash(e.Route) },
+	"upstream":             func(e *Entry) string { return dash(e.Upstream) },
+	"upstream_duration_ms": func(e *Entry) string { return formatMs(e.UpstreamDuration) },
+	"decision":             func(e *Entry) string { return e.Decision },
+	"deny_reason":          func(e *Entry) string { return dash(e.DenyReason) },
+	"user_agent":           func(e *Entry) string { return dash(e.UserAgent) },
+	"referer":              func(e *Entry) string { return dash(e.Referer) },
+}
+
+func newTemplateFormatter(tmpl string) (*templateFormatter, error) {
+	if tmpl == "" {
+		return nil, fmt.Errorf("access log template is empty")
+	}
+
+	f := &templateFormatter{}
+	for rest := tmpl; rest != ""; {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			f.parts = append(f.parts, literal(rest))
+			break
+		}
+		if open > 0 {
+			f.parts = append(f.parts, literal(rest[:open]))
+		}
+
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed '{' at position %d in access log template", len(tmpl)-len(rest)+open)
+		}
+
+		name := rest[open+1 : open+end]
+		field, ok := templateFields[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown field {%s} in access log template", name)
+		}
+		f.parts = append(f.parts, field)
+		rest = rest[open+end+1:]
+	}
+	return f, nil
+}
+
+func (f *templateFormatter) Format(e *Entry) []byte {
+	var b strings.Builder
+	for _, part := range f.parts {
+		b.WriteString(part(e))
+	}
+	return []byte(b.String())
+}
+
+func literal(s string) func(*Entry) string {
+	return func(*Entry) string { return s }
+}
+
+func requestURI(e *Entry) string {
+	if e.Query == "" {
+		return e.Path
+	}
+	return e.Path + "?" + e.Query
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func clfBytes(n int64) string {
+	if n == 0 {
+		return "-"
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func quoteEscape(s string) string {
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func formatMs(d time.Duration) string {
+	return strconv.FormatFloat(milliseconds(d), 'f', 3, 64)
+}
@@ -0,0 +1,90 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testEntry() *Entry {
+	return &Entry{
+		Time:             time.Date(2026, 10, 19, 12, 30, 45, 0, time.FixedZone("MSK", 3*3600)),
+		RequestID:        "req-1",
+		ClientIP:         "10.0.0.1",
+		User:             "alice",
+		Method:           "GET",
+		Path:             "/api/orders",
+		Query:            "page=2",
+		Proto:            "HTTP/1.1",
+		Status:           200,
+		Bytes:            512,
+		Duration:         12345 * time.Microsecond,
+		Route:            "api",
+		Upstream:         "http://app:8080",
+		UpstreamDuration: 10 * time.Millisecond,
+		Decision:         "allow",
+		UserAgent:        `curl/8 "test"`,
+		Referer:          "https://example.com/",
+	}
+}
+
+func TestCommonAndCombinedFormats(t *testing.T) {
+	e := testEntry()
+	common, _ := NewFormatter(FormatCommon, "")
+	want := `10.0.0.1 - alice [19/Oct/2026:12:30:45 +0300] "GET /api/orders?page=2 HTTP/1.1" 200 512`
+	if got := string(common.Format(e)); got != want {
+		t.Errorf("common:\n got %s\nwant %s", got, want)
+	}
+
+	combined, _ := NewFormatter(FormatCombined, "")
+	want += ` "https://example.com/" "curl/8 \"test\""`
+	if got := string(combined.Format(e)); got != want {
+		t.Errorf("combined:\n got %s\nwant %s", got, want)
+	}
+
+	e.User, e.Bytes = "", 0
+	if got := string(common.Format(e)); !strings.Contains(got, "10.0.0.1 - - [") || !strings.HasSuffix(got, " 200 -") {
+		t.Errorf("empty fields not dashed: %s", got)
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	f, _ := NewFormatter("", "")
+	line := f.Format(testEntry())
+	if strings.Contains(string(line), "\n") {
+		t.Fatal("json line contains newline")
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(line, &got); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if got["duration_ms"] != 12.345 || got["status"] != 200.0 || got["route"] != "api" {
+		t.Errorf("unexpected fields: %v", got)
+	}
+	if _, ok := got["deny_reason"]; ok {
+		t.Error("empty deny_reason should be omitted")
+	}
+}
+
+func TestTemplateFormat(t *testing.T) {
+	f, err := NewFormatter(FormatTemplate, "{method} {uri} -> {status} in {duration_ms}ms [{deny_reason}]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "GET /api/orders?page=2 -> 200 in 12.345ms [-]"
+	if got := string(f.Format(testEntry())); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	for _, tmpl := range []string{"", "{method", "{nope}"} {
+		if _, err := NewFormatter(FormatTemplate, tmpl); err == nil {
+			t.Errorf("template %q: expected error", tmpl)
+		}
+	}
+	if _, err := NewFormatter("xml", ""); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
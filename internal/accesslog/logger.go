@@ -0,0 +1,58 @@
+// internal/accesslog/logger.go
+package accesslog
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Options настройки журнала доступа
+type Options struct {
+	Format         string
+	Template       string
+	Output         string
+	MaxSizeMB      int
+	MaxBackups     int
+	RotateInterval time.Duration
+}
+
+// Logger пишет по одной записи на запрос
+type Logger struct {
+	mu        sync.Mutex
+	formatter Formatter
+	out       io.Writer
+}
+
+// New создаёт журнал доступа. Output "stdout", "stderr" или путь к файлу
+func New(opts Options) (*Logger, error) {
+	formatter, err := NewFormatter(opts.Format, opts.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	var out io.Writer
+	switch opts.Output {
+	case "", "stdout":
+		out = os.Stdout
+	case "stderr":
+		out = os.Stderr
+	default:
+		out, err = NewRotatingFile(opts.Output, int64(opts.MaxSizeMB)*1024*1024, opts.RotateInterval, opts.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Logger{formatter: formatter, out: out}, nil
+}
+
+// Log форматирует и пишет запись
+func (l *Logger) Log(e *Entry) {
+	line := append(l.formatter.Format(e), '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(line)
+}
@@ -0,0 +1,178 @@
+// internal/accesslog/rotate.go
+package accesslog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeLayout время в имени старого файла; при совпадении имени добавляется
+// номер: access.log.20060102-150405.000-1
+const backupTimeLayout = "20060102-150405.000"
+
+// RotatingFile файл журнала с ротацией по размеру и по времени
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// NewRotatingFile открывает файл журнала. maxSize и interval равные нулю отключают
+// соответствующий вид ротации, maxBackups равный нулю хранит все старые файлы
+func NewRotatingFile(path string, maxSize int64, interval time.Duration, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		interval:   interval,
+		maxBackups: maxBackups,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.shouldRotate(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+func (f *RotatingFile) shouldRotate(next int64) bool {
+	if f.maxSize > 0 && f.size > 0 && f.size+next > f.maxSize {
+		return true
+	}
+	return f.interval > 0 && time.Since(f.openedAt) >= f.interval
+}
+
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return fmt.Errorf("create access log dir: %w", err)
+	}
+
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open access log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("stat access log: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.openedAt = time.Now()
+	return nil
+}
+
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("close access log: %w", err)
+	}
+
+	backup, err := f.backupName(time.Now())
+	if err != nil {
+		return fmt.Errorf("rotate access log: %w", err)
+	}
+	if err := os.Rename(f.path, backup); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("rotate access log: %w", err)
+	}
+
+	f.removeOldBackups()
+	return f.open()
+}
+
+// backupName свободное имя для старого файла: две ротации в одну миллисекунду
+// не должны перезаписать друг друга
+func (f *RotatingFile) backupName(now time.Time) (string, error) {
+	base := f.path + "." + now.Format(backupTimeLayout)
+	name := base
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(name); os.IsNotExist(err) {
+			return name, nil
+		} else if err != nil {
+			return "", err
+		}
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// backup старый файл журнала: время ротации и номер при совпадении имён
+type backup struct {
+	name string
+	at   time.Time
+	n    int
+}
+
+// parseBackup разбирает суффикс имени: время ротации, возможно с номером
+func parseBackup(suffix string) (time.Time, int, bool) {
+	n := 0
+	if len(suffix) > len(backupTimeLayout) && suffix[len(backupTimeLayout)] == '-' {
+		var err error
+		if n, err = strconv.Atoi(suffix[len(backupTimeLayout)+1:]); err != nil || n < 1 {
+			return time.Time{}, 0, false
+		}
+		suffix = suffix[:len(backupTimeLayout)]
+	}
+	at, err := time.Parse(backupTimeLayout, suffix)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	return at, n, true
+}
+
+func (f *RotatingFile) removeOldBackups() {
+	if f.maxBackups <= 0 {
+		return
+	}
+
+	names, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return
+	}
+	var backups []backup
+	for _, name := range names {
+		if at, n, ok := parseBackup(strings.TrimPrefix(name, f.path+".")); ok {
+			backups = append(backups, backup{name, at, n})
+		}
+	}
+	// По имени сортировать нельзя: номер "-10" оказался бы раньше "-2"
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].at.Equal(backups[j].at) {
+			return backups[i].at.Before(backups[j].at)
+		}
+		return backups[i].n < backups[j].n
+	})
+	for len(backups) > f.maxBackups {
+		os.Remove(backups[0].name)
+		backups = backups[1:]
+	}
+}
@@ -0,0 +1,128 @@
+package accesslog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func backups(t *testing.T, path string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestRotateBySizeKeepsEveryBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := NewRotatingFile(path, 10, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// Несколько ротаций подряд укладываются в одну миллисекунду: ни одна
+	// старая запись не должна потеряться
+	lines := []string{"line-0001\n", "line-0002\n", "line-0003\n", "line-0004\n"}
+	for _, l := range lines {
+		if _, err := f.Write([]byte(l)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var all strings.Builder
+	for _, b := range append(backups(t, path), path) {
+		data, err := os.ReadFile(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all.Write(data)
+	}
+	for _, l := range lines {
+		if !strings.Contains(all.String(), l) {
+			t.Errorf("lost %q after rotation", strings.TrimSpace(l))
+		}
+	}
+	if n := len(backups(t, path)); n != 3 {
+		t.Errorf("got %d backups, want 3", n)
+	}
+}
+
+func TestRotateRemovesOldBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	// Давний старый файл и посторонний файл
+	os.WriteFile(path+".20200101-000000.000", []byte("old\n"), 0o644)
+	os.WriteFile(path+".keep", []byte("other\n"), 0o644)
+
+	f, err := NewRotatingFile(path, 5, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for i := 0; i < 5; i++ {
+		f.Write([]byte("abcd\n"))
+	}
+
+	got := backups(t, path)
+	var rotated []string
+	for _, b := range got {
+		if _, _, ok := parseBackup(strings.TrimPrefix(b, path+".")); ok {
+			rotated = append(rotated, b)
+		}
+	}
+	if len(rotated) != 2 {
+		t.Errorf("got backups %v, want 2", rotated)
+	}
+	if _, err := os.Stat(path + ".20200101-000000.000"); !os.IsNotExist(err) {
+		t.Error("oldest backup was not removed")
+	}
+	if _, err := os.Stat(path + ".keep"); err != nil {
+		t.Error("unrelated file was removed")
+	}
+}
+
+func TestRemoveOldBackupsOrdersNumberedNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	// Одиннадцать ротаций в одну миллисекунду: "-10" новее "-2", хотя раньше по алфавиту
+	base := path + ".20200101-000000.000"
+	for _, name := range []string{base, base + "-2", base + "-10"} {
+		os.WriteFile(name, []byte("old\n"), 0o644)
+	}
+
+	f, err := NewRotatingFile(path, 0, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.removeOldBackups()
+
+	for name, kept := range map[string]bool{base: false, base + "-2": true, base + "-10": true} {
+		if _, err := os.Stat(name); (err == nil) != kept {
+			t.Errorf("%s: kept = %v, want %v", filepath.Base(name), err == nil, kept)
+		}
+	}
+}
+
+func TestParseBackup(t *testing.T) {
+	for suffix, want := range map[string]int{
+		"20261019-123045.123":    0,
+		"20261019-123045.123-2":  2,
+		"20261019-123045.123-10": 10,
+		"20261019-123045":        -1,
+		"keep":                   -1,
+		"20261019-123045.123-":   -1,
+		"20261019-123045.123-0":  -1,
+	} {
+		_, n, ok := parseBackup(suffix)
+		if !ok {
+			n = -1
+		}
+		if n != want {
+			t.Errorf("parseBackup(%q) = %d, want %d", suffix, n, want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// internal/auth/forward.go
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxForwardAuthBody ограничивает тело отказа, которое возвращается клиенту
+const maxForwardAuthBody = 64 * 1024
+
+// ForwardAuthOptions параметры внешнего сервиса авторизации
+type ForwardAuthOptions struct {
+	URL             string
+	RequestHeaders  []string // заголовки клиента, передаваемые в подзапрос
+	ResponseHeaders []string // заголовки ответа сервиса, копируемые в запрос к upstream
+	Timeout         time.Duration
+	CacheTTL        time.Duration // 0 — без кеша
+	CacheSize       int
+}
+
+// Decision ответ сервиса авторизации
+type Decision struct {
+	Allowed bool
+	Status  int
+	Header  http.Header // при разрешении — копируемые заголовки, при отказе — заголовки ответа
+	Body    []byte
+}
+
+type cachedDecision struct {
+	decision *Decision
+	expires  time.Time
+}
+
+// ForwardAuth отправляет подзапрос во внешний сервис авторизации
+// и кеширует решения на короткое время
+type ForwardAuth struct {
+	opts   ForwardAuthOptions
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cachedDecision
+}
+
+func NewForwardAuth(opts ForwardAuthOptions) *ForwardAuth {
+	return &ForwardAuth{
+		opts: opts,
+		client: &http.Client{
+			Timeout: opts.Timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		cache: make(map[string]cachedDecision),
+	}
+}
+
+// Check спрашивает сервис авторизации о запросе. 2xx — разрешение, 401/403 — отказ,
+// остальные статусы и сетевые ошибки возвращаются как ошибка
+func (f *ForwardAuth) Check(r *http.Request) (*Decision, error) {
+	key := f.cacheKey(r)
+	if d, ok := f.cached(key); ok {
+		return d, nil
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, f.opts.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range f.opts.RequestHeaders {
+		if values := r.Header.Values(name); len(values) > 0 {
+			req.Header[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	req.Header.Set("X-Forwarded-Method", r.Method)
+	req.Header.Set("X-Forwarded-Uri", r.URL.RequestURI())
+	req.Header.Set("X-Forwarded-Host", r.Host)
+	if r.TLS != nil {
+		req.Header.Set("X-Forwarded-Proto", "https")
+	} else {
+		req.Header.Set("X-Forwarded-Proto", "http")
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var d *Decision
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		d = &Decision{Allowed: true, Status: resp.StatusCode, Header: make(http.Header)}
+		for _, name := range f.opts.ResponseHeaders {
+			if values := resp.Header.Values(name); len(values) > 0 {
+				d.Header[http.CanonicalHeaderKey(name)] = values
+			}
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxForwardAuthBody))
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxForwardAuthBody))
+		if err != nil {
+			return nil, err
+		}
+		d = &Decision{Status: resp.StatusCode, Header: resp.Header.Clone(), Body: body}
+	default:
+		return nil, fmt.Errorf("auth service returned status %d", resp.StatusCode)
+	}
+
+	f.store(key, d)
+	return d, nil
+}
+
+// cacheKey решение зависит только от метода, URI и передаваемых заголовков
+func (f *ForwardAuth) cacheKey(r *http.Request) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00", r.Method, r.Host, r.URL.RequestURI())
+	for _, name := range f.opts.RequestHeaders {
+		for _, v := range r.Header.Values(name) {
+			fmt.Fprintf(h, "%s:%s\x00", name, v)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (f *ForwardAuth) cached(key string) (*Decision, bool) {
+	if f.opts.CacheTTL <= 0 {
+		return nil, false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.cache[key]
+	if !ok || time.Now().After(c.expires) {
+		return nil, false
+	}
+	return c.decision, true
+}
+
+func (f *ForwardAuth) store(key string, d *Decision) {
+	if f.opts.CacheTTL <= 0 {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	if len(f.cache) >= f.opts.CacheSize {
+		for k, c := range f.cache {
+			if now.After(c.expires) {
+				delete(f.cache, k)
+			}
+		}
+		if len(f.cache) >= f.opts.CacheSize {
+			return
+		}
+	}
+	f.cache[key] = cachedDecision{decision: d, expires: now.Add(f.opts.CacheTTL)}
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestForwardAuthCheck(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch r.Header.Get("Authorization") {
+		case "Bearer good":
+			if r.Header.Get("X-Forwarded-Uri") != "/orders?id=1" || r.Header.Get("X-Forwarded-Method") != http.MethodPost {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("X-User-Id", "alice")
+			w.Header().Set("X-Internal", "secret")
+		case "Bearer broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("login first"))
+		}
+	}))
+	defer srv.Close()
+
+	f := NewForwardAuth(ForwardAuthOptions{
+		URL:             srv.URL,
+		RequestHeaders:  []string{"Authorization"},
+		ResponseHeaders: []string{"X-User-ID"},
+		Timeout:         time.Second,
+		CacheTTL:        time.Minute,
+		CacheSize:       10,
+	})
+
+	request := func(token string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/orders?id=1", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		return r
+	}
+
+	d, err := f.Check(request("good"))
+	if err != nil || !d.Allowed || d.Header.Get("X-User-Id") != "alice" || d.Header.Get("X-Internal") != "" {
+		t.Fatalf("allowed decision = %+v, %v", d, err)
+	}
+	if d, _ := f.Check(request("good")); !d.Allowed || calls.Load() != 1 {
+		t.Errorf("decision not cached, calls = %d", calls.Load())
+	}
+
+	d, err = f.Check(request("bad"))
+	if err != nil || d.Allowed || d.Status != http.StatusUnauthorized || string(d.Body) != "login first" || d.Header.Get("WWW-Authenticate") != "Bearer" {
+		t.Errorf("denied decision = %+v, %v", d, err)
+	}
+
+	if _, err := f.Check(request("broken")); err == nil {
+		t.Error("5xx from auth service treated as a decision")
+	}
+}
+
+func TestForwardAuthCacheKeyAndSize(t *testing.T) {
+	f := NewForwardAuth(ForwardAuthOptions{RequestHeaders: []string{"Authorization"}, CacheTTL: time.Minute, CacheSize: 1})
+
+	a := httptest.NewRequest(http.MethodGet, "/x", nil)
+	a.Header.Set("Authorization", "one")
+	b := httptest.NewRequest(http.MethodGet, "/x", nil)
+	b.Header.Set("Authorization", "two")
+	if f.cacheKey(a) == f.cacheKey(b) {
+		t.Error("different credentials share a cache key")
+	}
+
+	f.store(f.cacheKey(a), &Decision{Allowed: true})
+	f.store(f.cacheKey(b), &Decision{Allowed: true})
+	if _, ok := f.cached(f.cacheKey(b)); ok {
+		t.Error("cache grew beyond its size")
+	}
+	if _, ok := f.cached(f.cacheKey(a)); !ok {
+		t.Error("cached decision lost")
+	}
+}
@@ -0,0 +1,164 @@
+// internal/auth/hmac.go
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HMACOptions параметры проверки подписей
+type HMACOptions struct {
+	Secrets       map[string]string // ID клиента -> общий секрет
+	SignedHeaders []string          // заголовки, входящие в подпись, по порядку
+	MaxSkew       time.Duration     // допустимое расхождение timestamp с часами прокси
+	NonceCacheMax int               // на одного клиента
+}
+
+// SignedRequest значения подписи из запроса
+type SignedRequest struct {
+	ClientID  string
+	Timestamp string // unix секунды
+	Nonce     string
+	Signature string // hex или base64 HMAC-SHA256
+}
+
+// HMACVerifier проверяет подписи HMAC-SHA256 и отклоняет повторы по nonce
+type HMACVerifier struct {
+	opts HMACOptions
+
+	// У каждого клиента свой кеш nonce: клиент, исчерпавший лимит,
+	// не мешает остальным
+	mu     sync.Mutex
+	nonces map[string]map[string]time.Time // клиент -> nonce -> когда можно забыть
+}
+
+func NewHMACVerifier(opts HMACOptions) *HMACVerifier {
+	return &HMACVerifier{opts: opts, nonces: make(map[string]map[string]time.Time, len(opts.Secrets))}
+}
+
+// Verify проверяет подпись запроса с телом body. Текст ошибки можно отдавать клиенту
+func (v *HMACVerifier) Verify(r *http.Request, sr SignedRequest, body []byte) error {
+	if sr.ClientID == "" || sr.Timestamp == "" || sr.Nonce == "" || sr.Signature == "" {
+		return errors.New("missing signature headers")
+	}
+	secret, ok := v.opts.Secrets[sr.ClientID]
+	if !ok {
+		return errors.New("unknown client")
+	}
+
+	ts, err := strconv.ParseInt(sr.Timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+	now := time.Now()
+	if skew := now.Sub(time.Unix(ts, 0)); skew > v.opts.MaxSkew || skew < -v.opts.MaxSkew {
+		return errors.New("timestamp outside the allowed window")
+	}
+
+	got, err := decodeSignature(sr.Signature)
+	if err != nil {
+		return errors.New("malformed signature")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(CanonicalRequest(r, v.opts.SignedHeaders, sr.Timestamp, sr.Nonce, body)))
+	if !hmac.Equal(mac.Sum(nil), got) {
+		return errors.New("invalid signature")
+	}
+
+	// Nonce запоминается только после проверки подписи, чтобы чужие запросы не засоряли кеш
+	return v.useNonce(sr.ClientID, sr.Nonce, now)
+}
+
+// useNonce запоминает nonce на время окна. Запрос с временем вне окна
+// уже отклонён, поэтому дольше хранить не нужно
+func (v *HMACVerifier) useNonce(clientID, nonce string, now time.Time) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	nonces := v.nonces[clientID]
+	if nonces == nil {
+		nonces = make(map[string]time.Time)
+		v.nonces[clientID] = nonces
+	}
+
+	if expires, seen := nonces[nonce]; seen && now.Before(expires) {
+		return errors.New("nonce already used")
+	}
+	if len(nonces) >= v.opts.NonceCacheMax {
+		for k, expires := range nonces {
+			if !now.Before(expires) {
+				delete(nonces, k)
+			}
+		}
+		if len(nonces) >= v.opts.NonceCacheMax {
+			return errors.New("too many signed requests, retry later")
+		}
+	}
+	nonces[nonce] = now.Add(2 * v.opts.MaxSkew)
+	return nil
+}
+
+// CanonicalRequest строка, которая подписывается клиентом:
+//
+//	METHOD
+//	/path
+//	a=1&b=2            (параметры отсортированы по имени и значению)
+//	content-type:application/json
+//	...                (заголовки из SignedHeaders в заданном порядке)
+//	timestamp
+//	nonce
+//	hex(sha256(body))
+func CanonicalRequest(r *http.Request, signedHeaders []string, timestamp, nonce string, body []byte) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteByte('\n')
+	b.WriteString(r.URL.EscapedPath())
+	b.WriteByte('\n')
+	b.WriteString(canonicalQuery(r.URL.Query()))
+	b.WriteByte('\n')
+	for _, name := range signedHeaders {
+		value := r.Header.Get(name)
+		if strings.EqualFold(name, "Host") {
+			value = r.Host
+		}
+		b.WriteString(strings.ToLower(name))
+		b.WriteByte(':')
+		b.WriteString(strings.TrimSpace(value))
+		b.WriteByte('\n')
+	}
+	b.WriteString(timestamp)
+	b.WriteByte('\n')
+	b.WriteString(nonce)
+	b.WriteByte('\n')
+	sum := sha256.Sum256(body)
+	b.WriteString(hex.EncodeToString(sum[:]))
+	return b.String()
+}
+
+func canonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func decodeSignature(s string) ([]byte, error) {
+	if b, err := hex.DecodeString(s); err == nil && len(b) == sha256.Size {
+		return b, nil
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCanonicalRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "http://api.example.com/v1/a%2Fb?b=2&a=z&a=1&c=x+y", nil)
+	r.Header.Set("Content-Type", " application/json ")
+
+	got := CanonicalRequest(r, []string{"Content-Type", "Host", "X-Missing"}, "1700000000", "n-1", []byte(`{"x":1}`))
+	sum := sha256.Sum256([]byte(`{"x":1}`))
+	want := strings.Join([]string{
+		"POST",
+		"/v1/a%2Fb",
+		"a=1&a=z&b=2&c=x+y",
+		"content-type:application/json",
+		"host:api.example.com",
+		"x-missing:",
+		"1700000000",
+		"n-1",
+		hex.EncodeToString(sum[:]),
+	}, "\n")
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Порядок параметров в запросе на подпись не влияет
+	reordered := httptest.NewRequest(http.MethodPost, "http://api.example.com/v1/a%2Fb?c=x%20y&a=1&b=2&a=z", nil)
+	reordered.Header.Set("Content-Type", "application/json")
+	if CanonicalRequest(reordered, []string{"Content-Type", "Host", "X-Missing"}, "1700000000", "n-1", []byte(`{"x":1}`)) != want {
+		t.Error("canonical form depends on query order")
+	}
+}
+
+func sign(secret string, r *http.Request, ts, nonce string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(CanonicalRequest(r, []string{"Host"}, ts, nonce, body)))
+	return mac.Sum(nil)
+}
+
+func newTestHMAC(max int) *HMACVerifier {
+	return NewHMACVerifier(HMACOptions{
+		Secrets:       map[string]string{"partner": "s3cret", "other": "0ther"},
+		SignedHeaders: []string{"Host"},
+		MaxSkew:       time.Minute,
+		NonceCacheMax: max,
+	})
+}
+
+func TestHMACVerify(t *testing.T) {
+	v := newTestHMAC(100)
+	body := []byte("payload")
+	r := httptest.NewRequest(http.MethodPost, "/hook?x=1", nil)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name string
+		sr   SignedRequest
+		ok   bool
+	}{
+		{"hex", SignedRequest{"partner", now, "n1", hex.EncodeToString(sign("s3cret", r, now, "n1", body))}, true},
+		{"base64", SignedRequest{"partner", now, "n2", base64.StdEncoding.EncodeToString(sign("s3cret", r, now, "n2", body))}, true},
+		{"replay", SignedRequest{"partner", now, "n1", hex.EncodeToString(sign("s3cret", r, now, "n1", body))}, false},
+		{"same nonce other client", SignedRequest{"other", now, "n1", hex.EncodeToString(sign("0ther", r, now, "n1", body))}, true},
+		{"wrong secret", SignedRequest{"partner", now, "n3", hex.EncodeToString(sign("other", r, now, "n3", body))}, false},
+		{"unknown client", SignedRequest{"nobody", now, "n4", hex.EncodeToString(sign("s3cret", r, now, "n4", body))}, false},
+		{"stale timestamp", SignedRequest{"partner", old, "n5", hex.EncodeToString(sign("s3cret", r, old, "n5", body))}, false},
+		{"bad timestamp", SignedRequest{"partner", "soon", "n6", "00"}, false},
+		{"missing nonce", SignedRequest{"partner", now, "", "00"}, false},
+		{"malformed signature", SignedRequest{"partner", now, "n7", "!!"}, false},
+	}
+	for _, tt := range tests {
+		if err := v.Verify(r, tt.sr, body); (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+	}
+
+	// Подпись покрывает тело
+	sr := SignedRequest{"partner", now, "n8", hex.EncodeToString(sign("s3cret", r, now, "n8", body))}
+	if err := v.Verify(r, sr, []byte("tampered")); err == nil {
+		t.Error("tampered body accepted")
+	}
+}
+
+func TestNonceCacheIsPerClient(t *testing.T) {
+	v := newTestHMAC(2)
+	now := time.Now()
+
+	for _, nonce := range []string{"a", "b"} {
+		if err := v.useNonce("partner", nonce, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := v.useNonce("partner", "c", now); err == nil {
+		t.Error("client exceeded its nonce cache")
+	}
+	if err := v.useNonce("other", "a", now); err != nil {
+		t.Errorf("full cache of one client blocked another: %v", err)
+	}
+
+	// После окна старые nonce освобождают место
+	if err := v.useNonce("partner", "c", now.Add(3*time.Minute)); err != nil {
+		t.Errorf("expired nonces not evicted: %v", err)
+	}
+}
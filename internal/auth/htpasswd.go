@@ -0,0 +1,130 @@
+// internal/auth/htpasswd.go
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Htpasswd пользователи из файла в формате Apache htpasswd.
+// Поддерживаются хеши bcrypt ($2y$, $2a$, $2b$) и {SHA}
+type Htpasswd struct {
+	path string
+	log  logger.Logger
+
+	mu      sync.RWMutex
+	users   map[string]string
+	modTime time.Time
+
+	// verified кеширует успешные проверки bcrypt: хеш sha256 от пароля по пользователю.
+	// Сбрасывается при перечитывании файла
+	verified sync.Map
+}
+
+func LoadHtpasswd(path string, log logger.Logger) (*Htpasswd, error) {
+	h := &Htpasswd{path: path, log: log}
+	if err := h.reload(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Watch перечитывает файл при изменении
+func (h *Htpasswd) Watch(interval time.Duration) {
+	watchFile(h.path, interval, h.loadedAt, h.reload, h.log, "htpasswd")
+}
+
+// Len число пользователей
+func (h *Htpasswd) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.users)
+}
+
+// Verify проверяет имя пользователя и пароль
+func (h *Htpasswd) Verify(user, password string) bool {
+	h.mu.RLock()
+	hash, ok := h.users[user]
+	h.mu.RUnlock()
+	if !ok {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		expected := base64.StdEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(hash[len("{SHA}"):]), []byte(expected)) == 1
+	case isBcrypt(hash):
+		sum := sha256.Sum256([]byte(hash + "\x00" + password))
+		if cached, ok := h.verified.Load(user); ok && bytes.Equal(cached.([]byte), sum[:]) {
+			return true
+		}
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+			return false
+		}
+		h.verified.Store(user, sum[:])
+		return true
+	}
+	return false
+}
+
+func (h *Htpasswd) loadedAt() time.Time {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.modTime
+}
+
+func (h *Htpasswd) reload() error {
+	stat, err := os.Stat(h.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		return err
+	}
+
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		user, hash, ok := strings.Cut(text, ":")
+		if !ok || user == "" {
+			return fmt.Errorf("%s:%d: expected user:hash", h.path, line)
+		}
+		if !strings.HasPrefix(hash, "{SHA}") && !isBcrypt(hash) {
+			return fmt.Errorf("%s:%d: unsupported hash for user %s, use bcrypt or {SHA}", h.path, line, user)
+		}
+		users[user] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	h.users = users
+	h.modTime = stat.ModTime()
+	h.mu.Unlock()
+	h.verified.Clear()
+	return nil
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2y$") || strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$")
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"os"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func shaHash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestHtpasswdVerify(t *testing.T) {
+	bcryptHash, _ := bcrypt.GenerateFromPassword([]byte("b-pass"), bcrypt.MinCost)
+	path := writeTestFile(t, ".htpasswd", "# users\n\nalice:"+string(bcryptHash)+"\nbob:"+shaHash("s-pass")+"\n")
+	h, err := LoadHtpasswd(path, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Len() != 2 {
+		t.Errorf("Len() = %d", h.Len())
+	}
+
+	tests := []struct {
+		user, password string
+		want           bool
+	}{
+		{"alice", "b-pass", true},
+		{"alice", "b-pass", true}, // из кеша
+		{"alice", "wrong", false},
+		{"bob", "s-pass", true},
+		{"bob", "b-pass", false},
+		{"carol", "b-pass", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got := h.Verify(tt.user, tt.password); got != tt.want {
+			t.Errorf("Verify(%q, %q) = %v, want %v", tt.user, tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestHtpasswdReloadInvalidatesCache(t *testing.T) {
+	oldHash, _ := bcrypt.GenerateFromPassword([]byte("old"), bcrypt.MinCost)
+	path := writeTestFile(t, ".htpasswd", "alice:"+string(oldHash)+"\n")
+	h, err := LoadHtpasswd(path, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.Verify("alice", "old") {
+		t.Fatal("old password rejected")
+	}
+
+	newHash, _ := bcrypt.GenerateFromPassword([]byte("new"), bcrypt.MinCost)
+	os.WriteFile(path, []byte("alice:"+string(newHash)+"\n"), 0o600)
+	if err := h.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if h.Verify("alice", "old") {
+		t.Error("old password accepted after password change")
+	}
+	if !h.Verify("alice", "new") {
+		t.Error("new password rejected")
+	}
+}
+
+func TestHtpasswdRejectsInvalidFiles(t *testing.T) {
+	for name, data := range map[string]string{
+		"no colon":   "alice\n",
+		"no user":    ":" + shaHash("x") + "\n",
+		"md5 apr1":   "alice:$apr1$salt$hash\n",
+		"plain text": "alice:password\n",
+	} {
+		if _, err := LoadHtpasswd(writeTestFile(t, ".htpasswd", data), testLogger()); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+// internal/auth/jwks.go
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+// minRefreshInterval не даёт токенам с неизвестным kid заставлять
+// прокси запрашивать JWKS на каждый запрос
+const minRefreshInterval = time.Minute
+
+// jwk ключ в формате JSON Web Key
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// verificationKey ключ проверки подписи: *rsa.PublicKey, *ecdsa.PublicKey или []byte для HMAC
+type verificationKey struct {
+	kid string
+	alg string
+	key interface{}
+}
+
+// JWKS набор ключей из файла или URL. Ключи из URL кешируются на ttl,
+// при ошибке обновления остаются прежние
+type JWKS struct {
+	file   string
+	url    string
+	ttl    time.Duration
+	client *http.Client
+	log    logger.Logger
+
+	// Набор ключей заменяется целиком (copy-on-write), поэтому проверка
+	// токенов читает его без блокировки и не ждёт запроса к JWKS
+	keys atomic.Pointer[keySet]
+
+	mu          sync.Mutex
+	lastAttempt time.Time
+	inflight    chan struct{} // закрывается по завершении текущего обновления
+}
+
+type keySet struct {
+	keys      []verificationKey
+	fetchedAt time.Time
+}
+
+func NewJWKS(file, url string, ttl time.Duration, log logger.Logger) (*JWKS, error) {
+	s := &JWKS{file: file, url: url, ttl: ttl, client: &http.Client{Timeout: 10 * time.Second}, log: log}
+	s.keys.Store(&keySet{})
+	if file == "" && url == "" {
+		return s, nil
+	}
+	s.lastAttempt = time.Now()
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// lookup возвращает ключи с подходящим kid (или все, если в токене его нет).
+// Для URL устаревший кеш и неизвестный kid приводят к обновлению набора
+func (s *JWKS) lookup(kid string) []verificationKey {
+	set := s.keys.Load()
+	if s.url != "" {
+		known := hasKid(set.keys, kid)
+		if expired := s.ttl > 0 && time.Since(set.fetchedAt) > s.ttl; expired || !known {
+			// С известным kid подходят и старые ключи: ждать обновления,
+			// начатого другим запросом, незачем
+			set = s.refreshShared(!known)
+		}
+	}
+
+	var keys []verificationKey
+	for _, k := range set.keys {
+		if kid == "" || k.kid == kid {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// refreshShared обновляет набор не чаще minRefreshInterval. Одновременные
+// вызовы не порождают новых запросов: если wait, они дожидаются текущего
+func (s *JWKS) refreshShared(wait bool) *keySet {
+	s.mu.Lock()
+	if done := s.inflight; done != nil {
+		s.mu.Unlock()
+		if wait {
+			<-done
+		}
+		return s.keys.Load()
+	}
+	if time.Since(s.lastAttempt) <= minRefreshInterval {
+		s.mu.Unlock()
+		return s.keys.Load()
+	}
+	done := make(chan struct{})
+	s.inflight = done
+	s.lastAttempt = time.Now()
+	s.mu.Unlock()
+
+	if err := s.refresh(); err != nil {
+		s.log.Errorf("❌ Failed to refresh JWKS, keeping previous keys: %v", err)
+	}
+
+	s.mu.Lock()
+	s.inflight = nil
+	s.mu.Unlock()
+	close(done)
+	return s.keys.Load()
+}
+
+// refresh загружает и разбирает набор ключей без блокировок
+func (s *JWKS) refresh() error {
+	var data []byte
+	var err error
+	if s.url != "" {
+		data, err = s.fetch()
+	} else {
+		data, err = os.ReadFile(s.file)
+	}
+	if err != nil {
+		return fmt.Errorf("load JWKS: %w", err)
+	}
+
+	// Секрет HMAC, опубликованный по URL, секретом не является
+	keys, skipped, err := parseJWKS(data, s.url == "")
+	for _, e := range skipped {
+		s.log.Warnf("⚠️ Skipping JWKS %v", e)
+	}
+	if err != nil {
+		return err
+	}
+	s.keys.Store(&keySet{keys: keys, fetchedAt: time.Now()})
+	return nil
+}
+
+func (s *JWKS) fetch() ([]byte, error) {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: status %d", s.url, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// parseJWKS разбирает набор ключей. Провайдеры публикуют вместе с ключами подписи
+// ключи шифрования и ключи неподдерживаемых типов: такие ключи пропускаются
+// и возвращаются в skipped, ошибка — только если не осталось ни одного ключа.
+// Симметричные ключи (oct) принимаются, только если allowSecret
+func parseJWKS(data []byte, allowSecret bool) (keys []verificationKey, skipped []error, err error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, nil, fmt.Errorf("parse JWKS: %w", err)
+	}
+
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, alg, err := k.publicKey(allowSecret)
+		if err != nil {
+			skipped = append(skipped, fmt.Errorf("key %q: %w", k.Kid, err))
+			continue
+		}
+		if k.Alg != "" {
+			alg = k.Alg
+		}
+		keys = append(keys, verificationKey{kid: k.Kid, alg: alg, key: key})
+	}
+	if len(keys) == 0 {
+		return nil, skipped, errors.New("JWKS has no usable signing keys")
+	}
+	return keys, skipped, nil
+}
+
+func (k jwk) publicKey(allowSecret bool) (interface{}, string, error) {
+	if k.Alg != "" && k.Alg != AlgRS256 && k.Alg != AlgES256 && k.Alg != AlgHS256 {
+		return nil, "", fmt.Errorf("unsupported algorithm %q", k.Alg)
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, "", err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, "", err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, AlgRS256, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, "", fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, "", err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, "", err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, "", fmt.Errorf("point is not on curve P-256")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, AlgES256, nil
+	case "oct":
+		if !allowSecret {
+			return nil, "", errors.New("symmetric keys are accepted only from a local JWKS file")
+		}
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, "", err
+		}
+		return secret, AlgHS256, nil
+	}
+	return nil, "", fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func hasKid(keys []verificationKey, kid string) bool {
+	if kid == "" {
+		return len(keys) > 0
+	}
+	for _, k := range keys {
+		if k.kid == kid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type jwksServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	body    []byte
+	fetches atomic.Int32
+	delay   chan struct{} // если не nil, ответ ждёт закрытия канала
+}
+
+func newJWKSServer(t *testing.T, body []byte) *jwksServer {
+	t.Helper()
+	s := &jwksServer{body: body}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		s.mu.Lock()
+		body, delay := s.body, s.delay
+		s.mu.Unlock()
+		if delay != nil {
+			<-delay
+		}
+		w.Write(body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) set(body []byte, delay chan struct{}) {
+	s.mu.Lock()
+	s.body, s.delay = body, delay
+	s.mu.Unlock()
+}
+
+func TestJWKSRefreshesOnUnknownKid(t *testing.T) {
+	k1, _ := rsa.GenerateKey(rand.Reader, 2048)
+	k2, _ := rsa.GenerateKey(rand.Reader, 2048)
+	srv := newJWKSServer(t, jwksJSON(rsaJWK("k1", &k1.PublicKey)))
+
+	jwks, err := NewJWKS("", srv.URL, time.Hour, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jwks.lookup("k1")) != 1 || srv.fetches.Load() != 1 {
+		t.Fatalf("known kid should be served from cache, fetches = %d", srv.fetches.Load())
+	}
+
+	// Ротация ключей: новый kid подхватывается, но не чаще minRefreshInterval
+	srv.set(jwksJSON(rsaJWK("k1", &k1.PublicKey), rsaJWK("k2", &k2.PublicKey)), nil)
+	if len(jwks.lookup("k2")) != 0 {
+		t.Error("refresh happened before minRefreshInterval")
+	}
+	jwks.lastAttempt = time.Time{}
+	if len(jwks.lookup("k2")) != 1 {
+		t.Error("new kid not picked up")
+	}
+	if n := srv.fetches.Load(); n != 2 {
+		t.Errorf("fetches = %d, want 2", n)
+	}
+}
+
+func TestJWKSKeepsKeysOnFailedRefresh(t *testing.T) {
+	k1, _ := rsa.GenerateKey(rand.Reader, 2048)
+	srv := newJWKSServer(t, jwksJSON(rsaJWK("k1", &k1.PublicKey)))
+	jwks, err := NewJWKS("", srv.URL, time.Millisecond, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.set([]byte("not json"), nil)
+	jwks.lastAttempt = time.Time{}
+	time.Sleep(2 * time.Millisecond)
+	if len(jwks.lookup("k1")) != 1 {
+		t.Error("keys lost after failed refresh")
+	}
+}
+
+func TestJWKSConcurrentRefreshIsShared(t *testing.T) {
+	k1, _ := rsa.GenerateKey(rand.Reader, 2048)
+	k2, _ := rsa.GenerateKey(rand.Reader, 2048)
+	srv := newJWKSServer(t, jwksJSON(rsaJWK("k1", &k1.PublicKey)))
+	jwks, err := NewJWKS("", srv.URL, time.Hour, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release := make(chan struct{})
+	srv.set(jwksJSON(rsaJWK("k1", &k1.PublicKey), rsaJWK("k2", &k2.PublicKey)), release)
+	jwks.lastAttempt = time.Time{}
+
+	var wg sync.WaitGroup
+	found := make(chan int, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			found <- len(jwks.lookup("k2"))
+		}()
+	}
+
+	// Пока JWKS отвечает медленно, токены с известным kid проверяются без ожидания
+	done := make(chan struct{})
+	go func() {
+		jwks.lookup("k1")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("lookup of a known kid blocked on the JWKS fetch")
+	}
+
+	close(release)
+	wg.Wait()
+	close(found)
+	for n := range found {
+		if n != 1 {
+			t.Error("waiting lookup did not get the refreshed keys")
+		}
+	}
+	if n := srv.fetches.Load(); n != 2 {
+		t.Errorf("fetches = %d, want 2 (initial + one shared refresh)", n)
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	for name, data := range map[string]string{
+		"not json":     "{",
+		"no keys":      `{"keys":[]}`,
+		"only bad":     `{"keys":[{"kty":"EC","crv":"P-384","x":"AQ","y":"AQ"},{"kty":"OKP","crv":"Ed25519"}]}`,
+		"only enc":     `{"keys":[{"kty":"RSA","use":"enc","n":"AQ","e":"AQ"}]}`,
+		"empty rsa n":  `{"keys":[{"kty":"RSA","n":"","e":"AQAB"}]}`,
+		"off curve":    `{"keys":[{"kty":"EC","crv":"P-256","x":"AQ","y":"AQ"}]}`,
+		"secret local": `{"keys":[{"kty":"oct","alg":"HS512","k":"c2VjcmV0"}]}`,
+	} {
+		if _, _, err := parseJWKS([]byte(data), true); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	// Набор провайдера: неподдерживаемые ключи пропускаются, остальные работают
+	mixed := `{"keys":[
+		{"kty":"EC","crv":"P-384","kid":"p384","x":"AQ","y":"AQ"},
+		{"kty":"OKP","crv":"Ed25519","kid":"ed","x":"AQ"},
+		{"kty":"RSA","alg":"RSA-OAEP","kid":"oaep","n":"AQ","e":"AQ"},
+		{"kty":"RSA","use":"enc","kid":"enc","n":"AQ","e":"AQ"},
+		{"kty":"RSA","kid":"r1","n":"AQAB","e":"AQAB"},
+		{"kty":"oct","kid":"h","k":"c2VjcmV0"}
+	]}`
+	keys, skipped, err := parseJWKS([]byte(mixed), true)
+	if err != nil || len(keys) != 2 || keys[0].kid != "r1" || keys[1].alg != AlgHS256 || len(skipped) != 3 {
+		t.Errorf("file: keys = %+v, skipped = %v, %v", keys, skipped, err)
+	}
+
+	// Из URL симметричный ключ не принимается
+	keys, skipped, err = parseJWKS([]byte(mixed), false)
+	if err != nil || len(keys) != 1 || keys[0].kid != "r1" || len(skipped) != 4 {
+		t.Errorf("url: keys = %+v, skipped = %v, %v", keys, skipped, err)
+	}
+}
+
+func TestJWKSFromURLRejectsSecrets(t *testing.T) {
+	srv := newJWKSServer(t, []byte(`{"keys":[{"kty":"oct","kid":"h","k":"c2VjcmV0"}]}`))
+	if _, err := NewJWKS("", srv.URL, time.Hour, testLogger()); err == nil {
+		t.Error("symmetric key accepted from URL")
+	}
+}
@@ -0,0 +1,271 @@
+// internal/auth/jwt.go
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Поддерживаемые алгоритмы подписи
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+)
+
+// maxNumericDate ограничивает exp/nbf, чтобы не переполнить time.Unix
+const maxNumericDate = 1 << 40
+
+// Claims полезная нагрузка токена
+type Claims map[string]interface{}
+
+// String значение строкового claim или ""
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Scopes права из claim "scope" (строка через пробел) или "scp" (массив)
+func (c Claims) Scopes() []string {
+	if s := c.String("scope"); s != "" {
+		return strings.Fields(s)
+	}
+	return c.Strings("scp")
+}
+
+// Strings значение claim как список строк: массив или одиночная строка
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// Format значение claim для заголовка upstream
+func (c Claims) Format(name string) string {
+	switch v := c[name].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []interface{}:
+		return strings.Join(c.Strings(name), ",")
+	case json.Number:
+		return v.String()
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// VerifierOptions параметры проверки токенов
+type VerifierOptions struct {
+	Secret     []byte // общий секрет для HS256
+	JWKS       *JWKS
+	Issuer     string
+	Audience   []string
+	Algorithms []string // пусто — все поддерживаемые
+	Leeway     time.Duration
+}
+
+// Verifier проверяет подпись и стандартные claims JWT
+type Verifier struct {
+	opts       VerifierOptions
+	algorithms map[string]bool
+}
+
+func NewVerifier(opts VerifierOptions) (*Verifier, error) {
+	algs := opts.Algorithms
+	if len(algs) == 0 {
+		algs = []string{AlgHS256, AlgRS256, AlgES256}
+	}
+
+	v := &Verifier{opts: opts, algorithms: make(map[string]bool, len(algs))}
+	for _, alg := range algs {
+		switch alg {
+		case AlgHS256, AlgRS256, AlgES256:
+			v.algorithms[alg] = true
+		default:
+			return nil, fmt.Errorf("unsupported JWT algorithm %q", alg)
+		}
+	}
+	if len(opts.Secret) == 0 && opts.JWKS == nil {
+		return nil, errors.New("JWT verification requires a secret or a JWKS")
+	}
+	return v, nil
+}
+
+// Verify проверяет токен и возвращает его claims. Текст ошибки можно отдавать клиенту
+func (v *Verifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, errors.New("malformed token header")
+	}
+	if !v.algorithms[header.Alg] {
+		return nil, fmt.Errorf("algorithm %q is not allowed", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	if !v.verifySignature(header.Alg, header.Kid, []byte(parts[0]+"."+parts[1]), signature) {
+		return nil, errors.New("invalid signature")
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, errors.New("malformed token claims")
+	}
+	if err := v.validateClaims(claims, time.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *Verifier) verifySignature(alg, kid string, signed, signature []byte) bool {
+	if alg == AlgHS256 && len(v.opts.Secret) > 0 && verifyHMAC(v.opts.Secret, signed, signature) {
+		return true
+	}
+	if v.opts.JWKS == nil {
+		return false
+	}
+
+	for _, k := range v.opts.JWKS.lookup(kid) {
+		if k.alg != alg {
+			continue
+		}
+		var ok bool
+		switch key := k.key.(type) {
+		case []byte:
+			ok = verifyHMAC(key, signed, signature)
+		case *rsa.PublicKey:
+			digest := sha256.Sum256(signed)
+			ok = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+		case *ecdsa.PublicKey:
+			ok = verifyES256(key, signed, signature)
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Verifier) validateClaims(claims Claims, now time.Time) error {
+	leeway := v.opts.Leeway
+
+	exp, hasExp, err := numericDate(claims, "exp")
+	if err != nil {
+		return err
+	}
+	if hasExp && now.After(exp.Add(leeway)) {
+		return errors.New("token has expired")
+	}
+
+	nbf, hasNbf, err := numericDate(claims, "nbf")
+	if err != nil {
+		return err
+	}
+	if hasNbf && now.Add(leeway).Before(nbf) {
+		return errors.New("token is not valid yet")
+	}
+
+	if v.opts.Issuer != "" && claims.String("iss") != v.opts.Issuer {
+		return errors.New("invalid issuer")
+	}
+
+	if len(v.opts.Audience) > 0 && !intersects(claims.Strings("aud"), v.opts.Audience) {
+		return errors.New("invalid audience")
+	}
+	return nil
+}
+
+func numericDate(claims Claims, name string) (time.Time, bool, error) {
+	raw, ok := claims[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	n, ok := raw.(json.Number)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("claim %s must be a number", name)
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("claim %s must be a number", name)
+	}
+	// Значения за пределами time.Unix считаются бесконечно далёкими
+	sec := math.Max(math.Min(math.Floor(f), maxNumericDate), -maxNumericDate)
+	var nsec int64
+	if sec == math.Floor(f) {
+		nsec = int64((f - sec) * float64(time.Second))
+	}
+	return time.Unix(int64(sec), nsec), true, nil
+}
+
+func verifyHMAC(secret, signed, signature []byte) bool {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(signed)
+	return hmac.Equal(mac.Sum(nil), signature)
+}
+
+// verifyES256 проверяет подпись JWS: r и s по 32 байта подряд
+func verifyES256(key *ecdsa.PublicKey, signed, signature []byte) bool {
+	if len(signature) != 64 {
+		return false
+	}
+	digest := sha256.Sum256(signed)
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	return ecdsa.Verify(key, digest[:], r, s)
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// signToken собирает JWT; key — []byte, *rsa.PrivateKey или *ecdsa.PrivateKey
+func signToken(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		sig = hmacSHA256(k, []byte(signed))
+	case *rsa.PrivateKey:
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return signed + "." + b64(sig)
+}
+
+func hmacSHA256(secret, data []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{"kty": "RSA", "kid": kid, "n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes())}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) map[string]string {
+	return map[string]string{"kty": "EC", "kid": kid, "crv": "P-256", "x": b64(key.X.Bytes()), "y": b64(key.Y.Bytes())}
+}
+
+func jwksJSON(keys ...map[string]string) []byte {
+	data, _ := json.Marshal(map[string]interface{}{"keys": keys})
+	return data
+}
+
+func TestVerifyAlgorithms(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	jwks, err := NewJWKS(writeTestFile(t, "jwks.json", string(jwksJSON(rsaJWK("r1", &rsaKey.PublicKey), ecJWK("e1", &ecKey.PublicKey)))), "", 0, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewVerifier(VerifierOptions{Secret: []byte("shared"), JWKS: jwks})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := map[string]interface{}{"sub": "alice"}
+	valid := map[string]string{
+		"HS256":             signToken(t, AlgHS256, "", []byte("shared"), claims),
+		"RS256":             signToken(t, AlgRS256, "r1", rsaKey, claims),
+		"ES256":             signToken(t, AlgES256, "e1", ecKey, claims),
+		"RS256 without kid": signToken(t, AlgRS256, "", rsaKey, claims),
+	}
+	for name, token := range valid {
+		if got, err := v.Verify(token); err != nil || got.String("sub") != "alice" {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	otherRSA, _ := rsa.GenerateKey(rand.Reader, 2048)
+	invalid := map[string]string{
+		"wrong secret":     signToken(t, AlgHS256, "", []byte("other"), claims),
+		"wrong rsa key":    signToken(t, AlgRS256, "r1", otherRSA, claims),
+		"alg mismatch":     signToken(t, AlgES256, "r1", ecKey, claims),
+		"alg none":         b64([]byte(`{"alg":"none"}`)) + "." + b64([]byte(`{"sub":"alice"}`)) + ".",
+		"two parts":        "a.b",
+		"bad header":       "!!." + b64([]byte(`{}`)) + ".sig",
+		"tampered payload": strings.Replace(valid["HS256"], ".", "."+b64([]byte(`{"sub":"root"}`))+"x", 1),
+	}
+	for name, token := range invalid {
+		if _, err := v.Verify(token); err == nil {
+			t.Errorf("%s: token accepted", name)
+		}
+	}
+}
+
+func TestValidateClaims(t *testing.T) {
+	v, _ := NewVerifier(VerifierOptions{Secret: []byte("s"), Issuer: "https://idp", Audience: []string{"api"}, Leeway: 30 * time.Second})
+	now := time.Unix(1_700_000_000, 0)
+	num := func(d time.Duration) json.Number { return json.Number(big.NewInt(now.Add(d).Unix()).String()) }
+
+	tests := []struct {
+		name   string
+		claims Claims
+		ok     bool
+	}{
+		{"valid", Claims{"iss": "https://idp", "aud": "api", "exp": num(time.Minute)}, true},
+		{"aud array", Claims{"iss": "https://idp", "aud": []interface{}{"web", "api"}}, true},
+		{"expired within leeway", Claims{"iss": "https://idp", "aud": "api", "exp": num(-10 * time.Second)}, true},
+		{"expired", Claims{"iss": "https://idp", "aud": "api", "exp": num(-time.Minute)}, false},
+		{"not yet valid", Claims{"iss": "https://idp", "aud": "api", "nbf": num(time.Minute)}, false},
+		{"huge exp", Claims{"iss": "https://idp", "aud": "api", "exp": json.Number("1e300")}, true},
+		{"exp not a number", Claims{"iss": "https://idp", "aud": "api", "exp": "tomorrow"}, false},
+		{"wrong issuer", Claims{"iss": "https://evil", "aud": "api"}, false},
+		{"wrong audience", Claims{"iss": "https://idp", "aud": "web"}, false},
+	}
+	for _, tt := range tests {
+		if err := v.validateClaims(tt.claims, now); (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+	}
+}
+
+func TestNewVerifierErrors(t *testing.T) {
+	if _, err := NewVerifier(VerifierOptions{}); err == nil {
+		t.Error("verifier without keys accepted")
+	}
+	if _, err := NewVerifier(VerifierOptions{Secret: []byte("s"), Algorithms: []string{"none"}}); err == nil {
+		t.Error("alg none accepted")
+	}
+}
+
+func TestClaimsHelpers(t *testing.T) {
+	c := Claims{"scope": "read write", "scp": []interface{}{"x"}, "groups": []interface{}{"a", "b"}, "n": json.Number("42"), "obj": map[string]interface{}{"k": true}}
+	if got := c.Scopes(); len(got) != 2 || got[1] != "write" {
+		t.Errorf("Scopes() = %v", got)
+	}
+	for name, want := range map[string]string{"groups": "a,b", "n": "42", "obj": `{"k":true}`, "missing": ""} {
+		if got := c.Format(name); got != want {
+			t.Errorf("Format(%s) = %q, want %q", name, got, want)
+		}
+	}
+}
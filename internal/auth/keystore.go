@@ -0,0 +1,126 @@
+// internal/auth/keystore.go
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+	"gopkg.in/yaml.v3"
+)
+
+// APIKey запись хранилища ключей. Сам ключ не хранится, только его хеш
+type APIKey struct {
+	ID        string    `yaml:"id"`   // principal, попадает в логи и лимиты
+	Hash      string    `yaml:"hash"` // sha256:<hex>
+	Owner     string    `yaml:"owner"`
+	Scopes    []string  `yaml:"scopes"`
+	Tier      string    `yaml:"tier"`
+	ExpiresAt time.Time `yaml:"expires_at"`
+}
+
+// Expired истёк ли срок действия ключа
+func (k *APIKey) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && now.After(k.ExpiresAt)
+}
+
+type keyFile struct {
+	Keys []APIKey `yaml:"keys"`
+}
+
+// KeyStore хранилище ключей из YAML файла, перечитывается при изменении файла
+type KeyStore struct {
+	path string
+	log  logger.Logger
+
+	mu      sync.RWMutex
+	keys    map[string]*APIKey // хеш -> ключ
+	modTime time.Time
+}
+
+func LoadKeyStore(path string, log logger.Logger) (*KeyStore, error) {
+	s := &KeyStore{path: path, log: log}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Lookup ищет ключ по его значению из запроса
+func (s *KeyStore) Lookup(raw string) (*APIKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[HashKey(raw)]
+	return key, ok
+}
+
+// Len число ключей в хранилище
+func (s *KeyStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.keys)
+}
+
+// Watch перечитывает файл при изменении. Если новый файл
+// содержит ошибку, остаются прежние ключи
+func (s *KeyStore) Watch(interval time.Duration) {
+	watchFile(s.path, interval, s.loadedAt, s.reload, s.log, "API key store")
+}
+
+func (s *KeyStore) loadedAt() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.modTime
+}
+
+func (s *KeyStore) reload() error {
+	stat, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+
+	var file keyFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("parse %s: %w", s.path, err)
+	}
+
+	keys := make(map[string]*APIKey, len(file.Keys))
+	for i := range file.Keys {
+		key := &file.Keys[i]
+		if key.ID == "" {
+			return fmt.Errorf("key #%d in %s has no id", i+1, s.path)
+		}
+		hash, ok := strings.CutPrefix(strings.ToLower(key.Hash), "sha256:")
+		if !ok || len(hash) != sha256.Size*2 {
+			return fmt.Errorf("key %s in %s: hash must be sha256:<64 hex chars>", key.ID, s.path)
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			return fmt.Errorf("key %s in %s: invalid hash: %w", key.ID, s.path, err)
+		}
+		if _, dup := keys[hash]; dup {
+			return fmt.Errorf("key %s in %s: duplicate hash", key.ID, s.path)
+		}
+		keys[hash] = key
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.modTime = stat.ModTime()
+	s.mu.Unlock()
+	return nil
+}
+
+// HashKey хеш ключа в формате хранилища, без префикса
+func HashKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+func testLogger() logger.Logger {
+	return logger.New("test", logger.LevelInfo, logger.ModeDev)
+}
+
+func writeTestFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestKeyStoreLookup(t *testing.T) {
+	path := writeTestFile(t, "keys.yaml", `
+keys:
+  - id: billing
+    hash: SHA256:`+strings.ToUpper(HashKey("secret-1"))+`
+    scopes: [read, write]
+    tier: gold
+  - id: old
+    hash: sha256:`+HashKey("secret-2")+`
+    expires_at: 2020-01-01T00:00:00Z
+`)
+	store, err := LoadKeyStore(path, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.Len() != 2 {
+		t.Errorf("Len() = %d", store.Len())
+	}
+
+	key, ok := store.Lookup("secret-1")
+	if !ok || key.ID != "billing" || key.Tier != "gold" || len(key.Scopes) != 2 || key.Expired(time.Now()) {
+		t.Errorf("unexpected key %+v, %v", key, ok)
+	}
+	if key, ok := store.Lookup("secret-2"); !ok || !key.Expired(time.Now()) {
+		t.Error("expired key not reported as expired")
+	}
+	if _, ok := store.Lookup("unknown"); ok {
+		t.Error("unknown key found")
+	}
+}
+
+func TestKeyStoreRejectsInvalidFiles(t *testing.T) {
+	hash := "sha256:" + HashKey("k")
+	for name, data := range map[string]string{
+		"no id":          "keys:\n  - hash: " + hash,
+		"no prefix":      "keys:\n  - id: a\n    hash: " + HashKey("k"),
+		"short hash":     "keys:\n  - id: a\n    hash: sha256:abcd",
+		"not hex":        "keys:\n  - id: a\n    hash: sha256:" + strings.Repeat("z", 64),
+		"duplicate hash": "keys:\n  - id: a\n    hash: " + hash + "\n  - id: b\n    hash: " + hash,
+		"bad yaml":       "keys: [",
+	} {
+		if _, err := LoadKeyStore(writeTestFile(t, "keys.yaml", data), testLogger()); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestKeyStoreReloadKeepsOldKeysOnError(t *testing.T) {
+	path := writeTestFile(t, "keys.yaml", "keys:\n  - id: a\n    hash: sha256:"+HashKey("k1")+"\n")
+	store, err := LoadKeyStore(path, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(path, []byte("keys:\n  - id: b\n    hash: sha256:"+HashKey("k2")+"\n"), 0o600)
+	if err := store.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Lookup("k2"); !ok {
+		t.Error("new key not loaded")
+	}
+
+	os.WriteFile(path, []byte("keys: ["), 0o600)
+	if err := store.reload(); err == nil {
+		t.Fatal("broken file accepted")
+	}
+	if _, ok := store.Lookup("k2"); !ok {
+		t.Error("keys lost after failed reload")
+	}
+}
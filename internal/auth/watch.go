@@ -0,0 +1,34 @@
+// internal/auth/watch.go
+package auth
+
+import (
+	"os"
+	"time"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+// watchFile раз в interval сравнивает время изменения файла с последним загруженным
+// и вызывает reload. Если reload вернул ошибку, прежние данные остаются в силе
+func watchFile(path string, interval time.Duration, loaded func() time.Time, reload func() error, log logger.Logger, what string) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			stat, err := os.Stat(path)
+			if err != nil {
+				log.Errorf("❌ %s unavailable: %v", what, err)
+				continue
+			}
+			if stat.ModTime().Equal(loaded()) {
+				continue
+			}
+
+			if err := reload(); err != nil {
+				log.Errorf("❌ Failed to reload %s, keeping previous data: %v", what, err)
+				continue
+			}
+			log.Infof("🔄 %s reloaded from %s", what, path)
+		}
+	}()
+}
@@ -0,0 +1,152 @@
+// internal/balance/pool.go
+package balance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"access-proxy/internal/reqctx"
+)
+
+// Режимы привязки клиента к экземпляру
+const (
+	AffinityCookie = "cookie"
+	AffinityIP     = "ip"
+	AffinityHeader = "header"
+	AffinityPath   = "path"
+)
+
+const defaultCookieName = "access_proxy_affinity"
+
+// Affinity настройки привязки
+type Affinity struct {
+	Mode        string
+	Cookie      string        // имя cookie для режима cookie
+	CookieTTL   time.Duration // 0 — cookie сессии браузера
+	Header      string        // заголовок для режима header
+	PathSegment int           // номер сегмента пути (с 1) для режима path
+}
+
+// Instance экземпляр upstream
+type Instance struct {
+	Target  string
+	Handler http.Handler
+}
+
+// Pool выбирает экземпляр upstream по ключу клиента через консистентный хеш
+type Pool struct {
+	affinity  Affinity
+	instances map[string]Instance
+	ring      *Ring
+}
+
+func NewPool(instances []Instance, affinity Affinity) (*Pool, error) {
+	if len(instances) == 0 {
+		return nil, fmt.Errorf("pool requires at least one instance")
+	}
+
+	switch affinity.Mode {
+	case "":
+		affinity.Mode = AffinityIP
+	case AffinityCookie:
+		if affinity.Cookie == "" {
+			affinity.Cookie = defaultCookieName
+		}
+	case AffinityIP:
+	case AffinityHeader:
+		if affinity.Header == "" {
+			return nil, fmt.Errorf("affinity mode header requires a header name")
+		}
+	case AffinityPath:
+		if affinity.PathSegment < 1 {
+			return nil, fmt.Errorf("affinity mode path requires path_segment >= 1")
+		}
+	default:
+		return nil, fmt.Errorf("unknown affinity mode %q", affinity.Mode)
+	}
+
+	p := &Pool{affinity: affinity, instances: make(map[string]Instance, len(instances))}
+	ids := make([]string, 0, len(instances))
+	for _, inst := range instances {
+		id := instanceID(inst.Target)
+		if _, dup := p.instances[id]; dup {
+			return nil, fmt.Errorf("duplicate target %s", inst.Target)
+		}
+		p.instances[id] = inst
+		ids = append(ids, id)
+	}
+	p.ring = NewRing(ids)
+	return p, nil
+}
+
+// Mode режим привязки после применения значений по умолчанию
+func (p *Pool) Mode() string {
+	return p.affinity.Mode
+}
+
+func (p *Pool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.pick(w, r).Handler.ServeHTTP(w, r)
+}
+
+func (p *Pool) pick(w http.ResponseWriter, r *http.Request) Instance {
+	if p.affinity.Mode != AffinityCookie {
+		return p.instances[p.ring.Get(p.key(r))]
+	}
+
+	// Cookie хранит ID экземпляра; если экземпляра больше нет, клиент получает новый
+	if c, err := r.Cookie(p.affinity.Cookie); err == nil {
+		if inst, ok := p.instances[c.Value]; ok {
+			return inst
+		}
+	}
+	id := p.ring.Get(clientIP(r))
+	cookie := &http.Cookie{
+		Name:     p.affinity.Cookie,
+		Value:    id,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if p.affinity.CookieTTL > 0 {
+		cookie.MaxAge = int(p.affinity.CookieTTL.Seconds())
+	}
+	http.SetCookie(w, cookie)
+	return p.instances[id]
+}
+
+// key ключ хеширования; если его нет в запросе, используется IP клиента
+func (p *Pool) key(r *http.Request) string {
+	switch p.affinity.Mode {
+	case AffinityHeader:
+		if v := r.Header.Get(p.affinity.Header); v != "" {
+			return v
+		}
+	case AffinityPath:
+		segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if n := p.affinity.PathSegment; n <= len(segments) && segments[n-1] != "" {
+			return segments[n-1]
+		}
+	}
+	return clientIP(r)
+}
+
+func clientIP(r *http.Request) string {
+	if info := reqctx.FromRequest(r); info != nil && info.ClientIP != "" {
+		return info.ClientIP
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// instanceID стабильный идентификатор экземпляра, не раскрывающий его адрес
+func instanceID(target string) string {
+	sum := sha256.Sum256([]byte(target))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,114 @@
+package balance
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testPool(t *testing.T, affinity Affinity, targets ...string) *Pool {
+	t.Helper()
+	instances := make([]Instance, len(targets))
+	for i, target := range targets {
+		target := target
+		instances[i] = Instance{Target: target, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(target))
+		})}
+	}
+	p, err := NewPool(instances, affinity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func serve(p *Pool, r *http.Request) (string, *httptest.ResponseRecorder) {
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, r)
+	return rec.Body.String(), rec
+}
+
+func TestCookieAffinity(t *testing.T) {
+	p := testPool(t, Affinity{Mode: AffinityCookie, CookieTTL: time.Hour}, "http://a", "http://b", "http://c")
+
+	first, rec := serve(p, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != defaultCookieName || cookies[0].MaxAge != 3600 || !cookies[0].HttpOnly {
+		t.Fatalf("unexpected cookies %+v", cookies)
+	}
+	if cookies[0].Value == first {
+		t.Error("cookie exposes the upstream address")
+	}
+
+	// С cookie клиент попадает на тот же экземпляр с любого адреса
+	for _, addr := range []string{"10.0.0.1:1", "10.0.0.2:1", "10.0.0.3:1"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = addr
+		r.AddCookie(cookies[0])
+		got, rec := serve(p, r)
+		if got != first || len(rec.Result().Cookies()) != 0 {
+			t.Errorf("from %s served by %s, want %s", addr, got, first)
+		}
+	}
+
+	// Cookie несуществующего экземпляра заменяется
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: defaultCookieName, Value: "gone"})
+	if _, rec := serve(p, r); len(rec.Result().Cookies()) != 1 {
+		t.Error("stale cookie was not replaced")
+	}
+}
+
+func TestHeaderAndPathAffinity(t *testing.T) {
+	header := testPool(t, Affinity{Mode: AffinityHeader, Header: "X-Tenant"}, "http://a", "http://b", "http://c")
+	path := testPool(t, Affinity{Mode: AffinityPath, PathSegment: 2}, "http://a", "http://b", "http://c")
+
+	for _, tenant := range []string{"t1", "t2", "t3", "t4"} {
+		r1 := httptest.NewRequest(http.MethodGet, "/", nil)
+		r1.Header.Set("X-Tenant", tenant)
+		r1.RemoteAddr = "10.0.0.1:1"
+		r2 := httptest.NewRequest(http.MethodGet, "/other", nil)
+		r2.Header.Set("X-Tenant", tenant)
+		r2.RemoteAddr = "10.0.0.2:1"
+		if a, b := header.pick(nil, r1), header.pick(nil, r2); a.Target != b.Target {
+			t.Errorf("tenant %s split between %s and %s", tenant, a.Target, b.Target)
+		}
+
+		p1 := httptest.NewRequest(http.MethodGet, "/tenants/"+tenant+"/a", nil)
+		p2 := httptest.NewRequest(http.MethodGet, "/tenants/"+tenant+"/b", nil)
+		p2.RemoteAddr = "10.9.9.9:1"
+		if a, b := path.pick(nil, p1), path.pick(nil, p2); a.Target != b.Target {
+			t.Errorf("path tenant %s split between %s and %s", tenant, a.Target, b.Target)
+		}
+	}
+
+	short := httptest.NewRequest(http.MethodGet, "/tenants", nil)
+	if got := path.key(short); got != "192.0.2.1" {
+		t.Errorf("missing segment should fall back to client IP, got %q", got)
+	}
+}
+
+func TestNewPoolErrors(t *testing.T) {
+	one := []Instance{{Target: "http://a"}}
+	tests := []struct {
+		instances []Instance
+		affinity  Affinity
+	}{
+		{nil, Affinity{}},
+		{one, Affinity{Mode: "round-robin"}},
+		{one, Affinity{Mode: AffinityHeader}},
+		{one, Affinity{Mode: AffinityPath}},
+		{[]Instance{{Target: "http://a"}, {Target: "http://a"}}, Affinity{}},
+	}
+	for _, tt := range tests {
+		if _, err := NewPool(tt.instances, tt.affinity); err == nil {
+			t.Errorf("NewPool(%v, %+v): expected error", tt.instances, tt.affinity)
+		}
+	}
+
+	p, err := NewPool(one, Affinity{})
+	if err != nil || p.Mode() != AffinityIP {
+		t.Errorf("default mode = %q, %v", p.Mode(), err)
+	}
+}
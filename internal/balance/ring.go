@@ -0,0 +1,53 @@
+// internal/balance/ring.go
+package balance
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"strconv"
+)
+
+// replicas число виртуальных узлов на экземпляр: сглаживает распределение ключей
+const replicas = 160
+
+// Ring консистентный хеш. При добавлении или удалении экземпляра
+// переезжает только доля ключей, приходившаяся на него
+type Ring struct {
+	hashes []uint64
+	owners map[uint64]string
+}
+
+func NewRing(ids []string) *Ring {
+	r := &Ring{owners: make(map[uint64]string, len(ids)*replicas)}
+	for _, id := range ids {
+		for i := 0; i < replicas; i++ {
+			h := hashKey(id + "#" + strconv.Itoa(i))
+			if _, taken := r.owners[h]; taken {
+				continue
+			}
+			r.owners[h] = id
+			r.hashes = append(r.hashes, h)
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+	return r
+}
+
+// Get возвращает экземпляр, которому принадлежит ключ
+func (r *Ring) Get(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	h := hashKey(key)
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]]
+}
+
+func hashKey(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
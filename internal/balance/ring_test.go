@@ -0,0 +1,39 @@
+package balance
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRingDistribution(t *testing.T) {
+	r := NewRing([]string{"a", "b", "c"})
+	counts := map[string]int{}
+	const keys = 30000
+	for i := 0; i < keys; i++ {
+		counts[r.Get(fmt.Sprintf("key-%d", i))]++
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if share := float64(counts[id]) / keys; share < 0.25 || share > 0.42 {
+			t.Errorf("instance %s got %.2f of keys", id, share)
+		}
+	}
+}
+
+func TestRingMovesOnlyRemovedKeys(t *testing.T) {
+	before := NewRing([]string{"a", "b", "c", "d"})
+	after := NewRing([]string{"a", "b", "c"})
+
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		was, now := before.Get(key), after.Get(key)
+		if was != "d" && was != now {
+			t.Fatalf("key %s moved from %s to %s although %s is still present", key, was, now, was)
+		}
+	}
+}
+
+func TestEmptyRing(t *testing.T) {
+	if got := NewRing(nil).Get("x"); got != "" {
+		t.Errorf("empty ring returned %q", got)
+	}
+}
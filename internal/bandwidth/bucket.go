@@ -0,0 +1,104 @@
+package bandwidth
+
+import (
+	"sync"
+	"time"
+)
+
+// bucket маркерная корзина байт: пополняется со скоростью rate до burst.
+// Запас может уйти в минус — порция уже разрешена, а следующая подождёт,
+// пока долг не восстановится
+type bucket struct {
+	rate   float64 // байт в секунду
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	users int // сколько запросов используют корзину, под блокировкой Limiter
+}
+
+func newBucket(rate, burst int64, now time.Time) *bucket {
+	return &bucket{rate: float64(rate), burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+}
+
+// reserve расходует n байт и возвращает, сколько ждать перед их передачей
+func (b *bucket) reserve(n int, now time.Time) time.Duration {
+	b.refill(now)
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// full запас восстановлен полностью: удаление корзины не изменит лимит
+func (b *bucket) full(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= b.burst
+}
+
+// buckets корзины по ключу «область/направление/клиент или маршрут»
+type buckets struct {
+	mu    sync.Mutex
+	items map[string]*bucket
+}
+
+// acquire возвращает корзину ключа, создавая её с полным запасом
+func (bs *buckets) acquire(key string, rate, burst int64) *bucket {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b, ok := bs.items[key]
+	if !ok {
+		b = newBucket(rate, burst, time.Now())
+		bs.items[key] = b
+	}
+	b.users++
+	return b
+}
+
+func (bs *buckets) release(list []*bucket) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	for _, b := range list {
+		b.users--
+	}
+}
+
+// reserve расходует n байт во всех корзинах и возвращает наибольшее ожидание
+func (bs *buckets) reserve(list []*bucket, n int) time.Duration {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	now := time.Now()
+	var delay time.Duration
+	for _, b := range list {
+		delay = max(delay, b.reserve(n, now))
+	}
+	return delay
+}
+
+// sweep удаляет неиспользуемые корзины с полным запасом; возвращает число удалённых
+func (bs *buckets) sweep(now time.Time) int {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	removed := 0
+	for key, b := range bs.items {
+		if b.users == 0 && b.full(now) {
+			delete(bs.items, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (bs *buckets) len() int {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return len(bs.items)
+}
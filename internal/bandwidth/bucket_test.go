@@ -0,0 +1,72 @@
+package bandwidth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketReserve(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	b := newBucket(1000, 500, now) // 1000 байт/с, запас 500
+
+	if d := b.reserve(500, now); d != 0 {
+		t.Errorf("within burst: wait %v", d)
+	}
+	// Долг в 250 байт восстанавливается за 250ms
+	if d := b.reserve(250, now); d != 250*time.Millisecond {
+		t.Errorf("over burst: wait %v, want 250ms", d)
+	}
+	if d := b.reserve(250, now.Add(250*time.Millisecond)); d != 250*time.Millisecond {
+		t.Errorf("after repaying debt: wait %v, want 250ms", d)
+	}
+
+	// Пополнение не превышает запас
+	if b.full(now.Add(500 * time.Millisecond)) {
+		t.Error("full too early")
+	}
+	if !b.full(now.Add(2 * time.Second)) {
+		t.Error("not full after idle period")
+	}
+	if b.tokens != 500 {
+		t.Errorf("tokens = %v, want burst 500", b.tokens)
+	}
+}
+
+func TestBucketClockGoesBack(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	b := newBucket(1000, 1000, now)
+	b.reserve(1000, now)
+	b.refill(now.Add(-time.Second))
+	if b.tokens != 0 || !b.last.Equal(now) {
+		t.Errorf("refill with earlier time changed the bucket: %v %v", b.tokens, b.last)
+	}
+}
+
+func TestBucketsShareAndSweep(t *testing.T) {
+	bs := buckets{items: make(map[string]*bucket)}
+
+	a := bs.acquire("client\x00download\x00a", 1000, 1000)
+	again := bs.acquire("client\x00download\x00a", 1000, 1000)
+	route := bs.acquire("route\x00download\x00api", 100, 100)
+	if a != again || a.users != 2 || bs.len() != 2 {
+		t.Fatalf("buckets are not shared: %d users, %d buckets", a.users, bs.len())
+	}
+
+	// Ожидание — наибольшее среди корзин запроса
+	if d := bs.reserve([]*bucket{a, route}, 200); d < 900*time.Millisecond || d > time.Second {
+		t.Errorf("wait %v, want about 1s", d)
+	}
+
+	bs.release([]*bucket{a, route})
+	if n := bs.sweep(time.Now().Add(time.Hour)); n != 1 || bs.len() != 1 {
+		t.Errorf("sweep removed %d, left %d: bucket in use must stay", n, bs.len())
+	}
+	bs.release([]*bucket{again})
+
+	if n := bs.sweep(time.Now()); n != 0 {
+		t.Errorf("swept bucket with debt")
+	}
+	if n := bs.sweep(time.Now().Add(time.Hour)); n != 1 || bs.len() != 0 {
+		t.Errorf("sweep removed %d, left %d", n, bs.len())
+	}
+}
@@ -0,0 +1,140 @@
+package bandwidth
+
+import (
+	"net/http"
+	"time"
+
+	"access-proxy/internal/metrics"
+	"access-proxy/internal/reqctx"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+// Направления трафика, они же метки метрик
+const (
+	DirectionUpload   = "upload"
+	DirectionDownload = "download"
+)
+
+// maxChunk наибольшая порция, которая передаётся за одно ожидание: крупные
+// записи делятся, чтобы трафик шёл ровно, а не рывками
+const maxChunk = 32 << 10
+
+// Limit скорость в байтах в секунду и запас, который можно передать сразу
+// (по умолчанию — секунда трафика); 0 — без ограничения
+type Limit struct {
+	Upload        int64
+	Download      int64
+	UploadBurst   int64
+	DownloadBurst int64
+}
+
+func (l Limit) forDirection(dir string) (rate, burst int64) {
+	rate, burst = l.Download, l.DownloadBurst
+	if dir == DirectionUpload {
+		rate, burst = l.Upload, l.UploadBurst
+	}
+	if burst <= 0 {
+		burst = rate
+	}
+	return rate, burst
+}
+
+// Options ограничения скорости передачи тел запросов и ответов
+type Options struct {
+	PerClient Limit            // общий для всех запросов клиента: по principal, иначе по IP
+	PerRoute  Limit            // общий для всех запросов маршрута без своего лимита
+	Routes    map[string]Limit // лимиты отдельных маршрутов
+	// UpstreamRoutes маршруты, которые уходят в upstream; служебные эндпоинты
+	// прокси не ограничиваются
+	UpstreamRoutes []string
+	// CleanupInterval как часто удаляются неиспользуемые корзины, по умолчанию 1m
+	CleanupInterval time.Duration
+}
+
+// Limiter ограничивает скорость, с которой прокси читает тело запроса
+// и пишет тело ответа. Запас байт общий для всех запросов клиента
+// или маршрута, поэтому параллельные загрузки не обходят лимит
+type Limiter struct {
+	opts     Options
+	upstream map[string]bool
+	buckets  buckets
+	metrics  *metrics.ProxyMetrics
+	log      logger.Logger
+}
+
+// New создаёт ограничитель; m может быть nil
+func New(opts Options, m *metrics.ProxyMetrics, log logger.Logger) *Limiter {
+	if opts.CleanupInterval <= 0 {
+		opts.CleanupInterval = time.Minute
+	}
+
+	l := &Limiter{
+		opts:     opts,
+		upstream: make(map[string]bool, len(opts.UpstreamRoutes)),
+		buckets:  buckets{items: make(map[string]*bucket)},
+		metrics:  m,
+		log:      log,
+	}
+	for _, route := range opts.UpstreamRoutes {
+		l.upstream[route] = true
+	}
+
+	go l.janitor(opts.CleanupInterval)
+	return l
+}
+
+// janitor удаляет корзины, чей запас полностью восстановился
+func (l *Limiter) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if n := l.buckets.sweep(time.Now()); n > 0 {
+			l.log.Infof("🧹 Bandwidth limiter evicted %d idle buckets, %d tracked", n, l.buckets.len())
+		}
+	}
+}
+
+func (l *Limiter) routeLimit(route string) Limit {
+	if limit, ok := l.opts.Routes[route]; ok {
+		return limit
+	}
+	return l.opts.PerRoute
+}
+
+// acquire корзины запроса для направления: клиента и маршрута
+func (l *Limiter) acquire(dir string, info *reqctx.Info) []*bucket {
+	var list []*bucket
+	if rate, burst := l.opts.PerClient.forDirection(dir); rate > 0 {
+		list = append(list, l.buckets.acquire("client\x00"+dir+"\x00"+info.Identity(), rate, burst))
+	}
+	if rate, burst := l.routeLimit(info.Route).forDirection(dir); rate > 0 {
+		list = append(list, l.buckets.acquire("route\x00"+dir+"\x00"+info.Route, rate, burst))
+	}
+	return list
+}
+
+// Middleware возвращает HTTP middleware, которое оборачивает тело запроса
+// и http.ResponseWriter
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := reqctx.FromRequest(r)
+		if info == nil || !l.upstream[info.Route] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Body != nil && r.Body != http.NoBody {
+			if up := l.acquire(DirectionUpload, info); len(up) > 0 {
+				defer l.buckets.release(up)
+				r.Body = &throttledReader{ReadCloser: r.Body, throttle: l.newThrottle(r, DirectionUpload, up)}
+			}
+		}
+		if down := l.acquire(DirectionDownload, info); len(down) > 0 {
+			defer l.buckets.release(down)
+			w = &throttledWriter{ResponseWriter: w, throttle: l.newThrottle(r, DirectionDownload, down)}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,103 @@
+package bandwidth
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// throttle выдаёт разрешение на передачу байт в одном направлении запроса
+type throttle struct {
+	ctx     context.Context
+	limiter *Limiter
+	dir     string
+	buckets []*bucket
+	chunk   int
+}
+
+func (l *Limiter) newThrottle(r *http.Request, dir string, list []*bucket) *throttle {
+	// Порция не больше запаса, иначе каждая передача начиналась бы с долга
+	chunk := maxChunk
+	for _, b := range list {
+		chunk = min(chunk, max(int(b.burst), 1))
+	}
+	return &throttle{ctx: r.Context(), limiter: l, dir: dir, buckets: list, chunk: chunk}
+}
+
+// wait расходует n байт и ждёт, пока их можно передать; прерывается,
+// если клиент отключился
+func (t *throttle) wait(n int) error {
+	m := t.limiter.metrics
+	if m != nil {
+		m.BandwidthTransferred(t.dir, n)
+	}
+	delay := t.limiter.buckets.reserve(t.buckets, n)
+	if delay <= 0 {
+		return nil
+	}
+	if m != nil {
+		m.BandwidthThrottled(t.dir, delay)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-t.ctx.Done():
+		return t.ctx.Err()
+	}
+}
+
+// throttledWriter пишет тело ответа порциями со скоростью лимита
+type throttledWriter struct {
+	http.ResponseWriter
+	*throttle
+}
+
+func (w *throttledWriter) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		n := min(len(b), w.chunk)
+		if err := w.wait(n); err != nil {
+			return written, err
+		}
+		m, err := w.ResponseWriter.Write(b[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
+
+func (w *throttledWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *throttledWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// throttledReader отдаёт тело запроса upstream'у со скоростью лимита
+type throttledReader struct {
+	io.ReadCloser
+	*throttle
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > r.chunk {
+		p = p[:r.chunk]
+	}
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		if werr := r.wait(n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,148 @@
+package bandwidth
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"access-proxy/internal/reqctx"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+func newTestLimiter(opts Options) *Limiter {
+	opts.UpstreamRoutes = []string{"api"}
+	return New(opts, nil, logger.New("test", logger.LevelInfo, logger.ModeDev))
+}
+
+func newRequest(ctx context.Context, route, ip string, body io.Reader) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/", body).WithContext(ctx)
+	return r.WithContext(reqctx.NewContext(r.Context(), &reqctx.Info{Route: route, ClientIP: ip}))
+}
+
+func TestThrottledWriter(t *testing.T) {
+	l := newTestLimiter(Options{PerClient: Limit{Download: 10 << 10}}) // 10 KiB/s, запас — секунда
+	payload := bytes.Repeat([]byte("x"), 15<<10)
+
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n, err := w.Write(payload); n != len(payload) || err != nil {
+			t.Errorf("write: %d, %v", n, err)
+		}
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("throttled writer is not a Flusher")
+		}
+	}))
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newRequest(context.Background(), "api", "10.0.0.1", nil))
+	elapsed := time.Since(start)
+
+	// 10 KiB уходят сразу, оставшиеся 5 KiB — за полсекунды
+	if elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("15 KiB at 10 KiB/s took %v", elapsed)
+	}
+	if rec.Body.Len() != len(payload) {
+		t.Errorf("body = %d bytes", rec.Body.Len())
+	}
+}
+
+func TestThrottledReader(t *testing.T) {
+	l := newTestLimiter(Options{Routes: map[string]Limit{"api": {Upload: 4 << 10, UploadBurst: 1 << 10}}})
+	body := strings.Repeat("y", 3<<10)
+
+	var got []byte
+	var reads []int
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 64<<10)
+		for {
+			n, err := r.Body.Read(buf)
+			if n > 0 {
+				reads = append(reads, n)
+				got = append(got, buf[:n]...)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}))
+
+	start := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest(context.Background(), "api", "10.0.0.1", strings.NewReader(body)))
+	elapsed := time.Since(start)
+
+	if string(got) != body {
+		t.Fatalf("body corrupted: %d bytes", len(got))
+	}
+	// Чтение делится на порции не больше запаса
+	for _, n := range reads {
+		if n > 1<<10 {
+			t.Errorf("read %d bytes at once, chunk is 1 KiB", n)
+		}
+	}
+	// 1 KiB сразу, ещё 2 KiB со скоростью 4 KiB/s
+	if elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("3 KiB at 4 KiB/s took %v", elapsed)
+	}
+}
+
+func TestThrottleCancelled(t *testing.T) {
+	l := newTestLimiter(Options{PerRoute: Limit{Download: 1024}})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var n int
+	var err error
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.AfterFunc(20*time.Millisecond, cancel)
+		n, err = w.Write(make([]byte, 10<<10))
+	}))
+
+	start := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest(ctx, "api", "10.0.0.1", nil))
+	if err != context.Canceled || n != 1024 || time.Since(start) > time.Second {
+		t.Errorf("write after disconnect: n = %d, err = %v, took %v", n, err, time.Since(start))
+	}
+}
+
+func TestBandwidthSharedBetweenRequests(t *testing.T) {
+	l := newTestLimiter(Options{PerClient: Limit{Download: 2 << 10}})
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 2<<10))
+	}))
+
+	// Первый запрос расходует весь запас клиента, второй ждёт секунду
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest(context.Background(), "api", "10.0.0.1", nil))
+	start := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest(context.Background(), "api", "10.0.0.2", nil))
+	if time.Since(start) > 200*time.Millisecond {
+		t.Error("other client was throttled")
+	}
+	start = time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest(context.Background(), "api", "10.0.0.1", nil))
+	if elapsed := time.Since(start); elapsed < 800*time.Millisecond {
+		t.Errorf("second request of the same client took %v", elapsed)
+	}
+}
+
+func TestUnlimitedRoutes(t *testing.T) {
+	l := newTestLimiter(Options{PerRoute: Limit{Download: 1}})
+	var wrapped bool
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, wrapped = w.(*throttledWriter)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest(context.Background(), "health", "10.0.0.1", nil))
+	if wrapped || l.buckets.len() != 0 {
+		t.Error("service route is throttled")
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest(context.Background(), "api", "10.0.0.1", nil))
+	if !wrapped {
+		t.Error("upstream route is not throttled")
+	}
+}
@@ -0,0 +1,166 @@
+// internal/canary/canary.go
+package canary
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"access-proxy/internal/metrics"
+	"access-proxy/internal/reqctx"
+)
+
+// VariantHeader сообщает клиенту, какой вариант обслужил запрос
+const VariantHeader = "X-Access-Proxy-Variant"
+
+const (
+	VariantStable = "stable"
+	VariantCanary = "canary"
+)
+
+// Options настройки разделения трафика маршрута
+type Options struct {
+	Target         string  // только для отображения в статусе
+	Percent        float64 // доля трафика на canary, 0..100
+	Header         string  // заголовок со значением canary/stable
+	Cookie         string  // cookie со значением canary/stable
+	ClientIDHeader string  // заголовок с ID клиента, по умолчанию IP клиента
+	CanaryClients  []string
+	StableClients  []string
+}
+
+// Status текущее состояние разделения
+type Status struct {
+	Route   string  `json:"route"`
+	Target  string  `json:"target"`
+	Percent float64 `json:"percent"`
+}
+
+// Splitter направляет часть трафика маршрута на canary upstream.
+// Клиент попадает в вариант по хешу своего ID, поэтому при неизменном
+// проценте он всегда обслуживается одним и тем же вариантом
+type Splitter struct {
+	route   string
+	stable  http.Handler
+	canary  http.Handler
+	opts    Options
+	basis   atomic.Int64 // процент в сотых долях, 0..10000
+	forced  map[string]string
+	metrics *metrics.ProxyMetrics
+}
+
+func New(route string, stable, canary http.Handler, opts Options, m *metrics.ProxyMetrics) (*Splitter, error) {
+	s := &Splitter{
+		route:   route,
+		stable:  stable,
+		canary:  canary,
+		opts:    opts,
+		forced:  make(map[string]string, len(opts.CanaryClients)+len(opts.StableClients)),
+		metrics: m,
+	}
+	if err := s.SetPercent(opts.Percent); err != nil {
+		return nil, err
+	}
+
+	for _, id := range opts.CanaryClients {
+		s.forced[id] = VariantCanary
+	}
+	for _, id := range opts.StableClients {
+		if s.forced[id] == VariantCanary {
+			return nil, fmt.Errorf("client %q is forced onto both canary and stable for route %s", id, route)
+		}
+		s.forced[id] = VariantStable
+	}
+	return s, nil
+}
+
+// Percent текущая доля трафика на canary
+func (s *Splitter) Percent() float64 {
+	return float64(s.basis.Load()) / 100
+}
+
+// SetPercent меняет долю трафика на canary без перезапуска
+func (s *Splitter) SetPercent(percent float64) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("canary percent for route %s must be within 0..100", s.route)
+	}
+	s.basis.Store(int64(math.Round(percent * 100)))
+	return nil
+}
+
+func (s *Splitter) Status() Status {
+	return Status{Route: s.route, Target: s.opts.Target, Percent: s.Percent()}
+}
+
+func (s *Splitter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	variant := s.choose(r)
+	w.Header().Set(VariantHeader, variant)
+	if s.metrics != nil {
+		s.metrics.CanaryServed(s.route, variant)
+	}
+
+	if variant == VariantCanary {
+		s.canary.ServeHTTP(w, r)
+		return
+	}
+	s.stable.ServeHTTP(w, r)
+}
+
+// choose выбирает вариант: заголовок, cookie, список клиентов, затем хеш ID клиента
+func (s *Splitter) choose(r *http.Request) string {
+	if s.opts.Header != "" {
+		if v := override(r.Header.Get(s.opts.Header)); v != "" {
+			return v
+		}
+	}
+	if s.opts.Cookie != "" {
+		if c, err := r.Cookie(s.opts.Cookie); err == nil {
+			if v := override(c.Value); v != "" {
+				return v
+			}
+		}
+	}
+
+	id := s.clientID(r)
+	if v, ok := s.forced[id]; ok {
+		return v
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(s.route))
+	h.Write([]byte{0})
+	h.Write([]byte(id))
+	if int64(h.Sum32()%10000) < s.basis.Load() {
+		return VariantCanary
+	}
+	return VariantStable
+}
+
+func (s *Splitter) clientID(r *http.Request) string {
+	if s.opts.ClientIDHeader != "" {
+		if id := r.Header.Get(s.opts.ClientIDHeader); id != "" {
+			return id
+		}
+	}
+	if info := reqctx.FromRequest(r); info != nil && info.ClientIP != "" {
+		return info.ClientIP
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+func override(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case VariantCanary, "always", "true", "1":
+		return VariantCanary
+	case VariantStable, "never", "false", "0":
+		return VariantStable
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package canary
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func variantHandler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name))
+	})
+}
+
+func newSplitter(t *testing.T, opts Options) *Splitter {
+	t.Helper()
+	s, err := New("api", variantHandler(VariantStable), variantHandler(VariantCanary), opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func serve(s *Splitter, r *http.Request) (string, string) {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, r)
+	return rec.Body.String(), rec.Header().Get(VariantHeader)
+}
+
+func TestOverridesTakePrecedence(t *testing.T) {
+	s := newSplitter(t, Options{
+		Percent:        0,
+		Header:         "X-Canary",
+		Cookie:         "canary",
+		ClientIDHeader: "X-User",
+		CanaryClients:  []string{"alice"},
+		StableClients:  []string{"10.0.0.9"},
+	})
+
+	tests := []struct {
+		name  string
+		setup func(r *http.Request)
+		want  string
+	}{
+		{"default", func(r *http.Request) {}, VariantStable},
+		{"header", func(r *http.Request) { r.Header.Set("X-Canary", "always") }, VariantCanary},
+		{"cookie", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "canary", Value: "1"}) }, VariantCanary},
+		{"forced client", func(r *http.Request) { r.Header.Set("X-User", "alice") }, VariantCanary},
+		{"header beats client list", func(r *http.Request) {
+			r.Header.Set("X-User", "alice")
+			r.Header.Set("X-Canary", "stable")
+		}, VariantStable},
+		{"unknown header value ignored", func(r *http.Request) { r.Header.Set("X-Canary", "maybe") }, VariantStable},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		tt.setup(r)
+		body, header := serve(s, r)
+		if body != tt.want || header != tt.want {
+			t.Errorf("%s: served by %q (header %q), want %q", tt.name, body, header, tt.want)
+		}
+	}
+}
+
+func TestHashIsStickyAndProportional(t *testing.T) {
+	s := newSplitter(t, Options{Percent: 30, ClientIDHeader: "X-User"})
+
+	var canary int
+	const clients = 10000
+	for i := 0; i < clients; i++ {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-User", fmt.Sprintf("user-%d", i))
+		first := s.choose(r)
+		if again := s.choose(r); again != first {
+			t.Fatalf("client user-%d switched variant", i)
+		}
+		if first == VariantCanary {
+			canary++
+		}
+	}
+	if share := float64(canary) / clients; share < 0.27 || share > 0.33 {
+		t.Errorf("canary share %.3f, want about 0.30", share)
+	}
+}
+
+func TestRaisingPercentKeepsCanaryClients(t *testing.T) {
+	s := newSplitter(t, Options{Percent: 10, ClientIDHeader: "X-User"})
+	var onCanary []*http.Request
+	for i := 0; i < 1000; i++ {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-User", fmt.Sprintf("user-%d", i))
+		if s.choose(r) == VariantCanary {
+			onCanary = append(onCanary, r)
+		}
+	}
+
+	s.SetPercent(50)
+	for _, r := range onCanary {
+		if s.choose(r) != VariantCanary {
+			t.Fatalf("client %s left canary when percent grew", r.Header.Get("X-User"))
+		}
+	}
+}
+
+func TestSetPercent(t *testing.T) {
+	s := newSplitter(t, Options{})
+	for _, p := range []float64{-1, 100.5} {
+		if err := s.SetPercent(p); err == nil {
+			t.Errorf("SetPercent(%v) accepted", p)
+		}
+	}
+	if err := s.SetPercent(0.29); err != nil || s.Percent() != 0.29 {
+		t.Errorf("Percent() = %v, %v", s.Percent(), err)
+	}
+}
+
+func TestNewRejectsConflictingClients(t *testing.T) {
+	_, err := New("api", nil, nil, Options{CanaryClients: []string{"a"}, StableClients: []string{"a"}}, nil)
+	if err == nil {
+		t.Error("client in both lists accepted")
+	}
+}
@@ -0,0 +1,238 @@
+// internal/capture/har.go
+package capture
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"access-proxy/internal/redact"
+)
+
+// HAR 1.2: http://www.softwareishard.com/blog/har-12-spec/
+
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []Entry    `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type Entry struct {
+	StartedDateTime string   `json:"startedDateTime"`
+	Time            float64  `json:"time"`
+	Request         Request  `json:"request"`
+	Response        Response `json:"response"`
+	Cache           struct{} `json:"cache"`
+	Timings         Timings  `json:"timings"`
+	RequestID       string   `json:"_requestId,omitempty"`
+	Route           string   `json:"_route,omitempty"`
+	ClientIP        string   `json:"_clientIp,omitempty"`
+}
+
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	PostData    *PostData   `json:"postData,omitempty"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int64       `json:"bodySize"`
+}
+
+type Response struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     Content     `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int64       `json:"bodySize"`
+}
+
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type PostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"_encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type Content struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type Timings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// Exchange перехваченная пара запрос/ответ
+type Exchange struct {
+	Request        *http.Request
+	RequestBody    []byte
+	RequestSize    int64
+	Status         int
+	ResponseHeader http.Header
+	ResponseBody   []byte
+	ResponseSize   int64
+	Start          time.Time
+	Duration       time.Duration
+	RequestID      string
+	Route          string
+	ClientIP       string
+}
+
+// NewEntry строит HAR запись, пропуская заголовки, URL и тела через redactor
+func NewEntry(ex *Exchange, redactor *redact.Redactor) Entry {
+	r := ex.Request
+	ms := float64(ex.Duration.Microseconds()) / 1000
+
+	entry := Entry{
+		StartedDateTime: ex.Start.Format(time.RFC3339Nano),
+		Time:            ms,
+		Request: Request{
+			Method:      r.Method,
+			URL:         redactor.URL(requestURL(r)),
+			HTTPVersion: r.Proto,
+			Cookies:     []NameValue{},
+			Headers:     nameValues(redactor.Header(r.Header)),
+			QueryString: queryString(redactor.Query(r.URL.RawQuery)),
+			HeadersSize: -1,
+			BodySize:    ex.RequestSize,
+		},
+		Response: Response{
+			Status:      ex.Status,
+			StatusText:  http.StatusText(ex.Status),
+			HTTPVersion: r.Proto,
+			Cookies:     []NameValue{},
+			Headers:     nameValues(redactor.Header(ex.ResponseHeader)),
+			Content: Content{
+				Size:     ex.ResponseSize,
+				MimeType: ex.ResponseHeader.Get("Content-Type"),
+			},
+			RedirectURL: ex.ResponseHeader.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    ex.ResponseSize,
+		},
+		Timings:   Timings{Wait: ms},
+		RequestID: ex.RequestID,
+		Route:     ex.Route,
+		ClientIP:  ex.ClientIP,
+	}
+
+	if ex.RequestSize > 0 {
+		contentType := r.Header.Get("Content-Type")
+		redacted := redactor.Body(contentType, ex.RequestBody)
+		text, encoding := bodyText(redacted)
+		entry.Request.PostData = &PostData{
+			MimeType: contentType,
+			Text:     text,
+			Encoding: encoding,
+			Comment:  bodyComment(ex.RequestBody, redacted, ex.RequestSize, redactor),
+		}
+	}
+
+	if ex.ResponseSize > 0 {
+		redacted := redactor.Body(entry.Response.Content.MimeType, ex.ResponseBody)
+		text, encoding := bodyText(redacted)
+		entry.Response.Content.Text = text
+		entry.Response.Content.Encoding = encoding
+		entry.Response.Content.Comment = bodyComment(ex.ResponseBody, redacted, ex.ResponseSize, redactor)
+	}
+
+	return entry
+}
+
+func requestURL(r *http.Request) *url.URL {
+	u := *r.URL
+	if u.Host == "" {
+		u.Host = r.Host
+	}
+	if u.Scheme == "" {
+		u.Scheme = "http"
+		if r.TLS != nil {
+			u.Scheme = "https"
+		}
+	}
+	return &u
+}
+
+func nameValues(h http.Header) []NameValue {
+	out := []NameValue{}
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range h[name] {
+			out = append(out, NameValue{Name: name, Value: v})
+		}
+	}
+	return out
+}
+
+func queryString(rawQuery string) []NameValue {
+	out := []NameValue{}
+	if rawQuery == "" {
+		return out
+	}
+	for _, part := range strings.Split(rawQuery, "&") {
+		name, value, _ := strings.Cut(part, "=")
+		if n, err := url.QueryUnescape(name); err == nil {
+			name = n
+		}
+		if v, err := url.QueryUnescape(value); err == nil {
+			value = v
+		}
+		out = append(out, NameValue{Name: name, Value: value})
+	}
+	return out
+}
+
+func bodyText(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+// bodyComment помечает тела, которые нельзя воспроизвести или сравнить как есть:
+// не захваченные, обрезанные или с данными, скрытыми redaction
+func bodyComment(captured, redacted []byte, total int64, redactor *redact.Redactor) string {
+	switch {
+	case len(captured) == 0 && total > 0:
+		return "not captured"
+	case int64(len(captured)) < total:
+		return "truncated"
+	case redactor.Masked(captured, redacted):
+		return "redacted"
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package capture
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"access-proxy/internal/redact"
+)
+
+func TestNewEntryMarksUnreplayableBodies(t *testing.T) {
+	redactor, err := redact.New(redact.Rules{JSONFields: []string{"password"}, QueryParams: []string{"token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		captured string
+		total    int64
+		want     string
+	}{
+		{"complete", `{"user":"a"}`, 12, ""},
+		{"truncated", `{"user":`, 12, "truncated"},
+		{"not captured", "", 12, "not captured"},
+		{"redacted", `{"password":"p"}`, 16, "redacted"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/login?token=abc", strings.NewReader(tt.captured))
+		req.Header.Set("Content-Type", "application/json")
+		entry := NewEntry(&Exchange{
+			Request:        req,
+			RequestBody:    []byte(tt.captured),
+			RequestSize:    tt.total,
+			Status:         http.StatusOK,
+			ResponseHeader: http.Header{},
+			Start:          time.Now(),
+		}, redactor)
+
+		if got := entry.Request.PostData.Comment; got != tt.want {
+			t.Errorf("%s: comment %q, want %q", tt.name, got, tt.want)
+		}
+		if strings.Contains(entry.Request.URL, "abc") {
+			t.Errorf("%s: query not redacted: %s", tt.name, entry.Request.URL)
+		}
+	}
+}
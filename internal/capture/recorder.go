@@ -0,0 +1,400 @@
+// internal/capture/recorder.go
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+// Форматы файлов захвата
+const (
+	FormatHAR   = "har"
+	FormatJSONL = "jsonl"
+)
+
+const (
+	defaultMaxEntries = 1000
+	creatorName       = "access-proxy"
+	creatorVersion    = "1.0"
+)
+
+// Filter определяет, какие запросы попадают в захват. Внутри одного списка
+// достаточно совпадения с любым значением, пустой список совпадает со всем
+type Filter struct {
+	Routes  []string `json:"routes,omitempty"`  // имена маршрутов
+	Clients []string `json:"clients,omitempty"` // IP или CIDR клиентов
+	Headers []string `json:"headers,omitempty"` // "Имя" или "Имя: значение"
+}
+
+// Options параметры сеанса захвата
+type Options struct {
+	Dir         string
+	Format      string
+	MaxEntries  int
+	MaxDuration time.Duration
+	Filter
+}
+
+// Status состояние захвата для эндпоинта /capture
+type Status struct {
+	Active      bool       `json:"active"`
+	File        string     `json:"file,omitempty"`
+	Format      string     `json:"format,omitempty"`
+	Entries     int        `json:"entries"`
+	MaxEntries  int        `json:"max_entries,omitempty"`
+	MaxDuration string     `json:"max_duration,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	Deadline    *time.Time `json:"deadline,omitempty"`
+	Filter      *Filter    `json:"filter,omitempty"`
+}
+
+// Recorder записывает совпавшие пары запрос/ответ в HAR или JSONL файлы.
+// Одновременно активен не больше одного сеанса
+type Recorder struct {
+	mu       sync.Mutex
+	log      logger.Logger
+	defaults Options
+	active   atomic.Bool
+	session  *session
+	last     Status
+}
+
+type session struct {
+	opts    Options
+	path    string
+	started time.Time
+	timer   *time.Timer
+	file    *os.File // записи дописываются в файл сразу, в памяти не копятся
+	writer  *bufio.Writer
+	har     *harWriter // nil для JSONL
+	count   int
+}
+
+func NewRecorder(defaults Options, log logger.Logger) *Recorder {
+	if defaults.Format == "" {
+		defaults.Format = FormatHAR
+	}
+	if defaults.MaxEntries <= 0 {
+		defaults.MaxEntries = defaultMaxEntries
+	}
+	if defaults.Dir == "" {
+		defaults.Dir = "."
+	}
+	return &Recorder{defaults: defaults, log: log}
+}
+
+// Active быстрый признак активного сеанса для middleware
+func (r *Recorder) Active() bool {
+	return r.active.Load()
+}
+
+// Start запускает сеанс. Незаданные параметры берутся из конфигурации
+func (r *Recorder) Start(opts Options) (Status, error) {
+	opts = r.withDefaults(opts)
+	if opts.Format != FormatHAR && opts.Format != FormatJSONL {
+		return Status{}, fmt.Errorf("unknown capture format %q", opts.Format)
+	}
+	for _, c := range opts.Clients {
+		if net.ParseIP(c) == nil {
+			if _, _, err := net.ParseCIDR(c); err != nil {
+				return Status{}, fmt.Errorf("invalid capture client %q", c)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.session != nil {
+		return Status{}, fmt.Errorf("capture already active: %s", r.session.path)
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return Status{}, fmt.Errorf("create capture dir: %w", err)
+	}
+
+	now := time.Now()
+	s := &session{
+		opts:    opts,
+		path:    filepath.Join(opts.Dir, "capture-"+now.Format("20060102-150405.000")+"."+opts.Format),
+		started: now,
+	}
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return Status{}, fmt.Errorf("open capture file: %w", err)
+	}
+	s.file = file
+	s.writer = bufio.NewWriter(file)
+	if opts.Format == FormatHAR {
+		if s.har, err = newHARWriter(s.writer); err != nil {
+			file.Close()
+			return Status{}, fmt.Errorf("write capture header: %w", err)
+		}
+	}
+	if opts.MaxDuration > 0 {
+		s.timer = time.AfterFunc(opts.MaxDuration, func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			// Сеанс мог уже завершиться по лимиту записей, а новый — начаться
+			if r.session == s {
+				r.log.Infof("🎥 Capture stopped: max duration %v reached", opts.MaxDuration)
+				r.stopLocked()
+			}
+		})
+	}
+
+	r.session = s
+	r.active.Store(true)
+	r.log.Infof("🎥 Capture started: %s", s.path)
+	return r.statusLocked(), nil
+}
+
+// Stop завершает сеанс и записывает файл
+func (r *Recorder) Stop() (Status, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.session == nil {
+		return r.last, fmt.Errorf("capture is not active")
+	}
+	return r.stopLocked()
+}
+
+// Status возвращает состояние текущего или последнего сеанса
+func (r *Recorder) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.session == nil {
+		return r.last
+	}
+	return r.statusLocked()
+}
+
+// Matches проверяет, попадает ли запрос в фильтр активного сеанса
+func (r *Recorder) Matches(req *http.Request, route, clientIP string) bool {
+	if !r.Active() {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.session == nil {
+		return false
+	}
+	return r.session.opts.Filter.matches(req, route, clientIP)
+}
+
+// Record добавляет запись в сеанс и останавливает его при достижении лимита
+func (r *Recorder) Record(entry Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.session
+	if s == nil {
+		return
+	}
+
+	var err error
+	if s.har != nil {
+		err = s.har.write(entry)
+	} else {
+		var line []byte
+		if line, err = json.Marshal(entry); err == nil {
+			_, err = s.writer.Write(append(line, '\n'))
+		}
+	}
+	if err != nil {
+		r.log.Errorf("❌ Failed to write capture entry to %s: %v", s.path, err)
+		return
+	}
+	s.count++
+
+	if s.count >= s.opts.MaxEntries {
+		r.log.Infof("🎥 Capture stopped: %d entries recorded", s.count)
+		r.stopLocked()
+	}
+}
+
+func (r *Recorder) stopLocked() (Status, error) {
+	s := r.session
+	r.active.Store(false)
+	r.session = nil
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+
+	status := s.status()
+	status.Active = false
+	status.Deadline = nil
+	r.last = status
+
+	var err error
+	if s.har != nil {
+		err = s.har.close()
+	}
+	if flushErr := s.writer.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		r.log.Errorf("❌ Failed to write capture %s: %v", s.path, err)
+		return status, err
+	}
+
+	r.log.Infof("🎥 Capture saved: %s (%d entries)", s.path, s.count)
+	return status, nil
+}
+
+func (r *Recorder) statusLocked() Status {
+	return r.session.status()
+}
+
+func (s *session) status() Status {
+	started := s.started
+	filter := s.opts.Filter
+	status := Status{
+		Active:     true,
+		File:       s.path,
+		Format:     s.opts.Format,
+		Entries:    s.count,
+		MaxEntries: s.opts.MaxEntries,
+		StartedAt:  &started,
+		Filter:     &filter,
+	}
+	if s.opts.MaxDuration > 0 {
+		deadline := s.started.Add(s.opts.MaxDuration)
+		status.MaxDuration = s.opts.MaxDuration.String()
+		status.Deadline = &deadline
+	}
+	return status
+}
+
+func (r *Recorder) withDefaults(opts Options) Options {
+	if opts.Dir == "" {
+		opts.Dir = r.defaults.Dir
+	}
+	if opts.Format == "" {
+		opts.Format = r.defaults.Format
+	}
+	// Лимиты из конфигурации — потолок: через /capture их можно только уменьшить
+	if opts.MaxEntries <= 0 || opts.MaxEntries > r.defaults.MaxEntries {
+		opts.MaxEntries = r.defaults.MaxEntries
+	}
+	if opts.MaxDuration <= 0 || (r.defaults.MaxDuration > 0 && opts.MaxDuration > r.defaults.MaxDuration) {
+		opts.MaxDuration = r.defaults.MaxDuration
+	}
+	if len(opts.Routes) == 0 && len(opts.Clients) == 0 && len(opts.Headers) == 0 {
+		opts.Filter = r.defaults.Filter
+	}
+	opts.Format = strings.ToLower(opts.Format)
+	return opts
+}
+
+// harWriter пишет HAR по мере поступления записей: заголовок — при открытии,
+// закрывающие скобки — в close
+type harWriter struct {
+	w     *bufio.Writer
+	count int
+}
+
+func newHARWriter(w *bufio.Writer) (*harWriter, error) {
+	creator, err := json.Marshal(HARCreator{Name: creatorName, Version: creatorVersion})
+	if err != nil {
+		return nil, err
+	}
+	_, err = fmt.Fprintf(w, "{\n  \"log\": {\n    \"version\": \"1.2\",\n    \"creator\": %s,\n    \"entries\": [", creator)
+	return &harWriter{w: w}, err
+}
+
+func (h *harWriter) write(entry Entry) error {
+	data, err := json.MarshalIndent(entry, "      ", "  ")
+	if err != nil {
+		return err
+	}
+	sep := "\n      "
+	if h.count > 0 {
+		sep = "," + sep
+	}
+	if _, err := h.w.WriteString(sep); err != nil {
+		return err
+	}
+	if _, err := h.w.Write(data); err != nil {
+		return err
+	}
+	h.count++
+	return nil
+}
+
+func (h *harWriter) close() error {
+	tail := "]\n  }\n}\n"
+	if h.count > 0 {
+		tail = "\n    " + tail
+	}
+	_, err := h.w.WriteString(tail)
+	return err
+}
+
+func (f Filter) matches(req *http.Request, route, clientIP string) bool {
+	return f.matchesRoute(route) && f.matchesClient(clientIP) && f.matchesHeader(req)
+}
+
+func (f Filter) matchesRoute(route string) bool {
+	if len(f.Routes) == 0 {
+		return true
+	}
+	for _, r := range f.Routes {
+		if r == route {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Filter) matchesClient(clientIP string) bool {
+	if len(f.Clients) == 0 {
+		return true
+	}
+	ip := net.ParseIP(clientIP)
+	for _, c := range f.Clients {
+		if c == clientIP {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(c); err == nil && ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Filter) matchesHeader(req *http.Request) bool {
+	if len(f.Headers) == 0 {
+		return true
+	}
+	for _, h := range f.Headers {
+		name, value, hasValue := strings.Cut(h, ":")
+		got := req.Header.Values(strings.TrimSpace(name))
+		if !hasValue && len(got) > 0 {
+			return true
+		}
+		for _, v := range got {
+			if v == strings.TrimSpace(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+func newTestRecorder(t *testing.T, defaults Options) *Recorder {
+	t.Helper()
+	defaults.Dir = t.TempDir()
+	return NewRecorder(defaults, logger.New("test", logger.LevelInfo, logger.ModeDev))
+}
+
+func testEntry(id string) Entry {
+	return Entry{RequestID: id, Request: Request{Method: "GET", URL: "http://app/" + id}}
+}
+
+func readHAR(t *testing.T, path string) HAR {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var har HAR
+	if err := json.Unmarshal(data, &har); err != nil {
+		t.Fatalf("invalid HAR %s: %v", data, err)
+	}
+	return har
+}
+
+func TestHARIsStreamedAndValid(t *testing.T) {
+	r := newTestRecorder(t, Options{})
+	status, err := r.Start(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Record(testEntry("a"))
+	r.Record(testEntry("b"))
+	r.mu.Lock()
+	r.session.writer.Flush()
+	r.mu.Unlock()
+	if data, _ := os.ReadFile(status.File); !strings.Contains(string(data), `"_requestId": "b"`) {
+		t.Errorf("entries are not written to disk while capture is active: %s", data)
+	}
+
+	if _, err := r.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	har := readHAR(t, status.File)
+	if har.Log.Version != "1.2" || har.Log.Creator.Name != creatorName || len(har.Log.Entries) != 2 {
+		t.Fatalf("unexpected HAR: %+v", har.Log)
+	}
+	if har.Log.Entries[1].RequestID != "b" {
+		t.Errorf("entries out of order: %+v", har.Log.Entries)
+	}
+}
+
+func TestEmptyHAR(t *testing.T) {
+	r := newTestRecorder(t, Options{})
+	status, _ := r.Start(Options{})
+	r.Stop()
+	if har := readHAR(t, status.File); har.Log.Entries == nil || len(har.Log.Entries) != 0 {
+		t.Errorf("expected empty entries array, got %+v", har.Log.Entries)
+	}
+}
+
+func TestJSONLStopsAtMaxEntries(t *testing.T) {
+	r := newTestRecorder(t, Options{Format: FormatJSONL, MaxEntries: 2})
+	status, err := r.Start(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		r.Record(testEntry(id))
+	}
+	if r.Active() {
+		t.Fatal("capture still active after max_entries")
+	}
+
+	f, _ := os.Open(status.File)
+	defer f.Close()
+	var lines int
+	for sc := bufio.NewScanner(f); sc.Scan(); lines++ {
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("invalid line: %v", err)
+		}
+	}
+	if lines != 2 {
+		t.Errorf("got %d lines, want 2", lines)
+	}
+}
+
+func TestStartCapsLimitsAtConfiguredMaximum(t *testing.T) {
+	r := newTestRecorder(t, Options{MaxEntries: 100, MaxDuration: time.Minute})
+
+	status, err := r.Start(Options{MaxEntries: 1 << 30, MaxDuration: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Stop()
+	if status.MaxEntries != 100 || status.MaxDuration != "1m0s" {
+		t.Errorf("limits not capped: %d, %s", status.MaxEntries, status.MaxDuration)
+	}
+
+	status, _ = r.Start(Options{MaxEntries: 10, MaxDuration: time.Second})
+	r.Stop()
+	if status.MaxEntries != 10 || status.MaxDuration != "1s" {
+		t.Errorf("smaller limits not kept: %d, %s", status.MaxEntries, status.MaxDuration)
+	}
+}
+
+func TestStartErrors(t *testing.T) {
+	r := newTestRecorder(t, Options{})
+	if _, err := r.Start(Options{Format: "xml"}); err == nil {
+		t.Error("unknown format accepted")
+	}
+	if _, err := r.Start(Options{Filter: Filter{Clients: []string{"not-an-ip"}}}); err == nil {
+		t.Error("invalid client accepted")
+	}
+	if _, err := r.Start(Options{}); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+	if _, err := r.Start(Options{}); err == nil {
+		t.Error("second session accepted")
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	f := Filter{Routes: []string{"api"}, Clients: []string{"10.0.0.0/8", "192.168.1.5"}, Headers: []string{"X-Debug: 1", "X-Trace"}}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Debug", "1")
+
+	tests := []struct {
+		route, ip string
+		want      bool
+	}{
+		{"api", "10.1.2.3", true},
+		{"api", "192.168.1.5", true},
+		{"web", "10.1.2.3", false},
+		{"api", "172.16.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := f.matches(req, tt.route, tt.ip); got != tt.want {
+			t.Errorf("matches(%s, %s) = %v, want %v", tt.route, tt.ip, got, tt.want)
+		}
+	}
+
+	req.Header.Set("X-Debug", "0")
+	if f.matches(req, "api", "10.1.2.3") {
+		t.Error("header value mismatch matched")
+	}
+	req.Header.Set("X-Trace", "anything")
+	if !f.matches(req, "api", "10.1.2.3") {
+		t.Error("header name filter did not match")
+	}
+}
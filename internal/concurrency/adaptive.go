@@ -0,0 +1,166 @@
+package concurrency
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Алгоритмы адаптивного лимита
+const (
+	AlgorithmAIMD     = "aimd"
+	AlgorithmGradient = "gradient"
+)
+
+// AdaptiveOptions параметры лимита, который подстраивается по задержке и ошибкам upstream
+type AdaptiveOptions struct {
+	Algorithm    string // aimd или gradient, по умолчанию gradient
+	InitialLimit int    // по умолчанию 20
+	MinLimit     int    // по умолчанию 1
+	MaxLimit     int    // по умолчанию 1000
+
+	// Backoff во сколько раз уменьшать лимит при ошибке или перегрузке, по умолчанию 0.9
+	Backoff float64
+	// LatencyThreshold aimd: ответ медленнее считается перегрузкой, по умолчанию 1s
+	LatencyThreshold time.Duration
+	// Tolerance gradient: во сколько раз задержка может превышать базовую,
+	// прежде чем лимит начнёт уменьшаться; по умолчанию 1.5
+	Tolerance float64
+}
+
+func (o *AdaptiveOptions) applyDefaults() error {
+	if o.Algorithm == "" {
+		o.Algorithm = AlgorithmGradient
+	}
+	if o.Algorithm != AlgorithmAIMD && o.Algorithm != AlgorithmGradient {
+		return fmt.Errorf("unknown adaptive algorithm %q (aimd or gradient)", o.Algorithm)
+	}
+	if o.MinLimit <= 0 {
+		o.MinLimit = 1
+	}
+	if o.MaxLimit <= 0 {
+		o.MaxLimit = 1000
+	}
+	if o.MaxLimit < o.MinLimit {
+		return fmt.Errorf("max_limit %d is less than min_limit %d", o.MaxLimit, o.MinLimit)
+	}
+	if o.InitialLimit <= 0 {
+		o.InitialLimit = 20
+	}
+	o.InitialLimit = min(max(o.InitialLimit, o.MinLimit), o.MaxLimit)
+	if o.Backoff <= 0 || o.Backoff >= 1 {
+		o.Backoff = 0.9
+	}
+	if o.LatencyThreshold <= 0 {
+		o.LatencyThreshold = time.Second
+	}
+	if o.Tolerance < 1 {
+		o.Tolerance = 1.5
+	}
+	return nil
+}
+
+// probeRounds через сколько раундов gradient заново измеряет базовую задержку
+const probeRounds = 100
+
+// probeFactor во сколько раз лимит снижается для измерения базовой задержки.
+// Снижение пропорционально лимиту: очередь в upstream уходит, а пропускная
+// способность при большом лимите не проваливается
+const probeFactor = 0.5
+
+// adaptiveLimit лимит одного upstream. Лимит пересчитывается раз в раунд — после
+// стольких ответов, каков лимит, — иначе он успевал бы сильно измениться до того,
+// как скажется на задержке. AIMD прибавляет единицу, если в раунде не было ошибок
+// и медленных ответов, иначе умножает на Backoff. Gradient сравнивает задержку
+// за раунд с базовой (наименьшей): пока они близки, лимит растёт на sqrt(limit),
+// а рост задержки — признак очереди в upstream — уменьшает его
+type adaptiveLimit struct {
+	mu    sync.Mutex
+	opts  AdaptiveOptions
+	limit float64
+
+	rtt     time.Duration // средняя за последний раунд
+	baseRTT time.Duration // наименьшая средняя за раунд с последнего измерения
+
+	// При постоянной очереди в upstream базовая задержка не видна, поэтому gradient
+	// время от времени снижает лимит в 1/probeFactor раз и измеряет её заново. Первый
+	// раунд после снижения не учитывается: в нём завершаются запросы, начатые раньше
+	sinceProbe int
+	skipRound  bool
+
+	// Текущий раунд
+	round         int
+	roundRTT      time.Duration
+	roundDropped  bool
+	roundInFlight int
+}
+
+func newAdaptiveLimit(opts AdaptiveOptions) *adaptiveLimit {
+	return &adaptiveLimit{opts: opts, limit: float64(opts.InitialLimit)}
+}
+
+func (a *adaptiveLimit) current() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return int(a.limit)
+}
+
+func (a *adaptiveLimit) rtts() (rtt, base time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.rtt, a.baseRTT
+}
+
+// observe учитывает ответ: rtt — задержка upstream, inFlight — запросов в обработке,
+// dropped — ошибка или ответ о перегрузке. Возвращает, изменился ли целый лимит
+func (a *adaptiveLimit) observe(rtt time.Duration, inFlight int, dropped bool) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.opts.Algorithm == AlgorithmAIMD && rtt > a.opts.LatencyThreshold {
+		dropped = true
+	}
+	a.round++
+	a.roundRTT += rtt
+	a.roundDropped = a.roundDropped || dropped
+	a.roundInFlight = max(a.roundInFlight, inFlight)
+	if float64(a.round) < a.limit {
+		return false
+	}
+
+	before := int(a.limit)
+	a.rtt = a.roundRTT / time.Duration(a.round)
+	if a.skipRound {
+		a.skipRound = false
+	} else if a.baseRTT == 0 || a.rtt < a.baseRTT {
+		a.baseRTT = a.rtt
+	}
+
+	// Лимит растёт, только если он действительно используется: иначе при слабой
+	// нагрузке он ушёл бы в максимум и перестал бы защищать upstream
+	utilized := float64(a.roundInFlight)*2 >= a.limit
+
+	switch {
+	case a.roundDropped:
+		a.limit *= a.opts.Backoff
+	case !utilized:
+	case a.opts.Algorithm == AlgorithmAIMD:
+		a.limit++
+	case a.baseRTT > 0:
+		gradient := math.Max(0.5, math.Min(1, a.opts.Tolerance*float64(a.baseRTT)/float64(a.rtt)))
+		a.limit = a.limit*gradient + math.Sqrt(a.limit)
+	}
+
+	if a.opts.Algorithm == AlgorithmGradient {
+		if a.sinceProbe++; a.sinceProbe >= probeRounds {
+			// Снижение считается от действующего лимита, а не от прироста за этот раунд
+			a.limit = math.Min(a.limit, float64(a.opts.MaxLimit)) * probeFactor
+			a.baseRTT, a.sinceProbe, a.skipRound = 0, 0, true
+		}
+	}
+	a.round, a.roundRTT, a.roundDropped, a.roundInFlight = 0, 0, false, 0
+
+	a.limit = math.Min(math.Max(a.limit, float64(a.opts.MinLimit)), float64(a.opts.MaxLimit))
+	return int(a.limit) != before
+}
@@ -0,0 +1,216 @@
+package concurrency
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"access-proxy/internal/reqctx"
+)
+
+func newTestAdaptive(t *testing.T, opts AdaptiveOptions) *adaptiveLimit {
+	t.Helper()
+	if err := opts.applyDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	return newAdaptiveLimit(opts)
+}
+
+// round проводит один раунд ответов с одинаковой задержкой
+func round(a *adaptiveLimit, rtt time.Duration, inFlight int, dropped bool) {
+	for {
+		a.observe(rtt, inFlight, dropped)
+		if a.round == 0 {
+			return
+		}
+	}
+}
+
+func TestAdaptiveDefaults(t *testing.T) {
+	opts := AdaptiveOptions{InitialLimit: 5000, Backoff: 1.5, Tolerance: 0.5}
+	if err := opts.applyDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Algorithm != AlgorithmGradient || opts.MinLimit != 1 || opts.MaxLimit != 1000 ||
+		opts.InitialLimit != 1000 || opts.Backoff != 0.9 || opts.Tolerance != 1.5 || opts.LatencyThreshold != time.Second {
+		t.Errorf("defaults: %+v", opts)
+	}
+
+	for _, bad := range []AdaptiveOptions{
+		{Algorithm: "vegas"},
+		{MinLimit: 10, MaxLimit: 5},
+	} {
+		if err := bad.applyDefaults(); err == nil {
+			t.Errorf("%+v accepted", bad)
+		}
+	}
+}
+
+func TestAIMD(t *testing.T) {
+	a := newTestAdaptive(t, AdaptiveOptions{Algorithm: AlgorithmAIMD, InitialLimit: 10, MaxLimit: 12, LatencyThreshold: 100 * time.Millisecond})
+
+	round(a, 10*time.Millisecond, 10, false)
+	if a.current() != 11 {
+		t.Fatalf("after good round: %d", a.current())
+	}
+
+	// Ответ медленнее порога — перегрузка
+	round(a, 200*time.Millisecond, 11, false)
+	if a.current() != 9 { // 11 * 0.9
+		t.Fatalf("after slow round: %d", a.current())
+	}
+	round(a, 10*time.Millisecond, 9, true)
+	if a.current() != 8 {
+		t.Fatalf("after dropped round: %d", a.current())
+	}
+
+	// При слабой нагрузке лимит не растёт
+	round(a, 10*time.Millisecond, 1, false)
+	if a.current() != 8 {
+		t.Fatalf("after idle round: %d", a.current())
+	}
+
+	for i := 0; i < 10; i++ {
+		round(a, 10*time.Millisecond, 20, false)
+	}
+	if a.current() != 12 {
+		t.Errorf("max limit not applied: %d", a.current())
+	}
+}
+
+func TestAIMDMinLimit(t *testing.T) {
+	a := newTestAdaptive(t, AdaptiveOptions{Algorithm: AlgorithmAIMD, InitialLimit: 3, MinLimit: 2, Backoff: 0.5})
+	for i := 0; i < 5; i++ {
+		round(a, time.Millisecond, 3, true)
+	}
+	if a.current() != 2 {
+		t.Errorf("limit = %d, want min 2", a.current())
+	}
+}
+
+func TestGradient(t *testing.T) {
+	a := newTestAdaptive(t, AdaptiveOptions{InitialLimit: 16, MaxLimit: 1000})
+
+	// Задержка держится у базовой — лимит растёт на sqrt(limit)
+	round(a, 10*time.Millisecond, 16, false)
+	if a.current() != 20 {
+		t.Fatalf("after stable round: %d", a.current())
+	}
+	if rtt, base := a.rtts(); rtt != 10*time.Millisecond || base != 10*time.Millisecond {
+		t.Errorf("rtt = %v, base = %v", rtt, base)
+	}
+
+	// Задержка выросла вчетверо: лимит уменьшается вдвое и прибавляет sqrt
+	before := a.limit
+	round(a, 40*time.Millisecond, 20, false)
+	want := before*0.5 + math.Sqrt(before)
+	if a.limit != want {
+		t.Errorf("after latency growth: %.2f, want %.2f", a.limit, want)
+	}
+	if _, base := a.rtts(); base != 10*time.Millisecond {
+		t.Errorf("base rtt changed to %v", base)
+	}
+}
+
+func TestGradientProbe(t *testing.T) {
+	a := newTestAdaptive(t, AdaptiveOptions{InitialLimit: 4, MaxLimit: 100})
+	for i := 0; i < probeRounds-1; i++ {
+		round(a, 10*time.Millisecond, 100, false)
+	}
+	if a.current() != 100 {
+		t.Fatalf("limit = %d before probe", a.current())
+	}
+
+	// Раунд измерения снижает лимит вдвое и сбрасывает базовую задержку
+	round(a, 10*time.Millisecond, 100, false)
+	if a.current() != 50 {
+		t.Fatalf("limit = %d after probe, want 50", a.current())
+	}
+	if _, base := a.rtts(); base != 0 {
+		t.Errorf("base rtt not reset: %v", base)
+	}
+
+	// Первый раунд после снижения не задаёт базовую задержку
+	round(a, 50*time.Millisecond, 50, false)
+	if _, base := a.rtts(); base != 0 {
+		t.Errorf("skipped round set base rtt to %v", base)
+	}
+	round(a, 20*time.Millisecond, 50, false)
+	if _, base := a.rtts(); base != 20*time.Millisecond {
+		t.Errorf("base rtt = %v, want 20ms", base)
+	}
+}
+
+func TestGradientProbeIsProportional(t *testing.T) {
+	// Большой лимит при измерении не проваливается до sqrt(limit)
+	a := newTestAdaptive(t, AdaptiveOptions{InitialLimit: 400, MaxLimit: 400})
+	for i := 0; i < probeRounds; i++ {
+		round(a, 10*time.Millisecond, 400, false)
+	}
+	if a.current() != 200 {
+		t.Errorf("limit = %d after probe, want 200", a.current())
+	}
+}
+
+func TestGroupAdaptiveLimit(t *testing.T) {
+	g := newGroup(0, nil, 10)
+	opts := AdaptiveOptions{Algorithm: AlgorithmAIMD, InitialLimit: 2}
+	opts.applyDefaults()
+	g.adaptiveOpts = &opts
+	changes := map[string]int{}
+	g.onLimit = func(key string, limit int) { changes[key] = limit }
+
+	ctx := context.Background()
+	r1, _ := g.acquire(ctx, "api", func() {})
+	r2, _ := g.acquire(ctx, "api", func() {})
+	if changes["api"] != 2 {
+		t.Fatalf("initial limit not reported: %v", changes)
+	}
+
+	g.observe("api", time.Millisecond, false)
+	g.observe("api", time.Millisecond, false)
+	if changes["api"] != 3 {
+		t.Fatalf("limit change not reported: %v", changes)
+	}
+
+	// Новый лимит сразу действует на семафор ключа
+	r3, err := g.acquire(ctx, "api", func() { t.Error("third request queued") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	r1()
+	r2()
+	r3()
+
+	g.observe("unknown", time.Millisecond, true)
+	if _, ok := changes["unknown"]; ok {
+		t.Error("observe created a limit for an unknown key")
+	}
+}
+
+func TestLimiterObservesUpstream(t *testing.T) {
+	l := newTestLimiter(t, Options{Adaptive: &AdaptiveOptions{Algorithm: AlgorithmAIMD, InitialLimit: 1}})
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := reqctx.FromRequest(r)
+		info.Upstream = "http://backend"
+		info.UpstreamDuration = time.Millisecond
+		info.Status = http.StatusServiceUnavailable
+	})
+
+	for i := 0; i < 3; i++ {
+		serve(l.Middleware(upstream), "api", "10.0.0.1", "")
+	}
+	st := l.Status()
+	if st.Adaptive != AlgorithmAIMD || len(st.Routes) != 1 || st.Routes[0].Limit != 1 || st.Routes[0].RTT != "1ms" {
+		t.Errorf("status: %+v", st)
+	}
+
+	// Без обращения к upstream (например, ответ из кеша) задержка не учитывается
+	local := newTestLimiter(t, Options{Adaptive: &AdaptiveOptions{Algorithm: AlgorithmAIMD, InitialLimit: 1}})
+	rec := serve(local.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})), "api", "10.0.0.1", "")
+	if st := local.Status(); st.Routes[0].RTT != "0s" || rec.Code != http.StatusOK {
+		t.Errorf("status without upstream: %+v", st.Routes)
+	}
+}
@@ -0,0 +1,240 @@
+package concurrency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"access-proxy/internal/metrics"
+	"access-proxy/internal/reqctx"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+// Области ограничения, они же метки метрик
+const (
+	ScopeClient = "client"
+	ScopeRoute  = "route"
+	ScopeGlobal = "global"
+)
+
+// Options ограничения одновременных запросов; 0 — без ограничения
+type Options struct {
+	Global    int
+	PerClient int            // по principal, иначе по IP
+	PerRoute  int            // для маршрутов без своего лимита
+	Routes    map[string]int // лимиты отдельных маршрутов
+
+	MaxQueue     int           // сколько запросов может ждать у каждого ограничения
+	QueueTimeout time.Duration // сколько запрос может ждать всего, по умолчанию 5s
+	RetryAfter   time.Duration // подсказка клиенту при отказе, по умолчанию 1s
+	// UpstreamRoutes маршруты, которые уходят в upstream; служебные эндпоинты
+	// прокси не ограничиваются
+	UpstreamRoutes []string
+
+	// Adaptive не nil — лимит каждого маршрута подбирается по задержке и ошибкам
+	// upstream вместо PerRoute и Routes
+	Adaptive *AdaptiveOptions
+}
+
+type scope struct {
+	name  string
+	group *group
+	key   func(info *reqctx.Info) string
+}
+
+// Limiter ограничивает число запросов в обработке: сначала для клиента, затем
+// для маршрута и всего прокси. Места занимаются всегда в этом порядке, поэтому
+// запросы не могут ждать друг друга по кругу
+type Limiter struct {
+	scopes       []scope
+	clients      *group
+	routes       *group
+	global       *group
+	opts         Options
+	upstream     map[string]bool
+	queueTimeout time.Duration
+	retryAfter   time.Duration
+	metrics      *metrics.ProxyMetrics
+	log          logger.Logger
+}
+
+// New создаёт ограничитель; m может быть nil
+func New(opts Options, m *metrics.ProxyMetrics, log logger.Logger) (*Limiter, error) {
+	if opts.QueueTimeout <= 0 {
+		opts.QueueTimeout = 5 * time.Second
+	}
+	if opts.RetryAfter <= 0 {
+		opts.RetryAfter = time.Second
+	}
+
+	if opts.Adaptive != nil {
+		adaptive := *opts.Adaptive
+		if err := adaptive.applyDefaults(); err != nil {
+			return nil, err
+		}
+		opts.Adaptive = &adaptive
+	}
+
+	l := &Limiter{
+		opts:         opts,
+		upstream:     make(map[string]bool, len(opts.UpstreamRoutes)),
+		queueTimeout: opts.QueueTimeout,
+		retryAfter:   opts.RetryAfter,
+		metrics:      m,
+		log:          log,
+	}
+	for _, route := range opts.UpstreamRoutes {
+		l.upstream[route] = true
+	}
+
+	if opts.PerClient > 0 {
+		l.clients = newGroup(opts.PerClient, nil, opts.MaxQueue)
+		l.scopes = append(l.scopes, scope{ScopeClient, l.clients,
+			func(info *reqctx.Info) string { return info.Identity() }})
+	}
+	if opts.PerRoute > 0 || len(opts.Routes) > 0 || opts.Adaptive != nil {
+		l.routes = newGroup(opts.PerRoute, opts.Routes, opts.MaxQueue)
+		if opts.Adaptive != nil {
+			l.routes.adaptiveOpts = opts.Adaptive
+			l.routes.onLimit = func(route string, limit int) {
+				if m != nil {
+					m.ConcurrencyLimitChanged(route, limit)
+				}
+			}
+		}
+		l.scopes = append(l.scopes, scope{ScopeRoute, l.routes,
+			func(info *reqctx.Info) string { return info.Route }})
+	}
+	if opts.Global > 0 {
+		l.global = newGroup(opts.Global, nil, opts.MaxQueue)
+		l.scopes = append(l.scopes, scope{ScopeGlobal, l.global,
+			func(*reqctx.Info) string { return "" }})
+	}
+	return l, nil
+}
+
+// Status текущие лимиты и загрузка
+type Status struct {
+	Global        int         `json:"global"`
+	InFlight      int         `json:"in_flight"` // под общим лимитом
+	PerClient     int         `json:"per_client"`
+	ActiveClients int         `json:"active_clients"` // клиенты с запросами в обработке или в очереди
+	PerRoute      int         `json:"per_route"`
+	Adaptive      string      `json:"adaptive,omitempty"` // алгоритм адаптивного лимита маршрутов
+	Routes        []KeyStatus `json:"routes"`
+}
+
+func (l *Limiter) Status() Status {
+	st := Status{
+		Global:    l.opts.Global,
+		PerClient: l.opts.PerClient,
+		PerRoute:  l.opts.PerRoute,
+		Routes:    []KeyStatus{},
+	}
+	if l.opts.Adaptive != nil {
+		st.Adaptive = l.opts.Adaptive.Algorithm
+	}
+	if l.global != nil {
+		st.InFlight = l.global.inFlight("")
+	}
+	if l.clients != nil {
+		st.ActiveClients = l.clients.size()
+	}
+	if l.routes != nil {
+		st.Routes = l.routes.status()
+	}
+	return st
+}
+
+// Middleware возвращает HTTP middleware, которое держит места на время обработки запроса
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := reqctx.FromRequest(r)
+		if info == nil || !l.upstream[info.Route] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), l.queueTimeout)
+		defer cancel()
+
+		var held []func()
+		defer func() {
+			for i := len(held) - 1; i >= 0; i-- {
+				held[i]()
+			}
+		}()
+
+		for _, s := range l.scopes {
+			release, err := l.acquire(ctx, s, info)
+			if err != nil {
+				l.reject(w, r, s.name, err)
+				return
+			}
+			if release != nil {
+				held = append(held, release)
+			}
+		}
+		next.ServeHTTP(w, r)
+
+		if l.opts.Adaptive != nil && info.Upstream != "" {
+			l.routes.observe(info.Route, info.UpstreamDuration, overloaded(info))
+		}
+	})
+}
+
+// overloaded ответ говорит о перегрузке upstream: ошибка соединения или таймаут,
+// либо upstream сам отказал из-за нагрузки. Отменённый клиентом запрос и прочие
+// ошибки (DNS, TLS) о нагрузке ничего не говорят
+func overloaded(info *reqctx.Info) bool {
+	switch info.UpstreamError {
+	case "timeout", "connection_refused", "connection_reset":
+		return true
+	}
+	switch info.Status {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (l *Limiter) acquire(ctx context.Context, s scope, info *reqctx.Info) (func(), error) {
+	start := time.Now()
+	queued := false
+	release, err := s.group.acquire(ctx, s.key(info), func() {
+		queued = true
+		if l.metrics != nil {
+			l.metrics.ConcurrencyQueued(s.name)
+		}
+	})
+	if queued && l.metrics != nil {
+		l.metrics.ConcurrencyDequeued(s.name, time.Since(start))
+	}
+	return release, err
+}
+
+func (l *Limiter) reject(w http.ResponseWriter, r *http.Request, scope string, err error) {
+	reason := "timeout"
+	if errors.Is(err, errQueueFull) {
+		reason = "queue_full"
+	}
+	if l.metrics != nil {
+		l.metrics.ConcurrencyRejected(scope, reason)
+	}
+	l.log.Warnf("🚦 Too many concurrent requests (%s, %s): %s %s", scope, reason, r.Method, r.URL.Path)
+	reqctx.Deny(r, "concurrency_"+reason)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(l.retryAfter.Seconds()))))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":   "too_many_concurrent_requests",
+		"message": "Too many concurrent requests, retry later",
+		"scope":   scope,
+	})
+}
@@ -0,0 +1,173 @@
+package concurrency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"access-proxy/internal/reqctx"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+func newTestLimiter(t *testing.T, opts Options) *Limiter {
+	t.Helper()
+	if opts.UpstreamRoutes == nil {
+		opts.UpstreamRoutes = []string{"api", "slow"}
+	}
+	l, err := New(opts, nil, logger.New("test", logger.LevelInfo, logger.ModeDev))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// blockingHandler держит запросы, пока не закрыт release
+type blockingHandler struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func newBlockingHandler() *blockingHandler {
+	return &blockingHandler{entered: make(chan struct{}, 100), release: make(chan struct{})}
+}
+
+func (h *blockingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.entered <- struct{}{}
+	<-h.release
+}
+
+func serve(h http.Handler, route, ip, principal string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	info := &reqctx.Info{Route: route, ClientIP: ip, Principal: principal, AuthMethod: "api_key"}
+	r = r.WithContext(reqctx.NewContext(r.Context(), info))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestLimiterPerClient(t *testing.T) {
+	l := newTestLimiter(t, Options{PerClient: 1, QueueTimeout: 20 * time.Millisecond, RetryAfter: 2 * time.Second})
+	h := newBlockingHandler()
+	handler := l.Middleware(h)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- serve(handler, "api", "10.0.0.1", "") }()
+	<-h.entered
+
+	// Тот же клиент ждёт в очереди до таймаута, другой проходит
+	rec := serve(handler, "api", "10.0.0.1", "")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "2" ||
+		!strings.Contains(rec.Body.String(), `"scope":"client"`) {
+		t.Errorf("same client: %d %v %s", rec.Code, rec.Header(), rec.Body)
+	}
+	go func() { done <- serve(handler, "api", "10.0.0.2", "") }()
+	<-h.entered
+
+	// Служебные маршруты не ограничиваются
+	if rec := serve(l.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})), "health", "10.0.0.1", ""); rec.Code != http.StatusOK {
+		t.Errorf("health: %d", rec.Code)
+	}
+
+	if st := l.Status(); st.ActiveClients != 2 {
+		t.Errorf("active clients = %d, want 2", st.ActiveClients)
+	}
+	close(h.release)
+	<-done
+	<-done
+	if st := l.Status(); st.ActiveClients != 0 {
+		t.Errorf("active clients after release = %d", st.ActiveClients)
+	}
+}
+
+func TestLimiterQueueFull(t *testing.T) {
+	l := newTestLimiter(t, Options{Global: 1, MaxQueue: 1, QueueTimeout: time.Second})
+	h := newBlockingHandler()
+	handler := l.Middleware(h)
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- serve(handler, "api", "10.0.0.1", "").Code
+		}()
+	}
+	<-h.entered
+	l.global.mu.Lock()
+	sem := l.global.sems[""]
+	l.global.mu.Unlock()
+	waitQueued(t, sem, 1)
+
+	rec := serve(handler, "api", "10.0.0.3", "")
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"scope":"global"`) {
+		t.Errorf("queue full: %d %s", rec.Code, rec.Body)
+	}
+	if st := l.Status(); st.InFlight != 1 {
+		t.Errorf("in flight = %d", st.InFlight)
+	}
+
+	close(h.release)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("queued request got %d", code)
+		}
+	}
+}
+
+func TestLimiterRoutes(t *testing.T) {
+	l := newTestLimiter(t, Options{PerRoute: 1, Routes: map[string]int{"slow": 2}, QueueTimeout: 10 * time.Millisecond})
+	h := newBlockingHandler()
+	handler := l.Middleware(h)
+
+	done := make(chan struct{}, 3)
+	for i := 0; i < 2; i++ {
+		go func() { serve(handler, "slow", "10.0.0.1", ""); done <- struct{}{} }()
+		<-h.entered
+	}
+	if rec := serve(handler, "slow", "10.0.0.2", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("slow over its limit: %d", rec.Code)
+	}
+	go func() { serve(handler, "api", "10.0.0.1", ""); done <- struct{}{} }()
+	<-h.entered
+
+	st := l.Status()
+	if len(st.Routes) != 2 || st.Routes[0].Key != "api" || st.Routes[0].InFlight != 1 ||
+		st.Routes[1].Key != "slow" || st.Routes[1].Limit != 2 || st.Routes[1].InFlight != 2 {
+		t.Errorf("status routes: %+v", st.Routes)
+	}
+	close(h.release)
+	for i := 0; i < 3; i++ {
+		<-done
+	}
+}
+
+func TestOverloaded(t *testing.T) {
+	tests := []struct {
+		info reqctx.Info
+		want bool
+	}{
+		{reqctx.Info{Status: http.StatusOK}, false},
+		{reqctx.Info{Status: http.StatusInternalServerError}, false},
+		{reqctx.Info{Status: http.StatusTooManyRequests}, true},
+		{reqctx.Info{Status: http.StatusServiceUnavailable}, true},
+		{reqctx.Info{Status: http.StatusGatewayTimeout}, true},
+		{reqctx.Info{Status: http.StatusBadGateway, UpstreamError: "connection_refused"}, true},
+		{reqctx.Info{Status: http.StatusBadGateway, UpstreamError: "connection_reset"}, true},
+		{reqctx.Info{Status: http.StatusGatewayTimeout, UpstreamError: "timeout"}, true},
+		// Клиент закрыл соединение: upstream здоров
+		{reqctx.Info{Status: http.StatusBadGateway, UpstreamError: "canceled"}, false},
+		{reqctx.Info{Status: http.StatusBadGateway, UpstreamError: "tls"}, false},
+	}
+	for _, tt := range tests {
+		if got := overloaded(&tt.info); got != tt.want {
+			t.Errorf("overloaded(%d, %q) = %t", tt.info.Status, tt.info.UpstreamError, got)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package concurrency
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// errQueueFull очередь ожидания заполнена
+var errQueueFull = errors.New("queue is full")
+
+// semaphore ограничивает число одновременных запросов; лишние ждут
+// в очереди FIFO ограниченной длины
+type semaphore struct {
+	mu       sync.Mutex
+	limit    int
+	maxQueue int
+	active   int
+	waiters  list.List // chan struct{}, закрывается, когда место передано ожидающему
+
+	users int // сколько запросов держат или ждут семафор, под блокировкой group
+}
+
+// acquire занимает место или ждёт его до отмены ctx. queued вызывается,
+// если запрос встал в очередь
+func (s *semaphore) acquire(ctx context.Context, queued func()) error {
+	s.mu.Lock()
+	if s.active < s.limit && s.waiters.Len() == 0 {
+		s.active++
+		s.mu.Unlock()
+		return nil
+	}
+	if s.waiters.Len() >= s.maxQueue {
+		s.mu.Unlock()
+		return errQueueFull
+	}
+	ready := make(chan struct{})
+	el := s.waiters.PushBack(ready)
+	s.mu.Unlock()
+	queued()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	select {
+	case <-ready:
+		// Место передано одновременно с отменой — отдаём его следующему
+		s.mu.Unlock()
+		s.release()
+	default:
+		s.waiters.Remove(el)
+		s.mu.Unlock()
+	}
+	return ctx.Err()
+}
+
+// release освобождает место; если есть ожидающие и лимит не уменьшился,
+// оно передаётся первому
+func (s *semaphore) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if front := s.waiters.Front(); front != nil && s.active <= s.limit {
+		s.waiters.Remove(front)
+		close(front.Value.(chan struct{}))
+		return
+	}
+	s.active--
+}
+
+// setLimit меняет лимит; новые места сразу отдаются ожидающим
+func (s *semaphore) setLimit(limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limit = limit
+	for s.active < s.limit && s.waiters.Len() > 0 {
+		front := s.waiters.Front()
+		s.waiters.Remove(front)
+		close(front.Value.(chan struct{}))
+		s.active++
+	}
+}
+
+func (s *semaphore) counts() (active, queued int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active, s.waiters.Len()
+}
+
+// group семафоры по ключу (клиент, маршрут). Семафор удаляется, когда его
+// никто не держит, поэтому память зависит только от числа активных клиентов
+type group struct {
+	mu       sync.Mutex
+	limit    int            // 0 — без ограничения
+	limits   map[string]int // лимиты отдельных ключей
+	maxQueue int
+	sems     map[string]*semaphore
+
+	// adaptiveOpts не nil — лимит каждого ключа подстраивается по ответам upstream
+	adaptiveOpts *AdaptiveOptions
+	adaptive     map[string]*adaptiveLimit
+	onLimit      func(key string, limit int)
+}
+
+func newGroup(limit int, limits map[string]int, maxQueue int) *group {
+	return &group{
+		limit:    limit,
+		limits:   limits,
+		maxQueue: maxQueue,
+		sems:     make(map[string]*semaphore),
+		adaptive: make(map[string]*adaptiveLimit),
+	}
+}
+
+// limitFor лимит ключа; вызывается под g.mu
+func (g *group) limitFor(key string) int {
+	if g.adaptiveOpts != nil {
+		a, ok := g.adaptive[key]
+		if !ok {
+			a = newAdaptiveLimit(*g.adaptiveOpts)
+			g.adaptive[key] = a
+			if g.onLimit != nil {
+				g.onLimit(key, a.current())
+			}
+		}
+		return a.current()
+	}
+	if limit, ok := g.limits[key]; ok {
+		return limit
+	}
+	return g.limit
+}
+
+// acquire занимает место для ключа и возвращает функцию освобождения;
+// nil — для ключа нет ограничения
+func (g *group) acquire(ctx context.Context, key string, queued func()) (func(), error) {
+	g.mu.Lock()
+	limit := g.limitFor(key)
+	if limit <= 0 {
+		g.mu.Unlock()
+		return nil, nil
+	}
+	s, ok := g.sems[key]
+	if !ok {
+		s = &semaphore{limit: limit, maxQueue: g.maxQueue}
+		g.sems[key] = s
+	}
+	s.users++
+	g.mu.Unlock()
+
+	if err := s.acquire(ctx, queued); err != nil {
+		g.done(key, s)
+		return nil, err
+	}
+	return func() {
+		s.release()
+		g.done(key, s)
+	}, nil
+}
+
+func (g *group) done(key string, s *semaphore) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s.users--
+	if s.users == 0 {
+		delete(g.sems, key)
+	}
+}
+
+// observe учитывает ответ upstream для адаптивного лимита ключа. Вызывается,
+// пока запрос ещё держит место, поэтому он входит в число запросов в обработке
+func (g *group) observe(key string, rtt time.Duration, dropped bool) {
+	g.mu.Lock()
+	a := g.adaptive[key]
+	g.mu.Unlock()
+	if a == nil {
+		return
+	}
+
+	if !a.observe(rtt, g.inFlight(key), dropped) {
+		return
+	}
+
+	// Берётся текущее значение: параллельные ответы могли изменить его ещё раз
+	g.mu.Lock()
+	limit := a.current()
+	if s := g.sems[key]; s != nil {
+		s.setLimit(limit)
+	}
+	g.mu.Unlock()
+	if g.onLimit != nil {
+		g.onLimit(key, limit)
+	}
+}
+
+// inFlight число запросов в обработке для ключа
+func (g *group) inFlight(key string) int {
+	g.mu.Lock()
+	s := g.sems[key]
+	g.mu.Unlock()
+	if s == nil {
+		return 0
+	}
+	active, _ := s.counts()
+	return active
+}
+
+// size число ключей с запросами в обработке или в очереди
+func (g *group) size() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.sems)
+}
+
+// KeyStatus текущее состояние ограничения для ключа
+type KeyStatus struct {
+	Key      string `json:"key"`
+	Limit    int    `json:"limit"`
+	InFlight int    `json:"in_flight"`
+	Queued   int    `json:"queued"`
+
+	// Только для адаптивного лимита
+	RTT     string `json:"rtt,omitempty"`      // средняя задержка upstream за последний раунд
+	BaseRTT string `json:"base_rtt,omitempty"` // базовая (наименьшая) задержка upstream
+}
+
+// status ключи с отдельным лимитом, адаптивным лимитом или запросами в обработке
+func (g *group) status() []KeyStatus {
+	g.mu.Lock()
+	keys := make(map[string]bool, len(g.limits)+len(g.adaptive)+len(g.sems))
+	for key := range g.limits {
+		keys[key] = true
+	}
+	for key := range g.adaptive {
+		keys[key] = true
+	}
+	for key := range g.sems {
+		keys[key] = true
+	}
+
+	statuses := make([]KeyStatus, 0, len(keys))
+	for key := range keys {
+		st := KeyStatus{Key: key, Limit: g.limitFor(key)}
+		if s := g.sems[key]; s != nil {
+			st.InFlight, st.Queued = s.counts()
+		}
+		if a := g.adaptive[key]; a != nil {
+			rtt, base := a.rtts()
+			st.RTT, st.BaseRTT = rtt.String(), base.String()
+		}
+		statuses = append(statuses, st)
+	}
+	g.mu.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Key < statuses[j].Key })
+	return statuses
+}
@@ -0,0 +1,197 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitQueued ждёт, пока в очереди семафора окажется n запросов
+func waitQueued(t *testing.T, s *semaphore, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, queued := s.counts(); queued == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("queue did not reach %d", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSemaphoreHandoffIsFIFO(t *testing.T) {
+	s := &semaphore{limit: 1, maxQueue: 10}
+	ctx := context.Background()
+	if err := s.acquire(ctx, func() {}); err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		go func(i int) {
+			if err := s.acquire(ctx, func() {}); err != nil {
+				t.Error(err)
+				return
+			}
+			order <- i
+		}(i)
+		waitQueued(t, s, i+1)
+	}
+
+	for want := 0; want < 3; want++ {
+		s.release()
+		if got := <-order; got != want {
+			t.Fatalf("waiter %d got the slot, want %d", got, want)
+		}
+		// Место передано ожидающему, а не освобождено
+		if active, _ := s.counts(); active != 1 {
+			t.Fatalf("active = %d after handoff", active)
+		}
+	}
+	s.release()
+	if active, queued := s.counts(); active != 0 || queued != 0 {
+		t.Errorf("active = %d, queued = %d", active, queued)
+	}
+}
+
+func TestSemaphoreNewcomerDoesNotOvertakeQueue(t *testing.T) {
+	s := &semaphore{limit: 1, maxQueue: 1}
+	ctx := context.Background()
+	s.acquire(ctx, func() {})
+
+	got := make(chan struct{})
+	go func() {
+		s.acquire(ctx, func() {})
+		close(got)
+	}()
+	waitQueued(t, s, 1)
+
+	if err := s.acquire(ctx, func() {}); !errors.Is(err, errQueueFull) {
+		t.Errorf("got %v, want errQueueFull", err)
+	}
+	s.release()
+	<-got
+}
+
+func TestSemaphoreCancelWhileQueued(t *testing.T) {
+	s := &semaphore{limit: 1, maxQueue: 5}
+	s.acquire(context.Background(), func() {})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	queued := false
+	if err := s.acquire(ctx, func() { queued = true }); !errors.Is(err, context.DeadlineExceeded) || !queued {
+		t.Errorf("err = %v, queued = %t", err, queued)
+	}
+	if active, waiting := s.counts(); active != 1 || waiting != 0 {
+		t.Errorf("active = %d, queued = %d", active, waiting)
+	}
+
+	s.release()
+	if active, _ := s.counts(); active != 0 {
+		t.Errorf("slot leaked: active = %d", active)
+	}
+}
+
+// Отмена, совпавшая с передачей места, не должна терять место
+func TestSemaphoreCancelRace(t *testing.T) {
+	for i := 0; i < 500; i++ {
+		s := &semaphore{limit: 1, maxQueue: 1}
+		s.acquire(context.Background(), func() {})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		result := make(chan error, 1)
+		go func() { result <- s.acquire(ctx, func() {}) }()
+		waitQueued(t, s, 1)
+
+		go cancel()
+		s.release()
+		if err := <-result; err == nil {
+			s.release()
+		}
+		cancel()
+
+		if active, queued := s.counts(); active != 0 || queued != 0 {
+			t.Fatalf("iteration %d: active = %d, queued = %d", i, active, queued)
+		}
+	}
+}
+
+func TestSemaphoreSetLimit(t *testing.T) {
+	s := &semaphore{limit: 1, maxQueue: 5}
+	ctx := context.Background()
+	s.acquire(ctx, func() {})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.acquire(ctx, func() {})
+		}()
+		waitQueued(t, s, i+1)
+	}
+
+	// Увеличение лимита сразу отдаёт места ожидающим
+	s.setLimit(3)
+	wg.Wait()
+	if active, queued := s.counts(); active != 3 || queued != 0 {
+		t.Fatalf("after raise: active = %d, queued = %d", active, queued)
+	}
+
+	// После уменьшения освобождённые места не передаются, пока занято больше лимита
+	s.setLimit(1)
+	done := make(chan struct{})
+	go func() {
+		s.acquire(ctx, func() {})
+		close(done)
+	}()
+	waitQueued(t, s, 1)
+	s.release()
+	s.release()
+	select {
+	case <-done:
+		t.Fatal("slot handed off above the new limit")
+	case <-time.After(10 * time.Millisecond):
+	}
+	s.release()
+	<-done
+	if active, _ := s.counts(); active != 1 {
+		t.Errorf("active = %d, want 1", active)
+	}
+}
+
+func TestGroupForgetsIdleKeys(t *testing.T) {
+	g := newGroup(1, map[string]int{"vip": 2, "off": 0}, 0)
+	ctx := context.Background()
+
+	release, err := g.acquire(ctx, "a", func() {})
+	if err != nil || release == nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, err := g.acquire(ctx, "a", func() {}); !errors.Is(err, errQueueFull) {
+		t.Errorf("second acquire without queue: %v", err)
+	}
+	vip1, _ := g.acquire(ctx, "vip", func() {})
+	vip2, err := g.acquire(ctx, "vip", func() {})
+	if err != nil {
+		t.Errorf("vip limit not applied: %v", err)
+	}
+	if release, err := g.acquire(ctx, "off", func() {}); release != nil || err != nil {
+		t.Error("key with limit 0 is limited")
+	}
+	if g.size() != 2 {
+		t.Errorf("size = %d, want 2", g.size())
+	}
+
+	release()
+	vip1()
+	vip2()
+	if g.size() != 0 {
+		t.Errorf("size = %d after release, want 0", g.size())
+	}
+}
@@ -0,0 +1,13 @@
+package config
+
+import "time"
+
+// AccessLogConfig настройки журнала доступа (включается через log_requests)
+type AccessLogConfig struct {
+	Format         string        `yaml:"format"`   // json, common, combined, template
+	Template       string        `yaml:"template"` // например "{time} {method} {uri} {status}"
+	Output         string        `yaml:"output"`   // stdout, stderr или путь к файлу
+	MaxSizeMB      int           `yaml:"max_size_mb"`
+	MaxBackups     int           `yaml:"max_backups"`
+	RotateInterval time.Duration `yaml:"rotate_interval"`
+}
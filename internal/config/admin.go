@@ -0,0 +1,8 @@
+package config
+
+// AdminConfig доступ к управляющим эндпоинтам (POST/DELETE /capture, POST /canary).
+// Запрос должен нести "X-Admin-Token: <токен>" из списка: Authorization занят
+// аутентификацией клиента (JWT, Basic). Без токенов изменения через эти эндпоинты запрещены
+type AdminConfig struct {
+	Tokens []string `yaml:"tokens"`
+}
@@ -0,0 +1,28 @@
+package config
+
+import "time"
+
+// APIKeysConfig аутентификация по API ключам из файла-хранилища
+type APIKeysConfig struct {
+	Enabled        bool           `yaml:"enabled"`
+	Store          string         `yaml:"store"`       // YAML файл с хешами ключей
+	Header         string         `yaml:"header"`      // по умолчанию X-API-Key
+	QueryParam     string         `yaml:"query_param"` // пусто — ключ только в заголовке
+	Routes         []string       `yaml:"routes"`      // маршруты, требующие ключ; пусто — все
+	ReloadInterval time.Duration  `yaml:"reload_interval"`
+	Tiers          map[string]int `yaml:"tiers"` // лимит запросов в минуту по tier ключа
+}
+
+const (
+	defaultAPIKeyHeader         = "X-API-Key"
+	defaultAPIKeyReloadInterval = 5 * time.Second
+)
+
+func (c *APIKeysConfig) applyDefaults() {
+	if c.Header == "" {
+		c.Header = defaultAPIKeyHeader
+	}
+	if c.ReloadInterval == 0 {
+		c.ReloadInterval = defaultAPIKeyReloadInterval
+	}
+}
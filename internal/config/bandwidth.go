@@ -0,0 +1,18 @@
+package config
+
+// BandwidthConfig ограничение скорости передачи тел запросов (upload) и ответов (download)
+type BandwidthConfig struct {
+	Enabled   bool                            `yaml:"enabled"`
+	PerClient BandwidthLimitConfig            `yaml:"per_client"` // по principal, иначе по IP
+	PerRoute  BandwidthLimitConfig            `yaml:"per_route"`
+	Routes    map[string]BandwidthLimitConfig `yaml:"routes"` // лимиты отдельных маршрутов вместо per_route
+}
+
+// BandwidthLimitConfig скорость в байтах в секунду и запас, который можно передать
+// сразу (по умолчанию — секунда трафика); 0 — без ограничения
+type BandwidthLimitConfig struct {
+	Upload        int64 `yaml:"upload"`
+	Download      int64 `yaml:"download"`
+	UploadBurst   int64 `yaml:"upload_burst"`
+	DownloadBurst int64 `yaml:"download_burst"`
+}
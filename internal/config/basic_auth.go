@@ -0,0 +1,27 @@
+package config
+
+import "time"
+
+// BasicAuthConfig HTTP Basic по файлу htpasswd для набора маршрутов.
+// Можно задать несколько блоков с разными файлами и realm
+type BasicAuthConfig struct {
+	Routes            []string      `yaml:"routes"` // пусто — все маршруты
+	Htpasswd          string        `yaml:"htpasswd"`
+	Realm             string        `yaml:"realm"`
+	KeepAuthorization bool          `yaml:"keep_authorization"`
+	ReloadInterval    time.Duration `yaml:"reload_interval"`
+}
+
+const (
+	defaultBasicAuthRealm          = "access-proxy"
+	defaultBasicAuthReloadInterval = 5 * time.Second
+)
+
+func (c *BasicAuthConfig) applyDefaults() {
+	if c.Realm == "" {
+		c.Realm = defaultBasicAuthRealm
+	}
+	if c.ReloadInterval == 0 {
+		c.ReloadInterval = defaultBasicAuthReloadInterval
+	}
+}
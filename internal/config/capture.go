@@ -0,0 +1,25 @@
+package config
+
+import "time"
+
+// CaptureConfig захват трафика в HAR/JSONL файлы. Сеанс можно запустить
+// при старте (enabled) или во время работы через эндпоинт /capture
+type CaptureConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	Dir          string        `yaml:"dir"`
+	Format       string        `yaml:"format"` // har или jsonl
+	MaxEntries   int           `yaml:"max_entries"`
+	MaxDuration  time.Duration `yaml:"max_duration"`
+	MaxBodyBytes int           `yaml:"max_body_bytes"`
+	Routes       []string      `yaml:"routes"`
+	Clients      []string      `yaml:"clients"`
+	Headers      []string      `yaml:"headers"`
+}
+
+const defaultCaptureBodyBytes = 64 * 1024
+
+func (c *CaptureConfig) applyDefaults() {
+	if c.MaxBodyBytes == 0 {
+		c.MaxBodyBytes = defaultCaptureBodyBytes
+	}
+}
@@ -0,0 +1,49 @@
+package config
+
+import "time"
+
+// ConcurrencyConfig ограничение числа запросов в обработке одновременно;
+// лишние ждут в очереди до queue_timeout, затем получают 503
+type ConcurrencyConfig struct {
+	Enabled      bool           `yaml:"enabled"`
+	Global       int            `yaml:"global"`
+	PerClient    int            `yaml:"per_client"` // по principal, иначе по IP
+	PerRoute     int            `yaml:"per_route"`
+	Routes       map[string]int `yaml:"routes"`    // лимиты отдельных маршрутов вместо per_route
+	MaxQueue     int            `yaml:"max_queue"` // длина очереди у каждого ограничения
+	QueueTimeout time.Duration  `yaml:"queue_timeout"`
+	RetryAfter   time.Duration  `yaml:"retry_after"`
+
+	Adaptive ConcurrencyAdaptiveConfig `yaml:"adaptive"`
+}
+
+// ConcurrencyAdaptiveConfig лимит маршрутов, подбираемый по задержке и ошибкам upstream
+// вместо per_route и routes. Незаданные значения берутся по умолчанию алгоритма
+type ConcurrencyAdaptiveConfig struct {
+	Enabled          bool          `yaml:"enabled"`
+	Algorithm        string        `yaml:"algorithm"` // gradient/aimd
+	InitialLimit     int           `yaml:"initial_limit"`
+	MinLimit         int           `yaml:"min_limit"`
+	MaxLimit         int           `yaml:"max_limit"`
+	Backoff          float64       `yaml:"backoff"`           // множитель лимита при ошибке upstream
+	LatencyThreshold time.Duration `yaml:"latency_threshold"` // aimd: более медленный ответ — перегрузка
+	Tolerance        float64       `yaml:"tolerance"`         // gradient: допустимый рост задержки
+}
+
+const (
+	defaultConcurrencyMaxQueue     = 100
+	defaultConcurrencyQueueTimeout = 5 * time.Second
+	defaultConcurrencyRetryAfter   = time.Second
+)
+
+func (c *ConcurrencyConfig) applyDefaults() {
+	if c.MaxQueue == 0 {
+		c.MaxQueue = defaultConcurrencyMaxQueue
+	}
+	if c.QueueTimeout == 0 {
+		c.QueueTimeout = defaultConcurrencyQueueTimeout
+	}
+	if c.RetryAfter == 0 {
+		c.RetryAfter = defaultConcurrencyRetryAfter
+	}
+}
@@ -9,9 +9,29 @@ type Config struct {
 	Port               int
 	AllowedDomains     []string
 	BlockedMethods     []string
+	TrustedProxies     []string
 	RateLimitPerMinute int
+	RateLimit          RateLimitConfig
+	Concurrency        ConcurrencyConfig
+	Bandwidth          BandwidthConfig
+	Quota              QuotaConfig
 	LogRequests        bool
 	Env                string
+	Metrics            MetricsConfig
+	AccessLog          AccessLogConfig
+	BodyLogging        BodyLoggingConfig
+	Redaction          RedactionConfig
+	Capture            CaptureConfig
+	Admin              AdminConfig
+	Routes             []RouteConfig
+	APIKeys            APIKeysConfig
+	JWT                JWTConfig
+	BasicAuth          []BasicAuthConfig
+	ForwardAuth        ForwardAuthConfig
+	HMAC               HMACConfig
+	OIDC               OIDCConfig
+	Policies           []PolicyConfig
+	HeaderRules        []HeaderRuleConfig
 }
 
 func LoadConfig() *Config {
@@ -29,8 +49,24 @@ func LoadConfig() *Config {
 
 
 	final := mergeConfigs(yamlCfg, flagsRefs)
+	final.RateLimit.applyDefaults(final.RateLimitPerMinute)
+	final.Concurrency.applyDefaults()
+	final.Quota.applyDefaults()
+	final.Metrics.applyDefaults()
+	final.BodyLogging.applyDefaults()
+	final.Capture.applyDefaults()
+	final.APIKeys.applyDefaults()
+	final.JWT.applyDefaults()
+	final.ForwardAuth.applyDefaults()
+	final.HMAC.applyDefaults()
+	final.OIDC.applyDefaults()
+	for i := range final.BasicAuth {
+		final.BasicAuth[i].applyDefaults()
+	}
+	for i := range final.Policies {
+		final.Policies[i].applyDefaults()
+	}
 
-	
 	return final
 }
 
@@ -42,6 +78,7 @@ func mergeConfigs(yml *Config, flags *flagRefs) *Config {
 	}
 	if isFlagPassed("rate") {
 		final.RateLimitPerMinute = *flags.rate
+		final.RateLimit.Limit = *flags.rate
 	}
 	if isFlagPassed("log") {
 		final.LogRequests = *flags.log
@@ -58,6 +95,21 @@ func mergeConfigs(yml *Config, flags *flagRefs) *Config {
 	if isFlagPassed("env") {
 		final.Env = *flags.env
 	}
+	if isFlagPassed("metrics") {
+		final.Metrics.Enabled = *flags.metrics
+	}
+	if isFlagPassed("log-format") {
+		final.AccessLog.Format = *flags.logFormat
+	}
+	if isFlagPassed("log-output") {
+		final.AccessLog.Output = *flags.logOutput
+	}
+	if isFlagPassed("log-bodies") {
+		final.BodyLogging.Enabled = *flags.logBodies
+	}
+	if isFlagPassed("capture") {
+		final.Capture.Enabled = *flags.capture
+	}
 
 	return &final
 }
@@ -13,6 +13,11 @@ type flagRefs struct {
 	domains  *types.StringSlice
 	blocked  *types.StringSlice
 	env      *string
+	metrics   *bool
+	logFormat *string
+	logOutput *string
+	logBodies *bool
+	capture   *bool
 }
 
 func defineFlags(defaults *Config) *flagRefs {
@@ -28,5 +33,10 @@ func defineFlags(defaults *Config) *flagRefs {
 		domains: &allowed,
 		blocked: &blocked,
 		env: 	 flag.String("env", "dev", "Режим окружения: dev или prod"),
+		metrics: flag.Bool("metrics", defaults.Metrics.Enabled, "Включить эндпоинт метрик Prometheus"),
+		logFormat: flag.String("log-format", defaults.AccessLog.Format, "Формат журнала доступа: json, common, combined, template"),
+		logOutput: flag.String("log-output", defaults.AccessLog.Output, "Куда писать журнал доступа: stdout, stderr или путь к файлу"),
+		logBodies: flag.Bool("log-bodies", defaults.BodyLogging.Enabled, "Логировать заголовки и тела запросов/ответов (с redaction)"),
+		capture:   flag.Bool("capture", defaults.Capture.Enabled, "Начать захват трафика в HAR при старте"),
 	}
 }
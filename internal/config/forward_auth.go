@@ -0,0 +1,36 @@
+package config
+
+import "time"
+
+// ForwardAuthConfig проверка запросов внешним сервисом авторизации
+// (как auth_request в nginx или ForwardAuth в Traefik)
+type ForwardAuthConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	URL             string        `yaml:"url"`
+	Routes          []string      `yaml:"routes"`           // пусто — все маршруты
+	RequestHeaders  []string      `yaml:"request_headers"`  // заголовки клиента для подзапроса
+	ResponseHeaders []string      `yaml:"response_headers"` // заголовки ответа, копируемые в upstream
+	PrincipalHeader string        `yaml:"principal_header"`
+	Timeout         time.Duration `yaml:"timeout"`
+	CacheTTL        time.Duration `yaml:"cache_ttl"`
+	CacheSize       int           `yaml:"cache_size"`
+}
+
+const (
+	defaultForwardAuthTimeout   = 5 * time.Second
+	defaultForwardAuthCacheSize = 10000
+)
+
+var defaultForwardAuthRequestHeaders = []string{"Authorization", "Cookie"}
+
+func (c *ForwardAuthConfig) applyDefaults() {
+	if c.Timeout == 0 {
+		c.Timeout = defaultForwardAuthTimeout
+	}
+	if c.CacheSize == 0 {
+		c.CacheSize = defaultForwardAuthCacheSize
+	}
+	if len(c.RequestHeaders) == 0 {
+		c.RequestHeaders = defaultForwardAuthRequestHeaders
+	}
+}
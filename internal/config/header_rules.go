@@ -0,0 +1,9 @@
+package config
+
+// HeaderRuleConfig изменяет заголовки запроса к upstream, если выполнено условие.
+// Значения set — выражения, например identity.principal или "static"
+type HeaderRuleConfig struct {
+	When   string            `yaml:"when"` // пусто — всегда
+	Set    map[string]string `yaml:"set"`
+	Remove []string          `yaml:"remove"`
+}
@@ -0,0 +1,32 @@
+package config
+
+import "time"
+
+// HMACConfig проверка подписей HMAC-SHA256 от партнёров
+type HMACConfig struct {
+	Enabled       bool              `yaml:"enabled"`
+	Routes        []string          `yaml:"routes"`  // пусто — все маршруты
+	Secrets       map[string]string `yaml:"secrets"` // ID клиента -> общий секрет
+	SignedHeaders []string          `yaml:"signed_headers"`
+	MaxSkew       time.Duration     `yaml:"max_skew"`
+	NonceCacheMax int               `yaml:"nonce_cache_max"` // на одного клиента
+	MaxBodyBytes  int64             `yaml:"max_body_bytes"`
+}
+
+const (
+	defaultHMACMaxSkew       = 5 * time.Minute
+	defaultHMACNonceCacheMax = 100000
+	defaultHMACMaxBodyBytes  = 1024 * 1024
+)
+
+func (c *HMACConfig) applyDefaults() {
+	if c.MaxSkew == 0 {
+		c.MaxSkew = defaultHMACMaxSkew
+	}
+	if c.NonceCacheMax == 0 {
+		c.NonceCacheMax = defaultHMACNonceCacheMax
+	}
+	if c.MaxBodyBytes == 0 {
+		c.MaxBodyBytes = defaultHMACMaxBodyBytes
+	}
+}
@@ -0,0 +1,42 @@
+package config
+
+import "time"
+
+// JWTConfig проверка Bearer токенов. Ключи — общий секрет (HS256)
+// и/или JWKS из файла или по URL
+type JWTConfig struct {
+	Enabled        bool                    `yaml:"enabled"`
+	Secret         string                  `yaml:"secret"`
+	JWKSFile       string                  `yaml:"jwks_file"`
+	JWKSURL        string                  `yaml:"jwks_url"`
+	JWKSCacheTTL   time.Duration           `yaml:"jwks_cache_ttl"`
+	Algorithms     []string                `yaml:"algorithms"`
+	Issuer         string                  `yaml:"issuer"`
+	Audience       []string                `yaml:"audience"`
+	Leeway         time.Duration           `yaml:"leeway"`
+	Realm          string                  `yaml:"realm"`
+	Routes         []string                `yaml:"routes"` // маршруты, требующие токен; пусто — все
+	Rules          map[string]JWTRouteRule `yaml:"rules"`  // требования по имени маршрута
+	ForwardClaims  map[string]string       `yaml:"forward_claims"`
+	PrincipalClaim string                  `yaml:"principal_claim"`
+}
+
+// JWTRouteRule требования маршрута к токену
+type JWTRouteRule struct {
+	Scopes []string          `yaml:"scopes"`
+	Claims map[string]string `yaml:"claims"`
+}
+
+const (
+	defaultJWKSCacheTTL = 10 * time.Minute
+	defaultJWTRealm     = "access-proxy"
+)
+
+func (c *JWTConfig) applyDefaults() {
+	if c.JWKSCacheTTL == 0 {
+		c.JWKSCacheTTL = defaultJWKSCacheTTL
+	}
+	if c.Realm == "" {
+		c.Realm = defaultJWTRealm
+	}
+}
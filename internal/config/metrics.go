@@ -0,0 +1,15 @@
+package config
+
+// MetricsConfig настройки эндпоинта метрик Prometheus
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+}
+
+const defaultMetricsPath = "/metrics"
+
+func (c *MetricsConfig) applyDefaults() {
+	if c.Path == "" {
+		c.Path = defaultMetricsPath
+	}
+}
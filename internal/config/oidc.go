@@ -0,0 +1,50 @@
+package config
+
+import "time"
+
+// OIDCConfig вход пользователей через OpenID Connect с сессией в зашифрованной cookie
+type OIDCConfig struct {
+	Enabled             bool          `yaml:"enabled"`
+	Issuer              string        `yaml:"issuer"`
+	ClientID            string        `yaml:"client_id"`
+	ClientSecret        string        `yaml:"client_secret"`
+	RedirectURL         string        `yaml:"redirect_url"`
+	LogoutPath          string        `yaml:"logout_path"`
+	PostLogoutURL       string        `yaml:"post_logout_url"`
+	Scopes              []string      `yaml:"scopes"`
+	Routes              []string      `yaml:"routes"` // пусто — все маршруты
+	CookieName          string        `yaml:"cookie_name"`
+	CookieSecret        string        `yaml:"cookie_secret"`
+	CookieInsecure      bool          `yaml:"cookie_insecure"`
+	SessionTTL          time.Duration `yaml:"session_ttl"`
+	GroupsClaim         string        `yaml:"groups_claim"`
+	AllowedGroups       []string      `yaml:"allowed_groups"`
+	AllowedEmailDomains []string      `yaml:"allowed_email_domains"`
+}
+
+const (
+	defaultOIDCCookieName  = "access_proxy_session"
+	defaultOIDCLogoutPath  = "/oauth2/logout"
+	defaultOIDCSessionTTL  = 12 * time.Hour
+	defaultOIDCGroupsClaim = "groups"
+)
+
+var defaultOIDCScopes = []string{"openid", "email", "profile"}
+
+func (c *OIDCConfig) applyDefaults() {
+	if c.CookieName == "" {
+		c.CookieName = defaultOIDCCookieName
+	}
+	if c.LogoutPath == "" {
+		c.LogoutPath = defaultOIDCLogoutPath
+	}
+	if c.SessionTTL == 0 {
+		c.SessionTTL = defaultOIDCSessionTTL
+	}
+	if c.GroupsClaim == "" {
+		c.GroupsClaim = defaultOIDCGroupsClaim
+	}
+	if len(c.Scopes) == 0 {
+		c.Scopes = defaultOIDCScopes
+	}
+}
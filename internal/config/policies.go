@@ -0,0 +1,41 @@
+package config
+
+// PolicyConfig политика доступа для части путей: правила проверяются по порядку,
+// первое подошедшее решает, иначе применяется default_action
+type PolicyConfig struct {
+	Name          string             `yaml:"name"`
+	Paths         []string           `yaml:"paths"`  // шаблоны путей, '*' — любая последовательность символов
+	Routes        []string           `yaml:"routes"` // имена маршрутов; пусто вместе с paths — все запросы
+	Rules         []PolicyRuleConfig `yaml:"rules"`
+	DefaultAction string             `yaml:"default_action"` // allow/deny, по умолчанию deny
+}
+
+// PolicyRuleConfig правило политики; пустое условие подходит любому запросу
+type PolicyRuleConfig struct {
+	Action        string            `yaml:"action"` // allow/deny
+	Methods       []string          `yaml:"methods"`
+	Paths         []string          `yaml:"paths"`
+	CIDRs         []string          `yaml:"cidrs"`
+	Origins       []string          `yaml:"origins"`    // шаблоны Origin, например https://*.example.com
+	Principals    []string          `yaml:"principals"` // шаблоны principal
+	AuthMethods   []string          `yaml:"auth_methods"`
+	Authenticated *bool             `yaml:"authenticated"`
+	Time          *PolicyTimeConfig `yaml:"time"`
+	When          string            `yaml:"when"` // выражение, например `header("X-Tenant") != ""`
+}
+
+// PolicyTimeConfig окно времени: дни недели и интервал HH:MM
+type PolicyTimeConfig struct {
+	Days     []string `yaml:"days"` // mon, tue, ... sun
+	From     string   `yaml:"from"`
+	To       string   `yaml:"to"`
+	Timezone string   `yaml:"timezone"` // по умолчанию UTC
+}
+
+const defaultPolicyAction = "deny"
+
+func (c *PolicyConfig) applyDefaults() {
+	if c.DefaultAction == "" {
+		c.DefaultAction = defaultPolicyAction
+	}
+}
@@ -0,0 +1,76 @@
+package config
+
+import "time"
+
+// QuotaConfig квоты вызовов upstream за календарные сутки и месяц по клиентам
+// (principal, иначе IP). Счётчики сохраняются и переживают перезапуск
+type QuotaConfig struct {
+	Enabled   bool                        `yaml:"enabled"`
+	Daily     int64                       `yaml:"daily"`     // 0 — без ограничения
+	Monthly   int64                       `yaml:"monthly"`   // 0 — без ограничения
+	Consumers map[string]QuotaLimitConfig `yaml:"consumers"` // квоты отдельных principal
+	Tiers     map[string]QuotaLimitConfig `yaml:"tiers"`     // квоты по tier ключа API
+	Timezone  string                      `yaml:"timezone"`  // где начинаются сутки и месяц, по умолчанию UTC
+
+	Store QuotaStoreConfig `yaml:"store"`
+}
+
+// QuotaLimitConfig квота клиента вместо общей; 0 — без ограничения
+type QuotaLimitConfig struct {
+	Daily   int64 `yaml:"daily"`
+	Monthly int64 `yaml:"monthly"`
+}
+
+// QuotaStoreConfig где хранятся счётчики: file — JSON файл этой реплики,
+// redis — общие для всех реплик
+type QuotaStoreConfig struct {
+	Type          string        `yaml:"type"` // file/redis
+	Path          string        `yaml:"path"`
+	FlushInterval time.Duration `yaml:"flush_interval"` // как часто сохранять файл
+	Addr          string        `yaml:"addr"`
+	Username      string        `yaml:"username"`
+	Password      string        `yaml:"password"`
+	DB            int           `yaml:"db"`
+	KeyPrefix     string        `yaml:"key_prefix"`
+	Timeout       time.Duration `yaml:"timeout"`
+	OnError       string        `yaml:"on_error"` // allow (fail-open) / deny (fail-closed), если хранилище недоступно
+}
+
+const (
+	defaultQuotaTimezone       = "UTC"
+	defaultQuotaStore          = "file"
+	defaultQuotaPath           = "quota.json"
+	defaultQuotaFlushInterval  = 5 * time.Second
+	defaultQuotaRedisAddr      = "127.0.0.1:6379"
+	defaultQuotaRedisKeyPrefix = "access-proxy:quota:"
+	defaultQuotaRedisTimeout   = 100 * time.Millisecond
+	defaultQuotaOnError        = "allow"
+)
+
+func (c *QuotaConfig) applyDefaults() {
+	if c.Timezone == "" {
+		c.Timezone = defaultQuotaTimezone
+	}
+	s := &c.Store
+	if s.Type == "" {
+		s.Type = defaultQuotaStore
+	}
+	if s.Path == "" {
+		s.Path = defaultQuotaPath
+	}
+	if s.FlushInterval == 0 {
+		s.FlushInterval = defaultQuotaFlushInterval
+	}
+	if s.Addr == "" {
+		s.Addr = defaultQuotaRedisAddr
+	}
+	if s.KeyPrefix == "" {
+		s.KeyPrefix = defaultQuotaRedisKeyPrefix
+	}
+	if s.Timeout == 0 {
+		s.Timeout = defaultQuotaRedisTimeout
+	}
+	if s.OnError == "" {
+		s.OnError = defaultQuotaOnError
+	}
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// RateLimitConfig лимит limit запросов за window с запасом burst запросов подряд.
+// Без limit используется rate_limit_per_minute
+type RateLimitConfig struct {
+	Limit  int           `yaml:"limit"`
+	Window time.Duration `yaml:"window"`
+	Burst  int           `yaml:"burst"` // по умолчанию равен limit
+
+	Rules      []RateLimitRuleConfig `yaml:"rules"`       // дополнительные лимиты со своими ключами
+	IPv6Prefix int                   `yaml:"ipv6_prefix"` // IPv6 клиенты считаются по подсети, по умолчанию /64
+
+	Store RateLimitStoreConfig `yaml:"store"`
+
+	MaxClients      int           `yaml:"max_clients"`      // предел отслеживаемых клиентов, сверх него вытесняются давно не активные
+	CleanupInterval time.Duration `yaml:"cleanup_interval"` // как часто удалять клиентов с полностью восстановленным запасом
+}
+
+// RateLimitRuleConfig лимит по произвольному ключу, например
+// [ip], [header:X-Tenant], [api_key, route]. Запрос без значения ключа правило пропускает
+type RateLimitRuleConfig struct {
+	Name   string        `yaml:"name"`
+	Key    []string      `yaml:"key"` // ip, identity, principal, api_key, jwt_sub, path, route, method, header:<имя>, query:<имя>
+	Limit  int           `yaml:"limit"`
+	Window time.Duration `yaml:"window"`
+	Burst  int           `yaml:"burst"`
+	Routes []string      `yaml:"routes"` // пусто — все маршруты
+}
+
+// RateLimitStoreConfig где хранится состояние лимитов: memory — у каждой реплики своё,
+// redis — общее для всех реплик
+type RateLimitStoreConfig struct {
+	Type      string        `yaml:"type"` // memory/redis
+	Addr      string        `yaml:"addr"`
+	Username  string        `yaml:"username"`
+	Password  string        `yaml:"password"`
+	DB        int           `yaml:"db"`
+	KeyPrefix string        `yaml:"key_prefix"`
+	Timeout   time.Duration `yaml:"timeout"`
+	OnError   string        `yaml:"on_error"` // allow (fail-open) / deny (fail-closed), если Redis недоступен
+}
+
+const (
+	defaultRateLimitStore          = "memory"
+	defaultRateLimitRedisAddr      = "127.0.0.1:6379"
+	defaultRateLimitRedisKeyPrefix = "access-proxy:ratelimit:"
+	defaultRateLimitRedisTimeout   = 100 * time.Millisecond
+	defaultRateLimitOnError        = "allow"
+)
+
+const (
+	defaultRateLimitIPv6Prefix      = 64
+	defaultRateLimitWindow          = time.Minute
+	defaultRateLimitMaxClients      = 100000
+	defaultRateLimitCleanupInterval = time.Minute
+)
+
+func (c *RateLimitConfig) applyDefaults(perMinute int) {
+	if c.Limit == 0 {
+		c.Limit = perMinute
+	}
+	if c.Window == 0 {
+		c.Window = defaultRateLimitWindow
+	}
+	if c.Burst == 0 {
+		c.Burst = c.Limit
+	}
+	if c.IPv6Prefix == 0 {
+		c.IPv6Prefix = defaultRateLimitIPv6Prefix
+	}
+	for i := range c.Rules {
+		r := &c.Rules[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule%d", i+1)
+		}
+		if r.Window == 0 {
+			r.Window = defaultRateLimitWindow
+		}
+		if r.Burst == 0 {
+			r.Burst = r.Limit
+		}
+	}
+	c.Store.applyDefaults()
+	if c.MaxClients == 0 {
+		c.MaxClients = defaultRateLimitMaxClients
+	}
+	if c.CleanupInterval == 0 {
+		c.CleanupInterval = defaultRateLimitCleanupInterval
+	}
+}
+
+func (c *RateLimitStoreConfig) applyDefaults() {
+	if c.Type == "" {
+		c.Type = defaultRateLimitStore
+	}
+	if c.Addr == "" {
+		c.Addr = defaultRateLimitRedisAddr
+	}
+	if c.KeyPrefix == "" {
+		c.KeyPrefix = defaultRateLimitRedisKeyPrefix
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultRateLimitRedisTimeout
+	}
+	if c.OnError == "" {
+		c.OnError = defaultRateLimitOnError
+	}
+}
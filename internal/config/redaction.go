@@ -0,0 +1,32 @@
+package config
+
+// RedactionConfig правила скрытия чувствительных данных в логах
+type RedactionConfig struct {
+	Headers     []string `yaml:"headers"`
+	QueryParams []string `yaml:"query_params"`
+	JSONFields  []string `yaml:"json_fields"`
+	Patterns    []string `yaml:"patterns"`
+	Mask        string   `yaml:"mask"`
+}
+
+// BodyLoggingConfig подробное логирование заголовков и тел запросов/ответов
+type BodyLoggingConfig struct {
+	Enabled          bool     `yaml:"enabled"`
+	MaxBytes         int      `yaml:"max_bytes"`          // сколько байт тела сохранять
+	SampleRate       *float64 `yaml:"sample_rate"`        // доля запросов с захватом тел, 0..1; по умолчанию 1
+	SkipContentTypes []string `yaml:"skip_content_types"` // "multipart/" совпадает с любым подтипом
+}
+
+const defaultBodyMaxBytes = 4096
+
+func (c *BodyLoggingConfig) applyDefaults() {
+	if c.MaxBytes == 0 {
+		c.MaxBytes = defaultBodyMaxBytes
+	}
+	// 0 — допустимое значение (тела не захватываются), поэтому по умолчанию
+	// подставляем 1 только если параметр не задан
+	if c.SampleRate == nil {
+		rate := 1.0
+		c.SampleRate = &rate
+	}
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestBodyLoggingSampleRate(t *testing.T) {
+	tests := []struct {
+		yaml string
+		want float64
+	}{
+		{"enabled: true", 1},
+		{"sample_rate: 0", 0},
+		{"sample_rate: 0.25", 0.25},
+	}
+	for _, tt := range tests {
+		var c BodyLoggingConfig
+		if err := yaml.Unmarshal([]byte(tt.yaml), &c); err != nil {
+			t.Fatal(err)
+		}
+		c.applyDefaults()
+		if c.SampleRate == nil || *c.SampleRate != tt.want {
+			t.Errorf("%q: sample rate %v, want %v", tt.yaml, c.SampleRate, tt.want)
+		}
+		if c.MaxBytes != defaultBodyMaxBytes {
+			t.Errorf("%q: max bytes %d", tt.yaml, c.MaxBytes)
+		}
+	}
+}
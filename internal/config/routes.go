@@ -0,0 +1,45 @@
+package config
+
+import "time"
+
+// RouteConfig маршрут прокси: запросы с path_prefix получают имя маршрута
+// (оно же метка метрик) и его собственные настройки
+type RouteConfig struct {
+	Name       string          `yaml:"name"`
+	PathPrefix string          `yaml:"path_prefix"`
+	Targets    []string        `yaml:"targets"` // экземпляры upstream вместо общего target
+	Affinity   *AffinityConfig `yaml:"affinity"`
+	Mirror     *MirrorConfig   `yaml:"mirror"`
+	Canary     *CanaryConfig   `yaml:"canary"`
+	When       string          `yaml:"when"` // условие выбора маршрута, например `header("X-Beta") == "1"`
+}
+
+// MirrorConfig зеркалирование копий запросов в shadow upstream
+type MirrorConfig struct {
+	Target        string        `yaml:"target"`
+	SamplePercent float64       `yaml:"sample_percent"`
+	MaxBodyBytes  int64         `yaml:"max_body_bytes"`
+	Timeout       time.Duration `yaml:"timeout"`
+	MaxInFlight   int           `yaml:"max_in_flight"`
+}
+
+// CanaryConfig разделение трафика маршрута между основным target и canary.
+// Процент можно менять во время работы через эндпоинт /canary
+type CanaryConfig struct {
+	Target         string   `yaml:"target"`
+	Percent        float64  `yaml:"percent"`
+	Header         string   `yaml:"header"`
+	Cookie         string   `yaml:"cookie"`
+	ClientIDHeader string   `yaml:"client_id_header"`
+	CanaryClients  []string `yaml:"canary_clients"`
+	StableClients  []string `yaml:"stable_clients"`
+}
+
+// AffinityConfig привязка клиента к одному из targets маршрута
+type AffinityConfig struct {
+	Mode        string        `yaml:"mode"` // cookie/ip/header/path
+	Cookie      string        `yaml:"cookie"`
+	CookieTTL   time.Duration `yaml:"cookie_ttl"`
+	Header      string        `yaml:"header"`
+	PathSegment int           `yaml:"path_segment"`
+}
@@ -12,9 +12,29 @@ type yamlFileConfig struct {
 	Port              int      `yaml:"port"`
 	AllowedDomains    []string `yaml:"allowed_domains"`
 	BlockedMethods    []string `yaml:"blocked_methods"`
+	TrustedProxies    []string `yaml:"trusted_proxies"`
 	RateLimitPerMinute int     `yaml:"rate_limit_per_minute"`
+	RateLimit         RateLimitConfig `yaml:"rate_limit"`
+	Concurrency       ConcurrencyConfig `yaml:"concurrency"`
+	Bandwidth         BandwidthConfig `yaml:"bandwidth"`
+	Quota             QuotaConfig `yaml:"quota"`
 	LogRequests       bool     `yaml:"log_requests"`
 	Env               string   `yaml:"environment"`
+	Metrics           MetricsConfig `yaml:"metrics"`
+	AccessLog         AccessLogConfig `yaml:"access_log"`
+	BodyLogging       BodyLoggingConfig `yaml:"body_logging"`
+	Redaction         RedactionConfig `yaml:"redaction"`
+	Capture           CaptureConfig `yaml:"capture"`
+	Admin             AdminConfig   `yaml:"admin"`
+	Routes            []RouteConfig `yaml:"routes"`
+	APIKeys           APIKeysConfig `yaml:"api_keys"`
+	JWT               JWTConfig     `yaml:"jwt"`
+	BasicAuth         []BasicAuthConfig `yaml:"basic_auth"`
+	ForwardAuth       ForwardAuthConfig `yaml:"forward_auth"`
+	HMAC              HMACConfig    `yaml:"hmac"`
+	OIDC              OIDCConfig    `yaml:"oidc"`
+	Policies          []PolicyConfig `yaml:"policies"`
+	HeaderRules       []HeaderRuleConfig `yaml:"header_rules"`
 }
 
 // loadFromYAML читает конфиг из YAML и возвращает Config
@@ -36,8 +56,28 @@ func loadFromYAML(path string) *Config {
 		Port:              yml.Port,
 		AllowedDomains:    yml.AllowedDomains,
 		BlockedMethods:    yml.BlockedMethods,
+		TrustedProxies:    yml.TrustedProxies,
 		RateLimitPerMinute: yml.RateLimitPerMinute,
+		RateLimit:         yml.RateLimit,
+		Concurrency:       yml.Concurrency,
+		Bandwidth:         yml.Bandwidth,
+		Quota:             yml.Quota,
 		LogRequests:       yml.LogRequests,
 		Env:               yml.Env,
+		Metrics:           yml.Metrics,
+		AccessLog:         yml.AccessLog,
+		BodyLogging:       yml.BodyLogging,
+		Redaction:         yml.Redaction,
+		Capture:           yml.Capture,
+		Admin:             yml.Admin,
+		Routes:            yml.Routes,
+		APIKeys:           yml.APIKeys,
+		JWT:               yml.JWT,
+		BasicAuth:         yml.BasicAuth,
+		ForwardAuth:       yml.ForwardAuth,
+		HMAC:              yml.HMAC,
+		OIDC:              yml.OIDC,
+		Policies:          yml.Policies,
+		HeaderRules:       yml.HeaderRules,
 	}
 }
@@ -0,0 +1,199 @@
+// internal/expr/env.go
+package expr
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"access-proxy/internal/reqctx"
+)
+
+// Env данные запроса, доступные выражениям
+type Env struct {
+	Method     string
+	Path       string
+	Host       string
+	Route      string
+	Header     http.Header
+	Query      url.Values
+	ClientIP   net.IP
+	Principal  string
+	AuthMethod string
+	Scopes     []string
+	Time       time.Time
+}
+
+// EnvFromRequest собирает Env из запроса и его reqctx.Info
+func EnvFromRequest(r *http.Request) *Env {
+	env := &Env{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Host:   r.Host,
+		Header: r.Header,
+		Query:  r.URL.Query(),
+		Time:   time.Now(),
+	}
+	if info := reqctx.FromRequest(r); info != nil {
+		env.Route = info.Route
+		env.ClientIP = net.ParseIP(info.ClientIP)
+		env.Principal = info.Principal
+		env.AuthMethod = info.AuthMethod
+		env.Scopes = info.Scopes
+	}
+	return env
+}
+
+type variable struct {
+	typ  Type
+	eval func(*Env) interface{}
+}
+
+var variables = map[string]variable{
+	"request.method": {TypeString, func(e *Env) interface{} { return e.Method }},
+	"request.path":   {TypeString, func(e *Env) interface{} { return e.Path }},
+	"request.host":   {TypeString, func(e *Env) interface{} { return e.Host }},
+	"request.route":  {TypeString, func(e *Env) interface{} { return e.Route }},
+	"ip":             {TypeIP, func(e *Env) interface{} { return e.ClientIP }},
+
+	"identity.principal":     {TypeString, func(e *Env) interface{} { return e.Principal }},
+	"identity.method":        {TypeString, func(e *Env) interface{} { return e.AuthMethod }},
+	"identity.scopes":        {TypeList, func(e *Env) interface{} { return nonNil(e.Scopes) }},
+	"identity.authenticated": {TypeBool, func(e *Env) interface{} { return e.Principal != "" }},
+
+	// Время в UTC
+	"now.hour":    {TypeNumber, func(e *Env) interface{} { return float64(e.Time.UTC().Hour()) }},
+	"now.minute":  {TypeNumber, func(e *Env) interface{} { return float64(e.Time.UTC().Minute()) }},
+	"now.weekday": {TypeString, func(e *Env) interface{} { return strings.ToLower(e.Time.UTC().Weekday().String()[:3]) }},
+	"now.unix":    {TypeNumber, func(e *Env) interface{} { return float64(e.Time.Unix()) }},
+}
+
+// anyType аргумент функции, тип которого проверяет сама функция
+const anyType Type = -1
+
+type function struct {
+	args     []Type
+	variadic bool // последний аргумент можно повторять
+	result   Type
+	compile  func(args []*node) (func(*Env) interface{}, error)
+}
+
+var functions = map[string]function{
+	"header": {args: []Type{TypeString}, result: TypeString, compile: compileHeader},
+	"query":  {args: []Type{TypeString}, result: TypeString, compile: compileQuery},
+	"cookie": {args: []Type{TypeString}, result: TypeString, compile: compileCookie},
+	"cidr":   {args: []Type{TypeString}, variadic: true, result: TypeNet, compile: compileCIDR},
+
+	"lower": {args: []Type{TypeString}, result: TypeString, compile: stringFunc(strings.ToLower)},
+	"upper": {args: []Type{TypeString}, result: TypeString, compile: stringFunc(strings.ToUpper)},
+
+	"startsWith": {args: []Type{TypeString, TypeString}, result: TypeBool, compile: stringPredicate(strings.HasPrefix)},
+	"endsWith":   {args: []Type{TypeString, TypeString}, result: TypeBool, compile: stringPredicate(strings.HasSuffix)},
+	"contains":   {args: []Type{TypeString, TypeString}, result: TypeBool, compile: stringPredicate(strings.Contains)},
+	"matches":    {args: []Type{TypeString, TypeString}, result: TypeBool, compile: compileMatches},
+
+	"len": {args: []Type{anyType}, result: TypeNumber, compile: compileLen},
+}
+
+func compileHeader(args []*node) (func(*Env) interface{}, error) {
+	name := args[0].eval
+	return func(e *Env) interface{} {
+		return e.Header.Get(name(e).(string))
+	}, nil
+}
+
+func compileQuery(args []*node) (func(*Env) interface{}, error) {
+	name := args[0].eval
+	return func(e *Env) interface{} {
+		return e.Query.Get(name(e).(string))
+	}, nil
+}
+
+func compileCookie(args []*node) (func(*Env) interface{}, error) {
+	name := args[0].eval
+	return func(e *Env) interface{} {
+		r := http.Request{Header: e.Header}
+		if c, err := r.Cookie(name(e).(string)); err == nil {
+			return c.Value
+		}
+		return ""
+	}, nil
+}
+
+// compileCIDR разбирает сети при компиляции, поэтому аргументы должны быть литералами
+func compileCIDR(args []*node) (func(*Env) interface{}, error) {
+	nets := make([]*net.IPNet, 0, len(args))
+	for _, arg := range args {
+		if !arg.konst {
+			return nil, &Error{Pos: arg.pos, Msg: "cidr() arguments must be string literals"}
+		}
+		s := arg.eval(nil).(string)
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil {
+				bits := len(ip) * 8
+				if ip4 := ip.To4(); ip4 != nil {
+					ip, bits = ip4, 32
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, &Error{Pos: arg.pos, Msg: fmt.Sprintf("invalid CIDR %q", s)}
+		}
+		nets = append(nets, n)
+	}
+	return func(*Env) interface{} { return nets }, nil
+}
+
+func compileMatches(args []*node) (func(*Env) interface{}, error) {
+	pattern := args[1]
+	if !pattern.konst {
+		return nil, &Error{Pos: pattern.pos, Msg: "matches() pattern must be a string literal"}
+	}
+	re, err := regexp.Compile(pattern.eval(nil).(string))
+	if err != nil {
+		return nil, &Error{Pos: pattern.pos, Msg: fmt.Sprintf("invalid regular expression: %v", err)}
+	}
+	s := args[0].eval
+	return func(e *Env) interface{} {
+		return re.MatchString(s(e).(string))
+	}, nil
+}
+
+func compileLen(args []*node) (func(*Env) interface{}, error) {
+	arg := args[0].eval
+	switch args[0].typ {
+	case TypeString:
+		return func(e *Env) interface{} { return float64(len(arg(e).(string))) }, nil
+	case TypeList:
+		return func(e *Env) interface{} { return float64(len(arg(e).([]string))) }, nil
+	}
+	return nil, &Error{Pos: args[0].pos, Msg: fmt.Sprintf("len() needs a string or list, got %s", args[0].typ)}
+}
+
+func stringFunc(f func(string) string) func([]*node) (func(*Env) interface{}, error) {
+	return func(args []*node) (func(*Env) interface{}, error) {
+		s := args[0].eval
+		return func(e *Env) interface{} { return f(s(e).(string)) }, nil
+	}
+}
+
+func stringPredicate(f func(s, sub string) bool) func([]*node) (func(*Env) interface{}, error) {
+	return func(args []*node) (func(*Env) interface{}, error) {
+		a, b := args[0].eval, args[1].eval
+		return func(e *Env) interface{} { return f(a(e).(string), b(e).(string)) }, nil
+	}
+}
+
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
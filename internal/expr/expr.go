@@ -0,0 +1,146 @@
+// internal/expr/expr.go
+package expr
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Error ошибка компиляции с позицией в выражении
+type Error struct {
+	Source string
+	Pos    int // смещение от начала, с 0
+	Msg    string
+}
+
+func errorf(src string, pos int, format string, args ...interface{}) *Error {
+	return &Error{Source: src, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Error возвращает сообщение с колонкой и указателем на место ошибки
+func (e *Error) Error() string {
+	return fmt.Sprintf("column %d: %s\n    %s\n    %s^", e.Pos+1, e.Msg, e.Source, strings.Repeat(" ", e.Pos))
+}
+
+// Type тип значения выражения
+type Type int
+
+const (
+	TypeBool Type = iota
+	TypeString
+	TypeNumber
+	TypeList
+	TypeIP
+	TypeNet
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeBool:
+		return "bool"
+	case TypeString:
+		return "string"
+	case TypeNumber:
+		return "number"
+	case TypeList:
+		return "list"
+	case TypeIP:
+		return "ip"
+	case TypeNet:
+		return "cidr"
+	}
+	return "unknown"
+}
+
+// Program скомпилированное выражение. Безопасно для одновременного использования
+type Program struct {
+	src  string
+	root *node
+}
+
+// Compile разбирает и проверяет типы выражения
+func Compile(src string) (*Program, error) {
+	return compile(src, false)
+}
+
+func compile(src string, noIdentity bool) (*Program, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: src, tokens: tokens, noIdentity: noIdentity}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, errorf(src, t.pos, "unexpected %s", t)
+	}
+	return &Program{src: src, root: root}, nil
+}
+
+// CompileBool компилирует условие: выражение должно возвращать bool
+func CompileBool(src string) (*Program, error) {
+	return compileBool(src, false)
+}
+
+// CompileBoolBeforeAuth компилирует условие, которое вычисляется до аутентификации
+// (например, when маршрута). Переменные identity.* в нём ещё пусты, поэтому запрещены
+func CompileBoolBeforeAuth(src string) (*Program, error) {
+	return compileBool(src, true)
+}
+
+func compileBool(src string, noIdentity bool) (*Program, error) {
+	prog, err := compile(src, noIdentity)
+	if err != nil {
+		return nil, err
+	}
+	if prog.root.typ != TypeBool {
+		return nil, errorf(src, 0, "condition must be bool, got %s", prog.root.typ)
+	}
+	return prog, nil
+}
+
+// Source исходный текст выражения
+func (p *Program) Source() string {
+	return p.src
+}
+
+// Type тип результата
+func (p *Program) Type() Type {
+	return p.root.typ
+}
+
+// Bool вычисляет условие; для выражений другого типа — false
+func (p *Program) Bool(env *Env) bool {
+	b, _ := p.root.eval(env).(bool)
+	return b
+}
+
+// String вычисляет выражение и возвращает его значение строкой
+func (p *Program) String(env *Env) string {
+	switch v := p.root.eval(env).(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []string:
+		return strings.Join(v, ",")
+	case net.IP:
+		if v == nil {
+			return ""
+		}
+		return v.String()
+	case []*net.IPNet:
+		parts := make([]string, len(v))
+		for i, n := range v {
+			parts[i] = n.String()
+		}
+		return strings.Join(parts, ",")
+	}
+	return ""
+}
@@ -0,0 +1,130 @@
+package expr
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"access-proxy/internal/reqctx"
+)
+
+func testEnv() *Env {
+	return &Env{
+		Method:     "POST",
+		Path:       "/api/v1/orders",
+		Host:       "shop.example.com",
+		Route:      "api",
+		Header:     http.Header{"X-Tenant": {"acme"}, "Cookie": {"beta=1; theme=dark"}},
+		Query:      url.Values{"debug": {"true"}},
+		ClientIP:   net.ParseIP("10.1.2.3"),
+		Principal:  "bob@example.com",
+		AuthMethod: "jwt",
+		Scopes:     []string{"read", "write"},
+		Time:       time.Date(2024, 1, 1, 9, 30, 0, 0, time.UTC), // понедельник
+	}
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{`request.method == "POST"`, true},
+		{`startsWith(request.path, "/api/")`, true},
+		{`endsWith(request.host, ".example.com")`, true},
+		{`contains(request.path, "v2")`, false},
+		{`request.route != "web"`, true},
+		{`header("x-tenant") == "acme"`, true},
+		{`header("X-Missing") == ""`, true},
+		{`query("debug") == "true"`, true},
+		{`cookie("beta") == "1" && cookie("none") == ""`, true},
+		{`ip in cidr("10.0.0.0/8", "192.168.0.0/16")`, true},
+		{`ip in cidr("10.1.2.4")`, false},
+		{`ip == "10.1.2.3"`, true},
+		{`"10.1.2.3" != ip`, false},
+		{`identity.authenticated && identity.method == "jwt"`, true},
+		{`"write" in identity.scopes`, true},
+		{`"admin" in identity.scopes`, false},
+		{`"acme" in header("X-Tenant")`, true},
+		{`request.method in ["GET", "POST"]`, true},
+		{`len(identity.scopes) == 2 && len(request.path) > 5`, true},
+		{`now.hour == 9 && now.minute >= 30 && now.weekday == "mon"`, true},
+		{`now.unix > 1700000000`, true},
+		{`matches(identity.principal, "^[a-z]+@example\\.com$")`, true},
+		{`lower(upper(request.method)) == "post"`, true},
+		{`"b" < "a" || 2 <= 1`, false},
+	}
+	env := testEnv()
+	for _, tt := range tests {
+		prog, err := CompileBool(tt.src)
+		if err != nil {
+			t.Errorf("%s: %v", tt.src, err)
+			continue
+		}
+		if got := prog.Bool(env); got != tt.want {
+			t.Errorf("%s = %t, want %t", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestEvalEmptyEnv(t *testing.T) {
+	env := &Env{Header: http.Header{}, Query: url.Values{}}
+	for src, want := range map[string]bool{
+		`ip in cidr("0.0.0.0/0")`:   false,
+		`identity.authenticated`:    false,
+		`len(identity.scopes) == 0`: true,
+		`cookie("a") == ""`:         true,
+	} {
+		prog, err := CompileBool(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := prog.Bool(env); got != want {
+			t.Errorf("%s = %t, want %t", src, got, want)
+		}
+	}
+}
+
+func TestProgramString(t *testing.T) {
+	env := testEnv()
+	tests := map[string]string{
+		`identity.principal`:             "bob@example.com",
+		`identity.scopes`:                "read,write",
+		`ip`:                             "10.1.2.3",
+		`cidr("10.0.0.0/8", "fd00::/8")`: "10.0.0.0/8,fd00::/8",
+		`now.hour`:                       "9",
+		`1.5`:                            "1.5",
+		`identity.authenticated`:         "true",
+		`upper(header("X-Tenant"))`:      "ACME",
+	}
+	for src, want := range tests {
+		prog, err := Compile(src)
+		if err != nil {
+			t.Errorf("%s: %v", src, err)
+			continue
+		}
+		if got := prog.String(env); got != want {
+			t.Errorf("%s = %q, want %q", src, got, want)
+		}
+	}
+	if prog, _ := Compile(`ip`); prog.String(&Env{}) != "" {
+		t.Error("empty ip is not an empty string")
+	}
+}
+
+func TestEnvFromRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://shop.example.com/a?x=1", nil)
+	info := &reqctx.Info{Route: "api", ClientIP: "10.1.2.3", Principal: "bob", AuthMethod: "basic", Scopes: []string{"read"}}
+	r = r.WithContext(reqctx.NewContext(r.Context(), info))
+
+	env := EnvFromRequest(r)
+	if env.Method != "GET" || env.Path != "/a" || env.Host != "shop.example.com" || env.Query.Get("x") != "1" {
+		t.Errorf("request fields: %+v", env)
+	}
+	if env.Route != "api" || !env.ClientIP.Equal(net.ParseIP("10.1.2.3")) || env.Principal != "bob" || env.AuthMethod != "basic" {
+		t.Errorf("info fields: %+v", env)
+	}
+}
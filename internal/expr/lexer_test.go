@@ -0,0 +1,59 @@
+package expr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens, err := tokenize(`header("X-A") == 'it\'s' && now.hour >= 9.5 || !(x != "a\"b")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, tok := range tokens {
+		switch tok.kind {
+		case tokString:
+			got = append(got, "s:"+tok.str)
+		case tokNumber:
+			got = append(got, "n:"+tok.text)
+		case tokEOF:
+			got = append(got, "EOF")
+		default:
+			got = append(got, tok.text)
+		}
+	}
+	want := []string{"header", "(", "s:X-A", ")", "==", "s:it's", "&&", "now", ".", "hour", ">=", "n:9.5",
+		"||", "!", "(", "x", "!=", `s:a"b`, ")", "EOF"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	if tokens[4].pos != 14 {
+		t.Errorf("pos of == is %d, want 14", tokens[4].pos)
+	}
+}
+
+func TestTokenizeErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		pos int
+	}{
+		{`"unterminated`, 0},
+		{`a == 'x`, 5},
+		{`1.2.3`, 0},
+		{`a # b`, 2},
+		{`"\q"`, 0},
+	}
+	for _, tt := range tests {
+		_, err := tokenize(tt.src)
+		e, ok := err.(*Error)
+		if !ok {
+			t.Errorf("%s: got %v, want *Error", tt.src, err)
+			continue
+		}
+		if e.Pos != tt.pos {
+			t.Errorf("%s: pos %d, want %d (%v)", tt.src, e.Pos, tt.pos, e)
+		}
+	}
+}
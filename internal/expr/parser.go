@@ -0,0 +1,385 @@
+// internal/expr/parser.go
+package expr
+
+import (
+	"net"
+	"strings"
+)
+
+// node скомпилированный узел: тип известен при компиляции, eval вычисляет значение
+type node struct {
+	typ   Type
+	pos   int
+	eval  func(*Env) interface{}
+	konst bool // литерал: значение можно использовать при компиляции
+}
+
+func constant(typ Type, pos int, v interface{}) *node {
+	return &node{typ: typ, pos: pos, konst: true, eval: func(*Env) interface{} { return v }}
+}
+
+// parser рекурсивный спуск. Приоритет от низкого к высокому:
+//
+//	||  →  &&  →  !  →  сравнения и in  →  вызовы, поля, литералы
+//
+// '!' ниже сравнений, поэтому `!ip in cidr("10.0.0.0/8")` означает `!(ip in cidr(...))`
+type parser struct {
+	src        string
+	tokens     []token
+	i          int
+	noIdentity bool // identity.* запрещены: выражение вычисляется до аутентификации
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) isOp(op string) bool {
+	t := p.peek()
+	return t.kind == tokOp && t.text == op
+}
+
+func (p *parser) expect(op string) error {
+	if !p.isOp(op) {
+		t := p.peek()
+		return errorf(p.src, t.pos, "expected %q, got %s", op, t)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) parseExpr() (*node, error) {
+	return p.parseOr()
+}
+
+func (p *parser) parseOr() (*node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		op := p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.requireBool(op, left, right); err != nil {
+			return nil, err
+		}
+		l, r := left.eval, right.eval
+		left = &node{typ: TypeBool, pos: left.pos, eval: func(env *Env) interface{} {
+			return l(env).(bool) || r(env).(bool)
+		}}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (*node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		op := p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.requireBool(op, left, right); err != nil {
+			return nil, err
+		}
+		l, r := left.eval, right.eval
+		left = &node{typ: TypeBool, pos: left.pos, eval: func(env *Env) interface{} {
+			return l(env).(bool) && r(env).(bool)
+		}}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (*node, error) {
+	if !p.isOp("!") {
+		return p.parseComparison()
+	}
+	op := p.next()
+	operand, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.requireBool(op, operand); err != nil {
+		return nil, err
+	}
+	inner := operand.eval
+	return &node{typ: TypeBool, pos: op.pos, eval: func(env *Env) interface{} {
+		return !inner(env).(bool)
+	}}, nil
+}
+
+func (p *parser) requireBool(op token, operands ...*node) error {
+	for _, n := range operands {
+		if n.typ != TypeBool {
+			return errorf(p.src, n.pos, "operator %s needs bool operands, got %s", op, n.typ)
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseComparison() (*node, error) {
+	left, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	isCmp := t.kind == tokOp && strings.Contains(" == != < <= > >= ", " "+t.text+" ")
+	if !isCmp && !(t.kind == tokIdent && t.text == "in") {
+		return left, nil
+	}
+	op := p.next()
+	right, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+
+	var eval func(env *Env) interface{}
+	if op.text == "in" {
+		eval, err = p.compileIn(op, left, right)
+	} else {
+		eval, err = p.compileCompare(op, left, right)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &node{typ: TypeBool, pos: left.pos, eval: eval}, nil
+}
+
+func (p *parser) compileIn(op token, left, right *node) (func(*Env) interface{}, error) {
+	l, r := left.eval, right.eval
+	switch {
+	case left.typ == TypeString && right.typ == TypeList:
+		return func(env *Env) interface{} {
+			s := l(env).(string)
+			for _, item := range r(env).([]string) {
+				if item == s {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case left.typ == TypeString && right.typ == TypeString:
+		return func(env *Env) interface{} {
+			return strings.Contains(r(env).(string), l(env).(string))
+		}, nil
+	case left.typ == TypeIP && right.typ == TypeNet:
+		return func(env *Env) interface{} {
+			ip := l(env).(net.IP)
+			if ip == nil {
+				return false
+			}
+			for _, n := range r(env).([]*net.IPNet) {
+				if n.Contains(ip) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	return nil, errorf(p.src, op.pos, "cannot use %s in %s (use string in list, string in string or ip in cidr)", left.typ, right.typ)
+}
+
+func (p *parser) compileCompare(op token, left, right *node) (func(*Env) interface{}, error) {
+	// IP сравнивается со строковым литералом адреса
+	if left.typ == TypeIP && right.typ == TypeString && right.konst {
+		return p.compareIP(op, left, right)
+	}
+	if left.typ == TypeString && left.konst && right.typ == TypeIP {
+		return p.compareIP(op, right, left)
+	}
+
+	if left.typ != right.typ {
+		return nil, errorf(p.src, op.pos, "cannot compare %s with %s", left.typ, right.typ)
+	}
+	l, r := left.eval, right.eval
+
+	switch op.text {
+	case "==", "!=":
+		if left.typ == TypeList || left.typ == TypeNet || left.typ == TypeIP {
+			return nil, errorf(p.src, op.pos, "operator %s is not defined for %s", op, left.typ)
+		}
+		negate := op.text == "!="
+		return func(env *Env) interface{} {
+			return (l(env) == r(env)) != negate
+		}, nil
+	}
+
+	switch left.typ {
+	case TypeNumber:
+		return func(env *Env) interface{} {
+			return compareOrdered(op.text, l(env).(float64), r(env).(float64))
+		}, nil
+	case TypeString:
+		return func(env *Env) interface{} {
+			return compareOrdered(op.text, l(env).(string), r(env).(string))
+		}, nil
+	}
+	return nil, errorf(p.src, op.pos, "operator %s is not defined for %s", op, left.typ)
+}
+
+func (p *parser) compareIP(op token, ipNode, strNode *node) (func(*Env) interface{}, error) {
+	if op.text != "==" && op.text != "!=" {
+		return nil, errorf(p.src, op.pos, "operator %s is not defined for ip", op)
+	}
+	want := net.ParseIP(strNode.eval(nil).(string))
+	if want == nil {
+		return nil, errorf(p.src, strNode.pos, "invalid IP address")
+	}
+	negate := op.text == "!="
+	l := ipNode.eval
+	return func(env *Env) interface{} {
+		return want.Equal(l(env).(net.IP)) != negate
+	}, nil
+}
+
+func compareOrdered[T float64 | string](op string, a, b T) bool {
+	switch op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	}
+	return a >= b
+}
+
+func (p *parser) parsePostfix() (*node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return constant(TypeString, t.pos, t.str), nil
+	case tokNumber:
+		return constant(TypeNumber, t.pos, t.num), nil
+	case tokIdent:
+		switch t.text {
+		case "true", "false":
+			return constant(TypeBool, t.pos, t.text == "true"), nil
+		}
+		if p.isOp("(") {
+			return p.parseCall(t)
+		}
+		return p.parseVariable(t)
+	case tokOp:
+		switch t.text {
+		case "(":
+			inner, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		case "[":
+			return p.parseList(t)
+		}
+	}
+	return nil, errorf(p.src, t.pos, "unexpected %s, expected a value", t)
+}
+
+func (p *parser) parseVariable(first token) (*node, error) {
+	name := first.text
+	for p.isOp(".") {
+		p.next()
+		t := p.next()
+		if t.kind != tokIdent {
+			return nil, errorf(p.src, t.pos, "expected field name after '.', got %s", t)
+		}
+		name += "." + t.text
+	}
+	v, ok := variables[name]
+	if !ok {
+		return nil, errorf(p.src, first.pos, "unknown identifier %q", name)
+	}
+	if p.noIdentity && strings.HasPrefix(name, "identity.") {
+		return nil, errorf(p.src, first.pos, "%s is not available here: the condition is evaluated before authentication", name)
+	}
+	return &node{typ: v.typ, pos: first.pos, eval: v.eval}, nil
+}
+
+func (p *parser) parseList(open token) (*node, error) {
+	var items []*node
+	for !p.isOp("]") {
+		if len(items) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		item, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if item.typ != TypeString {
+			return nil, errorf(p.src, item.pos, "list items must be strings, got %s", item.typ)
+		}
+		items = append(items, item)
+	}
+	p.next()
+
+	return &node{typ: TypeList, pos: open.pos, eval: func(env *Env) interface{} {
+		out := make([]string, len(items))
+		for i, item := range items {
+			out[i] = item.eval(env).(string)
+		}
+		return out
+	}}, nil
+}
+
+func (p *parser) parseCall(name token) (*node, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, errorf(p.src, name.pos, "unknown function %q", name.text)
+	}
+	p.next() // (
+
+	var args []*node
+	for !p.isOp(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next() // )
+
+	if len(args) < len(fn.args) || (!fn.variadic && len(args) > len(fn.args)) {
+		return nil, errorf(p.src, name.pos, "%s() takes %d argument(s), got %d", name.text, len(fn.args), len(args))
+	}
+	for i, arg := range args {
+		want := fn.args[min(i, len(fn.args)-1)]
+		if want != anyType && arg.typ != want {
+			return nil, errorf(p.src, arg.pos, "argument %d of %s() must be %s, got %s", i+1, name.text, want, arg.typ)
+		}
+	}
+
+	eval, err := fn.compile(args)
+	if err != nil {
+		if e, ok := err.(*Error); ok {
+			e.Source = p.src
+			return nil, e
+		}
+		return nil, errorf(p.src, name.pos, "%s(): %v", name.text, err)
+	}
+	return &node{typ: fn.result, pos: name.pos, eval: eval}, nil
+}
@@ -0,0 +1,103 @@
+package expr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		pos int
+		msg string
+	}{
+		{`header("X-Beta") == 1`, 17, "cannot compare string with number"},
+		{`request.nope`, 0, `unknown identifier "request.nope"`},
+		{`nope("x")`, 0, "unknown function"},
+		{`header()`, 0, "argument"},
+		{`request.path &&`, 15, "expected a value"},
+		{`(request.path == "/"`, 20, `expected ")"`},
+		{`request.path == "/" request.host`, 20, "unexpected"},
+		{`ip in "10.0.0.0/8"`, 3, "cannot use ip in string"},
+		{`ip < "10.0.0.1"`, 3, "not defined for ip"},
+		{`ip == "not-an-ip"`, 6, "invalid IP address"},
+		{`ip in cidr(request.path)`, 11, "string literals"},
+		{`ip in cidr("10.0.0.0/33")`, 11, "invalid CIDR"},
+		{`matches(request.path, "(")`, 22, "invalid regular expression"},
+		{`len(1)`, 4, "len() needs a string or list"},
+		{`identity.scopes == ["a"]`, 16, "not defined for list"},
+		{`!request.path`, 1, "needs bool operands"},
+	}
+	for _, tt := range tests {
+		_, err := Compile(tt.src)
+		e, ok := err.(*Error)
+		if !ok {
+			t.Errorf("%s: got %v, want *Error", tt.src, err)
+			continue
+		}
+		if e.Pos != tt.pos || !strings.Contains(e.Msg, tt.msg) {
+			t.Errorf("%s: got column %d %q, want column %d %q", tt.src, e.Pos+1, e.Msg, tt.pos+1, tt.msg)
+		}
+	}
+}
+
+func TestErrorPointsAtColumn(t *testing.T) {
+	_, err := Compile(`header("X-Beta") == 1`)
+	want := "column 18: cannot compare string with number\n    header(\"X-Beta\") == 1\n                     ^"
+	if err == nil || err.Error() != want {
+		t.Errorf("got\n%v\nwant\n%s", err, want)
+	}
+}
+
+func TestCompileBool(t *testing.T) {
+	if _, err := CompileBool(`request.path`); err == nil || !strings.Contains(err.Error(), "condition must be bool") {
+		t.Errorf("string condition: %v", err)
+	}
+	if _, err := CompileBool(`request.path == "/"`); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCompileBoolBeforeAuth(t *testing.T) {
+	for _, src := range []string{
+		`identity.authenticated`,
+		`request.path == "/" && identity.principal == "bob"`,
+		`"admin" in identity.scopes`,
+		`len(identity.method) > 0`,
+	} {
+		_, err := CompileBoolBeforeAuth(src)
+		if err == nil || !strings.Contains(err.Error(), "before authentication") {
+			t.Errorf("%s: got %v", src, err)
+		}
+		if _, err := CompileBool(src); err != nil {
+			t.Errorf("%s: rejected after auth: %v", src, err)
+		}
+	}
+	if _, err := CompileBoolBeforeAuth(`header("X-Beta") == "1" && ip in cidr("10.0.0.0/8")`); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{`true || false && false`, true},
+		{`(true || false) && false`, false},
+		{`!false && false`, false},
+		{`!1 == 2`, true},
+		{`!ip in cidr("10.0.0.0/8")`, true},
+	}
+	env := &Env{}
+	for _, tt := range tests {
+		prog, err := CompileBool(tt.src)
+		if err != nil {
+			t.Errorf("%s: %v", tt.src, err)
+			continue
+		}
+		if got := prog.Bool(env); got != tt.want {
+			t.Errorf("%s = %t, want %t", tt.src, got, tt.want)
+		}
+	}
+}
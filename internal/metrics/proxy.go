@@ -0,0 +1,206 @@
+// internal/metrics/proxy.go
+package metrics
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ProxyMetrics набор метрик access-proxy
+type ProxyMetrics struct {
+	Registry *Registry
+
+	requests         *CounterVec
+	requestDuration  *HistogramVec
+	upstreamDuration *HistogramVec
+	upstreamErrors   *CounterVec
+	denials          *CounterVec
+	inFlight         *GaugeVec
+	shadowRequests   *CounterVec
+	shadowDuration   *HistogramVec
+	canaryRequests   *CounterVec
+	rateLimitEvicted *CounterVec
+	rateLimitStore   *CounterVec
+	queueDepth       *GaugeVec
+	queueWait        *HistogramVec
+	queueRejected    *CounterVec
+	concurrencyLimit *GaugeVec
+	bandwidthBytes   *CounterVec
+	bandwidthWait    *CounterVec
+	quotaStore       *CounterVec
+}
+
+func NewProxyMetrics() *ProxyMetrics {
+	reg := NewRegistry()
+	return &ProxyMetrics{
+		Registry: reg,
+		requests: reg.NewCounterVec("access_proxy_requests_total",
+			"Total HTTP requests by route, method and status.", "route", "method", "status"),
+		requestDuration: reg.NewHistogramVec("access_proxy_request_duration_seconds",
+			"Total request duration in seconds.", DefaultBuckets, "route", "method"),
+		upstreamDuration: reg.NewHistogramVec("access_proxy_upstream_duration_seconds",
+			"Upstream call duration in seconds.", DefaultBuckets, "route"),
+		upstreamErrors: reg.NewCounterVec("access_proxy_upstream_errors_total",
+			"Upstream call errors by type.", "type"),
+		denials: reg.NewCounterVec("access_proxy_denied_requests_total",
+			"Requests denied by access-proxy by reason.", "reason"),
+		inFlight: reg.NewGaugeVec("access_proxy_requests_in_flight",
+			"Requests currently being served."),
+		shadowRequests: reg.NewCounterVec("access_proxy_shadow_requests_total",
+			"Mirrored requests sent to shadow upstreams by route and status or error type.", "route", "status"),
+		shadowDuration: reg.NewHistogramVec("access_proxy_shadow_duration_seconds",
+			"Shadow upstream call duration in seconds.", DefaultBuckets, "route"),
+		canaryRequests: reg.NewCounterVec("access_proxy_canary_requests_total",
+			"Requests on canary-split routes by served variant.", "route", "variant"),
+		rateLimitEvicted: reg.NewCounterVec("access_proxy_ratelimit_evictions_total",
+			"Clients whose rate limit state was evicted, by reason (idle or capacity).", "reason"),
+		rateLimitStore: reg.NewCounterVec("access_proxy_ratelimit_store_errors_total",
+			"Rate limit checks that failed because the shared store was unavailable."),
+		queueDepth: reg.NewGaugeVec("access_proxy_concurrency_queue_depth",
+			"Requests waiting for a concurrency slot, by scope (client, route, global).", "scope"),
+		queueWait: reg.NewHistogramVec("access_proxy_concurrency_queue_wait_seconds",
+			"Time requests spent waiting for a concurrency slot, by scope.", DefaultBuckets, "scope"),
+		queueRejected: reg.NewCounterVec("access_proxy_concurrency_rejected_total",
+			"Requests rejected by concurrency limits, by scope and reason (queue_full or timeout).", "scope", "reason"),
+		concurrencyLimit: reg.NewGaugeVec("access_proxy_concurrency_limit",
+			"Current adaptive concurrency limit by route.", "route"),
+		bandwidthBytes: reg.NewCounterVec("access_proxy_bandwidth_bytes_total",
+			"Body bytes passed through bandwidth limits, by direction (upload or download).", "direction"),
+		bandwidthWait: reg.NewCounterVec("access_proxy_bandwidth_throttled_seconds_total",
+			"Time spent waiting for bandwidth limits, by direction.", "direction"),
+		quotaStore: reg.NewCounterVec("access_proxy_quota_store_errors_total",
+			"Quota checks that failed because the quota store was unavailable."),
+	}
+}
+
+func (m *ProxyMetrics) RequestStarted() {
+	m.inFlight.Inc()
+}
+
+func (m *ProxyMetrics) RequestFinished(route, method string, status int, duration time.Duration) {
+	m.inFlight.Dec()
+	m.requests.Inc(route, NormalizeMethod(method), strconv.Itoa(status))
+	m.requestDuration.Observe(duration.Seconds(), route, NormalizeMethod(method))
+}
+
+func (m *ProxyMetrics) UpstreamFinished(route string, duration time.Duration, errType string) {
+	m.upstreamDuration.Observe(duration.Seconds(), route)
+	if errType != "" {
+		m.upstreamErrors.Inc(errType)
+	}
+}
+
+// ShadowFinished учитывает зеркальный запрос: статус ответа или тип ошибки
+func (m *ProxyMetrics) ShadowFinished(route string, status int, duration time.Duration, errType string) {
+	if errType != "" {
+		m.shadowRequests.Inc(route, errType)
+		return
+	}
+	m.shadowRequests.Inc(route, strconv.Itoa(status))
+	m.shadowDuration.Observe(duration.Seconds(), route)
+}
+
+func (m *ProxyMetrics) CanaryServed(route, variant string) {
+	m.canaryRequests.Inc(route, variant)
+}
+
+func (m *ProxyMetrics) RateLimitEvicted(reason string, n int) {
+	m.rateLimitEvicted.Add(float64(n), reason)
+}
+
+func (m *ProxyMetrics) RateLimitStoreFailed() {
+	m.rateLimitStore.Inc()
+}
+
+// ConcurrencyQueued запрос встал в очередь за местом
+func (m *ProxyMetrics) ConcurrencyQueued(scope string) {
+	m.queueDepth.Inc(scope)
+}
+
+// ConcurrencyDequeued запрос вышел из очереди: получил место или отказ
+func (m *ProxyMetrics) ConcurrencyDequeued(scope string, wait time.Duration) {
+	m.queueDepth.Dec(scope)
+	m.queueWait.Observe(wait.Seconds(), scope)
+}
+
+func (m *ProxyMetrics) ConcurrencyRejected(scope, reason string) {
+	m.queueRejected.Inc(scope, reason)
+}
+
+func (m *ProxyMetrics) ConcurrencyLimitChanged(route string, limit int) {
+	m.concurrencyLimit.Set(float64(limit), route)
+}
+
+func (m *ProxyMetrics) QuotaStoreFailed() {
+	m.quotaStore.Inc()
+}
+
+func (m *ProxyMetrics) BandwidthTransferred(direction string, n int) {
+	m.bandwidthBytes.Add(float64(n), direction)
+}
+
+// BandwidthThrottled передача ждала лимита скорости
+func (m *ProxyMetrics) BandwidthThrottled(direction string, wait time.Duration) {
+	m.bandwidthWait.Add(wait.Seconds(), direction)
+}
+
+// TrackRateLimitClients публикует число клиентов, отслеживаемых rate limiter
+func (m *ProxyMetrics) TrackRateLimitClients(tracked func() int) {
+	m.Registry.NewGaugeFunc("access_proxy_ratelimit_tracked_clients",
+		"Clients with rate limit state currently held in memory.",
+		func() float64 { return float64(tracked()) })
+}
+
+func (m *ProxyMetrics) Denied(reason string) {
+	m.denials.Inc(reason)
+}
+
+// NormalizeMethod сводит нестандартные методы к "OTHER", чтобы метка не разрасталась
+func NormalizeMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+// ClassifyError определяет тип ошибки вызова upstream
+func ClassifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var recordErr tls.RecordHeaderError
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection_refused"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "connection_reset"
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthErr),
+		errors.As(err, &hostnameErr), errors.As(err, &recordErr):
+		return "tls"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	}
+	return "other"
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestNormalizeMethod(t *testing.T) {
+	for method, want := range map[string]string{
+		"GET":      "GET",
+		"DELETE":   "DELETE",
+		"PROPFIND": "OTHER",
+		"get":      "OTHER",
+		"":         "OTHER",
+	} {
+		if got := NormalizeMethod(method); got != want {
+			t.Errorf("NormalizeMethod(%q) = %q, want %q", method, got, want)
+		}
+	}
+}
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+func TestClassifyError(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{context.Canceled, "canceled"},
+		{fmt.Errorf("wrapped: %w", context.DeadlineExceeded), "timeout"},
+		{&net.DNSError{Err: "no such host", Name: "upstream"}, "dns"},
+		{refused, "connection_refused"},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), "connection_reset"},
+		{&net.OpError{Op: "read", Err: timeoutErr{}}, "timeout"},
+		{errors.New("boom"), "other"},
+	}
+	for _, tt := range tests {
+		if got := ClassifyError(tt.err); got != tt.want {
+			t.Errorf("ClassifyError(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
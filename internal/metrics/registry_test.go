@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, r *Registry) string {
+	t.Helper()
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	return b.String()
+}
+
+func TestCounterVecText(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_total", "Test counter.", "route", "status")
+	c.Inc("api", "200")
+	c.Add(2, "api", "200")
+	c.Inc("web", "500")
+
+	want := `# HELP test_total Test counter.
+# TYPE test_total counter
+test_total{route="api",status="200"} 3
+test_total{route="web",status="500"} 1
+`
+	if got := scrape(t, r); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGaugeVec(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGaugeVec("test_in_flight", "Test gauge.")
+	g.Inc()
+	g.Inc()
+	g.Dec()
+	if got := scrape(t, r); !strings.Contains(got, "test_in_flight 1\n") {
+		t.Errorf("gauge not 1:\n%s", got)
+	}
+	g.Set(7.5)
+	if got := scrape(t, r); !strings.Contains(got, "test_in_flight 7.5\n") {
+		t.Errorf("gauge not 7.5:\n%s", got)
+	}
+}
+
+func TestHistogramVecBuckets(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogramVec("test_seconds", "Test histogram.", []float64{0.1, 1}, "route")
+	h.Observe(0.05, "api")
+	h.Observe(0.5, "api")
+	h.Observe(5, "api")
+
+	got := scrape(t, r)
+	for _, line := range []string{
+		`test_seconds_bucket{route="api",le="0.1"} 1`,
+		`test_seconds_bucket{route="api",le="1"} 2`,
+		`test_seconds_bucket{route="api",le="+Inf"} 3`,
+		`test_seconds_sum{route="api"} 5.55`,
+		`test_seconds_count{route="api"} 3`,
+	} {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, got)
+		}
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_total", "Test counter.", "path")
+	c.Inc("a\"b\\c\nd")
+	if got := scrape(t, r); !strings.Contains(got, `test_total{path="a\"b\\c\nd"} 1`) {
+		t.Errorf("label not escaped:\n%s", got)
+	}
+}
+
+func TestSeriesOverflow(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_total", "Test counter.", "route")
+	for i := 0; i < MaxSeries+10; i++ {
+		c.Inc(fmt.Sprintf("r%d", i))
+	}
+	// Уже известная серия продолжает считаться отдельно
+	c.Inc("r0")
+
+	got := scrape(t, r)
+	if n := strings.Count(got, "\ntest_total{"); n != MaxSeries+1 {
+		t.Errorf("got %d series, want %d", n, MaxSeries+1)
+	}
+	if !strings.Contains(got, `test_total{route="other"} 10`) {
+		t.Errorf("overflow series missing:\n%s", got[len(got)-200:])
+	}
+	if !strings.Contains(got, `test_total{route="r0"} 2`) {
+		t.Error("existing series was not updated after overflow")
+	}
+}
+
+func TestWrongLabelCountPanics(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_total", "Test counter.", "route")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on wrong label count")
+		}
+	}()
+	c.Inc("a", "b")
+}
+
+func TestGaugeFunc(t *testing.T) {
+	r := NewRegistry()
+	n := 3
+	r.NewGaugeFunc("test_tracked", "Test gauge func.", func() float64 { return float64(n) })
+	n = 4
+	if got := scrape(t, r); !strings.Contains(got, "test_tracked 4\n") {
+		t.Errorf("gauge func not evaluated at scrape:\n%s", got)
+	}
+}
@@ -0,0 +1,51 @@
+// internal/middleware/access_log.go
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"access-proxy/internal/accesslog"
+	"access-proxy/internal/redact"
+	"access-proxy/internal/reqctx"
+)
+
+// AccessLogMiddleware пишет одну запись журнала доступа на каждый запрос.
+// Ставится сразу после RequestInfoMiddleware, чтобы видеть отказы остальных middleware.
+// Параметры запроса и Referer проходят через redactor
+func AccessLogMiddleware(accessLog *accesslog.Logger, redactor *redact.Redactor) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info := reqctx.FromRequest(r)
+			if info == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			defer func() {
+				accessLog.Log(&accesslog.Entry{
+					Time:             info.Start,
+					RequestID:        info.RequestID,
+					ClientIP:         info.ClientIP,
+					User:             info.Principal,
+					Method:           r.Method,
+					Path:             r.URL.Path,
+					Query:            redactor.Query(r.URL.RawQuery),
+					Proto:            r.Proto,
+					Status:           info.Status,
+					Bytes:            info.BytesWritten,
+					Duration:         time.Since(info.Start),
+					Route:            info.Route,
+					Upstream:         info.Upstream,
+					UpstreamDuration: info.UpstreamDuration,
+					Decision:         info.Decision,
+					DenyReason:       info.DenyReason,
+					UserAgent:        r.UserAgent(),
+					Referer:          redactor.URLString(r.Referer()),
+				})
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
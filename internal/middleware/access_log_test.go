@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"access-proxy/internal/accesslog"
+	"access-proxy/internal/redact"
+)
+
+func TestAccessLogMiddlewareWritesRedactedEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	log, err := accesslog.New(accesslog.Options{Format: accesslog.FormatJSON, Output: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	redactor, err := redact.New(redact.Rules{QueryParams: []string{"token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	})
+	chain := RequestInfoMiddleware(func(*http.Request) string { return "api" }, nil)(
+		AccessLogMiddleware(log, redactor)(handler))
+
+	req := httptest.NewRequest(http.MethodPost, "/orders?token=secret&page=1", nil)
+	chain.ServeHTTP(httptest.NewRecorder(), req)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("invalid log line %q: %v", data, err)
+	}
+	if entry["status"] != 201.0 || entry["bytes"] != 5.0 || entry["route"] != "api" || entry["method"] != "POST" {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+	if q, _ := entry["query"].(string); q == "" || q == "token=secret&page=1" {
+		t.Errorf("query not redacted: %q", q)
+	}
+	if entry["request_id"] == "" {
+		t.Error("request id missing")
+	}
+}
@@ -0,0 +1,124 @@
+// internal/middleware/api_key.go
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"access-proxy/internal/auth"
+	"access-proxy/internal/reqctx"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+// Заголовки с личностью клиента, которые прокси передаёт в upstream.
+// Одноимённые заголовки от клиента удаляются (см. IdentityHeaders)
+const (
+	PrincipalHeader = "X-Auth-Principal"
+	ScopesHeader    = "X-Auth-Scopes"
+)
+
+// APIKeyOptions откуда брать ключ и какие маршруты его требуют
+type APIKeyOptions struct {
+	Header     string
+	QueryParam string
+	Routes     []string // пусто — все маршруты
+}
+
+// APIKeyMiddleware проверяет API ключ по хранилищу и записывает principal в reqctx.Info.
+// Ключ не передаётся в upstream
+func APIKeyMiddleware(log logger.Logger, store *auth.KeyStore, opts APIKeyOptions) func(http.Handler) http.Handler {
+	routes := make(map[string]bool, len(opts.Routes))
+	for _, route := range opts.Routes {
+		routes[route] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info := reqctx.FromRequest(r)
+			if info == nil || (len(routes) > 0 && !routes[info.Route]) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			raw := extractAPIKey(r, opts)
+			if raw == "" {
+				denyAPIKey(w, r, opts, "api_key_missing", "API key is required")
+				return
+			}
+
+			key, ok := store.Lookup(raw)
+			if !ok {
+				log.Warnf("🔑 Invalid API key from %s: %s %s", info.ClientIP, r.Method, r.URL.Path)
+				denyAPIKey(w, r, opts, "api_key_invalid", "API key is invalid")
+				return
+			}
+			if key.Expired(time.Now()) {
+				log.Warnf("🔑 Expired API key %s used from %s", key.ID, info.ClientIP)
+				denyAPIKey(w, r, opts, "api_key_expired", "API key has expired")
+				return
+			}
+
+			info.Principal = key.ID
+			info.AuthMethod = "api_key"
+			info.Scopes = key.Scopes
+			info.RateLimitTier = key.Tier
+
+			r.Header.Set(PrincipalHeader, key.ID)
+			if len(key.Scopes) > 0 {
+				r.Header.Set(ScopesHeader, strings.Join(key.Scopes, " "))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// extractAPIKey достаёт ключ из заголовка или параметра запроса (заголовок важнее)
+// и убирает его из обоих мест, чтобы ключ не попал в upstream и его журналы
+func extractAPIKey(r *http.Request, opts APIKeyOptions) string {
+	var key string
+	if opts.Header != "" {
+		key = r.Header.Get(opts.Header)
+		r.Header.Del(opts.Header)
+	}
+	if opts.QueryParam != "" {
+		query := r.URL.Query()
+		if key == "" {
+			key = query.Get(opts.QueryParam)
+		}
+		if query.Has(opts.QueryParam) {
+			r.URL.RawQuery = removeQueryParam(r.URL.RawQuery, opts.QueryParam)
+		}
+	}
+	return key
+}
+
+// removeQueryParam убирает из сырой строки запроса все пары с именем name.
+// Остальные пары, их порядок и кодирование не меняются: upstream может
+// подписывать или кешировать запрос по исходной строке
+func removeQueryParam(rawQuery, name string) string {
+	parts := strings.Split(rawQuery, "&")
+	kept := parts[:0]
+	for _, part := range parts {
+		key, _, _ := strings.Cut(part, "=")
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+		if key != name {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+func denyAPIKey(w http.ResponseWriter, r *http.Request, opts APIKeyOptions, reason, message string) {
+	if opts.Header != "" {
+		w.Header().Set("WWW-Authenticate", `ApiKey header="`+opts.Header+`"`)
+	}
+	denyJSON(w, r, http.StatusUnauthorized, reason, map[string]interface{}{
+		"error":   reason,
+		"message": message,
+	})
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"access-proxy/internal/auth"
+	"access-proxy/internal/reqctx"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+func TestRemoveQueryParam(t *testing.T) {
+	tests := []struct {
+		raw, want string
+	}{
+		{"api_key=s&b=2", "b=2"},
+		{"a=1&api_key=s", "a=1"},
+		{"api_key=s", ""},
+		{"z=1&api_key=s&a=%2F+x&api_key=t", "z=1&a=%2F+x"},
+		{"api%5Fkey=s&sig=AbC%3D", "sig=AbC%3D"},
+		{"api_keys=1&x=api_key", "api_keys=1&x=api_key"},
+	}
+	for _, tt := range tests {
+		if got := removeQueryParam(tt.raw, "api_key"); got != tt.want {
+			t.Errorf("removeQueryParam(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	os.WriteFile(path, []byte("keys:\n  - id: billing\n    hash: sha256:"+auth.HashKey("s3cret")+"\n    scopes: [read]\n"), 0o600)
+	store, err := auth.LoadKeyStore(path, logger.New("test", logger.LevelInfo, logger.ModeDev))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var upstream *http.Request
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { upstream = r })
+	chain := RequestInfoMiddleware(func(*http.Request) string { return "api" }, nil)(
+		APIKeyMiddleware(logger.New("test", logger.LevelInfo, logger.ModeDev), store,
+			APIKeyOptions{Header: "X-Api-Key", QueryParam: "api_key"})(handler))
+
+	tests := []struct {
+		name   string
+		url    string
+		header string
+		want   int
+	}{
+		{"missing", "/x", "", http.StatusUnauthorized},
+		{"invalid", "/x", "nope", http.StatusUnauthorized},
+		{"header", "/x?b=%2f&a=1", "s3cret", http.StatusOK},
+		{"query", "/x?b=%2f&api_key=s3cret&a=1", "", http.StatusOK},
+		// Заголовок важнее, но ключ из запроса тоже не уходит в upstream
+		{"header and query", "/x?b=%2f&api_key=other&a=1", "s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		upstream = nil
+		req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+		if tt.header != "" {
+			req.Header.Set("X-Api-Key", tt.header)
+		}
+		rec := httptest.NewRecorder()
+		chain.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, rec.Code, tt.want)
+			continue
+		}
+		if tt.want != http.StatusOK {
+			continue
+		}
+		if upstream.URL.RawQuery != "b=%2f&a=1" || upstream.Header.Get("X-Api-Key") != "" {
+			t.Errorf("%s: key leaked or query changed: %q %v", tt.name, upstream.URL.RawQuery, upstream.Header)
+		}
+		if info := reqctx.FromRequest(upstream); info.Principal != "billing" || upstream.Header.Get(PrincipalHeader) != "billing" {
+			t.Errorf("%s: principal not set", tt.name)
+		}
+	}
+}
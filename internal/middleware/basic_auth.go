@@ -0,0 +1,65 @@
+// internal/middleware/basic_auth.go
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"access-proxy/internal/auth"
+	"access-proxy/internal/reqctx"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+// BasicAuthOptions маршруты под защитой и параметры ответа
+type BasicAuthOptions struct {
+	Routes            []string // пусто — все маршруты
+	Realm             string
+	KeepAuthorization bool // передавать Authorization в upstream
+}
+
+// BasicAuthMiddleware проверяет HTTP Basic по файлу htpasswd
+func BasicAuthMiddleware(log logger.Logger, users *auth.Htpasswd, opts BasicAuthOptions) func(http.Handler) http.Handler {
+	routes := make(map[string]bool, len(opts.Routes))
+	for _, route := range opts.Routes {
+		routes[route] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info := reqctx.FromRequest(r)
+			if info == nil || (len(routes) > 0 && !routes[info.Route]) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, password, ok := r.BasicAuth()
+			if !ok {
+				denyBasicAuth(w, r, opts.Realm, "basic_auth_missing", "Authentication required")
+				return
+			}
+			if !users.Verify(user, password) {
+				log.Warnf("🔐 Basic auth failed for user %q from %s", user, info.ClientIP)
+				denyBasicAuth(w, r, opts.Realm, "basic_auth_invalid", "Invalid username or password")
+				return
+			}
+
+			if info.Principal == "" {
+				info.Principal = user
+				info.AuthMethod = "basic"
+			}
+			if !opts.KeepAuthorization {
+				r.Header.Del("Authorization")
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func denyBasicAuth(w http.ResponseWriter, r *http.Request, realm, reason, message string) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, realm))
+	denyJSON(w, r, http.StatusUnauthorized, reason, map[string]interface{}{
+		"error":   reason,
+		"message": message,
+	})
+}
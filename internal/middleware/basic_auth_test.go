@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"access-proxy/internal/auth"
+	"access-proxy/internal/reqctx"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+func TestBasicAuthMiddleware(t *testing.T) {
+	sum := sha1.Sum([]byte("pw"))
+	path := filepath.Join(t.TempDir(), ".htpasswd")
+	os.WriteFile(path, []byte("alice:{SHA}"+base64.StdEncoding.EncodeToString(sum[:])+"\n"), 0o600)
+	log := logger.New("test", logger.LevelInfo, logger.ModeDev)
+	users, err := auth.LoadHtpasswd(path, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var principal, authorization string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal = reqctx.FromRequest(r).Principal
+		authorization = r.Header.Get("Authorization")
+	})
+	chain := RequestInfoMiddleware(func(r *http.Request) string { return strings.Trim(r.URL.Path, "/") }, nil)(
+		BasicAuthMiddleware(log, users, BasicAuthOptions{Routes: []string{"admin"}, Realm: "Admin"})(handler))
+
+	tests := []struct {
+		path, user, password string
+		want                 int
+	}{
+		{"/public", "", "", http.StatusOK},
+		{"/admin", "", "", http.StatusUnauthorized},
+		{"/admin", "alice", "bad", http.StatusUnauthorized},
+		{"/admin", "alice", "pw", http.StatusOK},
+	}
+	for _, tt := range tests {
+		principal, authorization = "", ""
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.user != "" {
+			req.SetBasicAuth(tt.user, tt.password)
+		}
+		rec := httptest.NewRecorder()
+		chain.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: got %d, want %d", tt.path, tt.user, rec.Code, tt.want)
+		}
+		if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != `Basic realm="Admin", charset="UTF-8"` {
+			t.Errorf("challenge = %q", rec.Header().Get("WWW-Authenticate"))
+		}
+		if tt.path == "/admin" && rec.Code == http.StatusOK && (principal != "alice" || authorization != "") {
+			t.Errorf("principal %q, authorization forwarded %q", principal, authorization)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// internal/middleware/body_capture.go
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"mime"
+	"strings"
+)
+
+// DefaultSkipContentTypes типы содержимого, тела которых не захватываются.
+// Значение с "/" на конце совпадает с любым подтипом
+var DefaultSkipContentTypes = []string{
+	"multipart/",
+	"image/",
+	"audio/",
+	"video/",
+	"font/",
+	"application/octet-stream",
+	"application/zip",
+	"application/gzip",
+	"application/pdf",
+	"application/grpc",
+}
+
+// BodyCaptureOptions ограничения захвата тел для логирования
+type BodyCaptureOptions struct {
+	MaxBytes         int      // сколько байт тела сохраняется
+	SampleRate       float64  // доля запросов, для которых захватываются тела (0..1)
+	SkipContentTypes []string // типы содержимого, которые не захватываются
+}
+
+// sampled решает, захватывать ли тела этого запроса
+func (o BodyCaptureOptions) sampled() bool {
+	if o.MaxBytes <= 0 || o.SampleRate <= 0 {
+		return false
+	}
+	return o.SampleRate >= 1 || rand.Float64() < o.SampleRate
+}
+
+// capturable проверяет, можно ли захватывать тело с таким Content-Type
+func (o BodyCaptureOptions) capturable(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	for _, skip := range o.SkipContentTypes {
+		skip = strings.ToLower(skip)
+		if mediaType == skip || (strings.HasSuffix(skip, "/") && strings.HasPrefix(mediaType, skip)) {
+			return false
+		}
+	}
+	return true
+}
+
+// limitedBuffer сохраняет не больше max байт и считает общий объём
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	max   int
+	total int64
+}
+
+func newLimitedBuffer(max int) *limitedBuffer {
+	return &limitedBuffer{max: max}
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.total += int64(len(p))
+	if room := b.max - b.buf.Len(); room > 0 {
+		if len(p) > room {
+			b.buf.Write(p[:room])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+func (b *limitedBuffer) Total() int64 {
+	return b.total
+}
+
+func (b *limitedBuffer) Truncated() bool {
+	return b.total > int64(b.buf.Len())
+}
+
+// captureReader копирует прочитанные upstream'ом байты в limitedBuffer,
+// не меняя того, как тело передаётся дальше
+type captureReader struct {
+	io.ReadCloser
+	buf *limitedBuffer
+}
+
+func (r *captureReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.buf.Write(p[:n])
+	}
+	return n, err
+}
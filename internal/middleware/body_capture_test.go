@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLimitedBuffer(t *testing.T) {
+	b := newLimitedBuffer(5)
+	b.Write([]byte("abc"))
+	b.Write([]byte("defgh"))
+
+	if string(b.Bytes()) != "abcde" || b.Total() != 8 || !b.Truncated() {
+		t.Errorf("got %q total=%d truncated=%v", b.Bytes(), b.Total(), b.Truncated())
+	}
+
+	b = newLimitedBuffer(5)
+	b.Write([]byte("abcde"))
+	if b.Truncated() {
+		t.Error("buffer of exact size reported as truncated")
+	}
+}
+
+func TestCaptureReaderPassesBodyThrough(t *testing.T) {
+	buf := newLimitedBuffer(4)
+	r := &captureReader{ReadCloser: io.NopCloser(strings.NewReader("hello world")), buf: buf}
+
+	data, err := io.ReadAll(r)
+	if err != nil || string(data) != "hello world" {
+		t.Fatalf("body changed: %q, %v", data, err)
+	}
+	if string(buf.Bytes()) != "hell" || buf.Total() != 11 {
+		t.Errorf("captured %q total=%d", buf.Bytes(), buf.Total())
+	}
+}
+
+func TestSampled(t *testing.T) {
+	tests := []struct {
+		opts BodyCaptureOptions
+		want bool
+	}{
+		{BodyCaptureOptions{MaxBytes: 10, SampleRate: 1}, true},
+		{BodyCaptureOptions{MaxBytes: 10, SampleRate: 0}, false},
+		{BodyCaptureOptions{MaxBytes: 0, SampleRate: 1}, false},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if got := tt.opts.sampled(); got != tt.want {
+				t.Fatalf("sampled(%+v) = %v, want %v", tt.opts, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestCapturable(t *testing.T) {
+	opts := BodyCaptureOptions{SkipContentTypes: DefaultSkipContentTypes}
+	for ct, want := range map[string]bool{
+		"":                                 true,
+		"application/json; charset=utf-8":  true,
+		"text/plain":                       true,
+		"multipart/form-data; boundary=xx": false,
+		"IMAGE/PNG":                        false,
+		"application/octet-stream":         false,
+		"application/grpc":                 false,
+	} {
+		if got := opts.capturable(ct); got != want {
+			t.Errorf("capturable(%q) = %v, want %v", ct, got, want)
+		}
+	}
+}
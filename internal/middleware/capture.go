@@ -0,0 +1,65 @@
+// internal/middleware/capture.go
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"access-proxy/internal/capture"
+	"access-proxy/internal/redact"
+	"access-proxy/internal/reqctx"
+)
+
+// CaptureMiddleware записывает совпавшие с фильтром пары запрос/ответ в активный
+// сеанс захвата. Тела перехватываются так же, как в LoggingMiddleware: потоково и
+// не больше bodies.MaxBytes, а перед записью проходят через redactor
+func CaptureMiddleware(recorder *capture.Recorder, redactor *redact.Redactor, bodies BodyCaptureOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info := reqctx.FromRequest(r)
+			if info == nil || !recorder.Matches(r, info.Route, info.ClientIP) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+
+			// Бинарные тела только считаются, но не сохраняются
+			requestBody := newLimitedBuffer(0)
+			if bodies.capturable(r.Header.Get("Content-Type")) {
+				requestBody = newLimitedBuffer(bodies.MaxBytes)
+			}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = &captureReader{ReadCloser: r.Body, buf: requestBody}
+			}
+
+			rec := &responseRecorder{
+				ResponseWriter: w,
+				statusCode:     http.StatusOK,
+				body:           newLimitedBuffer(bodies.MaxBytes),
+			}
+
+			next.ServeHTTP(rec, r)
+
+			responseBody := rec.body.Bytes()
+			if !bodies.capturable(w.Header().Get("Content-Type")) {
+				responseBody = nil
+			}
+
+			recorder.Record(capture.NewEntry(&capture.Exchange{
+				Request:        r,
+				RequestBody:    requestBody.Bytes(),
+				RequestSize:    requestBody.Total(),
+				Status:         rec.statusCode,
+				ResponseHeader: w.Header(),
+				ResponseBody:   responseBody,
+				ResponseSize:   rec.size,
+				Start:          start,
+				Duration:       time.Since(start),
+				RequestID:      info.RequestID,
+				Route:          info.Route,
+				ClientIP:       info.ClientIP,
+			}, redactor))
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// internal/middleware/client_ip.go
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies адреса прокси, которым разрешено передавать IP клиента
+// в X-Real-IP и X-Forwarded-For
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies принимает сети и одиночные адреса
+func ParseTrustedProxies(list []string) (TrustedProxies, error) {
+	proxies := make(TrustedProxies, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", s)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", s)
+		}
+		proxies = append(proxies, n)
+	}
+	return proxies, nil
+}
+
+func (p TrustedProxies) trusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range p {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP возвращает IP клиента. Заголовки учитываются только если запрос пришёл
+// от доверенного прокси, иначе клиент мог бы подставить любой адрес
+func (p TrustedProxies) ClientIP(r *http.Request) string {
+	remote := extractIPFromRemoteAddr(r.RemoteAddr)
+	if !p.trusted(remote) {
+		return remote
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+
+	// X-Forwarded-For дописывается каждым прокси справа: идём с конца
+	// и берём первый адрес, который не принадлежит доверенным прокси
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		remote = hop
+		if !p.trusted(hop) {
+			break
+		}
+	}
+	return remote
+}
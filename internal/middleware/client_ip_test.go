@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedProxiesClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		remote string
+		realIP string
+		xff    []string
+		want   string
+	}{
+		{"direct", "203.0.113.7:5000", "", nil, "203.0.113.7"},
+		{"spoofed xff from client", "203.0.113.7:5000", "", []string{"10.1.1.1"}, "203.0.113.7"},
+		{"spoofed real ip from client", "203.0.113.7:5000", "127.0.0.1", nil, "203.0.113.7"},
+		{"trusted real ip", "10.0.0.2:80", "198.51.100.4", nil, "198.51.100.4"},
+		{"trusted xff", "10.0.0.2:80", "", []string{"198.51.100.4"}, "198.51.100.4"},
+		{"xff with forged prefix", "10.0.0.2:80", "", []string{"1.2.3.4, 198.51.100.4"}, "198.51.100.4"},
+		{"chain of proxies", "192.0.2.1:80", "", []string{"198.51.100.4, 10.0.0.9", "10.0.0.3"}, "198.51.100.4"},
+		{"only proxies", "10.0.0.2:80", "", []string{"10.0.0.3"}, "10.0.0.3"},
+		{"garbage xff", "10.0.0.2:80", "", []string{"198.51.100.4, not-an-ip"}, "10.0.0.2"},
+		{"invalid real ip", "10.0.0.2:80", "evil", []string{"198.51.100.4"}, "198.51.100.4"},
+		{"no headers", "10.0.0.2:80", "", nil, "10.0.0.2"},
+		{"ipv6 proxy", "[2001:db8::1]:443", "", []string{"2001:db9::5"}, "2001:db9::5"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remote
+		if tt.realIP != "" {
+			r.Header.Set("X-Real-IP", tt.realIP)
+		}
+		for _, v := range tt.xff {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+		if got := proxies.ClientIP(r); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.2:80"
+	r.Header.Set("X-Forwarded-For", "198.51.100.4")
+	if got := TrustedProxies(nil).ClientIP(r); got != "10.0.0.2" {
+		t.Errorf("without trusted proxies: got %q", got)
+	}
+}
+
+func TestParseTrustedProxiesErrors(t *testing.T) {
+	for _, s := range []string{"10.0.0.0/33", "proxy.local", ""} {
+		if _, err := ParseTrustedProxies([]string{s}); err == nil {
+			t.Errorf("%q accepted", s)
+		}
+	}
+}
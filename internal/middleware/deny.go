@@ -0,0 +1,17 @@
+// internal/middleware/deny.go
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"access-proxy/internal/reqctx"
+)
+
+// denyJSON отмечает запрос как отклонённый с причиной reason и отвечает JSON телом body
+func denyJSON(w http.ResponseWriter, r *http.Request, status int, reason string, body map[string]interface{}) {
+	reqctx.Deny(r, reason)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
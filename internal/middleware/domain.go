@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"strings"
 
+	"access-proxy/internal/reqctx"
+
 	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
 )
 
@@ -30,9 +32,7 @@ func ClientDomainValidator(log logger.Logger, allowedDomains []string) func(http
 			// Проверяем разрешен ли клиент
 			if !isClientAllowed(clientIdentifier, allowedDomains) {
 				log.Warnf("🚫 Client not allowed: %s (allowed: %v)", clientIdentifier, allowedDomains)
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusForbidden)
-				json.NewEncoder(w).Encode(map[string]interface{}{
+				denyJSON(w, r, http.StatusForbidden, "domain_denied", map[string]interface{}{
 					"error":             "client_not_allowed",
 					"message":           "Client is not in allowed list",
 					"client_identifier": clientIdentifier,
@@ -84,23 +84,11 @@ func extractClientIdentifier(r *http.Request) string {
 	return clientIP
 }
 
-// extractClientIP извлекает IP клиента
+// extractClientIP IP клиента, определённый в RequestInfoMiddleware с учётом доверенных прокси
 func extractClientIP(r *http.Request) string {
-	// Пробуем получить из X-Real-IP
-	if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
-		return realIP
+	if info := reqctx.FromRequest(r); info != nil {
+		return info.ClientIP
 	}
-
-	// Пробуем получить из X-Forwarded-For (если за прокси)
-	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
-		// Берем первый IP из списка
-		ips := strings.Split(forwarded, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-	}
-	
-	// Или используем RemoteAddr
 	return extractIPFromRemoteAddr(r.RemoteAddr)
 }
 
@@ -0,0 +1,82 @@
+// internal/middleware/forward_auth.go
+package middleware
+
+import (
+	"net/http"
+
+	"access-proxy/internal/auth"
+	"access-proxy/internal/reqctx"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+// ForwardAuthOptions маршруты, которые проверяются внешним сервисом
+type ForwardAuthOptions struct {
+	Routes          []string // пусто — все маршруты
+	ResponseHeaders []string // удаляются из запроса клиента, чтобы их нельзя было подделать
+	PrincipalHeader string   // заголовок ответа сервиса с principal
+}
+
+// ForwardAuthMiddleware разрешает запрос по ответу внешнего сервиса авторизации.
+// Отказы 401/403 передаются клиенту как есть
+func ForwardAuthMiddleware(log logger.Logger, checker *auth.ForwardAuth, opts ForwardAuthOptions) func(http.Handler) http.Handler {
+	routes := make(map[string]bool, len(opts.Routes))
+	for _, route := range opts.Routes {
+		routes[route] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, name := range opts.ResponseHeaders {
+				if !isIdentityHeader(name) {
+					r.Header.Del(name)
+				}
+			}
+
+			info := reqctx.FromRequest(r)
+			if info == nil || (len(routes) > 0 && !routes[info.Route]) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			decision, err := checker.Check(r)
+			if err != nil {
+				log.Errorf("❌ Forward auth failed for %s %s: %v", r.Method, r.URL.Path, err)
+				denyJSON(w, r, http.StatusServiceUnavailable, "forward_auth_error", map[string]interface{}{
+					"error":   "forward_auth_error",
+					"message": "Authorization service is unavailable",
+				})
+				return
+			}
+
+			if !decision.Allowed {
+				log.Warnf("🛂 Forward auth denied %s %s: %d", r.Method, r.URL.Path, decision.Status)
+				reqctx.Deny(r, "forward_auth_denied")
+				for name, values := range decision.Header {
+					if name == "Content-Length" || name == "Transfer-Encoding" || name == "Connection" {
+						continue
+					}
+					w.Header()[name] = values
+				}
+				w.WriteHeader(decision.Status)
+				w.Write(decision.Body)
+				return
+			}
+
+			ownIdentity := info.Principal == ""
+			for name, values := range decision.Header {
+				if isIdentityHeader(name) && !ownIdentity {
+					continue
+				}
+				r.Header[name] = values
+			}
+			if opts.PrincipalHeader != "" && ownIdentity {
+				if principal := decision.Header.Get(opts.PrincipalHeader); principal != "" {
+					info.Principal = principal
+					info.AuthMethod = "forward_auth"
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+// internal/middleware/header_rules.go
+package middleware
+
+import (
+	"net/http"
+
+	"access-proxy/internal/expr"
+)
+
+// HeaderRule скомпилированное правило заголовков
+type HeaderRule struct {
+	When   *expr.Program // nil — всегда
+	Set    map[string]*expr.Program
+	Remove []string
+}
+
+// HeaderRulesMiddleware применяет правила заголовков по порядку. Пустое значение
+// выражения удаляет заголовок
+func HeaderRulesMiddleware(rules []HeaderRule) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			env := expr.EnvFromRequest(r)
+			for _, rule := range rules {
+				if rule.When != nil && !rule.When.Bool(env) {
+					continue
+				}
+				for _, name := range rule.Remove {
+					r.Header.Del(name)
+				}
+				for name, value := range rule.Set {
+					if v := value.String(env); v != "" {
+						r.Header.Set(name, v)
+					} else {
+						r.Header.Del(name)
+					}
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// internal/middleware/hmac_signature.go
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"access-proxy/internal/auth"
+	"access-proxy/internal/reqctx"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+// Заголовки подписанного запроса
+const (
+	SignatureClientHeader    = "X-Signature-Client"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+	SignatureNonceHeader     = "X-Signature-Nonce"
+	SignatureHeader          = "X-Signature"
+)
+
+// HMACSignatureOptions маршруты, требующие подпись
+type HMACSignatureOptions struct {
+	Routes       []string // пусто — все маршруты
+	MaxBodyBytes int64
+}
+
+// HMACSignatureMiddleware проверяет подпись HMAC запроса по секрету клиента.
+// Тело читается целиком (не больше MaxBodyBytes) и возвращается в запрос
+func HMACSignatureMiddleware(log logger.Logger, verifier *auth.HMACVerifier, opts HMACSignatureOptions) func(http.Handler) http.Handler {
+	routes := make(map[string]bool, len(opts.Routes))
+	for _, route := range opts.Routes {
+		routes[route] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info := reqctx.FromRequest(r)
+			if info == nil || (len(routes) > 0 && !routes[info.Route]) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var body []byte
+			if r.Body != nil && r.Body != http.NoBody {
+				var err error
+				body, err = io.ReadAll(io.LimitReader(r.Body, opts.MaxBodyBytes+1))
+				if err != nil {
+					denySignature(w, r, http.StatusBadRequest, "failed to read request body")
+					return
+				}
+				if int64(len(body)) > opts.MaxBodyBytes {
+					denySignature(w, r, http.StatusRequestEntityTooLarge, "request body is too large to verify")
+					return
+				}
+				r.Body.Close()
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			signed := auth.SignedRequest{
+				ClientID:  r.Header.Get(SignatureClientHeader),
+				Timestamp: r.Header.Get(SignatureTimestampHeader),
+				Nonce:     r.Header.Get(SignatureNonceHeader),
+				Signature: r.Header.Get(SignatureHeader),
+			}
+			if err := verifier.Verify(r, signed, body); err != nil {
+				log.Warnf("✍️ Signature rejected for client %q from %s: %v", signed.ClientID, info.ClientIP, err)
+				denySignature(w, r, http.StatusUnauthorized, err.Error())
+				return
+			}
+
+			if info.Principal == "" {
+				info.Principal = signed.ClientID
+				info.AuthMethod = "hmac"
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func denySignature(w http.ResponseWriter, r *http.Request, status int, message string) {
+	denyJSON(w, r, status, "signature_invalid", map[string]interface{}{
+		"error":   "signature_invalid",
+		"message": message,
+	})
+}
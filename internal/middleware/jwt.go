@@ -0,0 +1,142 @@
+// internal/middleware/jwt.go
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"access-proxy/internal/auth"
+	"access-proxy/internal/reqctx"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+// JWTRule требования маршрута к токену
+type JWTRule struct {
+	Scopes []string          // все перечисленные права должны быть в токене
+	Claims map[string]string // claim должен совпадать со значением (или содержать его, если это массив); "*" — просто присутствовать
+}
+
+// JWTOptions какие маршруты требуют токен и что передавать в upstream
+type JWTOptions struct {
+	Routes         []string           // пусто — все маршруты
+	Rules          map[string]JWTRule // по имени маршрута
+	ForwardClaims  map[string]string  // claim -> заголовок upstream
+	PrincipalClaim string             // по умолчанию sub
+	Realm          string
+}
+
+// JWTMiddleware проверяет Bearer токен, требования маршрута и передаёт выбранные claims в upstream
+func JWTMiddleware(log logger.Logger, verifier *auth.Verifier, opts JWTOptions) func(http.Handler) http.Handler {
+	routes := make(map[string]bool, len(opts.Routes))
+	for _, route := range opts.Routes {
+		routes[route] = true
+	}
+	if opts.PrincipalClaim == "" {
+		opts.PrincipalClaim = "sub"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, header := range opts.ForwardClaims {
+				if !isIdentityHeader(header) {
+					r.Header.Del(header)
+				}
+			}
+
+			info := reqctx.FromRequest(r)
+			if info == nil || (len(routes) > 0 && !routes[info.Route]) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token, ok := bearerToken(r)
+			if !ok {
+				denyJWT(w, r, opts.Realm, http.StatusUnauthorized, "jwt_missing", "", "Bearer token is required")
+				return
+			}
+
+			claims, err := verifier.Verify(token)
+			if err != nil {
+				log.Warnf("🎫 Invalid JWT from %s: %v", info.ClientIP, err)
+				denyJWT(w, r, opts.Realm, http.StatusUnauthorized, "jwt_invalid", "invalid_token", err.Error())
+				return
+			}
+
+			if rule, ok := opts.Rules[info.Route]; ok {
+				if err := checkJWTRule(claims, rule); err != nil {
+					log.Warnf("🎫 JWT for %s rejected on route %s: %v", claims.String(opts.PrincipalClaim), info.Route, err)
+					denyJWT(w, r, opts.Realm, http.StatusForbidden, "jwt_insufficient_scope", "insufficient_scope", err.Error())
+					return
+				}
+			}
+
+			ownIdentity := info.Principal == ""
+			if ownIdentity {
+				info.Principal = claims.String(opts.PrincipalClaim)
+				info.AuthMethod = "jwt"
+				info.Scopes = claims.Scopes()
+			}
+			for claim, header := range opts.ForwardClaims {
+				if isIdentityHeader(header) && !ownIdentity {
+					continue
+				}
+				if v := claims.Format(claim); v != "" {
+					r.Header.Set(header, v)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func checkJWTRule(claims auth.Claims, rule JWTRule) error {
+	scopes := claims.Scopes()
+	for _, required := range rule.Scopes {
+		if !containsString(scopes, required) {
+			return fmt.Errorf("scope %q is required", required)
+		}
+	}
+
+	for name, want := range rule.Claims {
+		if _, present := claims[name]; !present {
+			return fmt.Errorf("claim %q is required", name)
+		}
+		if want != "*" && !containsString(claims.Strings(name), want) && claims.Format(name) != want {
+			return fmt.Errorf("claim %q must be %q", name, want)
+		}
+	}
+	return nil
+}
+
+// denyJWT отвечает по RFC 6750: 401 без кода ошибки, если токена нет
+func denyJWT(w http.ResponseWriter, r *http.Request, realm string, status int, reason, code, message string) {
+	challenge := fmt.Sprintf("Bearer realm=%q", realm)
+	if code != "" {
+		challenge += fmt.Sprintf(", error=%q, error_description=%q", code, strings.ReplaceAll(message, `"`, "'"))
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	denyJSON(w, r, status, reason, map[string]interface{}{
+		"error":   reason,
+		"message": message,
+	})
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -2,18 +2,21 @@
 package middleware
 
 import (
-	"bytes"
-	"io"
+	"fmt"
 	"net/http"
 	"time"
 
+	"access-proxy/internal/redact"
+
 	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
 )
 
+// responseRecorder перехватывает статус и не больше capture.max байт тела ответа
 type responseRecorder struct {
 	http.ResponseWriter
 	statusCode int
-	body       bytes.Buffer
+	body       *limitedBuffer
+	size       int64
 }
 
 func (r *responseRecorder) WriteHeader(statusCode int) {
@@ -22,12 +25,28 @@ func (r *responseRecorder) WriteHeader(statusCode int) {
 }
 
 func (r *responseRecorder) Write(b []byte) (int, error) {
-	r.body.Write(b)
-	return r.ResponseWriter.Write(b)
+	n, err := r.ResponseWriter.Write(b)
+	r.size += int64(n)
+	if r.body != nil {
+		r.body.Write(b[:n])
+	}
+	return n, err
+}
+
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
 }
 
-// LoggingMiddleware логирует все входящие запросы и ответы
-func LoggingMiddleware(log logger.Logger, enabled bool) func(http.Handler) http.Handler {
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// LoggingMiddleware логирует все входящие запросы и ответы.
+// Заголовки, параметры запроса и тела проходят через redactor, тела захватываются
+// потоково и не больше capture.MaxBytes, поэтому логирование не меняет расход памяти прокси
+func LoggingMiddleware(log logger.Logger, enabled bool, redactor *redact.Redactor, capture BodyCaptureOptions) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if !enabled {
@@ -36,23 +55,19 @@ func LoggingMiddleware(log logger.Logger, enabled bool) func(http.Handler) http.
 			}
 
 			start := time.Now()
-			
+			sampled := capture.sampled()
+
 			// Логируем входящий запрос
-			log.Infof("📥 INCOMING REQUEST: %s %s %s", r.Method, r.URL.Path, r.Proto)
+			log.Infof("📥 INCOMING REQUEST: %s %s %s", r.Method, redactor.URL(r.URL), r.Proto)
 			log.Infof("📍 Remote Addr: %s", r.RemoteAddr)
 			log.Infof("🌐 User Agent: %s", r.UserAgent())
-			log.Infof("📨 Headers: %v", r.Header)
-
-			// Читаем тело запроса если есть
-			var requestBody bytes.Buffer
-			if r.Body != nil {
-				tee := io.TeeReader(r.Body, &requestBody)
-				bodyBytes, _ := io.ReadAll(tee)
-				r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-				
-				if len(bodyBytes) > 0 {
-					log.Infof("📦 Request Body: %s", string(bodyBytes))
-				}
+			log.Infof("📨 Headers: %v", redactor.Header(r.Header))
+
+			// Тело запроса копируется по мере чтения upstream'ом
+			var requestBody *limitedBuffer
+			if sampled && r.Body != nil && r.Body != http.NoBody && capture.capturable(r.Header.Get("Content-Type")) {
+				requestBody = newLimitedBuffer(capture.MaxBytes)
+				r.Body = &captureReader{ReadCloser: r.Body, buf: requestBody}
 			}
 
 			// Создаем recorder для перехвата ответа
@@ -60,21 +75,39 @@ func LoggingMiddleware(log logger.Logger, enabled bool) func(http.Handler) http.
 				ResponseWriter: w,
 				statusCode:     http.StatusOK,
 			}
+			if sampled {
+				recorder.body = newLimitedBuffer(capture.MaxBytes)
+			}
 
 			// Обрабатываем запрос
 			next.ServeHTTP(recorder, r)
 
+			if requestBody != nil && requestBody.Total() > 0 {
+				log.Infof("📦 Request Body%s: %s", truncatedNote(requestBody),
+					redactor.Body(r.Header.Get("Content-Type"), requestBody.Bytes()))
+			}
+
 			// Логируем ответ
 			duration := time.Since(start)
 			log.Infof("📤 RESPONSE: %d %s", recorder.statusCode, http.StatusText(recorder.statusCode))
 			log.Infof("⏱️  Duration: %v", duration)
-			log.Infof("📊 Response Size: %d bytes", recorder.body.Len())
-			
-			if recorder.body.Len() > 0 && recorder.body.Len() < 1024 { // Логируем только маленькие тела
-				log.Infof("📦 Response Body: %s", recorder.body.String())
+			log.Infof("📊 Response Size: %d bytes", recorder.size)
+			log.Infof("📨 Response Headers: %v", redactor.Header(w.Header()))
+
+			contentType := w.Header().Get("Content-Type")
+			if recorder.body != nil && recorder.body.Total() > 0 && capture.capturable(contentType) {
+				log.Infof("📦 Response Body%s: %s", truncatedNote(recorder.body),
+					redactor.Body(contentType, recorder.body.Bytes()))
 			}
-			
+
 			log.Infof("🔚 Request completed: %s %s", r.Method, r.URL.Path)
 		})
 	}
-}
\ No newline at end of file
+}
+
+func truncatedNote(b *limitedBuffer) string {
+	if !b.Truncated() {
+		return ""
+	}
+	return fmt.Sprintf(" (truncated, %d bytes total)", b.Total())
+}
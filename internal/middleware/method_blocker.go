@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"strings"
 
+	"access-proxy/internal/reqctx"
+
 	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
 )
 
@@ -25,6 +27,7 @@ func MethodBlockerMiddleware(log logger.Logger, blockedMethods []string) func(ht
 			// Проверяем заблокирован ли метод
 			if isMethodBlocked(method, blockedMethods) {
 				log.Warnf("🚫 Method blocked: %s %s", method, r.URL.Path)
+				reqctx.Deny(r, "method_blocked")
 				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(http.StatusMethodNotAllowed)
 				json.NewEncoder(w).Encode(map[string]interface{}{
@@ -0,0 +1,36 @@
+// internal/middleware/metrics.go
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"access-proxy/internal/metrics"
+	"access-proxy/internal/reqctx"
+)
+
+// MetricsMiddleware собирает метрики запросов. Ставится сразу после RequestInfoMiddleware
+func MetricsMiddleware(m *metrics.ProxyMetrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info := reqctx.FromRequest(r)
+			if info == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			m.RequestStarted()
+			defer func() {
+				m.RequestFinished(info.Route, r.Method, info.Status, time.Since(info.Start))
+				if info.Decision == reqctx.DecisionDeny {
+					m.Denied(info.DenyReason)
+				}
+				if info.UpstreamDuration > 0 || info.UpstreamError != "" {
+					m.UpstreamFinished(info.Route, info.UpstreamDuration, info.UpstreamError)
+				}
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"access-proxy/internal/metrics"
+	"access-proxy/internal/reqctx"
+)
+
+func TestMetricsMiddlewareRecordsRequestAndDenial(t *testing.T) {
+	m := metrics.NewProxyMetrics()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := reqctx.FromRequest(r)
+		info.UpstreamDuration = 20 * time.Millisecond
+		reqctx.Deny(r, "policy_denied")
+		w.WriteHeader(http.StatusForbidden)
+	})
+	chain := RequestInfoMiddleware(func(*http.Request) string { return "api" }, nil)(MetricsMiddleware(m)(handler))
+
+	chain.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/x", nil))
+
+	var b strings.Builder
+	m.Registry.WriteText(&b)
+	got := b.String()
+	for _, line := range []string{
+		`access_proxy_requests_total{route="api",method="GET",status="403"} 1`,
+		`access_proxy_denied_requests_total{reason="policy_denied"} 1`,
+		`access_proxy_upstream_duration_seconds_count{route="api"} 1`,
+		`access_proxy_requests_in_flight 0`,
+	} {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("missing %q", line)
+		}
+	}
+}
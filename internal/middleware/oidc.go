@@ -0,0 +1,93 @@
+// internal/middleware/oidc.go
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"access-proxy/internal/oidc"
+	"access-proxy/internal/reqctx"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+// Заголовки с данными пользователя OIDC для upstream
+const (
+	EmailHeader  = "X-Auth-Email"
+	GroupsHeader = "X-Auth-Groups"
+)
+
+// OIDCOptions маршруты, требующие входа
+type OIDCOptions struct {
+	Routes     []string // пусто — все маршруты, кроме служебных маршрутов OIDC
+	SkipRoutes []string
+}
+
+// OIDCMiddleware требует сессию OIDC. Браузер без сессии перенаправляется на вход,
+// остальные клиенты получают 401
+func OIDCMiddleware(log logger.Logger, rp *oidc.RelyingParty, opts OIDCOptions) func(http.Handler) http.Handler {
+	routes := make(map[string]bool, len(opts.Routes))
+	for _, route := range opts.Routes {
+		routes[route] = true
+	}
+	skip := make(map[string]bool, len(opts.SkipRoutes))
+	for _, route := range opts.SkipRoutes {
+		skip[route] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info := reqctx.FromRequest(r)
+			if info == nil || skip[info.Route] || (len(routes) > 0 && !routes[info.Route]) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			session := rp.Session(w, r)
+			if session == nil {
+				if isBrowserNavigation(r) {
+					reqctx.Deny(r, "oidc_login_required")
+					rp.Login(w, r)
+					return
+				}
+				denyJSON(w, r, http.StatusUnauthorized, "oidc_login_required", map[string]interface{}{
+					"error":   "oidc_login_required",
+					"message": "Login is required",
+				})
+				return
+			}
+
+			if err := rp.Authorize(session); err != nil {
+				log.Warnf("🪪 OIDC user %s denied on route %s: %v", session.Principal(), info.Route, err)
+				denyJSON(w, r, http.StatusForbidden, "oidc_forbidden", map[string]interface{}{
+					"error":   "oidc_forbidden",
+					"message": err.Error(),
+				})
+				return
+			}
+
+			// Если клиент уже опознан другим способом (например, API ключом),
+			// его заголовки личности не перезаписываются
+			if info.Principal == "" {
+				info.Principal = session.Principal()
+				info.AuthMethod = "oidc"
+				r.Header.Set(PrincipalHeader, session.Principal())
+				if session.Email != "" {
+					r.Header.Set(EmailHeader, session.Email)
+				}
+				if len(session.Groups) > 0 {
+					r.Header.Set(GroupsHeader, strings.Join(session.Groups, ","))
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isBrowserNavigation запрос страницы браузером, а не вызов API
+func isBrowserNavigation(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"access-proxy/internal/oidc"
+	"access-proxy/internal/reqctx"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+// oidcLogin проходит вход через mock IdP и возвращает cookie сессии
+func oidcLogin(t *testing.T, rp *oidc.RelyingParty) *http.Cookie {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	rp.Login(rec, httptest.NewRequest(http.MethodGet, "/app", nil))
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := noRedirect.Get(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	rec = httptest.NewRecorder()
+	rp.Callback(rec, req)
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "sess" && c.MaxAge >= 0 {
+			return c
+		}
+	}
+	t.Fatalf("callback: got %d, no session cookie: %s", rec.Code, rec.Body)
+	return nil
+}
+
+func TestOIDCMiddlewareForwardsPrincipal(t *testing.T) {
+	log := logger.New("test", logger.LevelInfo, logger.ModeDev)
+	var mock *oidc.MockIdP
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { mock.ServeHTTP(w, r) }))
+	defer idp.Close()
+	mock, err := oidc.NewMockIdP(oidc.MockOptions{
+		Issuer:       idp.URL,
+		ClientID:     "proxy",
+		ClientSecret: "s3cret",
+		Subject:      "u-123",
+		Email:        "alice@example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp, err := oidc.New(context.Background(), oidc.Options{
+		Issuer:         idp.URL,
+		ClientID:       "proxy",
+		ClientSecret:   "s3cret",
+		RedirectURL:    "http://proxy.test/oauth2/callback",
+		Scopes:         []string{"openid", "email"},
+		CookieName:     "sess",
+		CookieSecret:   "0123456789abcdef0123456789abcdef",
+		CookieInsecure: true,
+		SessionTTL:     time.Hour,
+	}, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var upstream *http.Request
+	chain := RequestInfoMiddleware(func(*http.Request) string { return "app" }, nil)(
+		OIDCMiddleware(log, rp, OIDCOptions{})(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { upstream = r })))
+
+	req := httptest.NewRequest(http.MethodGet, "/app", nil)
+	req.AddCookie(oidcLogin(t, rp))
+	rec := httptest.NewRecorder()
+	chain.ServeHTTP(rec, req)
+	if upstream == nil {
+		t.Fatalf("request did not reach upstream: %d %s", rec.Code, rec.Body)
+	}
+
+	// Upstream получает того же principal, по которому считаются лимиты и политики
+	info := reqctx.FromRequest(upstream)
+	if info.Principal != "alice@example.com" || upstream.Header.Get(PrincipalHeader) != info.Principal ||
+		upstream.Header.Get(EmailHeader) != "alice@example.com" {
+		t.Errorf("principal %q, headers %v", info.Principal, upstream.Header)
+	}
+}
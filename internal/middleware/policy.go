@@ -0,0 +1,35 @@
+// internal/middleware/policy.go
+package middleware
+
+import (
+	"net/http"
+
+	"access-proxy/internal/expr"
+	"access-proxy/internal/policy"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+// PolicyMiddleware применяет политики доступа маршрутов. Стоит после аутентификации,
+// чтобы правила могли проверять principal
+func PolicyMiddleware(log logger.Logger, policies *policy.Set) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req := expr.EnvFromRequest(r)
+
+			decision := policies.Evaluate(req)
+			if !decision.Allowed {
+				log.Warnf("🚫 Policy %s denied %s %s from %s (rule %d)",
+					decision.Policy, r.Method, r.URL.Path, req.ClientIP, decision.Rule)
+				denyJSON(w, r, http.StatusForbidden, "policy_denied", map[string]interface{}{
+					"error":   "forbidden",
+					"message": "Request is denied by access policy",
+					"policy":  decision.Policy,
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
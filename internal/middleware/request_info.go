@@ -0,0 +1,119 @@
+// internal/middleware/request_info.go
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"access-proxy/internal/reqctx"
+)
+
+// RequestIDHeader заголовок с идентификатором запроса
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength ограничивает длину входящего идентификатора
+const maxRequestIDLength = 128
+
+// IdentityHeaders заголовки с личностью клиента, которые выставляет только прокси.
+// Одноимённые заголовки клиента удаляются один раз в RequestInfoMiddleware,
+// дальше их пишет только middleware, установивший principal
+var IdentityHeaders = []string{PrincipalHeader, ScopesHeader, EmailHeader, GroupsHeader}
+
+func isIdentityHeader(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	for _, h := range IdentityHeaders {
+		if name == h {
+			return true
+		}
+	}
+	return false
+}
+
+// infoWriter фиксирует статус и размер ответа в reqctx.Info
+type infoWriter struct {
+	http.ResponseWriter
+	info        *reqctx.Info
+	wroteHeader bool
+}
+
+func (w *infoWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.info.Status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *infoWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.info.BytesWritten += int64(n)
+	return n, err
+}
+
+func (w *infoWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *infoWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// RequestInfoMiddleware создаёт reqctx.Info для запроса. Должен быть самым внешним,
+// чтобы остальные middleware могли записывать в него решение и маршрут.
+// routeName получает запрос уже с Info (без маршрута), чтобы условия маршрутов видели IP клиента.
+// IP берётся из X-Real-IP/X-Forwarded-For только для запросов от proxies
+func RequestInfoMiddleware(routeName func(r *http.Request) string, proxies TrustedProxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info := &reqctx.Info{
+				Start:     time.Now(),
+				RequestID: requestID(r),
+				ClientIP:  proxies.ClientIP(r),
+				Decision:  reqctx.DecisionAllow,
+				Status:    http.StatusOK,
+			}
+
+			for _, name := range IdentityHeaders {
+				r.Header.Del(name)
+			}
+
+			// Идентификатор уходит и в upstream, и обратно клиенту
+			r.Header.Set(RequestIDHeader, info.RequestID)
+			w.Header().Set(RequestIDHeader, info.RequestID)
+
+			r = r.WithContext(reqctx.NewContext(r.Context(), info))
+			info.Route = routeName(r)
+			next.ServeHTTP(&infoWriter{ResponseWriter: w, info: info}, r)
+		})
+	}
+}
+
+// requestID берёт идентификатор из запроса или генерирует новый
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(RequestIDHeader); isValidRequestID(id) {
+		return id
+	}
+
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] >= 0x7f {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"access-proxy/internal/auth"
+	"access-proxy/internal/reqctx"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+func hs256Token(t *testing.T, secret string, claims map[string]interface{}) string {
+	t.Helper()
+	enc := base64.RawURLEncoding
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + enc.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+func TestRequestInfoStripsIdentityHeaders(t *testing.T) {
+	var upstream *http.Request
+	chain := RequestInfoMiddleware(func(*http.Request) string { return "api" }, nil)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { upstream = r }))
+
+	req := httptest.NewRequest(http.MethodGet, "/x", nil)
+	for _, name := range IdentityHeaders {
+		req.Header.Set(name, "spoofed")
+	}
+	req.Header.Set("x-auth-principal", "admin")
+	chain.ServeHTTP(httptest.NewRecorder(), req)
+
+	for _, name := range IdentityHeaders {
+		if v := upstream.Header.Get(name); v != "" {
+			t.Errorf("%s = %q reached upstream", name, v)
+		}
+	}
+}
+
+func TestIdentityHeadersNotOverwritten(t *testing.T) {
+	log := logger.New("test", logger.LevelInfo, logger.ModeDev)
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	os.WriteFile(path, []byte("keys:\n  - id: billing\n    hash: sha256:"+auth.HashKey("s3cret")+"\n"), 0o600)
+	store, err := auth.LoadKeyStore(path, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := auth.NewVerifier(auth.VerifierOptions{Secret: []byte("jwt-secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var upstream *http.Request
+	chain := RequestInfoMiddleware(func(*http.Request) string { return "api" }, nil)(
+		APIKeyMiddleware(log, store, APIKeyOptions{Header: "X-Api-Key"})(
+			JWTMiddleware(log, verifier, JWTOptions{ForwardClaims: map[string]string{
+				"sub":    PrincipalHeader,
+				"tenant": "X-Tenant",
+			}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { upstream = r }))))
+
+	req := httptest.NewRequest(http.MethodGet, "/x", nil)
+	req.Header.Set("X-Api-Key", "s3cret")
+	req.Header.Set("X-Tenant", "spoofed")
+	req.Header.Set("Authorization", "Bearer "+hs256Token(t, "jwt-secret", map[string]interface{}{"sub": "mallory", "tenant": "acme"}))
+	rec := httptest.NewRecorder()
+	chain.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+	if got := upstream.Header.Get(PrincipalHeader); got != "billing" {
+		t.Errorf("%s = %q, want billing", PrincipalHeader, got)
+	}
+	if info := reqctx.FromRequest(upstream); info.Principal != "billing" || info.AuthMethod != "api_key" {
+		t.Errorf("principal = %q via %q", info.Principal, info.AuthMethod)
+	}
+	if got := upstream.Header.Get("X-Tenant"); got != "acme" {
+		t.Errorf("X-Tenant = %q, want acme", got)
+	}
+}
@@ -0,0 +1,173 @@
+// internal/mirror/mirror.go
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"access-proxy/internal/metrics"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+// ShadowHeader помечает копии запросов, отправленные в shadow upstream
+const ShadowHeader = "X-Access-Proxy-Shadow"
+
+const (
+	defaultTimeout      = 5 * time.Second
+	defaultMaxBodyBytes = 1024 * 1024
+	defaultMaxInFlight  = 100
+)
+
+// Options настройки зеркалирования маршрута
+type Options struct {
+	Target        string
+	SamplePercent float64 // доля зеркалируемых запросов, 0..100
+	MaxBodyBytes  int64   // запросы с телом больше лимита не зеркалируются
+	Timeout       time.Duration
+	MaxInFlight   int // сверх лимита копии отбрасываются
+}
+
+// Mirror асинхронно отправляет копии запросов в shadow upstream.
+// Ответы shadow отбрасываются, клиент всегда получает ответ основного прокси
+type Mirror struct {
+	route   string
+	target  *url.URL
+	opts    Options
+	client  *http.Client
+	sem     chan struct{}
+	metrics *metrics.ProxyMetrics
+	log     logger.Logger
+}
+
+func New(route string, opts Options, m *metrics.ProxyMetrics, log logger.Logger) (*Mirror, error) {
+	target, err := url.Parse(opts.Target)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		return nil, fmt.Errorf("invalid mirror target %q for route %s", opts.Target, route)
+	}
+	if opts.SamplePercent < 0 || opts.SamplePercent > 100 {
+		return nil, fmt.Errorf("mirror sample_percent for route %s must be within 0..100", route)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if opts.MaxInFlight <= 0 {
+		opts.MaxInFlight = defaultMaxInFlight
+	}
+
+	return &Mirror{
+		route:   route,
+		target:  target,
+		opts:    opts,
+		client:  &http.Client{Timeout: opts.Timeout},
+		sem:     make(chan struct{}, opts.MaxInFlight),
+		metrics: m,
+		log:     log,
+	}, nil
+}
+
+// Wrap зеркалирует часть запросов и передаёт исходный запрос в next без изменений
+func (m *Mirror) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.sampled() {
+			if shadow := m.shadowRequest(r); shadow != nil {
+				m.send(shadow)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m *Mirror) sampled() bool {
+	return m.opts.SamplePercent >= 100 || rand.Float64()*100 < m.opts.SamplePercent
+}
+
+// shadowRequest копирует запрос. Тело читается не больше MaxBodyBytes+1 байт,
+// прочитанное возвращается в r.Body, поэтому основной запрос получает его целиком
+func (m *Mirror) shadowRequest(r *http.Request) *http.Request {
+	if r.ContentLength > m.opts.MaxBodyBytes {
+		return nil
+	}
+
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		buf, err := io.ReadAll(io.LimitReader(r.Body, m.opts.MaxBodyBytes+1))
+		r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(buf), r.Body), Closer: r.Body}
+		if err != nil || int64(len(buf)) > m.opts.MaxBodyBytes {
+			return nil
+		}
+		body = buf
+	}
+
+	u := *m.target
+	u.Path = joinPath(m.target.Path, r.URL.Path)
+	u.RawPath = ""
+	u.RawQuery = r.URL.RawQuery
+
+	// Контекст не наследуется: shadow не должен обрываться вместе с клиентским запросом
+	shadow, err := http.NewRequestWithContext(context.Background(), r.Method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+	shadow.Header = r.Header.Clone()
+	shadow.Header.Set(ShadowHeader, "true")
+	shadow.ContentLength = int64(len(body))
+	return shadow
+}
+
+func (m *Mirror) send(shadow *http.Request) {
+	select {
+	case m.sem <- struct{}{}:
+	default:
+		m.record(0, 0, "dropped")
+		return
+	}
+
+	go func() {
+		defer func() { <-m.sem }()
+
+		start := time.Now()
+		resp, err := m.client.Do(shadow)
+		if err != nil {
+			m.record(0, time.Since(start), metrics.ClassifyError(err))
+			return
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		m.record(resp.StatusCode, time.Since(start), "")
+	}()
+}
+
+func (m *Mirror) record(status int, duration time.Duration, errType string) {
+	if errType != "" && errType != "dropped" {
+		m.log.Warnf("👥 Shadow request failed for route %s: %s", m.route, errType)
+	}
+	if m.metrics != nil {
+		m.metrics.ShadowFinished(m.route, status, duration, errType)
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func joinPath(a, b string) string {
+	switch {
+	case strings.HasSuffix(a, "/") && strings.HasPrefix(b, "/"):
+		return a + b[1:]
+	case !strings.HasSuffix(a, "/") && !strings.HasPrefix(b, "/"):
+		return a + "/" + b
+	}
+	return a + b
+}
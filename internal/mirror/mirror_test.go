@@ -0,0 +1,121 @@
+package mirror
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+type shadowHit struct {
+	path, query, body, header string
+}
+
+func newShadow(t *testing.T) (*httptest.Server, chan shadowHit) {
+	t.Helper()
+	hits := make(chan shadowHit, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		hits <- shadowHit{r.URL.Path, r.URL.RawQuery, string(body), r.Header.Get(ShadowHeader)}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, hits
+}
+
+func newMirror(t *testing.T, opts Options) *Mirror {
+	t.Helper()
+	m, err := New("api", opts, nil, logger.New("test", logger.LevelInfo, logger.ModeDev))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMirrorCopiesRequestAndKeepsBody(t *testing.T) {
+	shadow, hits := newShadow(t)
+	m := newMirror(t, Options{Target: shadow.URL + "/shadow/", SamplePercent: 100})
+
+	var primaryBody string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		primaryBody = string(b)
+	})
+	req := httptest.NewRequest(http.MethodPost, "/orders?id=7", strings.NewReader(`{"n":1}`))
+	m.Wrap(next).ServeHTTP(httptest.NewRecorder(), req)
+
+	if primaryBody != `{"n":1}` {
+		t.Errorf("primary body = %q", primaryBody)
+	}
+	select {
+	case hit := <-hits:
+		want := shadowHit{"/shadow/orders", "id=7", `{"n":1}`, "true"}
+		if hit != want {
+			t.Errorf("shadow got %+v, want %+v", hit, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("shadow request not sent")
+	}
+}
+
+func TestMirrorSkipsLargeBodies(t *testing.T) {
+	m := newMirror(t, Options{Target: "http://shadow", SamplePercent: 100, MaxBodyBytes: 4})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("123456"))
+	req.ContentLength = -1 // размер заранее неизвестен, лимит проверяется при чтении
+	if shadow := m.shadowRequest(req); shadow != nil {
+		t.Error("oversized body mirrored")
+	}
+	if b, _ := io.ReadAll(req.Body); string(b) != "123456" {
+		t.Errorf("primary body damaged: %q", b)
+	}
+}
+
+func TestMirrorDropsOverMaxInFlight(t *testing.T) {
+	release := make(chan struct{})
+	var once sync.Once
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer once.Do(func() { close(release) })
+
+	m := newMirror(t, Options{Target: srv.URL, SamplePercent: 100, MaxInFlight: 1})
+	m.send(m.shadowRequest(httptest.NewRequest(http.MethodGet, "/a", nil)))
+	m.send(m.shadowRequest(httptest.NewRequest(http.MethodGet, "/b", nil)))
+
+	if n := len(m.sem); n != 1 {
+		t.Errorf("in flight = %d, want 1", n)
+	}
+	once.Do(func() { close(release) })
+}
+
+func TestNewValidatesOptions(t *testing.T) {
+	for _, opts := range []Options{
+		{Target: "not a url"},
+		{Target: "/relative"},
+		{Target: "http://shadow", SamplePercent: 101},
+		{Target: "http://shadow", SamplePercent: -1},
+	} {
+		if _, err := New("api", opts, nil, nil); err == nil {
+			t.Errorf("options %+v: expected error", opts)
+		}
+	}
+}
+
+func TestJoinPath(t *testing.T) {
+	for _, tt := range [][3]string{
+		{"/a/", "/b", "/a/b"},
+		{"/a", "b", "/a/b"},
+		{"/a", "/b", "/a/b"},
+		{"", "/b", "/b"},
+	} {
+		if got := joinPath(tt[0], tt[1]); got != tt[2] {
+			t.Errorf("joinPath(%q, %q) = %q, want %q", tt[0], tt[1], got, tt[2])
+		}
+	}
+}
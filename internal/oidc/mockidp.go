@@ -0,0 +1,232 @@
+// internal/oidc/mockidp.go
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MockOptions параметры локального IdP для разработки и проверки входа
+type MockOptions struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Subject      string
+	Email        string
+	Name         string
+	Groups       []string
+	TokenTTL     time.Duration
+}
+
+// MockIdP минимальный OpenID провайдер: вход подтверждается автоматически,
+// токены подписываются RS256 ключом, созданным при запуске
+type MockIdP struct {
+	opts MockOptions
+	key  *rsa.PrivateKey
+	kid  string
+
+	mu       sync.Mutex
+	codes    map[string]mockGrant
+	refreshs map[string]bool
+}
+
+type mockGrant struct {
+	nonce       string
+	challenge   string
+	redirectURI string
+	expiry      time.Time
+}
+
+func NewMockIdP(opts MockOptions) (*MockIdP, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	opts.Issuer = strings.TrimSuffix(opts.Issuer, "/")
+	if opts.TokenTTL <= 0 {
+		opts.TokenTTL = 5 * time.Minute
+	}
+	if opts.Subject == "" {
+		opts.Subject = opts.Email
+	}
+	return &MockIdP{
+		opts:     opts,
+		key:      key,
+		kid:      randomString(8),
+		codes:    make(map[string]mockGrant),
+		refreshs: make(map[string]bool),
+	}, nil
+}
+
+func (m *MockIdP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		m.writeJSON(w, http.StatusOK, discovery{
+			Issuer:                m.opts.Issuer,
+			AuthorizationEndpoint: m.opts.Issuer + "/authorize",
+			TokenEndpoint:         m.opts.Issuer + "/token",
+			JWKSURI:               m.opts.Issuer + "/jwks",
+		})
+	case "/jwks":
+		m.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": m.kid,
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+			}},
+		})
+	case "/authorize":
+		m.authorize(w, r)
+	case "/token":
+		m.token(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (m *MockIdP) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || q.Get("client_id") != m.opts.ClientID || q.Get("response_type") != "code" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	if q.Get("code_challenge") != "" && q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "only S256 code challenge is supported", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString(24)
+	m.mu.Lock()
+	m.codes[code] = mockGrant{
+		nonce:       q.Get("nonce"),
+		challenge:   q.Get("code_challenge"),
+		redirectURI: redirect.String(),
+		expiry:      time.Now().Add(time.Minute),
+	}
+	m.mu.Unlock()
+
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (m *MockIdP) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, _ := r.BasicAuth()
+	id, _ = url.QueryUnescape(id)
+	secret, _ = url.QueryUnescape(secret)
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		m.tokenError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+	if id != m.opts.ClientID || subtle.ConstantTimeCompare([]byte(secret), []byte(m.opts.ClientSecret)) != 1 {
+		m.tokenError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var nonce string
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		grant, ok := m.codes[r.PostForm.Get("code")]
+		delete(m.codes, r.PostForm.Get("code"))
+		if !ok || time.Now().After(grant.expiry) || grant.redirectURI != r.PostForm.Get("redirect_uri") {
+			m.tokenError(w, http.StatusBadRequest, "invalid_grant")
+			return
+		}
+		if grant.challenge != "" {
+			sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+			if base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+				m.tokenError(w, http.StatusBadRequest, "invalid_grant")
+				return
+			}
+		}
+		nonce = grant.nonce
+	case "refresh_token":
+		token := r.PostForm.Get("refresh_token")
+		if !m.refreshs[token] {
+			m.tokenError(w, http.StatusBadRequest, "invalid_grant")
+			return
+		}
+		delete(m.refreshs, token)
+	default:
+		m.tokenError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	idToken, err := m.idToken(nonce)
+	if err != nil {
+		m.tokenError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+	refresh := randomString(32)
+	m.refreshs[refresh] = true
+
+	m.writeJSON(w, http.StatusOK, tokenResponse{
+		AccessToken:  randomString(32),
+		IDToken:      idToken,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(m.opts.TokenTTL / time.Second),
+		TokenType:    "Bearer",
+	})
+}
+
+func (m *MockIdP) idToken(nonce string) (string, error) {
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":            m.opts.Issuer,
+		"sub":            m.opts.Subject,
+		"aud":            m.opts.ClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(m.opts.TokenTTL).Unix(),
+		"email":          m.opts.Email,
+		"email_verified": true,
+		"name":           m.opts.Name,
+		"groups":         m.opts.Groups,
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": m.kid})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func (m *MockIdP) tokenError(w http.ResponseWriter, status int, code string) {
+	m.writeJSON(w, status, map[string]string{"error": code})
+}
+
+func (m *MockIdP) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,396 @@
+// internal/oidc/relying_party.go
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"access-proxy/internal/auth"
+	"access-proxy/internal/reqctx"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+// stateTTL сколько ждать возврата пользователя от IdP
+const stateTTL = 10 * time.Minute
+
+// refreshGrace сколько результат обновления выдаётся запросам со старым refresh token.
+// Параллельные запросы браузера ещё несут прежнюю cookie, а IdP с ротацией
+// refresh token повторное использование старого отклонит
+const refreshGrace = 30 * time.Second
+
+// Options настройки relying party
+type Options struct {
+	Issuer         string
+	ClientID       string
+	ClientSecret   string
+	RedirectURL    string // полный URL callback, путь обрабатывает прокси
+	Scopes         []string
+	CookieName     string
+	CookieSecret   string
+	CookieInsecure bool // без Secure, только для локальной разработки по http
+	SessionTTL     time.Duration
+	GroupsClaim    string
+	PostLogoutURL  string
+
+	AllowedGroups       []string
+	AllowedEmailDomains []string
+}
+
+// RelyingParty вход пользователей через OpenID Connect (authorization code + PKCE)
+// с сессией в зашифрованной cookie
+type RelyingParty struct {
+	opts         Options
+	provider     *discovery
+	verifier     *auth.Verifier
+	sealer       *sealer
+	client       *http.Client
+	callbackPath string
+	stateCookie  string
+
+	refreshMu sync.Mutex
+	refreshes map[string]*refreshCall // refresh token -> обновление по нему
+}
+
+// refreshCall одно обновление токенов, общее для всех запросов с этим refresh token
+type refreshCall struct {
+	done    chan struct{}
+	session Session
+	err     error
+	expires time.Time // нулевое, пока обновление не завершено
+}
+
+func New(ctx context.Context, opts Options, log logger.Logger) (*RelyingParty, error) {
+	if opts.Issuer == "" || opts.ClientID == "" || opts.RedirectURL == "" {
+		return nil, errors.New("OIDC requires issuer, client_id and redirect_url")
+	}
+	redirect, err := url.Parse(opts.RedirectURL)
+	if err != nil || redirect.Path == "" {
+		return nil, fmt.Errorf("invalid redirect_url %q", opts.RedirectURL)
+	}
+
+	s, err := newSealer(opts.CookieSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	provider, err := discover(ctx, client, opts.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	jwks, err := auth.NewJWKS("", provider.JWKSURI, time.Hour, log)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := auth.NewVerifier(auth.VerifierOptions{
+		JWKS:       jwks,
+		Issuer:     provider.Issuer,
+		Audience:   []string{opts.ClientID},
+		Algorithms: []string{auth.AlgRS256, auth.AlgES256},
+		Leeway:     time.Minute,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RelyingParty{
+		opts:         opts,
+		provider:     provider,
+		verifier:     verifier,
+		sealer:       s,
+		client:       client,
+		callbackPath: redirect.Path,
+		stateCookie:  opts.CookieName + "_state",
+		refreshes:    make(map[string]*refreshCall),
+	}, nil
+}
+
+// CallbackPath путь, на который IdP возвращает пользователя
+func (rp *RelyingParty) CallbackPath() string {
+	return rp.callbackPath
+}
+
+// Session возвращает сессию из cookie, при необходимости обновляя токены.
+// nil — сессии нет, она истекла или IdP отказал в обновлении
+func (rp *RelyingParty) Session(w http.ResponseWriter, r *http.Request) *Session {
+	cookie, err := r.Cookie(rp.opts.CookieName)
+	if err != nil {
+		return nil
+	}
+
+	var s Session
+	if err := rp.sealer.open(rp.opts.CookieName, cookie.Value, &s); err != nil {
+		rp.clearCookie(w, rp.opts.CookieName)
+		return nil
+	}
+
+	now := time.Now()
+	if now.Unix() >= s.Expiry {
+		rp.clearCookie(w, rp.opts.CookieName)
+		return nil
+	}
+	if now.Unix() >= s.TokenExpiry && s.RefreshToken != "" {
+		if err := rp.refreshShared(r.Context(), &s); err != nil {
+			rp.clearCookie(w, rp.opts.CookieName)
+			return nil
+		}
+		if err := rp.setCookie(w, rp.opts.CookieName, &s, time.Unix(s.Expiry, 0)); err != nil {
+			return nil
+		}
+	}
+	return &s
+}
+
+// Authorize проверяет правила доступа по группам и домену email
+func (rp *RelyingParty) Authorize(s *Session) error {
+	if len(rp.opts.AllowedGroups) > 0 && !intersects(s.Groups, rp.opts.AllowedGroups) {
+		return errors.New("user is not in an allowed group")
+	}
+	if len(rp.opts.AllowedEmailDomains) > 0 {
+		_, domain, _ := strings.Cut(s.Email, "@")
+		if !s.EmailVerified || !containsFold(rp.opts.AllowedEmailDomains, domain) {
+			return errors.New("user email domain is not allowed")
+		}
+	}
+	return nil
+}
+
+// Login перенаправляет пользователя на страницу входа IdP
+func (rp *RelyingParty) Login(w http.ResponseWriter, r *http.Request) {
+	state := loginState{
+		State:    randomString(24),
+		Nonce:    randomString(24),
+		Verifier: randomString(48),
+		ReturnTo: r.URL.RequestURI(),
+		Expiry:   time.Now().Add(stateTTL).Unix(),
+	}
+	if err := rp.setCookie(w, rp.stateCookie, &state, time.Unix(state.Expiry, 0)); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "oidc_error", "failed to start login")
+		return
+	}
+
+	challenge := sha256.Sum256([]byte(state.Verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {rp.opts.ClientID},
+		"redirect_uri":          {rp.opts.RedirectURL},
+		"scope":                 {strings.Join(rp.opts.Scopes, " ")},
+		"state":                 {state.State},
+		"nonce":                 {state.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	target := rp.provider.AuthorizationEndpoint
+	if strings.Contains(target, "?") {
+		target += "&" + query.Encode()
+	} else {
+		target += "?" + query.Encode()
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// Callback завершает вход: проверяет state, обменивает код на токены и создаёт сессию
+func (rp *RelyingParty) Callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if e := query.Get("error"); e != "" {
+		writeError(w, r, http.StatusForbidden, "oidc_login_failed", e+": "+query.Get("error_description"))
+		return
+	}
+
+	var state loginState
+	cookie, err := r.Cookie(rp.stateCookie)
+	if err != nil || rp.sealer.open(rp.stateCookie, cookie.Value, &state) != nil ||
+		time.Now().Unix() > state.Expiry ||
+		subtle.ConstantTimeCompare([]byte(state.State), []byte(query.Get("state"))) != 1 {
+		writeError(w, r, http.StatusBadRequest, "oidc_invalid_state", "login state is missing or does not match, start again")
+		return
+	}
+	rp.clearCookie(w, rp.stateCookie)
+
+	tokens, err := rp.exchange(r.Context(), url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {query.Get("code")},
+		"redirect_uri":  {rp.opts.RedirectURL},
+		"code_verifier": {state.Verifier},
+	})
+	if err != nil {
+		writeError(w, r, http.StatusBadGateway, "oidc_token_error", err.Error())
+		return
+	}
+
+	claims, err := rp.verifier.Verify(tokens.IDToken)
+	if err != nil {
+		writeError(w, r, http.StatusUnauthorized, "oidc_invalid_token", err.Error())
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.String("nonce")), []byte(state.Nonce)) != 1 {
+		writeError(w, r, http.StatusUnauthorized, "oidc_invalid_token", "nonce does not match")
+		return
+	}
+
+	s := &Session{Expiry: time.Now().Add(rp.opts.SessionTTL).Unix()}
+	rp.applyTokens(s, tokens, claims)
+	if err := rp.setCookie(w, rp.opts.CookieName, s, time.Unix(s.Expiry, 0)); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "oidc_error", "failed to create session")
+		return
+	}
+
+	http.Redirect(w, r, safeReturnTo(state.ReturnTo), http.StatusFound)
+}
+
+// Logout удаляет сессию
+func (rp *RelyingParty) Logout(w http.ResponseWriter, r *http.Request) {
+	rp.clearCookie(w, rp.opts.CookieName)
+	target := rp.opts.PostLogoutURL
+	if target == "" {
+		target = "/"
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// refreshShared обновляет токены один раз на refresh token: одновременные запросы
+// одной сессии ждут результата первого и получают те же токены
+func (rp *RelyingParty) refreshShared(ctx context.Context, s *Session) error {
+	key := s.RefreshToken
+	now := time.Now()
+
+	rp.refreshMu.Lock()
+	for k, c := range rp.refreshes {
+		if !c.expires.IsZero() && now.After(c.expires) {
+			delete(rp.refreshes, k)
+		}
+	}
+	call, running := rp.refreshes[key]
+	if !running {
+		call = &refreshCall{done: make(chan struct{})}
+		rp.refreshes[key] = call
+	}
+	rp.refreshMu.Unlock()
+
+	if running {
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	} else {
+		refreshed := *s
+		// Отмена запроса, начавшего обновление, не должна обрывать его для остальных
+		call.err = rp.refresh(context.WithoutCancel(ctx), &refreshed)
+		call.session = refreshed
+
+		rp.refreshMu.Lock()
+		if call.err != nil {
+			delete(rp.refreshes, key)
+		} else {
+			call.expires = time.Now().Add(refreshGrace)
+		}
+		rp.refreshMu.Unlock()
+		close(call.done)
+	}
+
+	if call.err != nil {
+		return call.err
+	}
+	*s = call.session
+	return nil
+}
+
+func (rp *RelyingParty) refresh(ctx context.Context, s *Session) error {
+	tokens, err := rp.exchange(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {s.RefreshToken},
+	})
+	if err != nil {
+		return err
+	}
+
+	// ID token в ответе на refresh необязателен; если он есть, обновляем данные пользователя
+	var claims auth.Claims
+	if tokens.IDToken != "" {
+		if claims, err = rp.verifier.Verify(tokens.IDToken); err != nil {
+			return err
+		}
+		if claims.String("sub") != s.Subject {
+			return errors.New("refreshed token belongs to another user")
+		}
+	}
+	rp.applyTokens(s, tokens, claims)
+	return nil
+}
+
+func (rp *RelyingParty) applyTokens(s *Session, tokens *tokenResponse, claims auth.Claims) {
+	if tokens.RefreshToken != "" {
+		s.RefreshToken = tokens.RefreshToken
+	}
+
+	expiry := time.Now().Add(5 * time.Minute)
+	if tokens.ExpiresIn > 0 {
+		expiry = time.Now().Add(time.Duration(tokens.ExpiresIn) * time.Second)
+	}
+	s.TokenExpiry = min(expiry.Unix(), s.Expiry)
+
+	if claims == nil {
+		return
+	}
+	s.Subject = claims.String("sub")
+	s.Email = claims.String("email")
+	s.Name = claims.String("name")
+	s.Groups = claims.Strings(rp.opts.GroupsClaim)
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		s.EmailVerified = v
+	case string:
+		s.EmailVerified = v == "true"
+	}
+}
+
+// safeReturnTo разрешает возврат только на локальный путь
+func safeReturnTo(target string) string {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return "/"
+	}
+	return target
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, reason, message string) {
+	reqctx.Deny(r, reason)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":   reason,
+		"message": message,
+	})
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
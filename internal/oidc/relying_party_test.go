@@ -0,0 +1,264 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+const testCookieSecret = "0123456789abcdef0123456789abcdef"
+
+type testIdP struct {
+	*httptest.Server
+	refreshes atomic.Int32
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	idp := &testIdP{}
+	var mock *MockIdP
+	idp.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			r.ParseForm()
+			if r.PostForm.Get("grant_type") == "refresh_token" {
+				idp.refreshes.Add(1)
+				time.Sleep(20 * time.Millisecond) // пусть параллельные запросы пересекутся
+			}
+		}
+		mock.ServeHTTP(w, r)
+	}))
+	t.Cleanup(idp.Close)
+
+	var err error
+	mock, err = NewMockIdP(MockOptions{
+		Issuer:       idp.URL,
+		ClientID:     "proxy",
+		ClientSecret: "s3cret",
+		Email:        "alice@example.com",
+		Groups:       []string{"dev"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idp
+}
+
+func newTestRP(t *testing.T, idp *testIdP) *RelyingParty {
+	t.Helper()
+	rp, err := New(context.Background(), Options{
+		Issuer:         idp.URL,
+		ClientID:       "proxy",
+		ClientSecret:   "s3cret",
+		RedirectURL:    "http://proxy.test/oauth2/callback",
+		Scopes:         []string{"openid", "email"},
+		CookieName:     "sess",
+		CookieSecret:   testCookieSecret,
+		CookieInsecure: true,
+		SessionTTL:     time.Hour,
+		GroupsClaim:    "groups",
+	}, logger.New("test", logger.LevelInfo, logger.ModeDev))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rp
+}
+
+func cookieByName(t *testing.T, rec *httptest.ResponseRecorder, name string) *http.Cookie {
+	t.Helper()
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == name && c.MaxAge >= 0 {
+			return c
+		}
+	}
+	t.Fatalf("cookie %s not set", name)
+	return nil
+}
+
+// login проходит вход через mock IdP и возвращает cookie сессии
+func login(t *testing.T, rp *RelyingParty) *http.Cookie {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	rp.Login(rec, httptest.NewRequest(http.MethodGet, "/app?x=1", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login: got %d", rec.Code)
+	}
+	state := cookieByName(t, rec, "sess_state")
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := noRedirect.Get(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || callback.Path != rp.CallbackPath() || callback.Query().Get("code") == "" {
+		t.Fatalf("authorize redirected to %q", resp.Header.Get("Location"))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	req.AddCookie(state)
+	rec = httptest.NewRecorder()
+	rp.Callback(rec, req)
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/app?x=1" {
+		t.Fatalf("callback: got %d %s: %s", rec.Code, rec.Header().Get("Location"), rec.Body)
+	}
+	return cookieByName(t, rec, "sess")
+}
+
+func sessionFor(rp *RelyingParty, c *http.Cookie) (*Session, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodGet, "/app", nil)
+	req.AddCookie(c)
+	rec := httptest.NewRecorder()
+	return rp.Session(rec, req), rec
+}
+
+// expireTokens возвращает cookie сессии, токены которой пора обновить
+func expireTokens(t *testing.T, rp *RelyingParty, c *http.Cookie) *http.Cookie {
+	t.Helper()
+	var s Session
+	if err := rp.sealer.open("sess", c.Value, &s); err != nil {
+		t.Fatal(err)
+	}
+	s.TokenExpiry = time.Now().Add(-time.Second).Unix()
+	value, err := rp.sealer.seal("sess", &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Cookie{Name: "sess", Value: value}
+}
+
+func TestLoginCallbackAndSession(t *testing.T) {
+	idp := newTestIdP(t)
+	rp := newTestRP(t, idp)
+	cookie := login(t, rp)
+
+	s, _ := sessionFor(rp, cookie)
+	if s == nil || s.Principal() != "alice@example.com" || !s.EmailVerified || len(s.Groups) != 1 || s.RefreshToken == "" {
+		t.Fatalf("unexpected session %+v", s)
+	}
+	if err := rp.Authorize(s); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCallbackRejectsWrongState(t *testing.T) {
+	rp := newTestRP(t, newTestIdP(t))
+
+	rec := httptest.NewRecorder()
+	rp.Login(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	req := httptest.NewRequest(http.MethodGet, "/oauth2/callback?code=x&state=forged", nil)
+	req.AddCookie(cookieByName(t, rec, "sess_state"))
+	rec = httptest.NewRecorder()
+	rp.Callback(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("got %d, want 400", rec.Code)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	idp := newTestIdP(t)
+	rp := newTestRP(t, idp)
+	stale := expireTokens(t, rp, login(t, rp))
+
+	s, rec := sessionFor(rp, stale)
+	if s == nil {
+		t.Fatal("session lost on refresh")
+	}
+	if s.TokenExpiry <= time.Now().Unix() || idp.refreshes.Load() != 1 {
+		t.Errorf("tokens not refreshed: %+v, refreshes = %d", s, idp.refreshes.Load())
+	}
+	fresh := cookieByName(t, rec, "sess")
+	if s, _ := sessionFor(rp, fresh); s == nil || idp.refreshes.Load() != 1 {
+		t.Error("refreshed cookie not accepted without another refresh")
+	}
+}
+
+func TestConcurrentRefreshIsShared(t *testing.T) {
+	idp := newTestIdP(t)
+	rp := newTestRP(t, idp)
+	stale := expireTokens(t, rp, login(t, rp))
+
+	// IdP ротирует refresh token: без общего обновления все запросы,
+	// кроме первого, получили бы invalid_grant и пользователь вышел бы из системы
+	var wg sync.WaitGroup
+	var lost atomic.Int32
+	tokens := make(chan string, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, _ := sessionFor(rp, stale)
+			if s == nil {
+				lost.Add(1)
+				return
+			}
+			tokens <- s.RefreshToken
+		}()
+	}
+	wg.Wait()
+	close(tokens)
+
+	if lost.Load() != 0 {
+		t.Fatalf("%d concurrent requests lost the session", lost.Load())
+	}
+	if n := idp.refreshes.Load(); n != 1 {
+		t.Errorf("refreshes = %d, want 1", n)
+	}
+	first := <-tokens
+	for rt := range tokens {
+		if rt != first {
+			t.Error("requests got different refresh tokens")
+		}
+	}
+
+	// Опоздавший запрос со старой cookie тоже получает обновлённую сессию
+	if s, _ := sessionFor(rp, stale); s == nil || idp.refreshes.Load() != 1 {
+		t.Error("late request with the old cookie was logged out")
+	}
+}
+
+func TestFailedRefreshClearsSession(t *testing.T) {
+	idp := newTestIdP(t)
+	rp := newTestRP(t, idp)
+
+	var s Session
+	cookie := login(t, rp)
+	rp.sealer.open("sess", cookie.Value, &s)
+	s.TokenExpiry = 0
+	s.RefreshToken = "revoked"
+	value, _ := rp.sealer.seal("sess", &s)
+
+	got, rec := sessionFor(rp, &http.Cookie{Name: "sess", Value: value})
+	if got != nil {
+		t.Fatal("session kept after failed refresh")
+	}
+	if !strings.Contains(rec.Header().Get("Set-Cookie"), "Max-Age=0") {
+		t.Errorf("cookie not cleared: %q", rec.Header().Get("Set-Cookie"))
+	}
+	if len(rp.refreshes) != 0 {
+		t.Error("failed refresh result is cached")
+	}
+}
+
+func TestSafeReturnTo(t *testing.T) {
+	for target, want := range map[string]string{
+		"/app?x=1":             "/app?x=1",
+		"//evil.example":       "/",
+		"/\\evil.example":      "/",
+		"https://evil.example": "/",
+		"":                     "/",
+	} {
+		if got := safeReturnTo(target); got != want {
+			t.Errorf("safeReturnTo(%q) = %q, want %q", target, got, want)
+		}
+	}
+}
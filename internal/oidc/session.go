@@ -0,0 +1,127 @@
+// internal/oidc/session.go
+package oidc
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Session данные пользователя в зашифрованной cookie
+type Session struct {
+	Subject       string   `json:"sub"`
+	Email         string   `json:"email,omitempty"`
+	EmailVerified bool     `json:"email_verified,omitempty"`
+	Name          string   `json:"name,omitempty"`
+	Groups        []string `json:"groups,omitempty"`
+	RefreshToken  string   `json:"rt,omitempty"`
+	TokenExpiry   int64    `json:"texp"` // когда нужно обновить токены
+	Expiry        int64    `json:"exp"`  // абсолютный срок сессии
+}
+
+// Principal идентификатор пользователя для логов и лимитов
+func (s *Session) Principal() string {
+	if s.Email != "" {
+		return s.Email
+	}
+	return s.Subject
+}
+
+// loginState состояние начатого входа, хранится в отдельной короткой cookie
+type loginState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"` // PKCE code_verifier
+	ReturnTo string `json:"return_to"`
+	Expiry   int64  `json:"exp"`
+}
+
+// sealer шифрует cookie AES-256-GCM; ключ получается из секрета конфигурации
+type sealer struct {
+	aead cipher.AEAD
+}
+
+func newSealer(secret string) (*sealer, error) {
+	if len(secret) < 32 {
+		return nil, errors.New("cookie secret must be at least 32 characters")
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{aead: aead}, nil
+}
+
+// seal шифрует v; имя cookie входит в дополнительные данные, поэтому
+// значение одной cookie нельзя подставить в другую
+func (s *sealer) seal(name string, v interface{}) (string, error) {
+	plain, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := s.aead.Seal(nonce, nonce, plain, []byte(name))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (s *sealer) open(name, value string, v interface{}) error {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(sealed) < s.aead.NonceSize() {
+		return errors.New("malformed cookie")
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	plain, err := s.aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return errors.New("cookie cannot be decrypted")
+	}
+	return json.Unmarshal(plain, v)
+}
+
+func (rp *RelyingParty) setCookie(w http.ResponseWriter, name string, v interface{}, expiry time.Time) error {
+	value, err := rp.sealer.seal(name, v)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expiry,
+		HttpOnly: true,
+		Secure:   !rp.opts.CookieInsecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+func (rp *RelyingParty) clearCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   !rp.opts.CookieInsecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func randomString(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,51 @@
+package oidc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSealerRoundTrip(t *testing.T) {
+	s, err := newSealer(testCookieSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := Session{Subject: "u1", Email: "a@b.c", Groups: []string{"g"}, Expiry: 42}
+	value, err := s.seal("sess", &in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(value, "a@b.c") {
+		t.Error("cookie is not encrypted")
+	}
+
+	var out Session
+	if err := s.open("sess", value, &out); err != nil || out.Email != in.Email || out.Expiry != 42 {
+		t.Errorf("open = %+v, %v", out, err)
+	}
+
+	// Значение нельзя подставить в cookie с другим именем
+	if err := s.open("sess_state", value, &out); err == nil {
+		t.Error("cookie opened under another name")
+	}
+
+	other, _ := newSealer(strings.Repeat("x", 32))
+	if err := other.open("sess", value, &out); err == nil {
+		t.Error("cookie opened with another secret")
+	}
+
+	tampered := []byte(value)
+	tampered[len(tampered)-2] ^= 1
+	for _, v := range []string{string(tampered), "", "!!", value[:10]} {
+		if err := s.open("sess", v, &out); err == nil {
+			t.Errorf("open(%q) succeeded", v)
+		}
+	}
+}
+
+func TestSealerRequiresLongSecret(t *testing.T) {
+	if _, err := newSealer("short"); err == nil {
+		t.Error("short secret accepted")
+	}
+}
@@ -0,0 +1,146 @@
+// internal/policy/match.go
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// glob шаблон, в котором '*' заменяет любую последовательность символов, включая '/'
+type glob []string
+
+func compileGlobs(patterns []string) []glob {
+	globs := make([]glob, 0, len(patterns))
+	for _, p := range patterns {
+		globs = append(globs, glob(strings.Split(p, "*")))
+	}
+	return globs
+}
+
+// compilePathGlobs как compileGlobs, но шаблон "/admin/*" подходит и для самого "/admin":
+// иначе запрет на "/admin/*" оставил бы открытым путь без завершающего слеша
+func compilePathGlobs(patterns []string) []glob {
+	globs := compileGlobs(patterns)
+	for _, p := range patterns {
+		if parent, ok := strings.CutSuffix(p, "/*"); ok && parent != "" {
+			globs = append(globs, glob(strings.Split(parent, "*")))
+		}
+	}
+	return globs
+}
+
+func (g glob) match(s string) bool {
+	if len(g) == 1 {
+		return s == g[0]
+	}
+	if !strings.HasPrefix(s, g[0]) {
+		return false
+	}
+	s = s[len(g[0]):]
+	for _, part := range g[1 : len(g)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, g[len(g)-1])
+}
+
+func matchAny(globs []glob, s string) bool {
+	for _, g := range globs {
+		if g.match(s) {
+			return true
+		}
+	}
+	return false
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// window окно времени в заданном часовом поясе; from > to — окно через полночь
+type window struct {
+	days     [7]bool
+	anyDay   bool
+	from, to int // минуты от начала суток
+	anyTime  bool
+	location *time.Location
+}
+
+func compileWindow(spec TimeSpec) (*window, error) {
+	w := &window{location: time.UTC, anyDay: len(spec.Days) == 0}
+	if spec.Timezone != "" {
+		loc, err := time.LoadLocation(spec.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q", spec.Timezone)
+		}
+		w.location = loc
+	}
+
+	for _, d := range spec.Days {
+		first, last, isRange := strings.Cut(strings.ToLower(d), "-")
+		if !isRange {
+			last = first
+		}
+		start, okStart := weekdays[first]
+		end, okEnd := weekdays[last]
+		if !okStart || !okEnd {
+			return nil, fmt.Errorf("invalid day %q (use mon..sun or ranges like mon-fri)", d)
+		}
+		for day := start; ; day = (day + 1) % 7 {
+			w.days[day] = true
+			if day == end {
+				break
+			}
+		}
+	}
+
+	if spec.From == "" && spec.To == "" {
+		w.anyTime = true
+		return w, nil
+	}
+	var err error
+	if w.from, err = parseClock(spec.From, 0); err != nil {
+		return nil, err
+	}
+	if w.to, err = parseClock(spec.To, 24*60); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func parseClock(s string, empty int) (int, error) {
+	if s == "" {
+		return empty, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (use HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (w *window) contains(t time.Time) bool {
+	t = t.In(w.location)
+	day := t.Weekday()
+	minute := t.Hour()*60 + t.Minute()
+
+	if w.anyTime {
+		return w.anyDay || w.days[day]
+	}
+	if w.from <= w.to {
+		return (w.anyDay || w.days[day]) && minute >= w.from && minute < w.to
+	}
+	// Окно через полночь: хвост после полуночи относится к предыдущему дню
+	if minute >= w.from {
+		return w.anyDay || w.days[day]
+	}
+	if minute < w.to {
+		return w.anyDay || w.days[(day+6)%7]
+	}
+	return false
+}
@@ -0,0 +1,297 @@
+// internal/policy/policy.go
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+
+	"access-proxy/internal/expr"
+)
+
+// Действия правил
+const (
+	ActionAllow = "allow"
+	ActionDeny  = "deny"
+)
+
+// Spec описание политики из конфигурации
+type Spec struct {
+	Name          string
+	Paths         []string
+	Routes        []string
+	Rules         []RuleSpec
+	DefaultAction string
+}
+
+// RuleSpec описание правила; пустое условие подходит любому запросу
+type RuleSpec struct {
+	Action        string
+	Methods       []string
+	Paths         []string
+	CIDRs         []string
+	Origins       []string
+	Principals    []string
+	AuthMethods   []string
+	Authenticated *bool
+	Time          *TimeSpec
+	When          string // выражение, см. пакет expr
+}
+
+// TimeSpec окно времени: дни недели и интервал HH:MM
+type TimeSpec struct {
+	Days     []string
+	From     string
+	To       string
+	Timezone string
+}
+
+// Request сведения о запросе, по которым проверяются правила
+type Request = expr.Env
+
+// Decision результат проверки. Matched=false — ни одна политика не относится к запросу
+type Decision struct {
+	Matched bool
+	Allowed bool
+	Policy  string
+	Rule    int // номер правила с 1, 0 — действие по умолчанию
+}
+
+// Set скомпилированные политики в порядке конфигурации
+type Set struct {
+	policies []*policy
+}
+
+type policy struct {
+	name         string
+	paths        []glob
+	routes       map[string]bool
+	rules        []*rule
+	defaultAllow bool
+}
+
+type rule struct {
+	allow         bool
+	methods       map[string]bool
+	paths         []glob
+	nets          []*net.IPNet
+	origins       []glob
+	principals    []glob
+	authMethods   map[string]bool
+	authenticated *bool
+	window        *window
+	when          *expr.Program
+}
+
+// Compile проверяет и компилирует политики
+func Compile(specs []Spec) (*Set, error) {
+	set := &Set{}
+	for i, spec := range specs {
+		name := spec.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		p, err := compilePolicy(name, spec)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: %w", name, err)
+		}
+		set.policies = append(set.policies, p)
+	}
+	return set, nil
+}
+
+// Len число политик
+func (s *Set) Len() int {
+	return len(s.policies)
+}
+
+// Evaluate применяет первую политику, к которой относится запрос
+func (s *Set) Evaluate(req *Request) Decision {
+	// Шаблоны сравниваются с нормализованным путём, иначе /public/../admin/x
+	// и //admin/x обходили бы правила для /admin/*
+	if clean := cleanPath(req.Path); clean != req.Path {
+		normalized := *req
+		normalized.Path = clean
+		req = &normalized
+	}
+	for _, p := range s.policies {
+		if !p.applies(req) {
+			continue
+		}
+		for i, r := range p.rules {
+			if r.matches(req) {
+				return Decision{Matched: true, Allowed: r.allow, Policy: p.name, Rule: i + 1}
+			}
+		}
+		return Decision{Matched: true, Allowed: p.defaultAllow, Policy: p.name}
+	}
+	return Decision{Allowed: true}
+}
+
+func compilePolicy(name string, spec Spec) (*policy, error) {
+	defaultAllow, err := parseAction(spec.DefaultAction)
+	if err != nil {
+		return nil, fmt.Errorf("default_action: %w", err)
+	}
+	p := &policy{
+		name:         name,
+		paths:        compilePathGlobs(spec.Paths),
+		routes:       toSet(spec.Routes, false),
+		defaultAllow: defaultAllow,
+	}
+	for i, rs := range spec.Rules {
+		r, err := compileRule(rs)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		p.rules = append(p.rules, r)
+	}
+	return p, nil
+}
+
+func compileRule(spec RuleSpec) (*rule, error) {
+	allow, err := parseAction(spec.Action)
+	if err != nil {
+		return nil, err
+	}
+	r := &rule{
+		allow:         allow,
+		methods:       toSet(spec.Methods, true),
+		paths:         compilePathGlobs(spec.Paths),
+		origins:       compileGlobs(spec.Origins),
+		principals:    compileGlobs(spec.Principals),
+		authMethods:   toSet(spec.AuthMethods, false),
+		authenticated: spec.Authenticated,
+	}
+	for _, cidr := range spec.CIDRs {
+		n, err := parseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		r.nets = append(r.nets, n)
+	}
+	if spec.Time != nil {
+		if r.window, err = compileWindow(*spec.Time); err != nil {
+			return nil, err
+		}
+	}
+	if spec.When != "" {
+		if r.when, err = expr.CompileBool(spec.When); err != nil {
+			return nil, fmt.Errorf("when: %w", err)
+		}
+	}
+	return r, nil
+}
+
+func (p *policy) applies(req *Request) bool {
+	if len(p.routes) > 0 && p.routes[req.Route] {
+		return true
+	}
+	if len(p.paths) > 0 && matchAny(p.paths, req.Path) {
+		return true
+	}
+	return len(p.routes) == 0 && len(p.paths) == 0
+}
+
+func (r *rule) matches(req *Request) bool {
+	if len(r.methods) > 0 && !r.methods[strings.ToUpper(req.Method)] {
+		return false
+	}
+	if len(r.paths) > 0 && !matchAny(r.paths, req.Path) {
+		return false
+	}
+	if len(r.nets) > 0 && !containsIP(r.nets, req.ClientIP) {
+		return false
+	}
+	if len(r.origins) > 0 {
+		origin := req.Header.Get("Origin")
+		if origin == "" || !matchAny(r.origins, origin) {
+			return false
+		}
+	}
+	if r.authenticated != nil && *r.authenticated != (req.Principal != "") {
+		return false
+	}
+	if len(r.principals) > 0 && (req.Principal == "" || !matchAny(r.principals, req.Principal)) {
+		return false
+	}
+	if len(r.authMethods) > 0 && !r.authMethods[req.AuthMethod] {
+		return false
+	}
+	if r.window != nil && !r.window.contains(req.Time) {
+		return false
+	}
+	if r.when != nil && !r.when.Bool(req) {
+		return false
+	}
+	return true
+}
+
+func parseAction(action string) (bool, error) {
+	switch strings.ToLower(action) {
+	case ActionAllow:
+		return true, nil
+	case ActionDeny:
+		return false, nil
+	case "":
+		return false, errors.New("action is required (allow or deny)")
+	}
+	return false, fmt.Errorf("unknown action %q (allow or deny)", action)
+}
+
+// parseCIDR принимает сеть или одиночный адрес
+func parseCIDR(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", s)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR %q", s)
+	}
+	return n, nil
+}
+
+// cleanPath убирает точечные сегменты и повторные '/', сохраняя завершающий '/'
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	clean := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && clean != "/" {
+		clean += "/"
+	}
+	return clean
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func toSet(items []string, upper bool) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		if upper {
+			item = strings.ToUpper(item)
+		}
+		set[item] = true
+	}
+	return set
+}
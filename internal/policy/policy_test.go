@@ -0,0 +1,179 @@
+package policy
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustCompile(t *testing.T, specs []Spec) *Set {
+	t.Helper()
+	set, err := Compile(specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return set
+}
+
+func TestEvaluateNormalizesPath(t *testing.T) {
+	set := mustCompile(t, []Spec{
+		{
+			Name:          "admin",
+			Paths:         []string{"/admin/*"},
+			Rules:         []RuleSpec{{Action: ActionAllow, CIDRs: []string{"10.0.0.0/8"}}},
+			DefaultAction: ActionDeny,
+		},
+		{
+			Name:          "public",
+			Paths:         []string{"/public/*"},
+			Rules:         []RuleSpec{{Action: ActionAllow, Methods: []string{"GET"}}},
+			DefaultAction: ActionDeny,
+		},
+	})
+
+	tests := []struct {
+		path   string
+		policy string
+		allow  bool
+	}{
+		{"/admin/x", "admin", false},
+		{"/admin", "admin", false},
+		{"/public/../admin", "admin", false},
+		{"/adminx", "", true},
+		{"/public/x", "public", true},
+		{"/public/../admin/x", "admin", false},
+		{"/public/./../admin/x", "admin", false},
+		{"//admin/x", "admin", false},
+		{"/admin//x", "admin", false},
+		{"/public/a/../b", "public", true},
+		{"/public/../../admin/", "admin", false},
+		{"admin/x", "admin", false},
+		{"/other", "", true},
+	}
+	for _, tt := range tests {
+		req := &Request{Method: "GET", Path: tt.path, ClientIP: net.ParseIP("203.0.113.7")}
+		d := set.Evaluate(req)
+		if d.Policy != tt.policy || d.Allowed != tt.allow {
+			t.Errorf("%s: got policy %q allowed=%t, want %q allowed=%t", tt.path, d.Policy, d.Allowed, tt.policy, tt.allow)
+		}
+		if req.Path != tt.path {
+			t.Errorf("%s: request path modified to %q", tt.path, req.Path)
+		}
+	}
+}
+
+func TestPathGlobs(t *testing.T) {
+	globs := compilePathGlobs([]string{"/admin/*", "/*/debug/*", "/exact"})
+	for path, want := range map[string]bool{
+		"/admin":         true,
+		"/admin/":        true,
+		"/admin/users/1": true,
+		"/adminx":        false,
+		"/v1/debug":      true,
+		"/v1/debug/vars": true,
+		"/exact":         true,
+		"/exact/x":       false,
+	} {
+		if got := matchAny(globs, path); got != want {
+			t.Errorf("%s: got %t, want %t", path, got, want)
+		}
+	}
+
+	// Для principals и origins правило родительского пути не действует
+	if matchAny(compileGlobs([]string{"team/*"}), "team") {
+		t.Error("principal glob matched the parent")
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	for in, want := range map[string]string{
+		"":                "/",
+		"/":               "/",
+		"//":              "/",
+		"/a/":             "/a/",
+		"/a/./b/../c//d/": "/a/c/d/",
+		"/../a":           "/a",
+	} {
+		if got := cleanPath(in); got != want {
+			t.Errorf("cleanPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRuleConditions(t *testing.T) {
+	yes, no := true, false
+	monday := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		rule RuleSpec
+		req  Request
+		want bool
+	}{
+		{"empty rule", RuleSpec{}, Request{}, true},
+		{"method", RuleSpec{Methods: []string{"get"}}, Request{Method: "GET"}, true},
+		{"other method", RuleSpec{Methods: []string{"GET"}}, Request{Method: "POST"}, false},
+		{"cidr", RuleSpec{CIDRs: []string{"10.0.0.0/8"}}, Request{ClientIP: net.ParseIP("10.1.2.3")}, true},
+		{"single ip", RuleSpec{CIDRs: []string{"192.0.2.1"}}, Request{ClientIP: net.ParseIP("192.0.2.2")}, false},
+		{"no ip", RuleSpec{CIDRs: []string{"0.0.0.0/0"}}, Request{}, false},
+		{"origin", RuleSpec{Origins: []string{"https://*.example.com"}}, Request{Header: http.Header{"Origin": {"https://app.example.com"}}}, true},
+		{"missing origin", RuleSpec{Origins: []string{"*"}}, Request{Header: http.Header{}}, false},
+		{"authenticated", RuleSpec{Authenticated: &yes}, Request{Principal: "bob"}, true},
+		{"anonymous", RuleSpec{Authenticated: &no}, Request{Principal: "bob"}, false},
+		{"principal", RuleSpec{Principals: []string{"*@example.com"}}, Request{Principal: "bob@example.com"}, true},
+		{"auth method", RuleSpec{AuthMethods: []string{"jwt"}}, Request{AuthMethod: "api_key"}, false},
+		{"time", RuleSpec{Time: &TimeSpec{Days: []string{"mon-fri"}, From: "09:00", To: "18:00"}}, Request{Time: monday}, true},
+		{"night window", RuleSpec{Time: &TimeSpec{Days: []string{"sun"}, From: "22:00", To: "06:00"}}, Request{Time: monday.Add(-8 * time.Hour)}, true},
+		{"outside window", RuleSpec{Time: &TimeSpec{From: "11:00"}}, Request{Time: monday}, false},
+		{"when", RuleSpec{When: `ip in cidr("10.0.0.0/8")`}, Request{ClientIP: net.ParseIP("10.0.0.1")}, true},
+	}
+	for _, tt := range tests {
+		tt.rule.Action = ActionAllow
+		set := mustCompile(t, []Spec{{Rules: []RuleSpec{tt.rule}, DefaultAction: ActionDeny}})
+		if d := set.Evaluate(&tt.req); d.Allowed != tt.want {
+			t.Errorf("%s: allowed = %t, want %t", tt.name, d.Allowed, tt.want)
+		}
+	}
+}
+
+func TestRulesFirstMatchWins(t *testing.T) {
+	set := mustCompile(t, []Spec{{
+		Routes: []string{"api"},
+		Rules: []RuleSpec{
+			{Action: ActionDeny, Methods: []string{"DELETE"}},
+			{Action: ActionAllow},
+		},
+		DefaultAction: ActionDeny,
+	}})
+
+	if d := set.Evaluate(&Request{Route: "api", Method: "DELETE"}); d.Allowed || d.Rule != 1 || d.Policy != "#1" {
+		t.Errorf("DELETE: %+v", d)
+	}
+	if d := set.Evaluate(&Request{Route: "api", Method: "GET"}); !d.Allowed || d.Rule != 2 {
+		t.Errorf("GET: %+v", d)
+	}
+	if d := set.Evaluate(&Request{Route: "web", Method: "DELETE"}); d.Matched || !d.Allowed {
+		t.Errorf("other route: %+v", d)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := map[string]Spec{
+		"action":   {Rules: []RuleSpec{{Action: "maybe"}}, DefaultAction: ActionDeny},
+		"default":  {},
+		"cidr":     {Rules: []RuleSpec{{Action: ActionAllow, CIDRs: []string{"10.0.0.0/33"}}}, DefaultAction: ActionDeny},
+		"day":      {Rules: []RuleSpec{{Action: ActionAllow, Time: &TimeSpec{Days: []string{"funday"}}}}, DefaultAction: ActionDeny},
+		"clock":    {Rules: []RuleSpec{{Action: ActionAllow, Time: &TimeSpec{From: "25:00"}}}, DefaultAction: ActionDeny},
+		"timezone": {Rules: []RuleSpec{{Action: ActionAllow, Time: &TimeSpec{Timezone: "Mars/Base"}}}, DefaultAction: ActionDeny},
+		"when":     {Rules: []RuleSpec{{Action: ActionAllow, When: "request.path =="}}, DefaultAction: ActionDeny},
+	}
+	for name, spec := range tests {
+		spec.Name = name
+		_, err := Compile([]Spec{spec})
+		if err == nil || !strings.Contains(err.Error(), "policy "+name) {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+type fileCounter struct {
+	Used    int64     `json:"used"`
+	Expires time.Time `json:"expires"`
+}
+
+// FileStore держит счётчики в памяти и раз в flushInterval сохраняет их в JSON файл.
+// Файл перезаписывается атомарно через временный, поэтому сбой при записи не портит
+// прежнее состояние; при аварийной остановке теряются изменения за последний интервал
+type FileStore struct {
+	path string
+	log  logger.Logger
+
+	mu       sync.Mutex
+	counters map[string]fileCounter
+	dirty    bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewFileStore загружает счётчики из path, если файл есть, и запускает сохранение
+func NewFileStore(path string, flushInterval time.Duration, log logger.Logger) (*FileStore, error) {
+	if flushInterval <= 0 {
+		flushInterval = 5 * time.Second
+	}
+
+	s := &FileStore{
+		path:     path,
+		log:      log,
+		counters: make(map[string]fileCounter),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, &s.counters); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+
+	go s.flushLoop(flushInterval)
+	return s, nil
+}
+
+func (s *FileStore) flushLoop(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				s.log.Errorf("❌ Failed to save quota counters: %v", err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Take реализует Store
+func (s *FileStore) Take(_ context.Context, counters []Counter, n int64) ([]int64, bool, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	used := make([]int64, len(counters))
+	allowed := true
+	for i, c := range counters {
+		if fc, ok := s.counters[c.Key]; ok && fc.Expires.After(now) {
+			used[i] = fc.Used
+		}
+		if used[i]+n > c.Limit {
+			allowed = false
+		}
+	}
+	if !allowed || n == 0 {
+		return used, allowed, nil
+	}
+
+	for i, c := range counters {
+		used[i] += n
+		s.counters[c.Key] = fileCounter{Used: used[i], Expires: c.Expires}
+	}
+	s.dirty = true
+	return used, true, nil
+}
+
+// Flush сохраняет счётчики, если они изменились, и удаляет истёкшие
+func (s *FileStore) Flush() error {
+	now := time.Now()
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	for key, fc := range s.counters {
+		if !fc.Expires.After(now) {
+			delete(s.counters, key)
+		}
+	}
+	data, err := json.Marshal(s.counters)
+	s.dirty = false
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(s.path, data); err != nil {
+		// Следующая попытка сохранит изменения снова
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Close останавливает периодическое сохранение и сохраняет счётчики последний раз
+func (s *FileStore) Close() error {
+	close(s.stop)
+	<-s.done
+	return s.Flush()
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
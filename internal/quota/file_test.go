@@ -0,0 +1,128 @@
+package quota
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+func newTestFileStore(t *testing.T, path string) *FileStore {
+	t.Helper()
+	s, err := NewFileStore(path, time.Hour, logger.New("test", logger.LevelInfo, logger.ModeDev))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestFileStoreTake(t *testing.T) {
+	s := newTestFileStore(t, filepath.Join(t.TempDir(), "quota.json"))
+	defer s.Close()
+	ctx := context.Background()
+	expires := time.Now().Add(time.Hour)
+	counters := []Counter{
+		{Key: "day", Limit: 2, Expires: expires},
+		{Key: "month", Limit: 5, Expires: expires},
+	}
+
+	tests := []struct {
+		n       int64
+		used    []int64
+		allowed bool
+	}{
+		{1, []int64{1, 1}, true},
+		{0, []int64{1, 1}, true}, // только чтение
+		{1, []int64{2, 2}, true},
+		{1, []int64{2, 2}, false}, // суточная квота исчерпана, месячная не расходуется
+		{0, []int64{2, 2}, true},
+	}
+	for i, tt := range tests {
+		used, allowed, err := s.Take(ctx, counters, tt.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(used, tt.used) || allowed != tt.allowed {
+			t.Errorf("step %d: used=%v allowed=%v, want %v %v", i, used, allowed, tt.used, tt.allowed)
+		}
+	}
+
+	// Другой набор окон с тем же месячным счётчиком видит его значение
+	used, allowed, _ := s.Take(ctx, counters[1:], 1)
+	if !allowed || used[0] != 3 {
+		t.Errorf("month only: used=%v allowed=%v", used, allowed)
+	}
+}
+
+func TestFileStoreExpired(t *testing.T) {
+	s := newTestFileStore(t, filepath.Join(t.TempDir(), "quota.json"))
+	defer s.Close()
+	ctx := context.Background()
+
+	s.counters["day"] = fileCounter{Used: 10, Expires: time.Now().Add(-time.Second)}
+	used, allowed, err := s.Take(ctx, []Counter{{Key: "day", Limit: 10, Expires: time.Now().Add(time.Hour)}}, 1)
+	if err != nil || !allowed || used[0] != 1 {
+		t.Errorf("expired counter: used=%v allowed=%v err=%v", used, allowed, err)
+	}
+}
+
+func TestFileStorePersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	ctx := context.Background()
+	counter := []Counter{{Key: "day", Limit: 10, Expires: time.Now().Add(time.Hour)}}
+
+	s := newTestFileStore(t, path)
+	s.Take(ctx, counter, 3)
+	s.counters["old"] = fileCounter{Used: 1, Expires: time.Now().Add(-time.Second)}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s = newTestFileStore(t, path)
+	defer s.Close()
+	if _, ok := s.counters["old"]; ok {
+		t.Error("expired counter was saved")
+	}
+	if used, _, _ := s.Take(ctx, counter, 0); used[0] != 3 {
+		t.Errorf("used after restart = %d, want 3", used[0])
+	}
+
+	// Без изменений файл не перезаписывается
+	os.Remove(path)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("clean flush wrote the file: %v", err)
+	}
+}
+
+func TestFileStoreFlushFailure(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestFileStore(t, filepath.Join(dir, "missing", "quota.json"))
+	s.Take(context.Background(), []Counter{{Key: "day", Limit: 10, Expires: time.Now().Add(time.Hour)}}, 1)
+
+	if err := s.Flush(); err == nil {
+		t.Fatal("flush into a missing directory succeeded")
+	}
+	// Изменения не потеряны и сохраняются следующей попыткой
+	os.Mkdir(filepath.Join(dir, "missing"), 0o755)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing", "quota.json")); err != nil {
+		t.Errorf("retry did not save counters: %v", err)
+	}
+}
+
+func TestFileStoreInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	os.WriteFile(path, []byte("{"), 0o644)
+	if _, err := NewFileStore(path, time.Hour, logger.New("test", logger.LevelInfo, logger.ModeDev)); err == nil {
+		t.Error("invalid file accepted")
+	}
+}
@@ -0,0 +1,271 @@
+package quota
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"access-proxy/internal/metrics"
+	"access-proxy/internal/reqctx"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+// Окна квот, они же значения period в ответах
+const (
+	PeriodDay   = "day"
+	PeriodMonth = "month"
+)
+
+// Limit число вызовов за календарные сутки и месяц; 0 — без ограничения
+type Limit struct {
+	Daily   int64
+	Monthly int64
+}
+
+// Options квоты вызовов upstream по клиентам
+type Options struct {
+	Default   Limit            // для клиентов без своей квоты
+	Consumers map[string]Limit // по principal, важнее tiers
+	Tiers     map[string]Limit // по tier ключа API
+
+	// Location часовой пояс, в котором начинаются сутки и месяц, по умолчанию UTC
+	Location *time.Location
+	Store    Store
+
+	// FailClosed отклонять запросы, если хранилище недоступно; иначе пропускать
+	FailClosed bool
+	// UpstreamRoutes маршруты, которые уходят в upstream; вызовы служебных
+	// эндпоинтов прокси не учитываются
+	UpstreamRoutes []string
+}
+
+// Limiter считает вызовы каждого клиента за сутки и месяц и отклоняет
+// запросы сверх квоты до начала следующего окна
+type Limiter struct {
+	opts     Options
+	upstream map[string]bool
+	metrics  *metrics.ProxyMetrics
+	log      logger.Logger
+
+	lastStoreError atomic.Int64 // unix nano последней ошибки хранилища в логе
+}
+
+// New создаёт ограничитель; m может быть nil
+func New(opts Options, m *metrics.ProxyMetrics, log logger.Logger) *Limiter {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+
+	l := &Limiter{
+		opts:     opts,
+		upstream: make(map[string]bool, len(opts.UpstreamRoutes)),
+		metrics:  m,
+		log:      log,
+	}
+	for _, route := range opts.UpstreamRoutes {
+		l.upstream[route] = true
+	}
+	return l
+}
+
+// Close сохраняет счётчики и закрывает хранилище
+func (l *Limiter) Close() error {
+	return l.opts.Store.Close()
+}
+
+func (l *Limiter) limitFor(info *reqctx.Info) Limit {
+	if info.Principal != "" {
+		if limit, ok := l.opts.Consumers[info.Principal]; ok {
+			return limit
+		}
+	}
+	if info.RateLimitTier != "" {
+		if limit, ok := l.opts.Tiers[info.RateLimitTier]; ok {
+			return limit
+		}
+	}
+	return l.opts.Default
+}
+
+// window одно окно квоты клиента
+type window struct {
+	period string
+	limit  int64
+	reset  time.Time
+}
+
+// windows окна квоты клиента и их счётчики; ключ содержит само окно,
+// поэтому в новые сутки или месяц счёт начинается заново
+func (l *Limiter) windows(info *reqctx.Info, now time.Time) ([]window, []Counter) {
+	limit := l.limitFor(info)
+	now = now.In(l.opts.Location)
+	identity := info.Identity()
+
+	var windows []window
+	var counters []Counter
+	if limit.Daily > 0 {
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, l.opts.Location)
+		reset := start.AddDate(0, 0, 1)
+		windows = append(windows, window{PeriodDay, limit.Daily, reset})
+		counters = append(counters, Counter{
+			Key:     "day:" + start.Format("2006-01-02") + ":" + identity,
+			Limit:   limit.Daily,
+			Expires: reset,
+		})
+	}
+	if limit.Monthly > 0 {
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, l.opts.Location)
+		reset := start.AddDate(0, 1, 0)
+		windows = append(windows, window{PeriodMonth, limit.Monthly, reset})
+		counters = append(counters, Counter{
+			Key:     "month:" + start.Format("2006-01") + ":" + identity,
+			Limit:   limit.Monthly,
+			Expires: reset,
+		})
+	}
+	return windows, counters
+}
+
+// PeriodStatus использование квоты клиента за одно окно
+type PeriodStatus struct {
+	Period    string    `json:"period"`
+	Limit     int64     `json:"limit"`
+	Used      int64     `json:"used"`
+	Remaining int64     `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// Status квоты клиента запроса без расхода
+func (l *Limiter) Status(r *http.Request) ([]PeriodStatus, error) {
+	info := reqctx.FromRequest(r)
+	if info == nil {
+		return []PeriodStatus{}, nil
+	}
+	windows, counters := l.windows(info, time.Now())
+	used, _, err := l.opts.Store.Take(r.Context(), counters, 0)
+	if err != nil {
+		return nil, err
+	}
+	return periodStatuses(windows, used), nil
+}
+
+func periodStatuses(windows []window, used []int64) []PeriodStatus {
+	statuses := make([]PeriodStatus, len(windows))
+	for i, w := range windows {
+		statuses[i] = PeriodStatus{
+			Period:    w.period,
+			Limit:     w.limit,
+			Used:      used[i],
+			Remaining: max(w.limit-used[i], 0),
+			Reset:     w.reset,
+		}
+	}
+	return statuses
+}
+
+// Middleware возвращает HTTP middleware, которое учитывает вызовы upstream
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := reqctx.FromRequest(r)
+		if info == nil || !l.upstream[info.Route] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		windows, counters := l.windows(info, time.Now())
+		if len(windows) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		used, allowed, err := l.opts.Store.Take(r.Context(), counters, 1)
+		if err != nil {
+			l.storeFailed(w, r, next, err)
+			return
+		}
+
+		statuses := periodStatuses(windows, used)
+		if !allowed {
+			l.deny(w, r, info, statuses)
+			return
+		}
+
+		// Заголовки описывают окно с наименьшим остатком
+		tightest := statuses[0]
+		for _, st := range statuses[1:] {
+			if st.Remaining < tightest.Remaining {
+				tightest = st
+			}
+		}
+		setHeaders(w, tightest)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func setHeaders(w http.ResponseWriter, st PeriodStatus) {
+	w.Header().Set("X-Quota-Limit", fmt.Sprintf("%d", st.Limit))
+	w.Header().Set("X-Quota-Remaining", fmt.Sprintf("%d", st.Remaining))
+	w.Header().Set("X-Quota-Reset", fmt.Sprintf("%d", st.Reset.Unix()))
+	w.Header().Set("X-Quota-Period", st.Period)
+}
+
+// deny отвечает 429 до начала окна, квота которого исчерпана; если исчерпаны
+// обе, ждать нужно до более позднего
+func (l *Limiter) deny(w http.ResponseWriter, r *http.Request, info *reqctx.Info, statuses []PeriodStatus) {
+	var exceeded PeriodStatus
+	for _, st := range statuses {
+		if st.Remaining == 0 && st.Reset.After(exceeded.Reset) {
+			exceeded = st
+		}
+	}
+
+	l.log.Warnf("📅 %s quota of %d calls exceeded for %s: %s %s",
+		exceeded.Period, exceeded.Limit, info.Identity(), r.Method, r.URL.Path)
+	reqctx.Deny(r, "quota_exceeded")
+
+	setHeaders(w, exceeded)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(time.Until(exceeded.Reset).Seconds()))))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":   "quota_exceeded",
+		"message": "Call quota exceeded",
+		"period":  exceeded.Period,
+		"limit":   exceeded.Limit,
+		"reset":   exceeded.Reset,
+	})
+}
+
+// storeFailed пропускает или отклоняет запрос, когда хранилище недоступно
+func (l *Limiter) storeFailed(w http.ResponseWriter, r *http.Request, next http.Handler, err error) {
+	if l.metrics != nil {
+		l.metrics.QuotaStoreFailed()
+	}
+
+	// Пока хранилище недоступно, ошибка пишется в лог не чаще раза в 10 секунд
+	now := time.Now().UnixNano()
+	if last := l.lastStoreError.Load(); now-last > int64(10*time.Second) && l.lastStoreError.CompareAndSwap(last, now) {
+		if l.opts.FailClosed {
+			l.log.Errorf("❌ Quota store is unavailable, requests are denied: %v", err)
+		} else {
+			l.log.Warnf("⚠️ Quota store is unavailable, requests are allowed: %v", err)
+		}
+	}
+
+	if !l.opts.FailClosed {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	reqctx.Deny(r, "quota_unavailable")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", "1")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":   "quota_unavailable",
+		"message": "Quota accounting is temporarily unavailable",
+	})
+}
@@ -0,0 +1,158 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"access-proxy/internal/reqctx"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+func newTestLimiter(t *testing.T, opts Options) *Limiter {
+	t.Helper()
+	if opts.Store == nil {
+		opts.Store = newTestFileStore(t, filepath.Join(t.TempDir(), "quota.json"))
+	}
+	if opts.UpstreamRoutes == nil {
+		opts.UpstreamRoutes = []string{"api"}
+	}
+	l := New(opts, nil, logger.New("test", logger.LevelInfo, logger.ModeDev))
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func serve(handler http.Handler, info reqctx.Info) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/api/items", nil)
+	r = r.WithContext(reqctx.NewContext(r.Context(), &info))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	return rec
+}
+
+var okHandler = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+
+func TestMiddleware(t *testing.T) {
+	l := newTestLimiter(t, Options{Default: Limit{Daily: 2, Monthly: 10}})
+	handler := l.Middleware(okHandler)
+	client := reqctx.Info{Route: "api", ClientIP: "10.0.0.1"}
+
+	rec := serve(handler, client)
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Quota-Period") != PeriodDay ||
+		rec.Header().Get("X-Quota-Limit") != "2" || rec.Header().Get("X-Quota-Remaining") != "1" {
+		t.Errorf("first: %d %v", rec.Code, rec.Header())
+	}
+	serve(handler, client)
+
+	rec = serve(handler, client)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("X-Quota-Remaining") != "0" ||
+		rec.Header().Get("Retry-After") == "" || !strings.Contains(rec.Body.String(), `"error":"quota_exceeded"`) ||
+		!strings.Contains(rec.Body.String(), `"period":"day"`) {
+		t.Errorf("over quota: %d %v %s", rec.Code, rec.Header(), rec.Body)
+	}
+
+	// Другой клиент считается отдельно, отклонённый запрос месячную квоту не расходует
+	if rec := serve(handler, reqctx.Info{Route: "api", ClientIP: "10.0.0.2"}); rec.Code != http.StatusOK {
+		t.Errorf("other client: %d", rec.Code)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/quota", nil)
+	r = r.WithContext(reqctx.NewContext(r.Context(), &client))
+	st, err := l.Status(r)
+	if err != nil || len(st) != 2 || st[0].Used != 2 || st[1].Period != PeriodMonth || st[1].Used != 2 || st[1].Remaining != 8 {
+		t.Errorf("status: %+v %v", st, err)
+	}
+
+	// Служебные маршруты не учитываются
+	if rec := serve(handler, reqctx.Info{Route: "health", ClientIP: "10.0.0.1"}); rec.Code != http.StatusOK || rec.Header().Get("X-Quota-Limit") != "" {
+		t.Errorf("health: %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestLimitFor(t *testing.T) {
+	l := newTestLimiter(t, Options{
+		Default:   Limit{Daily: 1},
+		Consumers: map[string]Limit{"partner-a": {Monthly: 3}},
+		Tiers:     map[string]Limit{"gold": {Daily: 2}},
+	})
+
+	tests := []struct {
+		name string
+		info reqctx.Info
+		want Limit
+	}{
+		{"default", reqctx.Info{ClientIP: "10.0.0.1"}, Limit{Daily: 1}},
+		{"tier", reqctx.Info{Principal: "partner-b", RateLimitTier: "gold"}, Limit{Daily: 2}},
+		{"consumer over tier", reqctx.Info{Principal: "partner-a", RateLimitTier: "gold"}, Limit{Monthly: 3}},
+		{"unknown tier", reqctx.Info{Principal: "partner-b", RateLimitTier: "silver"}, Limit{Daily: 1}},
+	}
+	for _, tt := range tests {
+		if got := l.limitFor(&tt.info); got != tt.want {
+			t.Errorf("%s: %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWindows(t *testing.T) {
+	loc := time.FixedZone("UTC+3", 3*3600)
+	l := newTestLimiter(t, Options{Default: Limit{Daily: 1, Monthly: 1}, Location: loc})
+
+	// 22:30 UTC 31 октября — уже 1 ноября по местному времени
+	now := time.Date(2026, 10, 31, 22, 30, 0, 0, time.UTC)
+	windows, counters := l.windows(&reqctx.Info{Principal: "partner-a", AuthMethod: "api_key"}, now)
+	if len(windows) != 2 {
+		t.Fatalf("windows: %+v", windows)
+	}
+	if counters[0].Key != "day:2026-11-01:api_key:partner-a" || !counters[0].Expires.Equal(time.Date(2026, 11, 2, 0, 0, 0, 0, loc)) {
+		t.Errorf("day: %+v", counters[0])
+	}
+	if counters[1].Key != "month:2026-11:api_key:partner-a" || !counters[1].Expires.Equal(time.Date(2026, 12, 1, 0, 0, 0, 0, loc)) {
+		t.Errorf("month: %+v", counters[1])
+	}
+
+	// Без квот запросы не считаются
+	l = newTestLimiter(t, Options{})
+	if rec := serve(l.Middleware(okHandler), reqctx.Info{Route: "api", ClientIP: "10.0.0.1"}); rec.Code != http.StatusOK || rec.Header().Get("X-Quota-Limit") != "" {
+		t.Errorf("unlimited: %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestTightestHeaders(t *testing.T) {
+	l := newTestLimiter(t, Options{Default: Limit{Daily: 10, Monthly: 2}})
+	rec := serve(l.Middleware(okHandler), reqctx.Info{Route: "api", ClientIP: "10.0.0.1"})
+	if rec.Header().Get("X-Quota-Period") != PeriodMonth || rec.Header().Get("X-Quota-Remaining") != "1" {
+		t.Errorf("headers: %v", rec.Header())
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, []Counter, int64) ([]int64, bool, error) {
+	return nil, false, errors.New("connection refused")
+}
+
+func (failingStore) Close() error { return nil }
+
+func TestStoreFailure(t *testing.T) {
+	for _, failClosed := range []bool{false, true} {
+		l := newTestLimiter(t, Options{Default: Limit{Daily: 1}, Store: failingStore{}, FailClosed: failClosed})
+		info := reqctx.Info{Route: "api", ClientIP: "10.0.0.1"}
+		rec := serve(l.Middleware(okHandler), info)
+
+		if !failClosed {
+			if rec.Code != http.StatusOK {
+				t.Errorf("allow: %d", rec.Code)
+			}
+			continue
+		}
+		if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" ||
+			!strings.Contains(rec.Body.String(), `"error":"quota_unavailable"`) {
+			t.Errorf("deny: %d %v %s", rec.Code, rec.Header(), rec.Body)
+		}
+	}
+}
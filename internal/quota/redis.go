@@ -0,0 +1,106 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisOptions подключение к Redis, общему для всех реплик прокси
+type RedisOptions struct {
+	Addr      string
+	Username  string
+	Password  string
+	DB        int
+	KeyPrefix string
+	Timeout   time.Duration // на одну проверку, по умолчанию 100ms
+}
+
+// takeScript проверяет и увеличивает все счётчики запроса атомарно.
+// KEYS — ключи, ARGV[1] — n, затем лимит и конец окна (unix ms) для каждого ключа.
+// Ответ — значения счётчиков и в конце 1, если вызов разрешён
+var takeScript = redis.NewScript(`
+local n = tonumber(ARGV[1])
+local used, allowed = {}, true
+for i, key in ipairs(KEYS) do
+	used[i] = tonumber(redis.call('GET', key)) or 0
+	if used[i] + n > tonumber(ARGV[i * 2]) then
+		allowed = false
+	end
+end
+
+if allowed and n > 0 then
+	for i, key in ipairs(KEYS) do
+		used[i] = redis.call('INCRBY', key, n)
+		redis.call('PEXPIREAT', key, ARGV[i * 2 + 1])
+	end
+end
+table.insert(used, allowed and 1 or 0)
+return used
+`)
+
+// RedisStore хранит счётчики квот в Redis, поэтому все реплики делят одну квоту
+type RedisStore struct {
+	client  *redis.Client
+	prefix  string
+	timeout time.Duration
+}
+
+func NewRedisStore(opts RedisOptions) *RedisStore {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 100 * time.Millisecond
+	}
+	return &RedisStore{
+		client: redis.NewClient(&redis.Options{
+			Addr:         opts.Addr,
+			Username:     opts.Username,
+			Password:     opts.Password,
+			DB:           opts.DB,
+			DialTimeout:  opts.Timeout,
+			ReadTimeout:  opts.Timeout,
+			WriteTimeout: opts.Timeout,
+		}),
+		prefix:  opts.KeyPrefix,
+		timeout: opts.Timeout,
+	}
+}
+
+// Ping проверяет, что Redis доступен
+func (s *RedisStore) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.client.Ping(ctx).Err()
+}
+
+// Take реализует Store одним вызовом скрипта
+func (s *RedisStore) Take(ctx context.Context, counters []Counter, n int64) ([]int64, bool, error) {
+	if len(counters) == 0 {
+		return nil, true, nil
+	}
+
+	keys := make([]string, len(counters))
+	args := make([]interface{}, 0, 1+2*len(counters))
+	args = append(args, n)
+	for i, c := range counters {
+		keys[i] = s.prefix + c.Key
+		args = append(args, c.Limit, c.Expires.UnixMilli())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	values, err := takeScript.Run(ctx, s.client, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, false, fmt.Errorf("redis: %w", err)
+	}
+	if len(values) != len(counters)+1 {
+		return nil, false, fmt.Errorf("redis: unexpected script reply of %d values", len(values))
+	}
+	return values[:len(counters)], values[len(counters)] == 1, nil
+}
+
+// Close закрывает соединения с Redis
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,22 @@
+package quota
+
+import (
+	"context"
+	"time"
+)
+
+// Counter счётчик вызовов клиента за одно окно квоты
+type Counter struct {
+	Key     string
+	Limit   int64
+	Expires time.Time // конец окна: после него счётчик больше не нужен
+}
+
+// Store хранилище счётчиков квот, которое переживает перезапуск прокси
+type Store interface {
+	// Take прибавляет n ко всем счётчикам, только если ни один не превысит лимит,
+	// и возвращает их значения после проверки; n = 0 — только прочитать
+	Take(ctx context.Context, counters []Counter, n int64) (used []int64, allowed bool, err error)
+	// Close сохраняет несохранённые изменения и освобождает ресурсы
+	Close() error
+}
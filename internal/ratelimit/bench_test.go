@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slidingLog прежний лимитер: журнал времени запросов клиента под общей блокировкой.
+// Оставлен для сравнения с GCRA
+type slidingLog struct {
+	mu       sync.Mutex
+	requests map[string][]time.Time
+	window   time.Duration
+}
+
+func newSlidingLog(window time.Duration) *slidingLog {
+	return &slidingLog{requests: make(map[string][]time.Time), window: window}
+}
+
+func (l *slidingLog) allow(identifier string, limit int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	valid := []time.Time{}
+	for _, t := range l.requests[identifier] {
+		if now.Sub(t) <= l.window {
+			valid = append(valid, t)
+		}
+	}
+	l.requests[identifier] = valid
+
+	if len(valid) >= limit {
+		return false
+	}
+	l.requests[identifier] = append(valid, now)
+	return true
+}
+
+const benchLimit = 1000
+
+func BenchmarkSlidingLogOneKey(b *testing.B) {
+	l := newSlidingLog(time.Minute)
+	for i := 0; i < b.N; i++ {
+		l.allow("client", benchLimit)
+	}
+}
+
+func BenchmarkGCRAOneKey(b *testing.B) {
+	g := newGCRA(0)
+	bucket := Bucket{Key: "client", Limit: benchLimit, Window: time.Minute, Burst: benchLimit}
+	for i := 0; i < b.N; i++ {
+		g.Take(context.Background(), []Bucket{bucket}, 1)
+	}
+}
+
+// ключи для параллельных тестов: каждая горутина ходит по своим клиентам
+func benchKeys(worker int64) []string {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "client-" + strconv.FormatInt(worker, 10) + "-" + strconv.Itoa(i)
+	}
+	return keys
+}
+
+func BenchmarkSlidingLogManyKeysParallel(b *testing.B) {
+	l := newSlidingLog(time.Minute)
+	var workers atomic.Int64
+	b.RunParallel(func(pb *testing.PB) {
+		keys := benchKeys(workers.Add(1))
+		for i := 0; pb.Next(); i++ {
+			l.allow(keys[i%len(keys)], benchLimit)
+		}
+	})
+}
+
+func BenchmarkGCRAManyKeysParallel(b *testing.B) {
+	g := newGCRA(0)
+	var workers atomic.Int64
+	b.RunParallel(func(pb *testing.PB) {
+		keys := benchKeys(workers.Add(1))
+		for i := 0; pb.Next(); i++ {
+			g.Take(context.Background(), []Bucket{{Key: keys[i%len(keys)], Limit: benchLimit, Window: time.Minute, Burst: benchLimit}}, 1)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// sameShardKeys n ключей, попадающих в один шард
+func sameShardKeys(g *gcra, n int) []string {
+	first := g.shard("k0")
+	keys := []string{"k0"}
+	for i := 1; len(keys) < n; i++ {
+		if key := "k" + strconv.Itoa(i); g.shard(key) == first {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func TestGCRAEvictsLeastRecentlyUsed(t *testing.T) {
+	g := newGCRA(2 * shardCount) // по два клиента на шард
+	evicted := map[string]int{}
+	g.onEvict = func(reason string, n int) { evicted[reason] += n }
+
+	keys := sameShardKeys(g, 3)
+	bucket := func(key string) Bucket { return Bucket{Key: key, Limit: 10, Window: time.Minute, Burst: 10} }
+	now := time.Now()
+
+	take(g, bucket(keys[0]), 3, now)
+	take(g, bucket(keys[1]), 1, now)
+	// Отказ тоже считается обращением
+	if d := take(g, bucket(keys[0]), 100, now); d.Allowed {
+		t.Fatal("take over burst allowed")
+	}
+	take(g, bucket(keys[2]), 1, now)
+
+	s := g.shard(keys[0])
+	if _, ok := s.items[keys[1]]; ok {
+		t.Errorf("least recently used client %s was kept", keys[1])
+	}
+	if d := take(g, bucket(keys[0]), 0, now); d.Remaining != 7 {
+		t.Errorf("recently used client lost its state: %+v", d)
+	}
+	if g.len() != 2 || s.lru.Len() != 2 || evicted[EvictCapacity] != 1 {
+		t.Errorf("tracked = %d, shard = %d, evicted = %v", g.len(), s.lru.Len(), evicted)
+	}
+}
+
+func TestGCRAUnlimitedClients(t *testing.T) {
+	g := newGCRA(0)
+	now := time.Now()
+	for i := 0; i < 1000; i++ {
+		take(g, Bucket{Key: "c" + strconv.Itoa(i), Limit: 1, Window: time.Minute, Burst: 1}, 1, now)
+	}
+	if g.len() != 1000 {
+		t.Errorf("tracked = %d, want 1000", g.len())
+	}
+}
+
+func TestGCRASmallMaxClients(t *testing.T) {
+	// Предел меньше числа шардов: в каждом шарде всё равно остаётся место для одного клиента
+	g := newGCRA(10)
+	for i := range g.shards {
+		if g.shards[i].max != 1 {
+			t.Fatalf("shard %d max = %d, want 1", i, g.shards[i].max)
+		}
+	}
+}
+
+func TestGCRASweep(t *testing.T) {
+	g := newGCRA(0)
+	evicted := map[string]int{}
+	g.onEvict = func(reason string, n int) { evicted[reason] += n }
+	now := time.Now()
+
+	// Быстро восстанавливающийся запас и запас на час
+	take(g, Bucket{Key: "fast", Limit: 10, Window: time.Second, Burst: 10}, 1, now)
+	take(g, Bucket{Key: "slow", Limit: 1, Window: time.Hour, Burst: 1}, 1, now)
+
+	if n := g.sweep(now); n != 0 {
+		t.Fatalf("swept %d clients with unrecovered state", n)
+	}
+	if n := g.sweep(now.Add(time.Second)); n != 1 {
+		t.Fatalf("swept %d, want 1", n)
+	}
+	if _, ok := g.shard("slow").items["slow"]; !ok {
+		t.Error("client with unrecovered state was removed")
+	}
+	if d := take(g, Bucket{Key: "slow", Limit: 1, Window: time.Hour, Burst: 1}, 1, now.Add(time.Second)); d.Allowed {
+		t.Error("sweep reset the limit of an active client")
+	}
+	if g.len() != 1 || evicted[EvictIdle] != 1 {
+		t.Errorf("tracked = %d, evicted = %v", g.len(), evicted)
+	}
+
+	if n := g.sweep(now.Add(2 * time.Hour)); n != 1 || g.len() != 0 {
+		t.Errorf("swept %d, tracked %d", n, g.len())
+	}
+}
+
+func TestTrackedWithExternalStore(t *testing.T) {
+	rl := newTestLimiter(t, Options{Limit: 1, Store: newGCRA(0)})
+	if !rl.Shared() || rl.Tracked() != 0 {
+		t.Errorf("shared = %t, tracked = %d", rl.Shared(), rl.Tracked())
+	}
+
+	local := newTestLimiter(t, Options{Limit: 1, MaxClients: 100})
+	serve(local, "10.0.0.1", "", "")
+	serve(local, "10.0.0.2", "", "")
+	if local.Shared() || local.Tracked() != 2 {
+		t.Errorf("shared = %t, tracked = %d", local.Shared(), local.Tracked())
+	}
+}
@@ -0,0 +1,235 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// shardCount число независимых блокировок; ключи распределяются по хешу
+const shardCount = 64
+
+// Причины вытеснения состояния клиента
+const (
+	EvictIdle     = "idle"
+	EvictCapacity = "capacity"
+)
+
+// gcra Generic Cell Rate Algorithm: для каждого ключа хранится только
+// теоретическое время прибытия (TAT) следующего запроса, поэтому память
+// на клиента постоянна и не зависит от лимита
+type gcra struct {
+	shards  [shardCount]shard
+	tracked atomic.Int64
+
+	// onEvict вызывается вне блокировок после вытеснения n клиентов
+	onEvict func(reason string, n int)
+	now     func() time.Time // часы; в тестах подменяются
+}
+
+// shard часть ключей со своей блокировкой и LRU списком (самые свежие спереди)
+type shard struct {
+	mu    sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List
+	max   int // 0 — без ограничения
+
+	tracked *atomic.Int64 // общий счётчик gcra
+}
+
+type entry struct {
+	key string
+	tat int64 // unix nano
+}
+
+// newGCRA maxClients ограничивает число отслеживаемых клиентов (0 — без ограничения);
+// предел делится между шардами поровну
+func newGCRA(maxClients int) *gcra {
+	g := &gcra{now: time.Now}
+	perShard := 0
+	if maxClients > 0 {
+		perShard = max((maxClients+shardCount-1)/shardCount, 1)
+	}
+	for i := range g.shards {
+		g.shards[i].items = make(map[string]*list.Element)
+		g.shards[i].lru = list.New()
+		g.shards[i].max = perShard
+		g.shards[i].tracked = &g.tracked
+	}
+	return g
+}
+
+func (g *gcra) shardIndex(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % shardCount)
+}
+
+func (g *gcra) shard(key string) *shard {
+	return &g.shards[g.shardIndex(key)]
+}
+
+// Take реализует Store в памяти процесса. Шарды всех ключей блокируются на время
+// проверки и расхода, поэтому параллельный запрос не может израсходовать запас
+// между ними. Блокировки берутся по возрастанию номера шарда, чтобы не было взаимоблокировок
+func (g *gcra) Take(_ context.Context, buckets []Bucket, n int) ([]Decision, error) {
+	nowNano := g.now().UnixNano()
+	locked := g.lock(buckets)
+	unlock := func() {
+		for _, s := range locked {
+			s.mu.Unlock()
+		}
+	}
+
+	decisions := make([]Decision, len(buckets))
+	denied := false
+	for i, b := range buckets {
+		decisions[i] = g.shard(b.Key).take(b, n, nowNano, false)
+		denied = denied || !decisions[i].Allowed
+	}
+	if n == 0 {
+		unlock()
+		return decisions, nil
+	}
+
+	// Отказ одного запаса не расходует остальные: для них сообщается текущий остаток
+	if denied {
+		for i, b := range buckets {
+			if decisions[i].Allowed {
+				decisions[i] = g.shard(b.Key).take(b, 0, nowNano, false)
+			}
+		}
+		unlock()
+		return decisions, nil
+	}
+
+	for _, b := range buckets {
+		g.shard(b.Key).take(b, n, nowNano, true)
+	}
+
+	// Вытеснение после расхода, чтобы не удалить ключ этого же запроса
+	evicted := 0
+	for _, s := range locked {
+		evicted += s.evict()
+	}
+	unlock()
+
+	if evicted > 0 {
+		g.tracked.Add(int64(-evicted))
+		if g.onEvict != nil {
+			g.onEvict(EvictCapacity, evicted)
+		}
+	}
+	return decisions, nil
+}
+
+// lock блокирует шарды ключей по возрастанию номера, каждый один раз
+func (g *gcra) lock(buckets []Bucket) []*shard {
+	var used [shardCount]bool
+	for _, b := range buckets {
+		used[g.shardIndex(b.Key)] = true
+	}
+	locked := make([]*shard, 0, len(buckets))
+	for i := range used {
+		if used[i] {
+			g.shards[i].mu.Lock()
+			locked = append(locked, &g.shards[i])
+		}
+	}
+	return locked
+}
+
+// take пытается израсходовать n запросов из запаса burst, пополняемого
+// со скоростью limit за window. Без consume только вычисляет решение.
+// Вызывается под s.mu
+func (s *shard) take(b Bucket, n int, nowNano int64, consume bool) Decision {
+	if b.Limit <= 0 {
+		return denyAll(b)
+	}
+	key := b.Key
+	interval := max(int64(b.Window)/int64(b.Limit), 1)
+	tolerance := interval * int64(b.Burst)
+
+	tat := nowNano
+	el, found := s.items[key]
+	if found {
+		tat = max(el.Value.(*entry).tat, nowNano)
+	}
+
+	newTat := tat + interval*int64(n)
+	if newTat-nowNano > tolerance {
+		if found {
+			s.lru.MoveToFront(el)
+		}
+		return Decision{
+			RetryAfter: time.Duration(newTat - tolerance - nowNano),
+			Remaining:  int((tolerance - (tat - nowNano)) / interval),
+			Reset:      time.Duration(tat - nowNano),
+		}
+	}
+
+	if consume && n > 0 {
+		if found {
+			el.Value.(*entry).tat = newTat
+			s.lru.MoveToFront(el)
+		} else {
+			s.items[key] = s.lru.PushFront(&entry{key: key, tat: newTat})
+			s.tracked.Add(1)
+		}
+	}
+	return Decision{
+		Allowed:   true,
+		Remaining: int((tolerance - (newTat - nowNano)) / interval),
+		Reset:     time.Duration(newTat - nowNano),
+	}
+}
+
+// evict удаляет давно не использованных клиентов сверх предела шарда. Вызывается под s.mu
+func (s *shard) evict() int {
+	evicted := 0
+	for s.max > 0 && s.lru.Len() > s.max {
+		s.remove(s.lru.Back())
+		evicted++
+	}
+	return evicted
+}
+
+// sweep удаляет клиентов, чей запас полностью восстановился: такое удаление
+// не меняет их лимит. Возвращает число удалённых
+func (g *gcra) sweep(now time.Time) int {
+	nowNano := now.UnixNano()
+	total := 0
+	for i := range g.shards {
+		s := &g.shards[i]
+		s.mu.Lock()
+		// Список упорядочен по последнему обращению, но TAT у клиентов с разными
+		// лимитами растёт по-разному, поэтому проверяется весь шард
+		for el := s.lru.Back(); el != nil; {
+			prev := el.Prev()
+			if el.Value.(*entry).tat <= nowNano {
+				s.remove(el)
+				total++
+			}
+			el = prev
+		}
+		s.mu.Unlock()
+	}
+	g.tracked.Add(int64(-total))
+	if total > 0 && g.onEvict != nil {
+		g.onEvict(EvictIdle, total)
+	}
+	return total
+}
+
+func (s *shard) remove(el *list.Element) {
+	s.lru.Remove(el)
+	delete(s.items, el.Value.(*entry).key)
+}
+
+// len число отслеживаемых клиентов
+func (g *gcra) len() int {
+	return int(g.tracked.Load())
+}
@@ -0,0 +1,194 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// take расходует n запросов одного запаса через Take в момент now
+func take(g *gcra, b Bucket, n int, now time.Time) Decision {
+	g.now = func() time.Time { return now }
+	decisions, _ := g.Take(context.Background(), []Bucket{b}, n) // в памяти ошибок не бывает
+	return decisions[0]
+}
+
+func TestGCRABurstAndRefill(t *testing.T) {
+	g := newGCRA(0)
+	b := Bucket{Key: "k", Limit: 10, Window: 10 * time.Second, Burst: 5} // один запрос в секунду, до 5 подряд
+	now := time.Unix(1_700_000_000, 0)
+
+	for i := 1; i <= 5; i++ {
+		d := take(g, b, 1, now)
+		if !d.Allowed || d.Remaining != 5-i || d.Reset != time.Duration(i)*time.Second || d.RetryAfter != 0 {
+			t.Fatalf("request %d: %+v", i, d)
+		}
+	}
+
+	d := take(g, b, 1, now)
+	if d.Allowed || d.Remaining != 0 || d.RetryAfter != time.Second || d.Reset != 5*time.Second {
+		t.Fatalf("over burst: %+v", d)
+	}
+
+	// Через 300ms запаса ещё нет, через секунду восстановился один запрос
+	if d := take(g, b, 1, now.Add(300*time.Millisecond)); d.Allowed || d.RetryAfter != 700*time.Millisecond {
+		t.Errorf("after 300ms: %+v", d)
+	}
+	if d := take(g, b, 1, now.Add(time.Second)); !d.Allowed || d.Remaining != 0 {
+		t.Errorf("after 1s: %+v", d)
+	}
+
+	// Через полное окно восстановления запас снова полный
+	if d := take(g, b, 0, now.Add(time.Minute)); !d.Allowed || d.Remaining != 5 || d.Reset != 0 {
+		t.Errorf("after idle: %+v", d)
+	}
+}
+
+func TestGCRAPeekDoesNotConsume(t *testing.T) {
+	g := newGCRA(0)
+	b := Bucket{Key: "k", Limit: 3, Window: time.Minute, Burst: 3}
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		if d := take(g, b, 0, now); !d.Allowed || d.Remaining != 3 {
+			t.Fatalf("peek %d: %+v", i, d)
+		}
+	}
+	if g.len() != 0 {
+		t.Errorf("peek tracked %d clients", g.len())
+	}
+}
+
+func TestGCRATakeMany(t *testing.T) {
+	g := newGCRA(0)
+	b := Bucket{Key: "k", Limit: 10, Window: 10 * time.Second, Burst: 10}
+	now := time.Now()
+
+	if d := take(g, b, 11, now); d.Allowed || d.Remaining != 10 {
+		t.Errorf("more than burst: %+v", d)
+	}
+	if d := take(g, b, 7, now); !d.Allowed || d.Remaining != 3 {
+		t.Errorf("take 7: %+v", d)
+	}
+	if d := take(g, b, 4, now); d.Allowed || d.Remaining != 3 || d.RetryAfter != time.Second {
+		t.Errorf("take 4 of 3: %+v", d)
+	}
+}
+
+func TestGCRAKeysAreIndependent(t *testing.T) {
+	g := newGCRA(0)
+	now := time.Now()
+	a := Bucket{Key: "a", Limit: 1, Window: time.Minute, Burst: 1}
+	b := Bucket{Key: "b", Limit: 1, Window: time.Minute, Burst: 1}
+
+	if !take(g, a, 1, now).Allowed || take(g, a, 1, now).Allowed {
+		t.Fatal("a: burst of one not enforced")
+	}
+	if !take(g, b, 1, now).Allowed {
+		t.Error("b limited by a")
+	}
+	if g.len() != 2 {
+		t.Errorf("tracked = %d, want 2", g.len())
+	}
+}
+
+func TestGCRAZeroLimitDenies(t *testing.T) {
+	g := newGCRA(0)
+	for _, limit := range []int{0, -1} {
+		b := Bucket{Key: "blocked", Limit: limit, Window: time.Minute, Burst: 1}
+		d := take(g, b, 1, time.Now())
+		if d.Allowed || d.Remaining != 0 || d.RetryAfter != time.Minute {
+			t.Errorf("limit %d: %+v", limit, d)
+		}
+	}
+
+	open := Bucket{Key: "open", Limit: 10, Window: time.Minute, Burst: 10}
+	blocked := Bucket{Key: "blocked", Limit: 0, Window: time.Minute}
+	decisions, err := g.Take(context.Background(), []Bucket{open, blocked}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decisions[0].Allowed || decisions[1].Allowed {
+		t.Errorf("decisions = %+v", decisions)
+	}
+	if d := take(g, open, 0, time.Now()); d.Remaining != 10 {
+		t.Errorf("denied request consumed another bucket: %+v", d)
+	}
+}
+
+func TestGCRATakeIsAtomic(t *testing.T) {
+	g := newGCRA(0)
+	global := Bucket{Key: "global", Limit: 100, Window: time.Hour, Burst: 100}
+
+	const clients = 2000
+	allowed := make([]bool, clients)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			client := Bucket{Key: "client-" + strconv.Itoa(i), Limit: 1, Window: time.Hour, Burst: 1}
+			<-start
+			decisions, _ := g.Take(context.Background(), []Bucket{client, global}, 1)
+			allowed[i] = decisions[0].Allowed && decisions[1].Allowed
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	total := 0
+	for i, ok := range allowed {
+		client := Bucket{Key: "client-" + strconv.Itoa(i), Limit: 1, Window: time.Hour, Burst: 1}
+		remaining := take(g, client, 0, time.Now()).Remaining
+		if ok {
+			total++
+		}
+		// Запас клиента расходуется, только если прошёл и общий
+		if ok != (remaining == 0) {
+			t.Errorf("client %d: allowed = %t, remaining = %d", i, ok, remaining)
+		}
+	}
+	if total != 100 {
+		t.Errorf("allowed %d requests, want 100", total)
+	}
+}
+
+func TestGCRATakeSameShard(t *testing.T) {
+	g := newGCRA(0)
+	keys := sameShardKeys(g, 2)
+	buckets := []Bucket{
+		{Key: keys[0], Limit: 2, Window: time.Hour, Burst: 2},
+		{Key: keys[1], Limit: 1, Window: time.Hour, Burst: 1},
+	}
+
+	first, _ := g.Take(context.Background(), buckets, 1)
+	second, _ := g.Take(context.Background(), buckets, 1)
+	if !first[0].Allowed || !first[1].Allowed || first[0].Remaining != 1 {
+		t.Errorf("first: %+v", first)
+	}
+	if !second[0].Allowed || second[1].Allowed || second[0].Remaining != 1 || second[1].RetryAfter <= 0 {
+		t.Errorf("second: %+v", second)
+	}
+}
+
+func TestGCRATakeEvictsOtherClients(t *testing.T) {
+	g := newGCRA(shardCount) // по одному клиенту на шард
+	keys := sameShardKeys(g, 3)
+	evicted := 0
+	g.onEvict = func(_ string, n int) { evicted += n }
+
+	g.Take(context.Background(), []Bucket{{Key: keys[0], Limit: 1, Window: time.Hour, Burst: 1}}, 1)
+	decisions, _ := g.Take(context.Background(), []Bucket{
+		{Key: keys[1], Limit: 1, Window: time.Hour, Burst: 1},
+		{Key: keys[2], Limit: 1, Window: time.Hour, Burst: 1},
+	}, 1)
+	if !decisions[0].Allowed || !decisions[1].Allowed {
+		t.Fatalf("decisions = %+v", decisions)
+	}
+	if evicted != 2 || g.len() != 1 {
+		t.Errorf("evicted = %d, tracked = %d", evicted, g.len())
+	}
+}
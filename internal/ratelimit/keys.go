@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"access-proxy/internal/reqctx"
+)
+
+// keyPart возвращает одну составляющую ключа лимита; "" — правило к запросу не применяется
+type keyPart func(r *http.Request, info *reqctx.Info) string
+
+// compileKey разбирает составляющие ключа:
+//
+//	ip, identity, principal, api_key, jwt_sub, path, route, method, header:<имя>, query:<имя>
+func compileKey(parts []string, ipv6Prefix int) ([]keyPart, error) {
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("key must have at least one part")
+	}
+
+	compiled := make([]keyPart, 0, len(parts))
+	for _, part := range parts {
+		kind, arg, _ := strings.Cut(part, ":")
+		var fn keyPart
+		switch strings.ToLower(kind) {
+		case "ip":
+			fn = func(r *http.Request, info *reqctx.Info) string {
+				return clientIP(r, info, ipv6Prefix)
+			}
+		case "identity":
+			fn = func(r *http.Request, info *reqctx.Info) string {
+				if info != nil && info.Principal != "" {
+					return info.Identity()
+				}
+				return clientIP(r, info, ipv6Prefix)
+			}
+		case "principal":
+			fn = func(r *http.Request, info *reqctx.Info) string {
+				if info == nil || info.Principal == "" {
+					return ""
+				}
+				return info.Identity()
+			}
+		case "api_key":
+			fn = principalOf("api_key")
+		case "jwt_sub":
+			fn = principalOf("jwt")
+		case "path":
+			fn = func(r *http.Request, _ *reqctx.Info) string { return r.URL.Path }
+		case "route":
+			fn = func(_ *http.Request, info *reqctx.Info) string {
+				if info == nil {
+					return ""
+				}
+				return info.Route
+			}
+		case "method":
+			fn = func(r *http.Request, _ *reqctx.Info) string { return r.Method }
+		case "header":
+			if arg == "" {
+				return nil, fmt.Errorf("key part %q needs a header name (header:X-Tenant)", part)
+			}
+			name := http.CanonicalHeaderKey(arg)
+			fn = func(r *http.Request, _ *reqctx.Info) string { return r.Header.Get(name) }
+		case "query":
+			if arg == "" {
+				return nil, fmt.Errorf("key part %q needs a parameter name (query:tenant)", part)
+			}
+			fn = func(r *http.Request, _ *reqctx.Info) string { return r.URL.Query().Get(arg) }
+		default:
+			return nil, fmt.Errorf("unknown key part %q", part)
+		}
+		compiled = append(compiled, fn)
+	}
+	return compiled, nil
+}
+
+// principalOf principal, если клиент прошёл проверку указанным методом
+func principalOf(method string) keyPart {
+	return func(_ *http.Request, info *reqctx.Info) string {
+		if info == nil || info.AuthMethod != method {
+			return ""
+		}
+		return info.Principal
+	}
+}
+
+// buildKey собирает ключ правила; false — одна из составляющих пуста
+func buildKey(name string, parts []keyPart, r *http.Request, info *reqctx.Info) (string, bool) {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, part := range parts {
+		v := part(r, info)
+		if v == "" {
+			return "", false
+		}
+		b.WriteByte(0)
+		b.WriteString(v)
+	}
+	return b.String(), true
+}
+
+// clientIP адрес клиента без порта; IPv6 сводится к префиксу, так как
+// один клиент обычно владеет целой подсетью
+func clientIP(r *http.Request, info *reqctx.Info, ipv6Prefix int) string {
+	raw := getClientIP(r)
+	if info != nil && info.ClientIP != "" {
+		raw = info.ClientIP
+	}
+	if host, _, err := net.SplitHostPort(raw); err == nil {
+		raw = host
+	}
+
+	ip := net.ParseIP(raw)
+	if ip == nil {
+		return raw
+	}
+	if ip.To4() == nil && ipv6Prefix > 0 && ipv6Prefix < 128 {
+		return (&net.IPNet{IP: ip.Mask(net.CIDRMask(ipv6Prefix, 128)), Mask: net.CIDRMask(ipv6Prefix, 128)}).String()
+	}
+	return ip.String()
+}
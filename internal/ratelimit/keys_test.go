@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"access-proxy/internal/reqctx"
+)
+
+func TestBuildKey(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/orders?tenant=acme", nil)
+	r.Header.Set("X-Tenant", "globex")
+	anonymous := &reqctx.Info{ClientIP: "203.0.113.7", Route: "api"}
+	withKey := &reqctx.Info{ClientIP: "203.0.113.7", Route: "api", Principal: "billing", AuthMethod: "api_key"}
+	withJWT := &reqctx.Info{ClientIP: "2001:db8:1:2:3:4:5:6", Route: "api", Principal: "bob", AuthMethod: "jwt"}
+
+	tests := []struct {
+		parts []string
+		info  *reqctx.Info
+		want  string // "" — правило не применяется
+	}{
+		{[]string{"ip"}, anonymous, "203.0.113.7"},
+		{[]string{"ip"}, withJWT, "2001:db8:1:2::/64"},
+		{[]string{"identity"}, anonymous, "203.0.113.7"},
+		{[]string{"identity"}, withKey, "api_key:billing"},
+		{[]string{"principal"}, anonymous, ""},
+		{[]string{"principal"}, withJWT, "jwt:bob"},
+		{[]string{"api_key"}, withKey, "billing"},
+		{[]string{"api_key"}, withJWT, ""},
+		{[]string{"jwt_sub"}, withJWT, "bob"},
+		{[]string{"path", "method"}, anonymous, "/orders POST"},
+		{[]string{"route"}, anonymous, "api"},
+		{[]string{"route"}, nil, ""},
+		{[]string{"header:x-tenant"}, anonymous, "globex"},
+		{[]string{"header:X-Missing"}, anonymous, ""},
+		{[]string{"query:tenant", "ip"}, anonymous, "acme 203.0.113.7"},
+		{[]string{"IP", "Header:X-Tenant"}, anonymous, "203.0.113.7 globex"},
+	}
+	for _, tt := range tests {
+		parts, err := compileKey(tt.parts, 64)
+		if err != nil {
+			t.Errorf("%v: %v", tt.parts, err)
+			continue
+		}
+		key, ok := buildKey("r", parts, r, tt.info)
+		if tt.want == "" {
+			if ok {
+				t.Errorf("%v: got key %q, want none", tt.parts, key)
+			}
+			continue
+		}
+		if want := "r\x00" + strings.ReplaceAll(tt.want, " ", "\x00"); !ok || key != want {
+			t.Errorf("%v: got %q, want %q", tt.parts, key, want)
+		}
+	}
+}
+
+func TestCompileKeyErrors(t *testing.T) {
+	for _, parts := range [][]string{nil, {"header"}, {"query:"}, {"cookie:x"}, {"ip", "nope"}} {
+		if _, err := compileKey(parts, 64); err == nil {
+			t.Errorf("%v accepted", parts)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "[2001:db8::1]:4000"
+	tests := []struct {
+		info   *reqctx.Info
+		prefix int
+		want   string
+	}{
+		{nil, 64, "2001:db8::/64"},
+		{nil, 128, "2001:db8::1"},
+		{&reqctx.Info{ClientIP: "198.51.100.4"}, 64, "198.51.100.4"},
+		{&reqctx.Info{ClientIP: "2001:db8:aa:bb:1::2"}, 48, "2001:db8:aa::/48"},
+		{&reqctx.Info{ClientIP: "not-an-ip"}, 64, "not-an-ip"},
+	}
+	for _, tt := range tests {
+		if got := clientIP(r, tt.info, tt.prefix); got != tt.want {
+			t.Errorf("clientIP(%+v, /%d) = %q, want %q", tt.info, tt.prefix, got, tt.want)
+		}
+	}
+}
@@ -1,104 +1,383 @@
 package ratelimit
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"access-proxy/internal/metrics"
+	"access-proxy/internal/reqctx"
+
 	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
 )
 
+// Options параметры ограничения: правило по умолчанию (limit запросов за window,
+// до burst подряд, ключ — principal или IP) и дополнительные правила
+type Options struct {
+	Limit  int           // 0 — без правила по умолчанию
+	Window time.Duration // по умолчанию минута
+	Burst  int           // по умолчанию равен Limit
+	Rules  []Rule
+
+	IPv6Prefix int // длина префикса, к которому сводятся IPv6 адреса; по умолчанию 64
+
+	// Store общее хранилище, например Redis; nil — в памяти процесса
+	Store Store
+	// FailClosed отклонять запросы, если хранилище недоступно; иначе пропускать
+	FailClosed bool
+	// FailOpenRoutes маршруты, которые пропускаются при недоступном хранилище
+	// даже с FailClosed, например health и metrics
+	FailOpenRoutes []string
+
+	MaxClients      int           // в памяти: 0 — без ограничения
+	CleanupInterval time.Duration // в памяти: по умолчанию минута
+}
+
+// Rule дополнительное правило лимита со своим ключом, окном и burst
+type Rule struct {
+	Name   string
+	Key    []string // составляющие ключа, см. compileKey
+	Limit  int
+	Window time.Duration
+	Burst  int
+	Routes []string // пусто — все маршруты
+}
+
+// defaultRuleName имя правила, заданного rate_limit_per_minute / rate_limit.limit
+const defaultRuleName = "default"
+
+type rule struct {
+	name   string
+	key    []keyPart
+	limit  int
+	burst  int
+	window time.Duration
+	routes map[string]bool
+	tiered bool // лимит зависит от tier клиента
+}
+
 type RateLimiter struct {
-	mu          sync.Mutex
-	requests    map[string][]time.Time
-	limit       int
-	window      time.Duration
-	log         logger.Logger
+	mu         sync.RWMutex
+	store      Store
+	memory     *gcra // nil, если состояние во внешнем хранилище
+	failClosed bool
+	failOpen   map[string]bool
+	rules      []*rule
+	limit      int
+	burst      int
+	tiers      map[string]int
+	window     time.Duration
+	metrics    *metrics.ProxyMetrics
+	log        logger.Logger
+
+	lastStoreError atomic.Int64 // unix nano последней записанной в лог ошибки хранилища
 }
 
-func NewRateLimiter(limitPerMinute int, log logger.Logger) *RateLimiter {
-	return &RateLimiter{
-		requests: make(map[string][]time.Time),
-		limit:    limitPerMinute,
-		window:   time.Minute,
-		log:      log,
+// NewRateLimiter создаёт лимитер; для хранилища в памяти запускает фоновую
+// очистку неактивных клиентов. m может быть nil
+func NewRateLimiter(opts Options, m *metrics.ProxyMetrics, log logger.Logger) (*RateLimiter, error) {
+	if opts.Window <= 0 {
+		opts.Window = time.Minute
+	}
+	if opts.Burst <= 0 {
+		opts.Burst = opts.Limit
+	}
+	if opts.IPv6Prefix == 0 {
+		opts.IPv6Prefix = 64
+	}
+	if opts.CleanupInterval <= 0 {
+		opts.CleanupInterval = time.Minute
 	}
-}
 
-func (rl *RateLimiter) Allow(identifier string) bool {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+	rl := &RateLimiter{
+		store:      opts.Store,
+		failClosed: opts.FailClosed,
+		failOpen:   make(map[string]bool, len(opts.FailOpenRoutes)),
+		limit:      opts.Limit,
+		burst:      opts.Burst,
+		window:     opts.Window,
+		metrics:    m,
+		log:        log,
+	}
 
-	now := time.Now()
-	
-	// Очищаем старые запросы вне временного окна
-	rl.cleanup(identifier, now)
-	
-	// Проверяем количество запросов
-	if len(rl.requests[identifier]) >= rl.limit {
-		return false
+	if opts.Limit > 0 {
+		key, _ := compileKey([]string{"identity"}, opts.IPv6Prefix)
+		rl.rules = append(rl.rules, &rule{
+			name:   defaultRuleName,
+			key:    key,
+			limit:  opts.Limit,
+			burst:  opts.Burst,
+			window: opts.Window,
+			tiered: true,
+		})
 	}
-	
-	// Добавляем текущий запрос
-	rl.requests[identifier] = append(rl.requests[identifier], now)
-	return true
+
+	seen := map[string]bool{defaultRuleName: opts.Limit > 0}
+	for i, rc := range opts.Rules {
+		if rc.Name == "" {
+			rc.Name = fmt.Sprintf("rule%d", i+1)
+		}
+		if seen[rc.Name] {
+			return nil, fmt.Errorf("duplicate rate limit rule %q", rc.Name)
+		}
+		seen[rc.Name] = true
+		if rc.Limit <= 0 {
+			return nil, fmt.Errorf("rate limit rule %s: limit must be positive", rc.Name)
+		}
+		key, err := compileKey(rc.Key, opts.IPv6Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit rule %s: %w", rc.Name, err)
+		}
+		r := &rule{
+			name:   rc.Name,
+			key:    key,
+			limit:  rc.Limit,
+			burst:  rc.Burst,
+			window: rc.Window,
+			routes: make(map[string]bool, len(rc.Routes)),
+		}
+		if r.window <= 0 {
+			r.window = time.Minute
+		}
+		if r.burst <= 0 {
+			r.burst = r.limit
+		}
+		for _, route := range rc.Routes {
+			r.routes[route] = true
+		}
+		rl.rules = append(rl.rules, r)
+	}
+
+	for _, route := range opts.FailOpenRoutes {
+		rl.failOpen[route] = true
+	}
+
+	if rl.store != nil {
+		return rl, nil
+	}
+
+	rl.memory = newGCRA(opts.MaxClients)
+	rl.store = rl.memory
+	if m != nil {
+		rl.memory.onEvict = m.RateLimitEvicted
+		m.TrackRateLimitClients(rl.Tracked)
+	}
+	go rl.janitor(opts.CleanupInterval)
+	return rl, nil
 }
 
-func (rl *RateLimiter) cleanup(identifier string, now time.Time) {
-	validRequests := []time.Time{}
-	
-	for _, t := range rl.requests[identifier] {
-		if now.Sub(t) <= rl.window {
-			validRequests = append(validRequests, t)
+// janitor удаляет клиентов, чей запас полностью восстановился
+func (rl *RateLimiter) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if n := rl.memory.sweep(time.Now()); n > 0 {
+			rl.log.Infof("🧹 Rate limiter evicted %d idle clients, %d tracked", n, rl.Tracked())
 		}
 	}
-	
-	rl.requests[identifier] = validRequests
 }
 
-func (rl *RateLimiter) GetRemaining(identifier string) int {
+// Tracked число клиентов, для которых хранится состояние в памяти
+func (rl *RateLimiter) Tracked() int {
+	if rl.memory == nil {
+		return 0
+	}
+	return rl.memory.len()
+}
+
+// Shared состояние лимитов общее для всех реплик
+func (rl *RateLimiter) Shared() bool {
+	return rl.memory == nil
+}
+
+// SetTiers задаёт лимиты за окно для tier аутентифицированных клиентов
+func (rl *RateLimiter) SetTiers(tiers map[string]int) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
-	
-	rl.cleanup(identifier, time.Now())
-	return rl.limit - len(rl.requests[identifier])
+	rl.tiers = tiers
+}
+
+// check правило, применимое к запросу, с запасом клиента
+type check struct {
+	rule *rule
+	Bucket
+}
+
+// checks правила, которые относятся к запросу. Правило пропускается, если запрос
+// вне его маршрутов или у ключа нет значения (например, нет заголовка)
+func (rl *RateLimiter) checks(r *http.Request) []check {
+	info := reqctx.FromRequest(r)
+	checks := make([]check, 0, len(rl.rules))
+	for _, rule := range rl.rules {
+		if len(rule.routes) > 0 && (info == nil || !rule.routes[info.Route]) {
+			continue
+		}
+		key, ok := buildKey(rule.name, rule.key, r, info)
+		if !ok {
+			continue
+		}
+		limit, burst := rl.limitFor(rule, info)
+		checks = append(checks, check{rule: rule, Bucket: Bucket{Key: key, Limit: limit, Burst: burst, Window: rule.window}})
+	}
+	return checks
+}
+
+// limitFor лимит правила для клиента: tier аутентифицированного клиента заменяет
+// лимит по умолчанию, а burst масштабируется в той же пропорции
+func (rl *RateLimiter) limitFor(rule *rule, info *reqctx.Info) (int, int) {
+	if !rule.tiered || info == nil || info.Principal == "" {
+		return rule.limit, rule.burst
+	}
+
+	rl.mu.RLock()
+	limit, ok := rl.tiers[info.RateLimitTier]
+	rl.mu.RUnlock()
+	if !ok || limit == rule.limit {
+		return rule.limit, rule.burst
+	}
+	return limit, max((limit*rule.burst+rule.limit-1)/rule.limit, 1)
+}
+
+// client значение ключа для логов
+func (c check) client() string {
+	return strings.ReplaceAll(c.Key[len(c.rule.name)+1:], "\x00", " ")
+}
+
+func (rl *RateLimiter) take(ctx context.Context, checks []check, n int) ([]Decision, error) {
+	buckets := make([]Bucket, len(checks))
+	for i, c := range checks {
+		buckets[i] = c.Bucket
+	}
+	return rl.store.Take(ctx, buckets, n)
+}
+
+// RuleStatus состояние правила для клиента
+type RuleStatus struct {
+	Rule      string `json:"rule"`
+	Limit     int    `json:"limit"`
+	Burst     int    `json:"burst"`
+	Window    string `json:"window"`
+	Remaining int    `json:"remaining"`
+}
+
+// Status остаток по каждому правилу, применимому к запросу, без расхода запаса
+func (rl *RateLimiter) Status(r *http.Request) ([]RuleStatus, error) {
+	checks := rl.checks(r)
+	decisions, err := rl.take(r.Context(), checks, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]RuleStatus, 0, len(checks))
+	for i, c := range checks {
+		statuses = append(statuses, RuleStatus{
+			Rule:      c.rule.name,
+			Limit:     c.Limit,
+			Burst:     c.Burst,
+			Window:    c.Window.String(),
+			Remaining: decisions[i].Remaining,
+		})
+	}
+	return statuses, nil
 }
 
 // Middleware возвращает HTTP middleware для ограничения запросов
 func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Используем IP адрес как идентификатор
-		identifier := getClientIP(r)
-		
-		if !rl.Allow(identifier) {
-			rl.log.Warnf("🚫 Rate limit exceeded for %s: %s %s", identifier, r.Method, r.URL.Path)
-			
-			w.Header().Set("Content-Type", "application/json")
-			w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", rl.limit))
-			w.Header().Set("X-RateLimit-Remaining", "0")
-			w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(time.Minute).Unix()))
-			
-			w.WriteHeader(http.StatusTooManyRequests)
-			w.Write([]byte(`{
-				"error": "rate_limit_exceeded",
-				"message": "Too many requests",
-				"limit": "` + fmt.Sprintf("%d", rl.limit) + ` per minute",
-				"retry_after": "60 seconds"
-			}`))
+		checks := rl.checks(r)
+		if len(checks) == 0 {
+			next.ServeHTTP(w, r)
 			return
 		}
-		
-		// Добавляем заголовки с информацией о лимите
-		remaining := rl.GetRemaining(identifier)
-		w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", rl.limit))
-		w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
-		
-		rl.log.Infof("📊 Rate limit: %s has %d/%d requests remaining", identifier, remaining, rl.limit)
+
+		decisions, err := rl.take(r.Context(), checks, 1)
+		if err != nil {
+			rl.storeFailed(w, r, next, err)
+			return
+		}
+
+		tightest := 0
+		for i, d := range decisions {
+			if !d.Allowed {
+				rl.deny(w, r, checks[i], d)
+				return
+			}
+			if d.Remaining < decisions[tightest].Remaining {
+				tightest = i
+			}
+		}
+
+		// Заголовки описывают правило с наименьшим остатком
+		c, d := checks[tightest], decisions[tightest]
+		rl.setHeaders(w, c.Limit, d)
+		rl.log.Infof("📊 Rate limit: %s has %d/%d requests remaining (%s)",
+			c.client(), d.Remaining, c.Limit, c.rule.name)
 		next.ServeHTTP(w, r)
 	})
 }
 
+// storeFailed пропускает или отклоняет запрос, когда хранилище недоступно
+func (rl *RateLimiter) storeFailed(w http.ResponseWriter, r *http.Request, next http.Handler, err error) {
+	if rl.metrics != nil {
+		rl.metrics.RateLimitStoreFailed()
+	}
+
+	// Пока хранилище недоступно, ошибка пишется в лог не чаще раза в 10 секунд
+	now := time.Now().UnixNano()
+	if last := rl.lastStoreError.Load(); now-last > int64(10*time.Second) && rl.lastStoreError.CompareAndSwap(last, now) {
+		if rl.failClosed {
+			rl.log.Errorf("❌ Rate limit store is unavailable, requests are denied: %v", err)
+		} else {
+			rl.log.Warnf("⚠️ Rate limit store is unavailable, requests are allowed: %v", err)
+		}
+	}
+
+	if info := reqctx.FromRequest(r); !rl.failClosed || (info != nil && rl.failOpen[info.Route]) {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	reqctx.Deny(r, "rate_limit_unavailable")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", "1")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	w.Write([]byte(`{
+				"error": "rate_limit_unavailable",
+				"message": "Rate limiting is temporarily unavailable"
+			}`))
+}
+
+func (rl *RateLimiter) setHeaders(w http.ResponseWriter, limit int, d Decision) {
+	w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", limit))
+	w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", d.Remaining))
+	w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(d.Reset).Unix()))
+}
+
+func (rl *RateLimiter) deny(w http.ResponseWriter, r *http.Request, c check, d Decision) {
+	retryAfter := int(math.Ceil(d.RetryAfter.Seconds()))
+	rl.log.Warnf("🚫 Rate limit %s exceeded for %s: %s %s",
+		c.rule.name, c.client(), r.Method, r.URL.Path)
+	reqctx.Deny(r, "rate_limit")
+	
+	rl.setHeaders(w, c.Limit, d)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", retryAfter))
+	
+	w.WriteHeader(http.StatusTooManyRequests)
+	w.Write([]byte(`{
+				"error": "rate_limit_exceeded",
+				"message": "Too many requests",
+				"rule": "` + c.rule.name + `",
+				"limit": "` + fmt.Sprintf("%d per %s", c.Limit, windowName(c.Window)) + `",
+				"retry_after": "` + fmt.Sprintf("%d", retryAfter) + ` seconds"
+			}`))
+}
+
 // Вспомогательная функция для получения IP клиента
 func getClientIP(r *http.Request) string {
 	// Пробуем получить IP из X-Forwarded-For (если за прокси)
@@ -112,4 +391,26 @@ func getClientIP(r *http.Request) string {
 
 func (rl *RateLimiter) GetLimit() int {
 	return rl.limit
+}
+
+// GetWindow окно, за которое действует лимит
+func (rl *RateLimiter) GetWindow() time.Duration {
+	return rl.window
+}
+
+// GetBurst сколько запросов можно сделать подряд
+func (rl *RateLimiter) GetBurst() int {
+	return rl.burst
+}
+
+func windowName(window time.Duration) string {
+	switch window {
+	case time.Second:
+		return "second"
+	case time.Minute:
+		return "minute"
+	case time.Hour:
+		return "hour"
+	}
+	return window.String()
 }
\ No newline at end of file
@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"access-proxy/internal/reqctx"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+func newTestLimiter(t *testing.T, opts Options) *RateLimiter {
+	t.Helper()
+	rl, err := NewRateLimiter(opts, nil, logger.New("test", logger.LevelInfo, logger.ModeDev))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rl
+}
+
+// serve пропускает запрос через лимитер от имени клиента
+func serve(rl *RateLimiter, ip, principal, tier string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/api", nil)
+	info := &reqctx.Info{ClientIP: ip, Route: "api", Principal: principal, AuthMethod: "api_key", RateLimitTier: tier}
+	r = r.WithContext(reqctx.NewContext(r.Context(), info))
+	rec := httptest.NewRecorder()
+	rl.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(rec, r)
+	return rec
+}
+
+func TestTiers(t *testing.T) {
+	rl := newTestLimiter(t, Options{Limit: 2, Window: time.Minute})
+	rl.SetTiers(map[string]int{"gold": 4, "blocked": 0})
+
+	for i := 0; i < 4; i++ {
+		if rec := serve(rl, "10.0.0.1", "partner", "gold"); rec.Code != http.StatusOK {
+			t.Fatalf("gold request %d: %d", i+1, rec.Code)
+		}
+	}
+	if rec := serve(rl, "10.0.0.1", "partner", "gold"); rec.Code != http.StatusTooManyRequests || rec.Header().Get("X-RateLimit-Limit") != "4" {
+		t.Errorf("gold over limit: %d %v", rec.Code, rec.Header())
+	}
+
+	// Лимит 0 запрещает все запросы tier, как и до перехода на GCRA
+	rec := serve(rl, "10.0.0.2", "spammer", "blocked")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "60" {
+		t.Errorf("blocked tier: %d %v", rec.Code, rec.Header())
+	}
+
+	// Неизвестный tier получает лимит по умолчанию
+	serve(rl, "10.0.0.3", "other", "silver")
+	serve(rl, "10.0.0.3", "other", "silver")
+	if rec := serve(rl, "10.0.0.3", "other", "silver"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("unknown tier: %d", rec.Code)
+	}
+}
+
+func TestRules(t *testing.T) {
+	rl := newTestLimiter(t, Options{
+		Limit: 100,
+		Rules: []Rule{
+			{Name: "tenant", Key: []string{"header:X-Tenant"}, Limit: 2, Window: time.Minute},
+			{Name: "admin", Key: []string{"ip"}, Limit: 1, Window: time.Hour, Routes: []string{"admin"}},
+		},
+	})
+
+	request := func(route, tenant string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tenant != "" {
+			r.Header.Set("X-Tenant", tenant)
+		}
+		r = r.WithContext(reqctx.NewContext(r.Context(), &reqctx.Info{ClientIP: "10.0.0.1", Route: route}))
+		rec := httptest.NewRecorder()
+		rl.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(rec, r)
+		return rec
+	}
+
+	// Без заголовка правило tenant не применяется
+	for i := 0; i < 5; i++ {
+		if rec := request("api", ""); rec.Code != http.StatusOK {
+			t.Fatalf("request without tenant: %d", rec.Code)
+		}
+	}
+
+	if rec := request("api", "acme"); rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Limit") != "2" {
+		t.Errorf("headers must describe the tightest rule: %v", rec.Header())
+	}
+	request("api", "acme")
+	rec := request("api", "acme")
+	if rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), `"rule": "tenant"`) {
+		t.Errorf("tenant over limit: %d %s", rec.Code, rec.Body)
+	}
+	if rec := request("api", "globex"); rec.Code != http.StatusOK {
+		t.Errorf("other tenant limited: %d", rec.Code)
+	}
+
+	// Правило маршрута admin; отказ по нему не расходует запас tenant
+	if rec := request("admin", "initech"); rec.Code != http.StatusOK {
+		t.Fatalf("admin: %d", rec.Code)
+	}
+	if rec := request("admin", "initech"); rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "3600" {
+		t.Errorf("admin over limit: %d %v", rec.Code, rec.Header())
+	}
+	if rec := request("api", "initech"); rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("denied request consumed tenant quota: %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestStatus(t *testing.T) {
+	rl := newTestLimiter(t, Options{
+		Limit: 5,
+		Rules: []Rule{{Name: "per_route", Key: []string{"route"}, Limit: 10, Burst: 3, Window: time.Second}},
+	})
+	serve(rl, "10.0.0.1", "", "")
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(reqctx.NewContext(r.Context(), &reqctx.Info{ClientIP: "10.0.0.1", Route: "api"}))
+	for i := 0; i < 2; i++ {
+		statuses, err := rl.Status(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(statuses) != 2 || statuses[0].Rule != defaultRuleName || statuses[0].Remaining != 4 ||
+			statuses[1].Rule != "per_route" || statuses[1].Burst != 3 || statuses[1].Remaining != 2 || statuses[1].Window != "1s" {
+			t.Fatalf("status %d: %+v", i, statuses)
+		}
+	}
+}
+
+func TestRuleErrors(t *testing.T) {
+	tests := map[string]Options{
+		"duplicate": {Rules: []Rule{{Name: "a", Key: []string{"ip"}, Limit: 1}, {Name: "a", Key: []string{"ip"}, Limit: 1}}},
+		"default":   {Limit: 1, Rules: []Rule{{Name: defaultRuleName, Key: []string{"ip"}, Limit: 1}}},
+		"limit":     {Rules: []Rule{{Name: "a", Key: []string{"ip"}}}},
+		"key":       {Rules: []Rule{{Name: "a", Key: []string{"cookie:x"}, Limit: 1}}},
+	}
+	for name, opts := range tests {
+		opts.Store = newGCRA(0)
+		if _, err := NewRateLimiter(opts, nil, nil); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
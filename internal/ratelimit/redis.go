@@ -0,0 +1,152 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisOptions подключение к Redis, общему для всех реплик прокси
+type RedisOptions struct {
+	Addr      string
+	Username  string
+	Password  string
+	DB        int
+	KeyPrefix string
+	Timeout   time.Duration // на одну проверку, по умолчанию 100ms
+}
+
+// gcraScript тот же GCRA, что и в памяти, но для всех ключей запроса сразу:
+// запас расходуется, только если его хватает во всех. Время берётся у Redis,
+// чтобы часы реплик не влияли на лимит. Значения в микросекундах.
+//
+// KEYS — ключи, ARGV[1] — n, затем интервал и допуск для каждого ключа.
+// Ответ — по четыре числа на ключ: allowed, remaining, retry_after, reset
+var gcraScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local n = tonumber(ARGV[1])
+
+local tats, denied = {}, false
+for i, key in ipairs(KEYS) do
+	local tat = tonumber(redis.call('GET', key)) or now
+	if tat < now then tat = now end
+	tats[i] = tat
+	if tat + tonumber(ARGV[i * 2]) * n - now > tonumber(ARGV[i * 2 + 1]) then
+		denied = true
+	end
+end
+
+local result = {}
+for i, key in ipairs(KEYS) do
+	local interval, tolerance, tat = tonumber(ARGV[i * 2]), tonumber(ARGV[i * 2 + 1]), tats[i]
+	local newTat = tat + interval * n
+	if newTat - now > tolerance then
+		table.insert(result, 0)
+		table.insert(result, math.floor((tolerance - (tat - now)) / interval))
+		table.insert(result, newTat - tolerance - now)
+		table.insert(result, tat - now)
+	elseif denied or n == 0 then
+		table.insert(result, 1)
+		table.insert(result, math.floor((tolerance - (tat - now)) / interval))
+		table.insert(result, 0)
+		table.insert(result, tat - now)
+	else
+		redis.call('SET', key, string.format('%d', newTat), 'PX', math.ceil((newTat - now) / 1000))
+		table.insert(result, 1)
+		table.insert(result, math.floor((tolerance - (newTat - now)) / interval))
+		table.insert(result, 0)
+		table.insert(result, newTat - now)
+	end
+end
+return result
+`)
+
+// RedisStore хранит состояние лимитов в Redis, поэтому все реплики делят один лимит
+type RedisStore struct {
+	client  *redis.Client
+	prefix  string
+	timeout time.Duration
+}
+
+func NewRedisStore(opts RedisOptions) *RedisStore {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 100 * time.Millisecond
+	}
+	return &RedisStore{
+		client: redis.NewClient(&redis.Options{
+			Addr:         opts.Addr,
+			Username:     opts.Username,
+			Password:     opts.Password,
+			DB:           opts.DB,
+			DialTimeout:  opts.Timeout,
+			ReadTimeout:  opts.Timeout,
+			WriteTimeout: opts.Timeout,
+		}),
+		prefix:  opts.KeyPrefix,
+		timeout: opts.Timeout,
+	}
+}
+
+// Ping проверяет, что Redis доступен
+func (s *RedisStore) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.client.Ping(ctx).Err()
+}
+
+// Take реализует Store одним вызовом скрипта
+func (s *RedisStore) Take(ctx context.Context, buckets []Bucket, n int) ([]Decision, error) {
+	if len(buckets) == 0 {
+		return nil, nil
+	}
+
+	// Запасы с лимитом 0 отклоняют запрос без обращения к Redis;
+	// остальные тогда только проверяются, без расхода
+	decisions := make([]Decision, len(buckets))
+	scripted := make([]int, 0, len(buckets))
+	for i, b := range buckets {
+		if b.Limit <= 0 {
+			decisions[i] = denyAll(b)
+			n = 0
+			continue
+		}
+		scripted = append(scripted, i)
+	}
+	if len(scripted) == 0 {
+		return decisions, nil
+	}
+
+	keys := make([]string, len(scripted))
+	args := make([]interface{}, 0, 1+2*len(scripted))
+	args = append(args, n)
+	for i, j := range scripted {
+		b := buckets[j]
+		interval := max(b.Window.Microseconds()/int64(b.Limit), 1)
+		keys[i] = s.prefix + b.Key
+		args = append(args, interval, interval*int64(b.Burst))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	values, err := gcraScript.Run(ctx, s.client, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	if len(values) != 4*len(scripted) {
+		return nil, fmt.Errorf("redis: unexpected script reply of %d values", len(values))
+	}
+
+	for i, j := range scripted {
+		v := values[4*i : 4*i+4]
+		decisions[j] = Decision{
+			Allowed:    v[0] == 1,
+			Remaining:  int(v[1]),
+			RetryAfter: time.Duration(v[2]) * time.Microsecond,
+			Reset:      time.Duration(v[3]) * time.Microsecond,
+		}
+	}
+	return decisions, nil
+}
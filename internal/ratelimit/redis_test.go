@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"access-proxy/internal/reqctx"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+// newTestRedis хранилище в Redis из REDIS_ADDR; без него тест пропускается
+func newTestRedis(t *testing.T) *RedisStore {
+	t.Helper()
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	store := NewRedisStore(RedisOptions{
+		Addr:      addr,
+		KeyPrefix: "access-proxy-test:" + strconv.FormatInt(time.Now().UnixNano(), 36) + ":",
+		Timeout:   time.Second,
+	})
+	if err := store.Ping(context.Background()); err != nil {
+		t.Fatalf("redis at %s: %v", addr, err)
+	}
+	return store
+}
+
+func TestRedisStoreBurst(t *testing.T) {
+	store := newTestRedis(t)
+	ctx := context.Background()
+	b := []Bucket{{Key: "client", Limit: 10, Window: 10 * time.Second, Burst: 3}}
+
+	for i := 1; i <= 3; i++ {
+		d, err := store.Take(ctx, b, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !d[0].Allowed || d[0].Remaining != 3-i {
+			t.Fatalf("request %d: %+v", i, d[0])
+		}
+	}
+
+	d, err := store.Take(ctx, b, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d[0].Allowed || d[0].Remaining != 0 || d[0].RetryAfter <= 0 || d[0].RetryAfter > time.Second {
+		t.Errorf("over burst: %+v", d[0])
+	}
+
+	if d, _ := store.Take(ctx, b, 0); d[0].Remaining != 0 {
+		t.Errorf("peek: %+v", d[0])
+	}
+}
+
+func TestRedisStoreMultipleKeys(t *testing.T) {
+	store := newTestRedis(t)
+	ctx := context.Background()
+	global := Bucket{Key: "global", Limit: 100, Window: time.Hour, Burst: 100}
+	client := Bucket{Key: "client", Limit: 1, Window: time.Hour, Burst: 1}
+
+	d, err := store.Take(ctx, []Bucket{global, client}, 1)
+	if err != nil || !d[0].Allowed || !d[1].Allowed || d[0].Remaining != 99 {
+		t.Fatalf("first: %+v %v", d, err)
+	}
+
+	// Отказ по ключу клиента не расходует общий запас
+	d, err = store.Take(ctx, []Bucket{global, client}, 1)
+	if err != nil || !d[0].Allowed || d[1].Allowed || d[0].Remaining != 99 {
+		t.Fatalf("second: %+v %v", d, err)
+	}
+
+	// Нулевой лимит отклоняет запрос, не обращаясь к остальным запасам
+	blocked := Bucket{Key: "blocked", Limit: 0, Window: time.Minute}
+	d, err = store.Take(ctx, []Bucket{global, blocked}, 1)
+	if err != nil || !d[0].Allowed || d[1].Allowed || d[1].RetryAfter != time.Minute {
+		t.Fatalf("blocked: %+v %v", d, err)
+	}
+	if d, _ := store.Take(ctx, []Bucket{global}, 0); d[0].Remaining != 99 {
+		t.Errorf("global consumed by denied requests: %+v", d[0])
+	}
+}
+
+func TestRedisStoreSharedBetweenLimiters(t *testing.T) {
+	store := newTestRedis(t)
+	a := newTestLimiter(t, Options{Limit: 2, Window: time.Hour, Store: store})
+	b := newTestLimiter(t, Options{Limit: 2, Window: time.Hour, Store: store})
+
+	serve(a, "10.0.0.1", "", "")
+	serve(b, "10.0.0.1", "", "")
+	if rec := serve(a, "10.0.0.1", "", ""); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("replicas do not share the limit: %d", rec.Code)
+	}
+}
+
+// unreachableStore хранилище, которое всегда недоступно
+func unreachableStore() *RedisStore {
+	return NewRedisStore(RedisOptions{Addr: "127.0.0.1:1", Timeout: 50 * time.Millisecond})
+}
+
+func TestStoreFailure(t *testing.T) {
+	request := func(rl *RateLimiter, route string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r = r.WithContext(reqctx.NewContext(r.Context(), &reqctx.Info{ClientIP: "10.0.0.1", Route: route}))
+		rec := httptest.NewRecorder()
+		rl.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(rec, r)
+		return rec
+	}
+	log := logger.New("test", logger.LevelInfo, logger.ModeDev)
+
+	open, err := NewRateLimiter(Options{Limit: 1, Store: unreachableStore()}, nil, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if rec := request(open, "api"); rec.Code != http.StatusOK {
+			t.Errorf("on_error allow: got %d", rec.Code)
+		}
+	}
+
+	closed, err := NewRateLimiter(Options{
+		Limit:          1,
+		Store:          unreachableStore(),
+		FailClosed:     true,
+		FailOpenRoutes: []string{"health"},
+	}, nil, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := request(closed, "api")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("on_error deny: got %d %v", rec.Code, rec.Header())
+	}
+	if rec := request(closed, "health"); rec.Code != http.StatusOK {
+		t.Errorf("fail-open route: got %d", rec.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if _, err := closed.Status(r); err == nil {
+		t.Error("status hides store error")
+	}
+}
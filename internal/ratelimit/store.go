@@ -0,0 +1,36 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Bucket запас запросов клиента: limit запросов за window, до burst подряд
+type Bucket struct {
+	Key    string
+	Limit  int
+	Burst  int
+	Window time.Duration
+}
+
+// Decision результат проверки лимита
+type Decision struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration // когда можно повторить отклонённый запрос
+	Reset      time.Duration // когда запас восстановится полностью
+}
+
+// denyAll решение для запаса с лимитом 0 и меньше: такой запас (например, tier
+// с лимитом 0) отклоняет любой запрос
+func denyAll(b Bucket) Decision {
+	return Decision{RetryAfter: b.Window}
+}
+
+// Store хранилище состояния лимитов
+type Store interface {
+	// Take расходует n запросов из каждого запаса, только если все они это позволяют;
+	// иначе ничего не расходуется, а отказавший запас отмечен Allowed == false.
+	// n == 0 — только узнать остаток
+	Take(ctx context.Context, buckets []Bucket, n int) ([]Decision, error)
+}
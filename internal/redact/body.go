@@ -0,0 +1,238 @@
+// internal/redact/body.go
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Body скрывает чувствительные данные в теле запроса или ответа.
+// JSON разбирается и чистится по ключам и JSONPath, формы — по параметрам,
+// остальное (и JSON, который не удалось разобрать) — регулярными выражениями
+func (r *Redactor) Body(contentType string, body []byte) []byte {
+	if r == nil || len(body) == 0 {
+		return body
+	}
+
+	ct := strings.ToLower(contentType)
+	switch {
+	case strings.Contains(ct, "application/x-www-form-urlencoded"):
+		return []byte(r.Query(string(body)))
+	case strings.Contains(ct, "json") || looksLikeJSON(body):
+		if redacted, ok := r.jsonBody(body); ok {
+			return redacted
+		}
+	}
+
+	if r.keyPattern != nil {
+		body = r.keyPattern.ReplaceAll(body, []byte(`${1}"`+strings.ReplaceAll(r.mask, "$", "$$")+`"`))
+	}
+	return []byte(r.String(string(body)))
+}
+
+func (r *Redactor) jsonBody(body []byte) ([]byte, bool) {
+	if len(r.jsonKeys) == 0 && len(r.jsonPaths) == 0 && len(r.patterns) == 0 {
+		return body, true
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil || dec.More() {
+		return nil, false
+	}
+
+	if len(r.jsonKeys) > 0 {
+		doc = r.redactKeys(doc)
+	}
+	for _, p := range r.jsonPaths {
+		doc = p.redact(doc, r.mask)
+	}
+
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return nil, false
+	}
+	return []byte(r.String(strings.TrimSuffix(out.String(), "\n"))), true
+}
+
+// redactKeys скрывает значения ключей из списка на любой глубине
+func (r *Redactor) redactKeys(node interface{}) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			if r.jsonKeys[strings.ToLower(k)] {
+				n[k] = r.mask
+			} else {
+				n[k] = r.redactKeys(v)
+			}
+		}
+	case []interface{}:
+		for i, v := range n {
+			n[i] = r.redactKeys(v)
+		}
+	}
+	return node
+}
+
+func looksLikeJSON(body []byte) bool {
+	trimmed := bytes.TrimSpace(body)
+	return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+}
+
+type segmentKind int
+
+const (
+	segKey segmentKind = iota
+	segIndex
+	segWildcard
+	segRecursive
+)
+
+type segment struct {
+	kind  segmentKind
+	key   string
+	index int
+}
+
+// jsonPath подмножество JSONPath: $.a.b, $.a[0], $.a[*].b, $['a'], $..key
+type jsonPath struct {
+	segments []segment
+}
+
+func parseJSONPath(expr string) (jsonPath, error) {
+	var p jsonPath
+	if !strings.HasPrefix(expr, "$") {
+		return p, fmt.Errorf("must start with '$'")
+	}
+
+	i := 1
+	for i < len(expr) {
+		switch {
+		case strings.HasPrefix(expr[i:], ".."):
+			name, n := readName(expr[i+2:])
+			if name == "" {
+				return p, fmt.Errorf("expected key after '..' at position %d", i)
+			}
+			p.segments = append(p.segments, segment{kind: segRecursive, key: name})
+			i += 2 + n
+		case expr[i] == '.':
+			name, n := readName(expr[i+1:])
+			switch name {
+			case "":
+				return p, fmt.Errorf("expected key after '.' at position %d", i)
+			case "*":
+				p.segments = append(p.segments, segment{kind: segWildcard})
+			default:
+				p.segments = append(p.segments, segment{kind: segKey, key: name})
+			}
+			i += 1 + n
+		case expr[i] == '[':
+			end := strings.IndexByte(expr[i:], ']')
+			if end < 0 {
+				return p, fmt.Errorf("unclosed '[' at position %d", i)
+			}
+			inner := strings.TrimSpace(expr[i+1 : i+end])
+			seg, err := parseBracket(inner)
+			if err != nil {
+				return p, fmt.Errorf("%v at position %d", err, i)
+			}
+			p.segments = append(p.segments, seg)
+			i += end + 1
+		default:
+			return p, fmt.Errorf("unexpected %q at position %d", expr[i], i)
+		}
+	}
+
+	if len(p.segments) == 0 {
+		return p, fmt.Errorf("path selects the whole document")
+	}
+	return p, nil
+}
+
+func readName(s string) (string, int) {
+	n := 0
+	for n < len(s) && s[n] != '.' && s[n] != '[' {
+		n++
+	}
+	return s[:n], n
+}
+
+func parseBracket(inner string) (segment, error) {
+	if inner == "*" {
+		return segment{kind: segWildcard}, nil
+	}
+	if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+		return segment{kind: segKey, key: inner[1 : len(inner)-1]}, nil
+	}
+	idx, err := strconv.Atoi(inner)
+	if err != nil || idx < 0 {
+		return segment{}, fmt.Errorf("invalid index %q", inner)
+	}
+	return segment{kind: segIndex, index: idx}, nil
+}
+
+// lastKey имя последнего ключа пути, если путь заканчивается ключом
+func (p jsonPath) lastKey() string {
+	last := p.segments[len(p.segments)-1]
+	if last.kind == segKey || last.kind == segRecursive {
+		return last.key
+	}
+	return ""
+}
+
+func (p jsonPath) redact(doc interface{}, mask string) interface{} {
+	return redactSegments(doc, p.segments, mask)
+}
+
+func redactSegments(node interface{}, segs []segment, mask string) interface{} {
+	if len(segs) == 0 {
+		return mask
+	}
+	seg, rest := segs[0], segs[1:]
+
+	switch seg.kind {
+	case segKey:
+		if m, ok := node.(map[string]interface{}); ok {
+			if v, ok := m[seg.key]; ok {
+				m[seg.key] = redactSegments(v, rest, mask)
+			}
+		}
+	case segIndex:
+		if a, ok := node.([]interface{}); ok && seg.index < len(a) {
+			a[seg.index] = redactSegments(a[seg.index], rest, mask)
+		}
+	case segWildcard:
+		switch n := node.(type) {
+		case map[string]interface{}:
+			for k, v := range n {
+				n[k] = redactSegments(v, rest, mask)
+			}
+		case []interface{}:
+			for i, v := range n {
+				n[i] = redactSegments(v, rest, mask)
+			}
+		}
+	case segRecursive:
+		switch n := node.(type) {
+		case map[string]interface{}:
+			for k, v := range n {
+				if k == seg.key {
+					n[k] = redactSegments(v, rest, mask)
+				} else {
+					n[k] = redactSegments(v, segs, mask)
+				}
+			}
+		case []interface{}:
+			for i, v := range n {
+				n[i] = redactSegments(v, segs, mask)
+			}
+		}
+	}
+	return node
+}
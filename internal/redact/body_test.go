@@ -0,0 +1,46 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBodyJSON(t *testing.T) {
+	r := mustNew(t, Rules{JSONFields: []string{"password", "$.card.number", "$..token", "$.items[*].secret", "$.list[1]"}})
+
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"user":"bob","Password":"p"}`, `{"Password":"[REDACTED]","user":"bob"}`},
+		{`{"card":{"number":"4111","exp":"12/30"}}`, `{"card":{"exp":"12/30","number":"[REDACTED]"}}`},
+		{`{"a":{"b":[{"token":"t"}]},"token":"u"}`, `{"a":{"b":[{"token":"[REDACTED]"}]},"token":"[REDACTED]"}`},
+		{`{"items":[{"secret":1,"id":2},{"secret":3}]}`, `{"items":[{"id":2,"secret":"[REDACTED]"},{"secret":"[REDACTED]"}]}`},
+		{`{"list":[1,2,3]}`, `{"list":[1,"[REDACTED]",3]}`},
+		{`{"big":12345678901234567890}`, `{"big":12345678901234567890}`},
+	}
+	for _, tt := range tests {
+		if got := string(r.Body("application/json", []byte(tt.body))); got != tt.want {
+			t.Errorf("Body(%s)\n got %s\nwant %s", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestBodyTruncatedJSONFallsBackToKeys(t *testing.T) {
+	r := mustNew(t, Rules{JSONFields: []string{"password", "$.card.number"}})
+	got := string(r.Body("application/json", []byte(`{"password":"hunter2","card":{"number":41111111`)))
+	if strings.Contains(got, "hunter2") || strings.Contains(got, "4111") {
+		t.Errorf("truncated body leaked secrets: %s", got)
+	}
+}
+
+func TestBodyFormAndText(t *testing.T) {
+	r := mustNew(t, Rules{QueryParams: []string{"pin"}, Patterns: []string{`secret-\w+`}})
+
+	if got := string(r.Body("application/x-www-form-urlencoded", []byte("pin=1234&name=a"))); got != "pin=[REDACTED]&name=a" {
+		t.Errorf("form body = %q", got)
+	}
+	if got := string(r.Body("text/plain", []byte("key secret-abc here"))); got != "key [REDACTED] here" {
+		t.Errorf("text body = %q", got)
+	}
+}
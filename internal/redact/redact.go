@@ -0,0 +1,209 @@
+// internal/redact/redact.go
+package redact
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DefaultMask подставляется вместо скрытых значений
+const DefaultMask = "[REDACTED]"
+
+// DefaultHeaders заголовки, которые скрываются, если список не задан в конфиге
+var DefaultHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Admin-Token"}
+
+// Rules правила скрытия чувствительных данных
+type Rules struct {
+	Headers     []string // имена заголовков, без учёта регистра
+	QueryParams []string // имена параметров запроса, без учёта регистра
+	JSONFields  []string // ключи ("password") или JSONPath ("$.card.number", "$..token")
+	Patterns    []string // регулярные выражения, совпадения заменяются маской
+	Mask        string
+}
+
+// Redactor применяет одни и те же правила ко всему, что попадает в логи
+type Redactor struct {
+	mask        string
+	headers     map[string]bool
+	queryParams map[string]bool
+	jsonKeys    map[string]bool
+	jsonPaths   []jsonPath
+	keyPattern  *regexp.Regexp
+	patterns    []*regexp.Regexp
+}
+
+// New компилирует правила. Ошибки указывают на конкретное правило
+func New(rules Rules) (*Redactor, error) {
+	r := &Redactor{
+		mask:        rules.Mask,
+		headers:     make(map[string]bool),
+		queryParams: make(map[string]bool),
+		jsonKeys:    make(map[string]bool),
+	}
+	if r.mask == "" {
+		r.mask = DefaultMask
+	}
+
+	headers := rules.Headers
+	if len(headers) == 0 {
+		headers = DefaultHeaders
+	}
+	for _, h := range headers {
+		r.headers[http.CanonicalHeaderKey(h)] = true
+	}
+
+	for _, q := range rules.QueryParams {
+		r.queryParams[strings.ToLower(q)] = true
+	}
+
+	var keys []string
+	for _, field := range rules.JSONFields {
+		if !strings.HasPrefix(field, "$") {
+			r.jsonKeys[strings.ToLower(field)] = true
+			keys = append(keys, regexp.QuoteMeta(field))
+			continue
+		}
+
+		path, err := parseJSONPath(field)
+		if err != nil {
+			return nil, fmt.Errorf("redaction json field %q: %w", field, err)
+		}
+		r.jsonPaths = append(r.jsonPaths, path)
+		if last := path.lastKey(); last != "" {
+			keys = append(keys, regexp.QuoteMeta(last))
+		}
+	}
+
+	// Для тел, которые не удалось разобрать как JSON (например, обрезанных),
+	// скрываем значения по имени ключа регулярным выражением
+	if len(keys) > 0 {
+		r.keyPattern = regexp.MustCompile(`(?i)("(?:` + strings.Join(keys, "|") + `)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`)
+	}
+
+	for _, p := range rules.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("redaction pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+
+	return r, nil
+}
+
+// String применяет регулярные маски к строке
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, r.mask)
+	}
+	return s
+}
+
+// Header возвращает копию заголовков со скрытыми значениями
+func (r *Redactor) Header(h http.Header) http.Header {
+	if r == nil {
+		return h
+	}
+
+	out := make(http.Header, len(h))
+	for name, values := range h {
+		redacted := make([]string, len(values))
+		for i, v := range values {
+			if r.headers[http.CanonicalHeaderKey(name)] {
+				redacted[i] = r.mask
+			} else {
+				redacted[i] = r.String(v)
+			}
+		}
+		out[name] = redacted
+	}
+	return out
+}
+
+// Query скрывает значения параметров в сырой строке запроса
+func (r *Redactor) Query(rawQuery string) string {
+	if r == nil || rawQuery == "" {
+		return rawQuery
+	}
+
+	parts := strings.Split(rawQuery, "&")
+	for i, part := range parts {
+		name, _, hasValue := strings.Cut(part, "=")
+		key, err := url.QueryUnescape(name)
+		if err != nil {
+			key = name
+		}
+		if hasValue && r.queryParams[strings.ToLower(key)] {
+			parts[i] = name + "=" + r.mask
+		}
+	}
+	return r.String(strings.Join(parts, "&"))
+}
+
+// URL возвращает строку URL со скрытыми параметрами запроса
+func (r *Redactor) URL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	if r == nil {
+		return u.String()
+	}
+
+	copied := *u
+	copied.RawQuery = r.Query(u.RawQuery)
+	copied.User = nil
+	return r.String(copied.String())
+}
+
+// URLString то же, что URL, для ещё не разобранной строки (например, Referer)
+func (r *Redactor) URLString(raw string) string {
+	if r == nil || raw == "" {
+		return raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return r.String(raw)
+	}
+	return r.URL(u)
+}
+
+// Masked сообщает, скрыл ли redactor что-то в redacted по сравнению с original.
+// Побайтное сравнение не подходит: JSON при разборе переупорядочивается
+func (r *Redactor) Masked(original, redacted []byte) bool {
+	if r == nil {
+		return false
+	}
+	mask := []byte(r.mask)
+	return bytes.Count(redacted, mask) > bytes.Count(original, mask)
+}
+
+// Error возвращает текст ошибки со скрытым URL. *url.Error от транспорта
+// содержит полный адрес апстрима вместе со строкой запроса
+func (r *Redactor) Error(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return r.String(err.Error())
+	}
+
+	redacted := *urlErr
+	redacted.URL = r.URLString(urlErr.URL)
+	msg := redacted.Error()
+	if urlErr != err {
+		// Ошибка обёрнута: заменяем только её часть с исходным URL
+		msg = strings.Replace(err.Error(), urlErr.Error(), msg, 1)
+	}
+	return r.String(msg)
+}
@@ -0,0 +1,128 @@
+// internal/replay/diff.go
+package replay
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"access-proxy/internal/capture"
+)
+
+// maxBodyDiffs ограничивает число различий в отчёте для одного ответа
+const maxBodyDiffs = 10
+
+// compareBodies сравнивает повторный ответ с записанным. JSON сравнивается по
+// структуре без учёта порядка ключей и игнорируемых полей, остальное — побайтно
+func compareBodies(result *Result, entry *capture.Entry, body []byte, contentType string, ignore []string) {
+	content := entry.Response.Content
+	if content.Comment != "" {
+		result.BodySkipped = "recorded body " + content.Comment
+		return
+	}
+	if strings.Contains(content.Text, "[REDACTED]") {
+		result.BodySkipped = "recorded body is redacted"
+		return
+	}
+
+	recorded := []byte(content.Text)
+	if content.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(content.Text)
+		if err != nil {
+			result.BodySkipped = "recorded body is not valid base64"
+			return
+		}
+		recorded = decoded
+	}
+
+	if strings.Contains(contentType, "json") || strings.Contains(content.MimeType, "json") {
+		var want, got interface{}
+		if json.Unmarshal(recorded, &want) == nil && json.Unmarshal(body, &got) == nil {
+			ignored := make(map[string]bool, len(ignore))
+			for _, f := range ignore {
+				ignored[f] = true
+			}
+			diffJSON("$", want, got, ignored, &result.BodyDiffs)
+			result.BodyMatch = len(result.BodyDiffs) == 0
+			return
+		}
+	}
+
+	result.BodyMatch = bytes.Equal(recorded, body)
+	if !result.BodyMatch {
+		result.BodyDiffs = []string{bytesDiff(recorded, body)}
+	}
+}
+
+func diffJSON(path string, want, got interface{}, ignored map[string]bool, diffs *[]string) {
+	if len(*diffs) >= maxBodyDiffs {
+		return
+	}
+
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			*diffs = append(*diffs, fmt.Sprintf("%s: type changed", path))
+			return
+		}
+		keys := make(map[string]bool)
+		for k := range w {
+			keys[k] = true
+		}
+		for k := range g {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			if ignored[k] {
+				continue
+			}
+			wv, inWant := w[k]
+			gv, inGot := g[k]
+			switch {
+			case !inGot:
+				*diffs = append(*diffs, fmt.Sprintf("%s.%s: missing", path, k))
+			case !inWant:
+				*diffs = append(*diffs, fmt.Sprintf("%s.%s: unexpected", path, k))
+			default:
+				diffJSON(path+"."+k, wv, gv, ignored, diffs)
+			}
+		}
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok {
+			*diffs = append(*diffs, fmt.Sprintf("%s: type changed", path))
+			return
+		}
+		if len(w) != len(g) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: length %d, recorded %d", path, len(g), len(w)))
+			return
+		}
+		for i := range w {
+			diffJSON(fmt.Sprintf("%s[%d]", path, i), w[i], g[i], ignored, diffs)
+		}
+	default:
+		if !reflect.DeepEqual(want, got) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: got %v, recorded %v", path, got, want))
+		}
+	}
+}
+
+func bytesDiff(want, got []byte) string {
+	n := min(len(want), len(got))
+	for i := 0; i < n; i++ {
+		if want[i] != got[i] {
+			return fmt.Sprintf("bodies differ at byte %d", i)
+		}
+	}
+	return fmt.Sprintf("body length %d, recorded %d", len(got), len(want))
+}
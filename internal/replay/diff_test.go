@@ -0,0 +1,52 @@
+package replay
+
+import (
+	"reflect"
+	"testing"
+
+	"access-proxy/internal/capture"
+)
+
+func responseEntry(text, mime, comment string) *capture.Entry {
+	return &capture.Entry{Response: capture.Response{Content: capture.Content{Text: text, MimeType: mime, Comment: comment}}}
+}
+
+func TestCompareJSONBodies(t *testing.T) {
+	var r Result
+	compareBodies(&r, responseEntry(`{"id":1,"date":"mon","items":[1,2],"user":{"name":"a"}}`, "application/json", ""),
+		[]byte(`{"user":{"name":"b"},"items":[1,2],"date":"tue","id":1,"extra":true}`), "application/json", []string{"date"})
+
+	want := []string{"$.extra: unexpected", "$.user.name: got b, recorded a"}
+	if r.BodyMatch || !reflect.DeepEqual(r.BodyDiffs, want) {
+		t.Errorf("diffs = %v, want %v", r.BodyDiffs, want)
+	}
+
+	r = Result{}
+	compareBodies(&r, responseEntry(`{"b":[1],"a":2}`, "application/json", ""), []byte(`{"a":2,"b":[1]}`), "", nil)
+	if !r.BodyMatch {
+		t.Errorf("equal JSON with different key order: %v", r.BodyDiffs)
+	}
+}
+
+func TestCompareSkipsUnusableRecordedBodies(t *testing.T) {
+	for _, e := range []*capture.Entry{
+		responseEntry("abc", "text/plain", "truncated"),
+		responseEntry("x", "text/plain", "redacted"),
+		responseEntry(`{"token":"[REDACTED]"}`, "application/json", ""),
+		{Response: capture.Response{Content: capture.Content{Text: "!!", Encoding: "base64"}}},
+	} {
+		var r Result
+		compareBodies(&r, e, []byte("abc"), "text/plain", nil)
+		if r.BodySkipped == "" {
+			t.Errorf("body %q was compared", e.Response.Content.Text)
+		}
+	}
+}
+
+func TestCompareBytes(t *testing.T) {
+	var r Result
+	compareBodies(&r, responseEntry("hello", "text/plain", ""), []byte("help"), "text/plain", nil)
+	if r.BodyMatch || len(r.BodyDiffs) != 1 || r.BodyDiffs[0] != "bodies differ at byte 3" {
+		t.Errorf("got %+v", r)
+	}
+}
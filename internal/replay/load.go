@@ -0,0 +1,50 @@
+// internal/replay/load.go
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"access-proxy/internal/capture"
+)
+
+// Load читает записи из HAR файла или JSONL (по записи HAR на строку)
+func Load(path string) ([]capture.Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+
+	// HAR — один JSON объект с ключом "log"
+	var har capture.HAR
+	if err := json.Unmarshal(trimmed, &har); err == nil && har.Log.Version != "" {
+		return har.Log.Entries, nil
+	}
+
+	var entries []capture.Entry
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var entry capture.Entry
+		if err := json.Unmarshal(text, &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,41 @@
+package replay
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadHARAndJSONL(t *testing.T) {
+	har := writeFile(t, "c.har", `{"log":{"version":"1.2","creator":{"name":"x","version":"1"},"entries":[{"request":{"method":"GET","url":"http://a/1"}}]}}`)
+	entries, err := Load(har)
+	if err != nil || len(entries) != 1 || entries[0].Request.URL != "http://a/1" {
+		t.Fatalf("har: %v %+v", err, entries)
+	}
+
+	jsonl := writeFile(t, "c.jsonl", "{\"request\":{\"method\":\"GET\"}}\n\n{\"request\":{\"method\":\"POST\"}}\n")
+	entries, err = Load(jsonl)
+	if err != nil || len(entries) != 2 || entries[1].Request.Method != "POST" {
+		t.Fatalf("jsonl: %v %+v", err, entries)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, data := range []string{"", "  \n", "{\"request\":{}}\nnot json\n"} {
+		if _, err := Load(writeFile(t, "bad", data)); err == nil {
+			t.Errorf("Load(%q): expected error", data)
+		}
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("missing file: expected error")
+	}
+}
@@ -0,0 +1,191 @@
+// internal/replay/replay.go
+package replay
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"access-proxy/internal/capture"
+	"access-proxy/internal/redact"
+)
+
+// Options параметры воспроизведения
+type Options struct {
+	Target       *url.URL
+	Rate         float64 // запросов в секунду, 0 — без ограничения
+	Concurrency  int
+	Headers      http.Header // заменяют одноимённые записанные заголовки
+	IgnoreFields []string    // ключи JSON, которые не сравниваются (например, "date")
+	Timeout      time.Duration
+}
+
+// Заголовки, которые не переносятся из записи в повторный запрос
+var skipHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+	"Te":                true,
+	"Trailer":           true,
+	"Accept-Encoding":   true,
+}
+
+// Run воспроизводит записи против opts.Target и сравнивает ответы с записанными
+func Run(ctx context.Context, entries []capture.Entry, opts Options) *Report {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	client := &http.Client{
+		Timeout: opts.Timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	results := make([]Result, len(entries))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				results[idx] = replayEntry(ctx, client, &entries[idx], opts)
+			}
+		}()
+	}
+
+	var tick <-chan time.Time
+	if opts.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.Rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	start := time.Now()
+dispatch:
+	for i := range entries {
+		if tick != nil && i > 0 {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return newReport(results, time.Since(start))
+}
+
+func replayEntry(ctx context.Context, client *http.Client, entry *capture.Entry, opts Options) Result {
+	result := Result{
+		Method:         entry.Request.Method,
+		URL:            entry.Request.URL,
+		RecordedStatus: entry.Response.Status,
+	}
+
+	if reason := notReplayable(entry); reason != "" {
+		result.Skipped = reason
+		return result
+	}
+
+	req, err := buildRequest(ctx, entry, opts)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.URL = req.URL.String()
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	result.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		result.Error = fmt.Sprintf("read body: %v", err)
+		return result
+	}
+
+	result.Status = resp.StatusCode
+	result.StatusMatch = resp.StatusCode == entry.Response.Status
+	compareBodies(&result, entry, body, resp.Header.Get("Content-Type"), opts.IgnoreFields)
+	return result
+}
+
+// notReplayable возвращает причину, по которой запись нельзя отправить повторно:
+// тело запроса записано не полностью или с подставленной маской redaction
+func notReplayable(entry *capture.Entry) string {
+	pd := entry.Request.PostData
+	switch {
+	case pd == nil:
+		if entry.Request.BodySize > 0 {
+			return "request body not captured"
+		}
+		return ""
+	case pd.Comment != "":
+		return "request body " + pd.Comment
+	case strings.Contains(pd.Text, redact.DefaultMask):
+		return "request body is redacted"
+	}
+	return ""
+}
+
+func buildRequest(ctx context.Context, entry *capture.Entry, opts Options) (*http.Request, error) {
+	recorded, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse recorded url: %w", err)
+	}
+
+	target := *opts.Target
+	target.Path = strings.TrimSuffix(opts.Target.Path, "/") + recorded.Path
+	target.RawPath = ""
+	target.RawQuery = recorded.RawQuery
+
+	var body io.Reader
+	if pd := entry.Request.PostData; pd != nil && pd.Text != "" {
+		data := []byte(pd.Text)
+		if pd.Encoding == "base64" {
+			if data, err = base64.StdEncoding.DecodeString(pd.Text); err != nil {
+				return nil, fmt.Errorf("decode request body: %w", err)
+			}
+		}
+		body = strings.NewReader(string(data))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, entry.Request.Method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, h := range entry.Request.Headers {
+		if !skipHeaders[http.CanonicalHeaderKey(h.Name)] {
+			req.Header.Add(h.Name, h.Value)
+		}
+	}
+	for name, values := range opts.Headers {
+		req.Header[name] = values
+	}
+	return req, nil
+}
@@ -0,0 +1,107 @@
+package replay
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"access-proxy/internal/capture"
+)
+
+func postEntry(text, comment string) capture.Entry {
+	return capture.Entry{
+		Request: capture.Request{
+			Method:   http.MethodPost,
+			URL:      "http://recorded:8080/orders?id=1",
+			BodySize: int64(len(text)),
+			Headers: []capture.NameValue{
+				{Name: "Content-Type", Value: "application/json"},
+				{Name: "Host", Value: "recorded:8080"},
+				{Name: "X-Token", Value: "old"},
+			},
+			PostData: &capture.PostData{MimeType: "application/json", Text: text, Comment: comment},
+		},
+		Response: capture.Response{Status: http.StatusCreated},
+	}
+}
+
+func TestNotReplayable(t *testing.T) {
+	noBody := postEntry("", "")
+	noBody.Request.PostData = nil
+	lost := noBody
+	lost.Request.BodySize = 10
+
+	tests := []struct {
+		name  string
+		entry capture.Entry
+		want  string
+	}{
+		{"full body", postEntry(`{"a":1}`, ""), ""},
+		{"no body", noBody, ""},
+		{"body lost", lost, "request body not captured"},
+		{"truncated", postEntry(`{"a":`, "truncated"), "request body truncated"},
+		{"not captured", postEntry("", "not captured"), "request body not captured"},
+		{"redacted comment", postEntry(`{"p":"***"}`, "redacted"), "request body redacted"},
+		{"default mask", postEntry(`{"p":"[REDACTED]"}`, ""), "request body is redacted"},
+	}
+	for _, tt := range tests {
+		if got := notReplayable(&tt.entry); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildRequest(t *testing.T) {
+	target, _ := url.Parse("http://staging:9000/base/")
+	entry := postEntry(base64.StdEncoding.EncodeToString([]byte{0xff, 0x00}), "")
+	entry.Request.PostData.Encoding = "base64"
+
+	req, err := buildRequest(context.Background(), &entry, Options{
+		Target:  target,
+		Headers: http.Header{"X-Token": {"new"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.URL.String() != "http://staging:9000/base/orders?id=1" {
+		t.Errorf("url = %s", req.URL)
+	}
+	if req.Header.Get("X-Token") != "new" || req.Header.Get("Host") != "" || req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", req.Header)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != "\xff\x00" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestRunSkipsUnreplayableEntries(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+	target, _ := url.Parse(srv.URL)
+
+	entries := []capture.Entry{postEntry(`{"a":1}`, ""), postEntry(`{"a":`, "truncated")}
+	report := Run(context.Background(), entries, Options{Target: target})
+
+	if hits != 1 {
+		t.Errorf("upstream got %d requests, want 1", hits)
+	}
+	s := report.Summary
+	if s.Total != 2 || s.Matched != 1 || s.NotReplayable != 1 || report.Failed() {
+		t.Errorf("summary = %+v, failed = %v", s, report.Failed())
+	}
+
+	var out strings.Builder
+	report.WriteText(&out)
+	if !strings.Contains(out.String(), "not replayed, request body truncated") {
+		t.Errorf("report does not mention skipped entry:\n%s", out.String())
+	}
+}
@@ -0,0 +1,114 @@
+// internal/replay/report.go
+package replay
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Result результат воспроизведения одной записи
+type Result struct {
+	Method         string   `json:"method"`
+	URL            string   `json:"url"`
+	RecordedStatus int      `json:"recorded_status"`
+	Status         int      `json:"status,omitempty"`
+	StatusMatch    bool     `json:"status_match"`
+	BodyMatch      bool     `json:"body_match"`
+	BodySkipped    string   `json:"body_skipped,omitempty"`
+	BodyDiffs      []string `json:"body_diffs,omitempty"`
+	DurationMs     float64  `json:"duration_ms,omitempty"`
+	Skipped        string   `json:"skipped,omitempty"` // причина, по которой запрос не отправлялся
+	Error          string   `json:"error,omitempty"`
+}
+
+// Matched запись совпала по статусу и (если сравнивалось) по телу
+func (r *Result) Matched() bool {
+	return r.Error == "" && r.StatusMatch && (r.BodyMatch || r.BodySkipped != "")
+}
+
+// Summary сводка по всем записям
+type Summary struct {
+	Total          int    `json:"total"`
+	Matched        int    `json:"matched"`
+	StatusMismatch int    `json:"status_mismatch"`
+	BodyMismatch   int    `json:"body_mismatch"`
+	BodySkipped    int    `json:"body_skipped"`
+	NotReplayable  int    `json:"not_replayable"`
+	Errors         int    `json:"errors"`
+	Elapsed        string `json:"elapsed"`
+}
+
+// Report отчёт о воспроизведении
+type Report struct {
+	Summary Summary  `json:"summary"`
+	Results []Result `json:"results"`
+}
+
+func newReport(results []Result, elapsed time.Duration) *Report {
+	report := &Report{Results: results}
+	s := &report.Summary
+	s.Total = len(results)
+	s.Elapsed = elapsed.Round(time.Millisecond).String()
+
+	for i := range results {
+		r := &results[i]
+		switch {
+		case r.Skipped != "":
+			s.NotReplayable++
+			continue
+		case r.Error != "":
+			s.Errors++
+			continue
+		case !r.StatusMatch:
+			s.StatusMismatch++
+		case r.BodySkipped != "":
+			s.BodySkipped++
+		case !r.BodyMatch:
+			s.BodyMismatch++
+		}
+		if r.Matched() {
+			s.Matched++
+		}
+	}
+	return report
+}
+
+// Failed есть ли расхождения или ошибки. Невоспроизводимые записи ошибкой не считаются
+func (r *Report) Failed() bool {
+	return r.Summary.Matched+r.Summary.NotReplayable != r.Summary.Total
+}
+
+// WriteText пишет читаемый отчёт: сводку и только расхождения
+func (r *Report) WriteText(w io.Writer) {
+	for _, res := range r.Results {
+		if res.Matched() {
+			continue
+		}
+		switch {
+		case res.Skipped != "":
+			fmt.Fprintf(w, "⏭️ %s %s: not replayed, %s\n", res.Method, res.URL, res.Skipped)
+		case res.Error != "":
+			fmt.Fprintf(w, "❌ %s %s: %s\n", res.Method, res.URL, res.Error)
+		case !res.StatusMatch:
+			fmt.Fprintf(w, "❌ %s %s: status %d, recorded %d\n", res.Method, res.URL, res.Status, res.RecordedStatus)
+		default:
+			fmt.Fprintf(w, "❌ %s %s: body differs\n", res.Method, res.URL)
+		}
+		for _, d := range res.BodyDiffs {
+			fmt.Fprintf(w, "     %s\n", d)
+		}
+	}
+
+	s := r.Summary
+	fmt.Fprintf(w, "\n=== REPLAY SUMMARY ===\n")
+	fmt.Fprintf(w, "Total: %d\n", s.Total)
+	fmt.Fprintf(w, "Matched: %d\n", s.Matched)
+	fmt.Fprintf(w, "Status mismatch: %d\n", s.StatusMismatch)
+	fmt.Fprintf(w, "Body mismatch: %d\n", s.BodyMismatch)
+	fmt.Fprintf(w, "Body not compared: %d\n", s.BodySkipped)
+	fmt.Fprintf(w, "Not replayable: %d\n", s.NotReplayable)
+	fmt.Fprintf(w, "Errors: %d\n", s.Errors)
+	fmt.Fprintf(w, "Elapsed: %s\n", s.Elapsed)
+	fmt.Fprintf(w, "======================\n")
+}
@@ -0,0 +1,73 @@
+// internal/reqctx/reqctx.go
+package reqctx
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Решения доступа, которые фиксируются для каждого запроса
+const (
+	DecisionAllow = "allow"
+	DecisionDeny  = "deny"
+)
+
+// Info хранит сведения о запросе, которые middleware и прокси
+// заполняют по ходу обработки, а метрики и логи читают в конце
+type Info struct {
+	Start     time.Time
+	RequestID string
+	ClientIP  string
+	Route     string
+
+	Decision   string
+	DenyReason string
+
+	// Аутентифицированный клиент, если запрос прошёл проверку
+	Principal     string
+	AuthMethod    string
+	Scopes        []string
+	RateLimitTier string
+
+	Status       int
+	BytesWritten int64
+
+	Upstream         string
+	UpstreamDuration time.Duration
+	UpstreamError    string
+}
+
+type ctxKey struct{}
+
+// NewContext возвращает контекст с привязанным Info
+func NewContext(ctx context.Context, info *Info) context.Context {
+	return context.WithValue(ctx, ctxKey{}, info)
+}
+
+// FromContext возвращает Info запроса или nil
+func FromContext(ctx context.Context) *Info {
+	info, _ := ctx.Value(ctxKey{}).(*Info)
+	return info
+}
+
+// FromRequest возвращает Info запроса или nil
+func FromRequest(r *http.Request) *Info {
+	return FromContext(r.Context())
+}
+
+// Identity идентификатор клиента для лимитов: principal, если он известен, иначе IP
+func (i *Info) Identity() string {
+	if i.Principal != "" {
+		return i.AuthMethod + ":" + i.Principal
+	}
+	return i.ClientIP
+}
+
+// Deny отмечает запрос как отклонённый с указанной причиной
+func Deny(r *http.Request, reason string) {
+	if info := FromRequest(r); info != nil {
+		info.Decision = DecisionDeny
+		info.DenyReason = reason
+	}
+}
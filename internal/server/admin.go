@@ -0,0 +1,46 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"access-proxy/internal/config"
+	"access-proxy/internal/reqctx"
+)
+
+func (s *httpServer) setupAdmin(cfg config.AdminConfig) {
+	for i, token := range cfg.Tokens {
+		if strings.TrimSpace(token) == "" {
+			s.log.Fatalf("❌ Invalid admin config: token #%d is empty", i+1)
+		}
+		s.adminTokens = append(s.adminTokens, []byte(token))
+	}
+}
+
+// adminTokenHeader заголовок токена администратора. Authorization не подходит:
+// его занимают JWT и Basic, которые проверяются раньше на всех маршрутах
+const adminTokenHeader = "X-Admin-Token"
+
+// requireAdmin пропускает только запросы с токеном администратора.
+// Иначе отвечает 401/403 и возвращает false
+func (s *httpServer) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if len(s.adminTokens) == 0 {
+		reqctx.Deny(r, "admin_disabled")
+		s.jsonError(w, "Admin endpoints are disabled: configure admin.tokens", http.StatusForbidden)
+		return false
+	}
+
+	if token := r.Header.Get(adminTokenHeader); token != "" {
+		for _, t := range s.adminTokens {
+			if subtle.ConstantTimeCompare([]byte(token), t) == 1 {
+				return true
+			}
+		}
+	}
+
+	s.log.Warnf("⚠️ Admin request rejected: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+	reqctx.Deny(r, "admin_unauthorized")
+	s.jsonError(w, "Admin token required", http.StatusUnauthorized)
+	return false
+}
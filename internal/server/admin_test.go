@@ -0,0 +1,160 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"access-proxy/internal/canary"
+	"access-proxy/internal/capture"
+	"access-proxy/internal/config"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+func newAdminTestServer(t *testing.T, tokens ...string) *infoHandlers {
+	t.Helper()
+	log := logger.New("test", logger.LevelInfo, logger.ModeDev)
+	s := &httpServer{log: log}
+	s.setupAdmin(config.AdminConfig{Tokens: tokens})
+	s.capture = capture.NewRecorder(capture.Options{Dir: t.TempDir()}, log)
+	return &infoHandlers{server: s}
+}
+
+func TestCaptureRequiresAdminToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		tokens []string
+		method string
+		auth   string
+		want   int
+	}{
+		{"status is public", []string{"t0k"}, http.MethodGet, "", http.StatusOK},
+		{"no token configured", nil, http.MethodPost, "anything", http.StatusForbidden},
+		{"missing token", []string{"t0k"}, http.MethodPost, "", http.StatusUnauthorized},
+		{"wrong token", []string{"t0k"}, http.MethodDelete, "nope", http.StatusUnauthorized},
+		{"valid token", []string{"other", "t0k"}, http.MethodPost, "t0k", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newAdminTestServer(t, tt.tokens...)
+			defer h.server.capture.Stop()
+
+			req := httptest.NewRequest(tt.method, "/capture", nil)
+			if tt.auth != "" {
+				req.Header.Set(adminTokenHeader, tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			h.captureHandler(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("got %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestCanaryUpdateRequiresAdminToken(t *testing.T) {
+	h := newAdminTestServer(t, "t0k")
+	splitter, err := canary.New("api", http.NotFoundHandler(), http.NotFoundHandler(), canary.Options{Percent: 5}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.server.canaries = map[string]*canary.Splitter{"api": splitter}
+
+	for _, auth := range []string{"", "t0k"} {
+		req := httptest.NewRequest(http.MethodPost, "/canary", strings.NewReader(`{"route": "api", "percent": 50}`))
+		if auth != "" {
+			req.Header.Set(adminTokenHeader, auth)
+		}
+		rec := httptest.NewRecorder()
+		h.canaryHandler(rec, req)
+
+		want, percent := http.StatusUnauthorized, 5.0
+		if auth != "" {
+			want, percent = http.StatusOK, 50
+		}
+		if rec.Code != want || splitter.Percent() != percent {
+			t.Errorf("auth %q: got %d, percent %v", auth, rec.Code, splitter.Percent())
+		}
+	}
+}
+
+// Authorization занят JWT, поэтому токен администратора передаётся отдельно
+func TestCaptureWithJWTEnabled(t *testing.T) {
+	log := logger.New("test", logger.LevelInfo, logger.ModeDev)
+	s := &httpServer{log: log}
+	s.setupAdmin(config.AdminConfig{Tokens: []string{"t0k"}})
+	s.setupJWT(config.JWTConfig{Enabled: true, Secret: "jwt-secret", Realm: "test"})
+	s.setupCapture(config.CaptureConfig{Dir: t.TempDir()})
+	defer s.capture.Stop()
+
+	router := newRouter(s, newInfoHandlers(s))
+	handler := newMiddlewareBuilder(s).build(router.createMainHandler(), router.routeName)
+
+	token := hs256Token(t, "jwt-secret", map[string]interface{}{"sub": "ops", "exp": time.Now().Add(time.Minute).Unix()})
+	tests := []struct {
+		name  string
+		jwt   string
+		admin string
+		want  int
+	}{
+		{"no jwt", "", "t0k", http.StatusUnauthorized},
+		{"jwt without admin token", token, "", http.StatusUnauthorized},
+		{"jwt and admin token", token, "t0k", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/capture", strings.NewReader(`{}`))
+		if tt.jwt != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.jwt)
+		}
+		if tt.admin != "" {
+			req.Header.Set(adminTokenHeader, tt.admin)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: got %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
+
+func hs256Token(t *testing.T, secret string, claims map[string]interface{}) string {
+	t.Helper()
+	enc := base64.RawURLEncoding
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + enc.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+// Пути выключенных функций уходят в upstream, а не в эндпоинты прокси
+func TestDisabledEndpointsAreNotRegistered(t *testing.T) {
+	s := &httpServer{log: logger.New("test", logger.LevelInfo, logger.ModeDev)}
+	s.setupCapture(config.CaptureConfig{Dir: t.TempDir()})
+	router := newRouter(s, newInfoHandlers(s))
+
+	for _, path := range []string{"/capture", "/canary", "/concurrency", "/quota"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if name := router.routeName(req); name != proxyRouteName {
+			t.Errorf("%s: route %q, want %q", path, name, proxyRouteName)
+		}
+	}
+
+	s.setupAdmin(config.AdminConfig{Tokens: []string{"t0k"}})
+	s.setupCapture(config.CaptureConfig{Dir: t.TempDir()})
+	defer s.capture.Stop()
+	router = newRouter(s, newInfoHandlers(s))
+	if name := router.routeName(httptest.NewRequest(http.MethodGet, "/capture", nil)); name != "capture" {
+		t.Errorf("/capture with admin tokens: route %q", name)
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"access-proxy/internal/canary"
+)
+
+// canaryUpdateRequest тело POST /canary
+type canaryUpdateRequest struct {
+	Route   string   `json:"route"`
+	Percent *float64 `json:"percent"`
+}
+
+// canaryHandler GET — доли canary по маршрутам, POST — изменить долю маршрута.
+// POST требует токена администратора
+func (h *infoHandlers) canaryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost && !h.server.requireAdmin(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.server.jsonResponse(w, map[string]interface{}{"canary": h.canaryStatus()})
+	case http.MethodPost:
+		h.updateCanary(w, r)
+	default:
+		h.server.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *infoHandlers) canaryStatus() []canary.Status {
+	statuses := make([]canary.Status, 0, len(h.server.canaries))
+	for _, splitter := range h.server.canaries {
+		statuses = append(statuses, splitter.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Route < statuses[j].Route })
+	return statuses
+}
+
+func (h *infoHandlers) updateCanary(w http.ResponseWriter, r *http.Request) {
+	var req canaryUpdateRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+		h.server.jsonError(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Percent == nil {
+		h.server.jsonError(w, "percent is required", http.StatusBadRequest)
+		return
+	}
+
+	splitter, ok := h.server.canaries[req.Route]
+	if !ok {
+		h.server.jsonError(w, "No canary configured for route "+req.Route, http.StatusNotFound)
+		return
+	}
+	if err := splitter.SetPercent(*req.Percent); err != nil {
+		h.server.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.server.log.Infof("🐤 Canary for route %s set to %.1f%%", req.Route, *req.Percent)
+	h.server.jsonResponse(w, map[string]interface{}{"canary": splitter.Status()})
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"access-proxy/internal/capture"
+)
+
+// captureStartRequest тело POST /capture; незаданные поля берутся из конфигурации
+type captureStartRequest struct {
+	Format      string   `json:"format"`
+	MaxEntries  int      `json:"max_entries"`
+	MaxDuration string   `json:"max_duration"`
+	Routes      []string `json:"routes"`
+	Clients     []string `json:"clients"`
+	Headers     []string `json:"headers"`
+}
+
+// captureHandler GET — состояние, POST — начать захват, DELETE — остановить и сохранить файл.
+// POST и DELETE требуют токена администратора
+func (h *infoHandlers) captureHandler(w http.ResponseWriter, r *http.Request) {
+	if (r.Method == http.MethodPost || r.Method == http.MethodDelete) && !h.server.requireAdmin(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.server.jsonResponse(w, map[string]interface{}{"capture": h.server.capture.Status()})
+	case http.MethodPost:
+		h.startCapture(w, r)
+	case http.MethodDelete:
+		status, err := h.server.capture.Stop()
+		if err != nil {
+			h.server.jsonError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		h.server.jsonResponse(w, map[string]interface{}{"capture": status})
+	default:
+		h.server.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *infoHandlers) startCapture(w http.ResponseWriter, r *http.Request) {
+	var req captureStartRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+			h.server.jsonError(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	opts := capture.Options{
+		Format:     req.Format,
+		MaxEntries: req.MaxEntries,
+		Filter: capture.Filter{
+			Routes:  req.Routes,
+			Clients: req.Clients,
+			Headers: req.Headers,
+		},
+	}
+	if req.MaxDuration != "" {
+		d, err := time.ParseDuration(req.MaxDuration)
+		if err != nil {
+			h.server.jsonError(w, "Invalid max_duration: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts.MaxDuration = d
+	}
+
+	status, err := h.server.capture.Start(opts)
+	if err != nil {
+		h.server.jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	h.server.jsonResponse(w, map[string]interface{}{"capture": status})
+}
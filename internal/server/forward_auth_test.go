@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"access-proxy/internal/config"
+	"access-proxy/internal/middleware"
+	"access-proxy/internal/reqctx"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+func TestForwardAuthPrincipalHeaderWithoutResponseHeaders(t *testing.T) {
+	authSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-User-Id", "alice")
+	}))
+	defer authSrv.Close()
+
+	log := logger.New("test", logger.LevelInfo, logger.ModeDev)
+	s := &httpServer{log: log}
+	s.setupForwardAuth(config.ForwardAuthConfig{Enabled: true, URL: authSrv.URL, PrincipalHeader: "X-User-ID"})
+
+	var principal, header string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal = reqctx.FromRequest(r).Principal
+		header = r.Header.Get("X-User-Id")
+	})
+	chain := middleware.RequestInfoMiddleware(func(*http.Request) string { return "api" }, nil)(
+		middleware.ForwardAuthMiddleware(log, s.forwardAuth, s.forwardOpts)(handler))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-User-ID", "mallory")
+	chain.ServeHTTP(httptest.NewRecorder(), req)
+
+	if principal != "alice" || header != "alice" {
+		t.Errorf("principal %q, upstream header %q, want alice", principal, header)
+	}
+}
@@ -2,6 +2,8 @@ package server
 
 import (
 	"net/http"
+
+	"access-proxy/internal/reqctx"
 )
 
 // Handler структуры для группировки связанных обработчиков
@@ -18,6 +20,31 @@ func (h *infoHandlers) rootHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	endpoints := map[string]string{
+		"health":      "/health",
+		"config":      "/config",
+		"ratelimit":   "/ratelimit-info",
+		"client_info": "/client-info",
+		"methods":     "/methods",
+		"domains":     "/domains",
+		"proxy":       "/* (proxies to target)",
+	}
+	if h.server.metrics != nil {
+		endpoints["metrics"] = h.server.metricsPath
+	}
+	if h.server.capture != nil {
+		endpoints["capture"] = "/capture"
+	}
+	if len(h.server.canaries) > 0 {
+		endpoints["canary"] = "/canary"
+	}
+	if h.server.concurrency != nil {
+		endpoints["concurrency"] = "/concurrency"
+	}
+	if h.server.quota != nil {
+		endpoints["quota"] = "/quota"
+	}
+
 	response := map[string]interface{}{
 		"service": "access-proxy",
 		"status":  "running",
@@ -28,16 +55,9 @@ func (h *infoHandlers) rootHandler(w http.ResponseWriter, r *http.Request) {
 			"request_logging":     h.server.logRequests,
 			"client_domain_check": len(h.server.allowedDomains) > 0,
 			"method_restrictions": len(h.server.blockedMethods) > 0,
+			"metrics":             h.server.metrics != nil,
 		},
-		"endpoints": map[string]string{
-			"health":      "/health",
-			"config":      "/config",
-			"ratelimit":   "/ratelimit-info",
-			"client_info": "/client-info",
-			"methods":     "/methods",
-			"domains":     "/domains",
-			"proxy":       "/* (proxies to target)",
-		},
+		"endpoints": endpoints,
 	}
 
 	h.server.jsonResponse(w, response)
@@ -58,6 +78,7 @@ func (h *infoHandlers) healthHandler(w http.ResponseWriter, r *http.Request) {
 			"request_logging":     h.server.logRequests,
 			"client_domain_check": len(h.server.allowedDomains) > 0,
 			"method_restrictions": len(h.server.blockedMethods) > 0,
+			"metrics":             h.server.metrics != nil,
 		},
 		"client_allowed": h.server.isClientAllowed(r),
 	}
@@ -121,14 +142,65 @@ func (h *infoHandlers) rateLimitInfoHandler(w http.ResponseWriter, r *http.Reque
 	}
 
 	identifier := h.server.getClientIP(r)
-	remaining := h.server.rateLimiter.GetRemaining(identifier)
+	rules, err := h.server.rateLimiter.Status(r)
+	if err != nil {
+		h.server.jsonError(w, "Rate limit store is unavailable: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	// remaining — остаток по правилу с наименьшим запасом
+	remaining := -1
+	for _, rule := range rules {
+		if remaining < 0 || rule.Remaining < remaining {
+			remaining = rule.Remaining
+		}
+	}
 
 	h.server.jsonResponse(w, map[string]interface{}{
-		"rate_limiting": true,
-		"limit":         h.server.rateLimiter.GetLimit(),
-		"remaining":     remaining,
-		"window":        "1 minute",
-		"your_ip":       identifier,
+		"rate_limiting":   true,
+		"limit":           h.server.rateLimiter.GetLimit(),
+		"burst":           h.server.rateLimiter.GetBurst(),
+		"remaining":       remaining,
+		"window":          h.server.rateLimiter.GetWindow().String(),
+		"rules":           rules,
+		"shared":          h.server.rateLimiter.Shared(),
+		"tracked_clients": h.server.rateLimiter.Tracked(),
+		"your_ip":         identifier,
+	})
+}
+
+// concurrencyHandler текущие лимиты одновременных запросов, в том числе адаптивные
+func (h *infoHandlers) concurrencyHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.validateMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	h.server.jsonResponse(w, map[string]interface{}{
+		"concurrency": true,
+		"limits":      h.server.concurrency.Status(),
+	})
+}
+
+// quotaHandler использование квот клиентом, который делает запрос
+func (h *infoHandlers) quotaHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.validateMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	periods, err := h.server.quota.Status(r)
+	if err != nil {
+		h.server.jsonError(w, "Quota store is unavailable: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	identity := ""
+	if info := reqctx.FromRequest(r); info != nil {
+		identity = info.Identity()
+	}
+	h.server.jsonResponse(w, map[string]interface{}{
+		"quota":    true,
+		"identity": identity,
+		"periods":  periods,
 	})
 }
 
@@ -163,6 +235,14 @@ func (h *infoHandlers) methodsHandler(w http.ResponseWriter, r *http.Request) {
 	h.server.jsonResponse(w, response)
 }
 
+func (h *infoHandlers) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.validateMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	h.server.metrics.Registry.Handler().ServeHTTP(w, r)
+}
+
 func (h *infoHandlers) validateMethod(w http.ResponseWriter, r *http.Request, allowedMethod string) bool {
 	if r.Method != allowedMethod {
 		h.server.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
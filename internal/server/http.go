@@ -1,12 +1,32 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
+	"access-proxy/internal/accesslog"
+	"access-proxy/internal/auth"
+	"access-proxy/internal/bandwidth"
+	"access-proxy/internal/canary"
+	"access-proxy/internal/concurrency"
+	"access-proxy/internal/capture"
+	"access-proxy/internal/config"
+	"access-proxy/internal/expr"
+	"access-proxy/internal/metrics"
+	"access-proxy/internal/middleware"
+	"access-proxy/internal/oidc"
+	"access-proxy/internal/policy"
+	"access-proxy/internal/quota"
 	"access-proxy/internal/ratelimit"
+	"access-proxy/internal/redact"
+	"access-proxy/internal/reqctx"
 
 	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
 )
@@ -22,38 +42,641 @@ type httpServer struct {
 	log            logger.Logger
 	rateLimiter    *ratelimit.RateLimiter
 	useRateLimit   bool
+	concurrency    *concurrency.Limiter
+	bandwidth      *bandwidth.Limiter
+	quota          *quota.Limiter
 	target         string
 	logRequests    bool
 	allowedDomains []string
 	blockedMethods []string
+	trustedProxies middleware.TrustedProxies
+	metrics        *metrics.ProxyMetrics
+	metricsPath    string
+	accessLog      *accesslog.Logger
+	logBodies      bool
+	bodyCapture    middleware.BodyCaptureOptions
+	redactor       *redact.Redactor
+	capture        *capture.Recorder
+	captureBodies  middleware.BodyCaptureOptions
+	routes         []proxyRoute
+	canaries       map[string]*canary.Splitter
+	apiKeys        *auth.KeyStore
+	apiKeyOptions  middleware.APIKeyOptions
+	jwtVerifier    *auth.Verifier
+	jwtOptions     middleware.JWTOptions
+	basicAuth      []basicAuthRule
+	forwardAuth    *auth.ForwardAuth
+	forwardOpts    middleware.ForwardAuthOptions
+	hmacVerifier   *auth.HMACVerifier
+	hmacOpts       middleware.HMACSignatureOptions
+	oidc           *oidc.RelyingParty
+	oidcRoutes     []string
+	oidcLogoutPath string
+	policies       *policy.Set
+	headerRules    []middleware.HeaderRule
+	adminTokens    [][]byte
 
 	// Внедренные компоненты
 	domainUtils *domainUtils
 }
 
-func NewHttpServer(proxy ProxyServer, port int, log logger.Logger, rateLimitPerMinute int, target string, logRequests bool, allowedDomains []string, blockedMethods []string) HttpServer {
+func NewHttpServer(proxy ProxyServer, cfg *config.Config, log logger.Logger, redactor *redact.Redactor) HttpServer {
 	server := &httpServer{
 		proxy:          proxy,
-		port:           port,
+		port:           cfg.Port,
 		log:            log,
-		target:         target,
-		logRequests:    logRequests,
-		allowedDomains: allowedDomains,
-		blockedMethods: blockedMethods,
-		domainUtils:    newDomainUtils(allowedDomains),
+		target:         cfg.Target,
+		logRequests:    cfg.LogRequests,
+		allowedDomains: cfg.AllowedDomains,
+		blockedMethods: cfg.BlockedMethods,
+		logBodies:      cfg.BodyLogging.Enabled,
+		bodyCapture:    newBodyCaptureOptions(cfg.BodyLogging),
+		redactor:       redactor,
+		domainUtils:    newDomainUtils(cfg.AllowedDomains),
 	}
 
-	server.setupRateLimiter(rateLimitPerMinute)
+	server.setupTrustedProxies(cfg.TrustedProxies)
+	server.setupMetrics(cfg.Metrics)
+	server.setupRateLimiter(cfg.RateLimit)
+	server.setupAPIKeys(cfg.APIKeys)
+	server.setupJWT(cfg.JWT)
+	server.setupBasicAuth(cfg.BasicAuth)
+	server.setupForwardAuth(cfg.ForwardAuth)
+	server.setupHMAC(cfg.HMAC)
+	server.setupOIDC(cfg.OIDC)
+	server.setupPolicies(cfg.Policies)
+	server.setupHeaderRules(cfg.HeaderRules)
+	server.setupAccessLog(cfg.AccessLog)
+	server.setupAdmin(cfg.Admin)
+	server.setupCapture(cfg.Capture)
+	server.setupRoutes(cfg.Routes)
+	server.setupConcurrency(cfg.Concurrency)
+	server.setupBandwidth(cfg.Bandwidth)
+	server.setupQuota(cfg.Quota)
 	server.logConfiguration()
 
 	return server
 }
 
-func (s *httpServer) setupRateLimiter(rateLimitPerMinute int) {
-	s.useRateLimit = rateLimitPerMinute > 0
-	if s.useRateLimit {
-		s.rateLimiter = ratelimit.NewRateLimiter(rateLimitPerMinute, s.log)
-		s.log.Infof("🔒 Rate limiting enabled: %d requests per minute", rateLimitPerMinute)
+func (s *httpServer) setupTrustedProxies(list []string) {
+	proxies, err := middleware.ParseTrustedProxies(list)
+	if err != nil {
+		s.log.Fatalf("❌ Invalid trusted_proxies: %v", err)
+	}
+	s.trustedProxies = proxies
+}
+
+func (s *httpServer) setupRateLimiter(cfg config.RateLimitConfig) {
+	s.useRateLimit = cfg.Limit > 0 || len(cfg.Rules) > 0
+	if !s.useRateLimit {
+		return
+	}
+
+	rules := make([]ratelimit.Rule, 0, len(cfg.Rules))
+	for _, rc := range cfg.Rules {
+		rules = append(rules, ratelimit.Rule{
+			Name:   rc.Name,
+			Key:    rc.Key,
+			Limit:  rc.Limit,
+			Window: rc.Window,
+			Burst:  rc.Burst,
+			Routes: rc.Routes,
+		})
+	}
+
+	opts := ratelimit.Options{
+		Limit:           cfg.Limit,
+		Window:          cfg.Window,
+		Burst:           cfg.Burst,
+		Rules:           rules,
+		IPv6Prefix:      cfg.IPv6Prefix,
+		MaxClients:      cfg.MaxClients,
+		CleanupInterval: cfg.CleanupInterval,
+	}
+
+	switch cfg.Store.Type {
+	case "memory":
+	case "redis":
+		if cfg.Store.OnError != "allow" && cfg.Store.OnError != "deny" {
+			s.log.Fatalf("❌ rate_limit.store.on_error must be allow or deny, got %q", cfg.Store.OnError)
+		}
+		store := ratelimit.NewRedisStore(ratelimit.RedisOptions{
+			Addr:      cfg.Store.Addr,
+			Username:  cfg.Store.Username,
+			Password:  cfg.Store.Password,
+			DB:        cfg.Store.DB,
+			KeyPrefix: cfg.Store.KeyPrefix,
+			Timeout:   cfg.Store.Timeout,
+		})
+		// Недоступный при старте Redis не мешает запуску: запросы обрабатываются по on_error
+		if err := store.Ping(context.Background()); err != nil {
+			s.log.Warnf("⚠️ Rate limit Redis %s is unavailable (on_error: %s): %v", cfg.Store.Addr, cfg.Store.OnError, err)
+		}
+		opts.Store = store
+		opts.FailClosed = cfg.Store.OnError == "deny"
+		// Мониторинг должен видеть сбой, а не получать 503
+		opts.FailOpenRoutes = []string{"health", "metrics"}
+		s.log.Infof("🗄️ Rate limit state is shared via Redis %s (on_error: %s)", cfg.Store.Addr, cfg.Store.OnError)
+	default:
+		s.log.Fatalf("❌ Unknown rate limit store %q (memory or redis)", cfg.Store.Type)
+	}
+
+	rl, err := ratelimit.NewRateLimiter(opts, s.metrics, s.log)
+	if err != nil {
+		s.log.Fatalf("❌ Invalid rate limit configuration: %v", err)
+	}
+	s.rateLimiter = rl
+
+	if cfg.Limit > 0 {
+		s.log.Infof("🔒 Rate limiting enabled: %d requests per %v, burst %d", cfg.Limit, cfg.Window, cfg.Burst)
+	}
+	for _, rc := range cfg.Rules {
+		s.log.Infof("🔒 Rate limit rule %s: %d requests per %v by %s", rc.Name, rc.Limit, rc.Window, strings.Join(rc.Key, "+"))
+	}
+}
+
+func (s *httpServer) setupConcurrency(cfg config.ConcurrencyConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	if cfg.Global <= 0 && cfg.PerClient <= 0 && cfg.PerRoute <= 0 && len(cfg.Routes) == 0 && !cfg.Adaptive.Enabled {
+		s.log.Fatalf("❌ Concurrency limiting requires global, per_client, per_route, routes or adaptive")
+	}
+
+	var adaptive *concurrency.AdaptiveOptions
+	if cfg.Adaptive.Enabled {
+		adaptive = &concurrency.AdaptiveOptions{
+			Algorithm:        cfg.Adaptive.Algorithm,
+			InitialLimit:     cfg.Adaptive.InitialLimit,
+			MinLimit:         cfg.Adaptive.MinLimit,
+			MaxLimit:         cfg.Adaptive.MaxLimit,
+			Backoff:          cfg.Adaptive.Backoff,
+			LatencyThreshold: cfg.Adaptive.LatencyThreshold,
+			Tolerance:        cfg.Adaptive.Tolerance,
+		}
+		if cfg.PerRoute > 0 || len(cfg.Routes) > 0 {
+			s.log.Warnf("⚠️ concurrency.per_route and routes are ignored: route limits are adaptive")
+		}
+	}
+
+	limiter, err := concurrency.New(concurrency.Options{
+		Global:         cfg.Global,
+		PerClient:      cfg.PerClient,
+		PerRoute:       cfg.PerRoute,
+		Routes:         cfg.Routes,
+		MaxQueue:       cfg.MaxQueue,
+		QueueTimeout:   cfg.QueueTimeout,
+		RetryAfter:     cfg.RetryAfter,
+		Adaptive:       adaptive,
+		UpstreamRoutes: s.upstreamRouteNames(),
+	}, s.metrics, s.log)
+	if err != nil {
+		s.log.Fatalf("❌ Invalid concurrency configuration: %v", err)
+	}
+	s.concurrency = limiter
+	s.log.Infof("🚦 Concurrency limits: global %d, per client %d, per route %d (%d route overrides), queue %d for %v",
+		cfg.Global, cfg.PerClient, cfg.PerRoute, len(cfg.Routes), cfg.MaxQueue, cfg.QueueTimeout)
+	if adaptive != nil {
+		s.log.Infof("🚦 Adaptive route concurrency: %s", limiter.Status().Adaptive)
+	}
+}
+
+// upstreamRouteNames маршруты, которые уходят в upstream. Ограничения нагрузки
+// защищают upstream, служебные эндпоинты прокси их не занимают
+func (s *httpServer) upstreamRouteNames() []string {
+	names := []string{proxyRouteName}
+	for _, route := range s.routes {
+		names = append(names, route.name)
+	}
+	return names
+}
+
+func (s *httpServer) setupBandwidth(cfg config.BandwidthConfig) {
+	if !cfg.Enabled {
+		return
+	}
+
+	limit := func(c config.BandwidthLimitConfig) bandwidth.Limit {
+		return bandwidth.Limit{
+			Upload:        c.Upload,
+			Download:      c.Download,
+			UploadBurst:   c.UploadBurst,
+			DownloadBurst: c.DownloadBurst,
+		}
+	}
+	opts := bandwidth.Options{
+		PerClient:      limit(cfg.PerClient),
+		PerRoute:       limit(cfg.PerRoute),
+		Routes:         make(map[string]bandwidth.Limit, len(cfg.Routes)),
+		UpstreamRoutes: s.upstreamRouteNames(),
+	}
+	for route, c := range cfg.Routes {
+		opts.Routes[route] = limit(c)
+	}
+
+	s.bandwidth = bandwidth.New(opts, s.metrics, s.log)
+	s.log.Infof("📶 Bandwidth limits, bytes/s: per client ↑%d ↓%d, per route ↑%d ↓%d (%d route overrides)",
+		cfg.PerClient.Upload, cfg.PerClient.Download, cfg.PerRoute.Upload, cfg.PerRoute.Download, len(cfg.Routes))
+}
+
+func (s *httpServer) setupQuota(cfg config.QuotaConfig) {
+	if !cfg.Enabled {
+		return
+	}
+
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		s.log.Fatalf("❌ Invalid quota timezone %q: %v", cfg.Timezone, err)
+	}
+	if cfg.Store.OnError != "allow" && cfg.Store.OnError != "deny" {
+		s.log.Fatalf("❌ quota.store.on_error must be allow or deny, got %q", cfg.Store.OnError)
+	}
+
+	limits := func(c map[string]config.QuotaLimitConfig) map[string]quota.Limit {
+		m := make(map[string]quota.Limit, len(c))
+		for name, l := range c {
+			m[name] = quota.Limit{Daily: l.Daily, Monthly: l.Monthly}
+		}
+		return m
+	}
+	opts := quota.Options{
+		Default:        quota.Limit{Daily: cfg.Daily, Monthly: cfg.Monthly},
+		Consumers:      limits(cfg.Consumers),
+		Tiers:          limits(cfg.Tiers),
+		Location:       location,
+		FailClosed:     cfg.Store.OnError == "deny",
+		UpstreamRoutes: s.upstreamRouteNames(),
+	}
+
+	switch cfg.Store.Type {
+	case "file":
+		store, err := quota.NewFileStore(cfg.Store.Path, cfg.Store.FlushInterval, s.log)
+		if err != nil {
+			s.log.Fatalf("❌ Failed to load quota counters: %v", err)
+		}
+		opts.Store = store
+		s.log.Infof("🗄️ Quota counters are saved to %s every %v", cfg.Store.Path, cfg.Store.FlushInterval)
+	case "redis":
+		store := quota.NewRedisStore(quota.RedisOptions{
+			Addr:      cfg.Store.Addr,
+			Username:  cfg.Store.Username,
+			Password:  cfg.Store.Password,
+			DB:        cfg.Store.DB,
+			KeyPrefix: cfg.Store.KeyPrefix,
+			Timeout:   cfg.Store.Timeout,
+		})
+		// Недоступный при старте Redis не мешает запуску: запросы обрабатываются по on_error
+		if err := store.Ping(context.Background()); err != nil {
+			s.log.Warnf("⚠️ Quota Redis %s is unavailable (on_error: %s): %v", cfg.Store.Addr, cfg.Store.OnError, err)
+		}
+		opts.Store = store
+		s.log.Infof("🗄️ Quota counters are shared via Redis %s (on_error: %s)", cfg.Store.Addr, cfg.Store.OnError)
+	default:
+		s.log.Fatalf("❌ Unknown quota store %q (file or redis)", cfg.Store.Type)
+	}
+
+	s.quota = quota.New(opts, s.metrics, s.log)
+	s.log.Infof("📅 Quotas: %d calls per day, %d per month (%d consumers, %d tiers, %s)",
+		cfg.Daily, cfg.Monthly, len(cfg.Consumers), len(cfg.Tiers), cfg.Timezone)
+}
+
+func (s *httpServer) setupAPIKeys(cfg config.APIKeysConfig) {
+	if !cfg.Enabled {
+		return
+	}
+
+	if cfg.ReloadInterval < 0 {
+		s.log.Fatalf("❌ api_keys.reload_interval must be positive, got %v", cfg.ReloadInterval)
+	}
+
+	store, err := auth.LoadKeyStore(cfg.Store, s.log)
+	if err != nil {
+		s.log.Fatalf("❌ Failed to load API key store: %v", err)
+	}
+	store.Watch(cfg.ReloadInterval)
+
+	s.apiKeys = store
+	s.apiKeyOptions = middleware.APIKeyOptions{
+		Header:     cfg.Header,
+		QueryParam: cfg.QueryParam,
+		Routes:     cfg.Routes,
+	}
+	s.log.Infof("🔑 API key authentication enabled: %d keys from %s", store.Len(), cfg.Store)
+
+	if len(cfg.Tiers) > 0 {
+		if s.rateLimiter == nil {
+			s.log.Warnf("⚠️ API key tiers are ignored: rate limiting is disabled")
+			return
+		}
+		s.rateLimiter.SetTiers(cfg.Tiers)
+	}
+}
+
+func (s *httpServer) setupJWT(cfg config.JWTConfig) {
+	if !cfg.Enabled {
+		return
+	}
+
+	var jwks *auth.JWKS
+	if cfg.JWKSFile != "" || cfg.JWKSURL != "" {
+		var err error
+		if jwks, err = auth.NewJWKS(cfg.JWKSFile, cfg.JWKSURL, cfg.JWKSCacheTTL, s.log); err != nil {
+			s.log.Fatalf("❌ Failed to load JWKS: %v", err)
+		}
+	}
+
+	verifier, err := auth.NewVerifier(auth.VerifierOptions{
+		Secret:     []byte(cfg.Secret),
+		JWKS:       jwks,
+		Issuer:     cfg.Issuer,
+		Audience:   cfg.Audience,
+		Algorithms: cfg.Algorithms,
+		Leeway:     cfg.Leeway,
+	})
+	if err != nil {
+		s.log.Fatalf("❌ Invalid JWT configuration: %v", err)
+	}
+
+	rules := make(map[string]middleware.JWTRule, len(cfg.Rules))
+	for route, rule := range cfg.Rules {
+		rules[route] = middleware.JWTRule{Scopes: rule.Scopes, Claims: rule.Claims}
+	}
+
+	s.jwtVerifier = verifier
+	s.jwtOptions = middleware.JWTOptions{
+		Routes:         cfg.Routes,
+		Rules:          rules,
+		ForwardClaims:  cfg.ForwardClaims,
+		PrincipalClaim: cfg.PrincipalClaim,
+		Realm:          cfg.Realm,
+	}
+	s.log.Infof("🎫 JWT validation enabled (issuer %q, audience %v)", cfg.Issuer, cfg.Audience)
+}
+
+// basicAuthRule файл пользователей и маршруты, которые он защищает
+type basicAuthRule struct {
+	users *auth.Htpasswd
+	opts  middleware.BasicAuthOptions
+}
+
+func (s *httpServer) setupBasicAuth(rules []config.BasicAuthConfig) {
+	for _, cfg := range rules {
+		if cfg.ReloadInterval < 0 {
+			s.log.Fatalf("❌ basic_auth.reload_interval must be positive, got %v", cfg.ReloadInterval)
+		}
+
+		users, err := auth.LoadHtpasswd(cfg.Htpasswd, s.log)
+		if err != nil {
+			s.log.Fatalf("❌ Failed to load htpasswd: %v", err)
+		}
+		users.Watch(cfg.ReloadInterval)
+
+		s.basicAuth = append(s.basicAuth, basicAuthRule{
+			users: users,
+			opts: middleware.BasicAuthOptions{
+				Routes:            cfg.Routes,
+				Realm:             cfg.Realm,
+				KeepAuthorization: cfg.KeepAuthorization,
+			},
+		})
+		s.log.Infof("🔐 Basic auth enabled for routes %v: %d users from %s", cfg.Routes, users.Len(), cfg.Htpasswd)
+	}
+}
+
+func (s *httpServer) setupForwardAuth(cfg config.ForwardAuthConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	if cfg.URL == "" {
+		s.log.Fatalf("❌ Forward auth requires a url")
+	}
+
+	// Заголовок с principal всегда копируется из ответа сервиса и всегда
+	// удаляется из запроса клиента, даже если его нет в response_headers
+	responseHeaders := cfg.ResponseHeaders
+	if cfg.PrincipalHeader != "" && !containsHeader(responseHeaders, cfg.PrincipalHeader) {
+		responseHeaders = append(append([]string(nil), responseHeaders...), cfg.PrincipalHeader)
+	}
+
+	s.forwardAuth = auth.NewForwardAuth(auth.ForwardAuthOptions{
+		URL:             cfg.URL,
+		RequestHeaders:  cfg.RequestHeaders,
+		ResponseHeaders: responseHeaders,
+		Timeout:         cfg.Timeout,
+		CacheTTL:        cfg.CacheTTL,
+		CacheSize:       cfg.CacheSize,
+	})
+	s.forwardOpts = middleware.ForwardAuthOptions{
+		Routes:          cfg.Routes,
+		ResponseHeaders: responseHeaders,
+		PrincipalHeader: cfg.PrincipalHeader,
+	}
+	s.log.Infof("🛂 Forward auth enabled: %s (cache %v)", cfg.URL, cfg.CacheTTL)
+}
+
+func containsHeader(headers []string, name string) bool {
+	for _, h := range headers {
+		if http.CanonicalHeaderKey(h) == http.CanonicalHeaderKey(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *httpServer) setupHMAC(cfg config.HMACConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	if len(cfg.Secrets) == 0 {
+		s.log.Fatalf("❌ HMAC signatures require at least one client secret")
+	}
+
+	s.hmacVerifier = auth.NewHMACVerifier(auth.HMACOptions{
+		Secrets:       cfg.Secrets,
+		SignedHeaders: cfg.SignedHeaders,
+		MaxSkew:       cfg.MaxSkew,
+		NonceCacheMax: cfg.NonceCacheMax,
+	})
+	s.hmacOpts = middleware.HMACSignatureOptions{
+		Routes:       cfg.Routes,
+		MaxBodyBytes: cfg.MaxBodyBytes,
+	}
+	s.log.Infof("✍️ HMAC signature verification enabled for %d clients (skew %v)", len(cfg.Secrets), cfg.MaxSkew)
+}
+
+func (s *httpServer) setupOIDC(cfg config.OIDCConfig) {
+	if !cfg.Enabled {
+		return
+	}
+
+	rp, err := oidc.New(context.Background(), oidc.Options{
+		Issuer:              cfg.Issuer,
+		ClientID:            cfg.ClientID,
+		ClientSecret:        cfg.ClientSecret,
+		RedirectURL:         cfg.RedirectURL,
+		Scopes:              cfg.Scopes,
+		CookieName:          cfg.CookieName,
+		CookieSecret:        cfg.CookieSecret,
+		CookieInsecure:      cfg.CookieInsecure,
+		SessionTTL:          cfg.SessionTTL,
+		GroupsClaim:         cfg.GroupsClaim,
+		PostLogoutURL:       cfg.PostLogoutURL,
+		AllowedGroups:       cfg.AllowedGroups,
+		AllowedEmailDomains: cfg.AllowedEmailDomains,
+	}, s.log)
+	if err != nil {
+		s.log.Fatalf("❌ Failed to set up OIDC: %v", err)
+	}
+
+	s.oidc = rp
+	s.oidcRoutes = cfg.Routes
+	s.oidcLogoutPath = cfg.LogoutPath
+	s.log.Infof("🪪 OIDC login enabled: issuer %s, callback %s", cfg.Issuer, rp.CallbackPath())
+}
+
+func (s *httpServer) setupPolicies(cfg []config.PolicyConfig) {
+	if len(cfg) == 0 {
+		return
+	}
+
+	specs := make([]policy.Spec, 0, len(cfg))
+	for _, pc := range cfg {
+		spec := policy.Spec{
+			Name:          pc.Name,
+			Paths:         pc.Paths,
+			Routes:        pc.Routes,
+			DefaultAction: pc.DefaultAction,
+		}
+		for _, rc := range pc.Rules {
+			rule := policy.RuleSpec{
+				Action:        rc.Action,
+				Methods:       rc.Methods,
+				Paths:         rc.Paths,
+				CIDRs:         rc.CIDRs,
+				Origins:       rc.Origins,
+				Principals:    rc.Principals,
+				AuthMethods:   rc.AuthMethods,
+				Authenticated: rc.Authenticated,
+				When:          rc.When,
+			}
+			if rc.Time != nil {
+				rule.Time = &policy.TimeSpec{
+					Days:     rc.Time.Days,
+					From:     rc.Time.From,
+					To:       rc.Time.To,
+					Timezone: rc.Time.Timezone,
+				}
+			}
+			spec.Rules = append(spec.Rules, rule)
+		}
+		specs = append(specs, spec)
+	}
+
+	set, err := policy.Compile(specs)
+	if err != nil {
+		s.log.Fatalf("❌ Invalid access policy: %v", err)
+	}
+	s.policies = set
+	s.log.Infof("📜 Access policies enabled: %d", set.Len())
+}
+
+func (s *httpServer) setupHeaderRules(cfg []config.HeaderRuleConfig) {
+	for i, hc := range cfg {
+		rule := middleware.HeaderRule{
+			Set:    make(map[string]*expr.Program, len(hc.Set)),
+			Remove: hc.Remove,
+		}
+		if hc.When != "" {
+			when, err := expr.CompileBool(hc.When)
+			if err != nil {
+				s.log.Fatalf("❌ Invalid header_rules[%d].when: %v", i, err)
+			}
+			rule.When = when
+		}
+		for name, src := range hc.Set {
+			value, err := expr.Compile(src)
+			if err != nil {
+				s.log.Fatalf("❌ Invalid header_rules[%d].set.%s: %v", i, name, err)
+			}
+			rule.Set[name] = value
+		}
+		s.headerRules = append(s.headerRules, rule)
+	}
+	if len(s.headerRules) > 0 {
+		s.log.Infof("🏷️ Header rules enabled: %d", len(s.headerRules))
+	}
+}
+
+func (s *httpServer) setupMetrics(cfg config.MetricsConfig) {
+	if cfg.Enabled {
+		s.metrics = metrics.NewProxyMetrics()
+		s.metricsPath = cfg.Path
+		s.log.Infof("📈 Metrics enabled: %s", cfg.Path)
+	}
+}
+
+func (s *httpServer) setupAccessLog(cfg config.AccessLogConfig) {
+	if !s.logRequests {
+		return
+	}
+
+	accessLog, err := accesslog.New(accesslog.Options{
+		Format:         cfg.Format,
+		Template:       cfg.Template,
+		Output:         cfg.Output,
+		MaxSizeMB:      cfg.MaxSizeMB,
+		MaxBackups:     cfg.MaxBackups,
+		RotateInterval: cfg.RotateInterval,
+	})
+	if err != nil {
+		s.log.Fatalf("❌ Failed to set up access log: %v", err)
+	}
+	s.accessLog = accessLog
+}
+
+func (s *httpServer) setupCapture(cfg config.CaptureConfig) {
+	// Без токенов администратора захват нельзя запустить во время работы
+	if !cfg.Enabled && len(s.adminTokens) == 0 {
+		return
+	}
+
+	s.capture = capture.NewRecorder(capture.Options{
+		Dir:         cfg.Dir,
+		Format:      cfg.Format,
+		MaxEntries:  cfg.MaxEntries,
+		MaxDuration: cfg.MaxDuration,
+		Filter: capture.Filter{
+			Routes:  cfg.Routes,
+			Clients: cfg.Clients,
+			Headers: cfg.Headers,
+		},
+	}, s.log)
+	s.captureBodies = middleware.BodyCaptureOptions{
+		MaxBytes:         cfg.MaxBodyBytes,
+		SampleRate:       1,
+		SkipContentTypes: middleware.DefaultSkipContentTypes,
+	}
+
+	if cfg.Enabled {
+		if _, err := s.capture.Start(capture.Options{}); err != nil {
+			s.log.Fatalf("❌ Failed to start traffic capture: %v", err)
+		}
+	}
+}
+
+func newBodyCaptureOptions(cfg config.BodyLoggingConfig) middleware.BodyCaptureOptions {
+	skip := cfg.SkipContentTypes
+	if len(skip) == 0 {
+		skip = middleware.DefaultSkipContentTypes
+	}
+	rate := 1.0
+	if cfg.SampleRate != nil {
+		rate = *cfg.SampleRate
+	}
+	return middleware.BodyCaptureOptions{
+		MaxBytes:         cfg.MaxBytes,
+		SampleRate:       rate,
+		SkipContentTypes: skip,
 	}
 }
 
@@ -62,6 +685,11 @@ func (s *httpServer) logConfiguration() {
 		s.log.Info("📝 Request logging enabled")
 	}
 
+	if s.logBodies {
+		s.log.Infof("📦 Body logging enabled (with redaction): max %d bytes, sample rate %.2f",
+			s.bodyCapture.MaxBytes, s.bodyCapture.SampleRate)
+	}
+
 	if len(s.allowedDomains) > 0 {
 		s.log.Infof("🌐 Client domain restrictions enabled: %v", s.allowedDomains)
 	}
@@ -79,7 +707,7 @@ func (s *httpServer) RegisterEndpoints() {
 
 	// Создаем и настраиваем обработчик
 	mainHandler := router.createMainHandler()
-	finalHandler := middlewareBuilder.build(mainHandler)
+	finalHandler := middlewareBuilder.build(mainHandler, router.routeName)
 
 	http.Handle("/", finalHandler)
 }
@@ -103,10 +731,10 @@ func (s *httpServer) extractDomain(urlStr string) string {
 
 // Остальные методы остаются в основном файле
 func (s *httpServer) getClientIP(r *http.Request) string {
-	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
-		return forwarded
+	if info := reqctx.FromRequest(r); info != nil {
+		return info.ClientIP
 	}
-	return r.RemoteAddr
+	return s.trustedProxies.ClientIP(r)
 }
 
 func (s *httpServer) jsonResponse(w http.ResponseWriter, data interface{}) {
@@ -134,7 +762,33 @@ func (s *httpServer) ListenAndServe() error {
 	s.log.Infof("📝 Request logging: %t", s.logRequests)
 	s.log.Infof("🌐 Client domain restrictions: %t", len(s.allowedDomains) > 0)
 	s.log.Infof("🚫 Method restrictions: %t", len(s.blockedMethods) > 0)
-	return http.ListenAndServe(addr, nil)
+	s.log.Infof("📈 Metrics: %t", s.metrics != nil)
+
+	// По SIGINT/SIGTERM сервер дожидается текущих запросов, чтобы их учёт
+	// (например, счётчики квот) успел сохраниться
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Addr: addr}
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.ListenAndServe() }()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		s.log.Info("🛑 Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err = srv.Shutdown(shutdownCtx)
+		cancel()
+	}
+
+	if s.quota != nil {
+		if closeErr := s.quota.Close(); closeErr != nil {
+			s.log.Errorf("❌ Failed to save quota counters: %v", closeErr)
+		}
+	}
+	return err
 }
 
 func (s *httpServer) GetRateLimit() int {
@@ -14,9 +14,35 @@ func newMiddlewareBuilder(server *httpServer) *middlewareBuilder {
 	return &middlewareBuilder{server: server}
 }
 
-func (b *middlewareBuilder) build(handler http.Handler) http.Handler {
+func (b *middlewareBuilder) build(handler http.Handler, routeName func(*http.Request) string) http.Handler {
 	// Порядок применения middleware (от внешнего к внутреннему)
-	middlewares := []func(http.Handler) http.Handler{}
+	middlewares := []func(http.Handler) http.Handler{
+		middleware.RequestInfoMiddleware(routeName, b.server.trustedProxies),
+	}
+
+	// 0. Метрики (снаружи, чтобы учитывать отказы остальных middleware)
+	if b.server.metrics != nil {
+		middlewares = append(middlewares,
+			middleware.MetricsMiddleware(b.server.metrics))
+	}
+
+	// 0.1 Журнал доступа (снаружи по той же причине)
+	if b.server.accessLog != nil {
+		middlewares = append(middlewares,
+			middleware.AccessLogMiddleware(b.server.accessLog, b.server.redactor))
+	}
+
+	// 0.2 Подробное логирование заголовков и тел
+	if b.server.logBodies {
+		middlewares = append(middlewares,
+			middleware.LoggingMiddleware(b.server.log, true, b.server.redactor, b.server.bodyCapture))
+	}
+
+	// 0.3 Захват трафика в HAR (включается во время работы через /capture)
+	if b.server.capture != nil {
+		middlewares = append(middlewares,
+			middleware.CaptureMiddleware(b.server.capture, b.server.redactor, b.server.captureBodies))
+	}
 
 	// 1. Блокировка методов
 	if len(b.server.blockedMethods) > 0 {
@@ -30,17 +56,79 @@ func (b *middlewareBuilder) build(handler http.Handler) http.Handler {
 			middleware.ClientDomainValidator(b.server.log, b.server.allowedDomains))
 	}
 
-	// 3. Логирование
-	if b.server.logRequests {
-		middlewares = append(middlewares, 
-			middleware.RequestLoggerMiddleware(b.server.log, true))
+	// 2.1 API ключи (до rate limiting: лимит считается по principal)
+	if b.server.apiKeys != nil {
+		middlewares = append(middlewares,
+			middleware.APIKeyMiddleware(b.server.log, b.server.apiKeys, b.server.apiKeyOptions))
+	}
+
+	// 2.2 JWT
+	if b.server.jwtVerifier != nil {
+		middlewares = append(middlewares,
+			middleware.JWTMiddleware(b.server.log, b.server.jwtVerifier, b.server.jwtOptions))
+	}
+
+	// 2.3 HTTP Basic
+	for _, rule := range b.server.basicAuth {
+		middlewares = append(middlewares,
+			middleware.BasicAuthMiddleware(b.server.log, rule.users, rule.opts))
+	}
+
+	// 2.4 Внешний сервис авторизации
+	if b.server.forwardAuth != nil {
+		middlewares = append(middlewares,
+			middleware.ForwardAuthMiddleware(b.server.log, b.server.forwardAuth, b.server.forwardOpts))
+	}
+
+	// 2.5 Подписи HMAC
+	if b.server.hmacVerifier != nil {
+		middlewares = append(middlewares,
+			middleware.HMACSignatureMiddleware(b.server.log, b.server.hmacVerifier, b.server.hmacOpts))
+	}
+
+	// 2.6 Вход через OIDC
+	if b.server.oidc != nil {
+		middlewares = append(middlewares,
+			middleware.OIDCMiddleware(b.server.log, b.server.oidc, middleware.OIDCOptions{
+				Routes:     b.server.oidcRoutes,
+				SkipRoutes: []string{oidcCallbackRoute, oidcLogoutRoute},
+			}))
+	}
+
+	// 2.7 Политики доступа маршрутов (после аутентификации: правила проверяют principal)
+	if b.server.policies != nil {
+		middlewares = append(middlewares,
+			middleware.PolicyMiddleware(b.server.log, b.server.policies))
 	}
 
-	// 4. Rate limiting
+	// 2.8 Правила заголовков (после аутентификации: значения могут использовать principal)
+	if len(b.server.headerRules) > 0 {
+		middlewares = append(middlewares,
+			middleware.HeaderRulesMiddleware(b.server.headerRules))
+	}
+
+	// 3. Rate limiting
 	if b.server.useRateLimit {
 		middlewares = append(middlewares, b.server.rateLimiter.Middleware)
 	}
 
+	// 3.1 Ограничение одновременных запросов (после rate limiting: отклонённые
+	// запросы не занимают места)
+	if b.server.concurrency != nil {
+		middlewares = append(middlewares, b.server.concurrency.Middleware)
+	}
+
+	// 3.2 Квоты вызовов (после rate limiting и ограничения одновременных запросов:
+	// отклонённые ими запросы не расходуют квоту)
+	if b.server.quota != nil {
+		middlewares = append(middlewares, b.server.quota.Middleware)
+	}
+
+	// 3.3 Ограничение скорости передачи тел запроса и ответа
+	if b.server.bandwidth != nil {
+		middlewares = append(middlewares, b.server.bandwidth.Middleware)
+	}
+
 	// Применяем middleware в обратном порядке (последний становится самым внешним)
 	for i := len(middlewares) - 1; i >= 0; i-- {
 		handler = middlewares[i](handler)
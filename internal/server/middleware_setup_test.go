@@ -0,0 +1,76 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"access-proxy/internal/capture"
+	"access-proxy/internal/concurrency"
+	"access-proxy/internal/quota"
+	"access-proxy/internal/reqctx"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+func TestQuotaAfterConcurrencyLimit(t *testing.T) {
+	log := logger.New("test", logger.LevelInfo, logger.ModeDev)
+	s := &httpServer{log: log}
+	s.capture = capture.NewRecorder(capture.Options{Dir: t.TempDir()}, log)
+
+	var err error
+	s.concurrency, err = concurrency.New(concurrency.Options{
+		Global:         1,
+		QueueTimeout:   10 * time.Millisecond,
+		UpstreamRoutes: []string{proxyRouteName},
+	}, nil, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := quota.NewFileStore(filepath.Join(t.TempDir(), "quota.json"), time.Hour, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.quota = quota.New(quota.Options{
+		Default:        quota.Limit{Daily: 10},
+		Store:          store,
+		UpstreamRoutes: []string{proxyRouteName},
+	}, nil, log)
+	defer s.quota.Close()
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	handler := newMiddlewareBuilder(s).build(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}), func(*http.Request) string { return proxyRouteName })
+
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api", nil))
+		return rec
+	}
+
+	done := make(chan struct{})
+	go func() {
+		serve()
+		close(done)
+	}()
+	<-entered
+
+	// Запрос, отклонённый ограничением одновременных запросов, квоту не расходует
+	if rec := serve(); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("second request: %d", rec.Code)
+	}
+	close(release)
+	<-done
+
+	req := httptest.NewRequest(http.MethodGet, "/quota", nil)
+	req = req.WithContext(reqctx.NewContext(req.Context(), &reqctx.Info{ClientIP: "192.0.2.1"}))
+	statuses, err := s.quota.Status(req)
+	if err != nil || len(statuses) != 1 || statuses[0].Used != 1 {
+		t.Errorf("quota status: %+v %v", statuses, err)
+	}
+}
@@ -85,6 +85,8 @@ import (
 	"net/url"
 	"time"
 
+	"access-proxy/internal/redact"
+
 	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
 )
 
@@ -93,11 +95,12 @@ type ProxyServer interface {
 }
 
 type proxyServer struct {
-	proxy *http.Handler
-	log   logger.Logger
+	proxy    *http.Handler
+	log      logger.Logger
+	redactor *redact.Redactor
 }
 
-func NewProxyServer(target string, log logger.Logger) ProxyServer {
+func NewProxyServer(target string, log logger.Logger, redactor *redact.Redactor) ProxyServer {
 	targetURL, err := url.Parse(target)
 	if err != nil {
 		log.Fatalf("❌ Failed to parse target URL in proxy: %v", err)
@@ -105,18 +108,19 @@ func NewProxyServer(target string, log logger.Logger) ProxyServer {
 	
 	log.Infof("🎯 Proxy target: %s", targetURL.String())
 
-	proxyBuilder := newProxyBuilder(targetURL, log)
+	proxyBuilder := newProxyBuilder(targetURL, log, redactor)
 	proxy := proxyBuilder.build()
 
 	return &proxyServer{
-		proxy: &proxy,
-		log:   log,
+		proxy:    &proxy,
+		log:      log,
+		redactor: redactor,
 	}
 }
 
 func (p *proxyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
-	p.log.Infof("📥 Incoming: %s %s", r.Method, r.URL.String())
+	p.log.Infof("📥 Incoming: %s %s", r.Method, p.redactor.URL(r.URL))
 	
 	// Устанавливаем правильные заголовки ответа
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -5,6 +5,8 @@ import (
 	"net/http/httputil"
 	"net/url"
 
+	"access-proxy/internal/redact"
+
 	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
 )
 
@@ -16,19 +18,20 @@ type proxyBuilder struct {
 	err       *errorHandler
 }
 
-func newProxyBuilder(targetURL *url.URL, log logger.Logger) *proxyBuilder {
+func newProxyBuilder(targetURL *url.URL, log logger.Logger, redactor *redact.Redactor) *proxyBuilder {
 	return &proxyBuilder{
 		targetURL: targetURL,
 		log:       log,
-		req:       newRequestProcessor(log),
+		req:       newRequestProcessor(log, redactor),
 		res:       newResponseProcessor(log),
-		err:       newErrorHandler(log),
+		err:       newErrorHandler(log, redactor),
 	}
 }
 
 func (b *proxyBuilder) build() http.Handler {
 	proxy := httputil.NewSingleHostReverseProxy(b.targetURL)
-	
+	proxy.Transport = newUpstreamTransport(nil)
+
 	b.setupDirector(proxy)
 	b.setupResponseModifier(proxy)
 	b.setupErrorHandler(proxy)
@@ -1,17 +1,21 @@
 package server
 
 import (
+	"encoding/json"
 	"net/http"
 
+	"access-proxy/internal/redact"
+
 	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
 )
 
 type requestProcessor struct {
-	log logger.Logger
+	log      logger.Logger
+	redactor *redact.Redactor
 }
 
-func newRequestProcessor(log logger.Logger) *requestProcessor {
-	return &requestProcessor{log: log}
+func newRequestProcessor(log logger.Logger, redactor *redact.Redactor) *requestProcessor {
+	return &requestProcessor{log: log, redactor: redactor}
 }
 
 func (p *requestProcessor) process(req *http.Request) {
@@ -32,7 +36,7 @@ func (p *requestProcessor) removeProblematicHeaders(req *http.Request) {
 }
 
 func (p *requestProcessor) logRequest(req *http.Request) {
-	p.log.Infof("➡️  Forwarding to %s %s", req.Method, req.URL.String())
+	p.log.Infof("➡️  Forwarding to %s %s", req.Method, p.redactor.URL(req.URL))
 }
 
 type responseProcessor struct {
@@ -48,22 +52,24 @@ func (p *responseProcessor) logResponse(resp *http.Response) {
 }
 
 type errorHandler struct {
-	log logger.Logger
+	log      logger.Logger
+	redactor *redact.Redactor
 }
 
-func newErrorHandler(log logger.Logger) *errorHandler {
-	return &errorHandler{log: log}
+func newErrorHandler(log logger.Logger, redactor *redact.Redactor) *errorHandler {
+	return &errorHandler{log: log, redactor: redactor}
 }
 
 func (h *errorHandler) handleError(w http.ResponseWriter, r *http.Request, err error) {
-	h.log.Errorf("❌ Proxy error: %v", err)
-	h.log.Errorf("❌ Request: %s %s", r.Method, r.URL.String())
+	h.log.Errorf("❌ Proxy error: %s", h.redactor.Error(err))
+	h.log.Errorf("❌ Request: %s %s", r.Method, h.redactor.URL(r.URL))
 	
 	h.writeErrorResponse(w, err)
 }
 
 func (h *errorHandler) writeErrorResponse(w http.ResponseWriter, err error) {
-	w.WriteHeader(http.StatusBadGateway)
+	message, _ := json.Marshal(h.redactor.Error(err))
 	w.Header().Set("Content-Type", "application/json")
-	w.Write([]byte(`{"error": "Bad Gateway", "message": "` + err.Error() + `"}`))
+	w.WriteHeader(http.StatusBadGateway)
+	w.Write([]byte(`{"error": "Bad Gateway", "message": ` + string(message) + `}`))
 }
\ No newline at end of file
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"access-proxy/internal/redact"
+
+	"github.com/Freyzan2006/go-logger-lib/pkg/logger"
+)
+
+func TestErrorHandlerRedactsUpstreamURL(t *testing.T) {
+	redactor, err := redact.New(redact.Rules{QueryParams: []string{"token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newErrorHandler(logger.New("test", logger.LevelInfo, logger.ModeDev), redactor)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/x?token=secret", nil)
+	h.handleError(rec, req, &url.Error{Op: "Get", URL: "http://app:8080/x?token=secret", Err: errors.New(`dial "tcp"`)})
+
+	if rec.Code != http.StatusBadGateway || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid json %q: %v", rec.Body.String(), err)
+	}
+	if strings.Contains(body["message"], "secret") {
+		t.Errorf("secret leaked to client: %q", body["message"])
+	}
+}
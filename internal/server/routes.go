@@ -0,0 +1,169 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"access-proxy/internal/balance"
+	"access-proxy/internal/canary"
+	"access-proxy/internal/config"
+	"access-proxy/internal/expr"
+	"access-proxy/internal/mirror"
+)
+
+// proxyRoute именованный маршрут прокси с собственным обработчиком
+type proxyRoute struct {
+	name    string
+	prefix  string
+	when    *expr.Program // дополнительное условие выбора маршрута
+	handler http.Handler
+}
+
+func (s *httpServer) setupRoutes(routes []config.RouteConfig) {
+	seen := make(map[string]bool, len(routes))
+
+	for _, rc := range routes {
+		if rc.Name == "" || !strings.HasPrefix(rc.PathPrefix, "/") {
+			s.log.Fatalf("❌ Route requires a name and a path_prefix starting with '/': %+v", rc)
+		}
+		if seen[rc.Name] {
+			s.log.Fatalf("❌ Duplicate route name: %s", rc.Name)
+		}
+		seen[rc.Name] = true
+
+		route := proxyRoute{name: rc.Name, prefix: rc.PathPrefix, handler: s.proxy}
+
+		if rc.When != "" {
+			when, err := expr.CompileBoolBeforeAuth(rc.When)
+			if err != nil {
+				s.log.Fatalf("❌ Invalid when of route %s: %v", rc.Name, err)
+			}
+			route.when = when
+		}
+
+		if len(rc.Targets) > 0 {
+			route.handler = s.setupPool(rc)
+		}
+
+		if rc.Canary != nil {
+			route.handler = s.setupCanary(rc.Name, rc.Canary, route.handler)
+		}
+
+		if rc.Mirror != nil {
+			m, err := mirror.New(rc.Name, mirror.Options{
+				Target:        rc.Mirror.Target,
+				SamplePercent: rc.Mirror.SamplePercent,
+				MaxBodyBytes:  rc.Mirror.MaxBodyBytes,
+				Timeout:       rc.Mirror.Timeout,
+				MaxInFlight:   rc.Mirror.MaxInFlight,
+			}, s.metrics, s.log)
+			if err != nil {
+				s.log.Fatalf("❌ Failed to set up mirroring: %v", err)
+			}
+			route.handler = m.Wrap(route.handler)
+			s.log.Infof("👥 Mirroring route %s (%s) to %s: %.1f%% of requests",
+				rc.Name, rc.PathPrefix, rc.Mirror.Target, rc.Mirror.SamplePercent)
+		}
+
+		s.routes = append(s.routes, route)
+	}
+
+	// Самый длинный префикс проверяется первым
+	sort.SliceStable(s.routes, func(i, j int) bool {
+		return len(s.routes[i].prefix) > len(s.routes[j].prefix)
+	})
+}
+
+// setupPool распределяет запросы маршрута по нескольким экземплярам upstream
+func (s *httpServer) setupPool(rc config.RouteConfig) http.Handler {
+	instances := make([]balance.Instance, 0, len(rc.Targets))
+	for _, target := range rc.Targets {
+		instances = append(instances, balance.Instance{
+			Target:  target,
+			Handler: NewProxyServer(target, s.log, s.redactor),
+		})
+	}
+
+	var affinity balance.Affinity
+	if rc.Affinity != nil {
+		affinity = balance.Affinity{
+			Mode:        rc.Affinity.Mode,
+			Cookie:      rc.Affinity.Cookie,
+			CookieTTL:   rc.Affinity.CookieTTL,
+			Header:      rc.Affinity.Header,
+			PathSegment: rc.Affinity.PathSegment,
+		}
+	}
+
+	pool, err := balance.NewPool(instances, affinity)
+	if err != nil {
+		s.log.Fatalf("❌ Failed to set up targets for route %s: %v", rc.Name, err)
+	}
+	s.log.Infof("🧲 Route %s balances %d targets with %s affinity", rc.Name, len(rc.Targets), pool.Mode())
+	return pool
+}
+
+// setupCanary делит трафик маршрута между stable и canary upstream
+func (s *httpServer) setupCanary(name string, cfg *config.CanaryConfig, stable http.Handler) http.Handler {
+	if cfg.Target == "" {
+		s.log.Fatalf("❌ Canary for route %s requires a target", name)
+	}
+
+	splitter, err := canary.New(name, stable, NewProxyServer(cfg.Target, s.log, s.redactor), canary.Options{
+		Target:         cfg.Target,
+		Percent:        cfg.Percent,
+		Header:         cfg.Header,
+		Cookie:         cfg.Cookie,
+		ClientIDHeader: cfg.ClientIDHeader,
+		CanaryClients:  cfg.CanaryClients,
+		StableClients:  cfg.StableClients,
+	}, s.metrics)
+	if err != nil {
+		s.log.Fatalf("❌ Failed to set up canary: %v", err)
+	}
+
+	if s.canaries == nil {
+		s.canaries = make(map[string]*canary.Splitter)
+	}
+	s.canaries[name] = splitter
+	s.log.Infof("🐤 Canary for route %s: %.1f%% of traffic to %s", name, cfg.Percent, cfg.Target)
+	return splitter
+}
+
+// matchRoute ищет маршрут по префиксу пути и условию when
+func (s *httpServer) matchRoute(r *http.Request) *proxyRoute {
+	var env *expr.Env
+	for i := range s.routes {
+		route := &s.routes[i]
+		if !matchPrefix(r.URL.Path, route.prefix) {
+			continue
+		}
+		if route.when != nil {
+			if env == nil {
+				env = expr.EnvFromRequest(r)
+			}
+			if !route.when.Bool(env) {
+				continue
+			}
+		}
+		return route
+	}
+	return nil
+}
+
+// matchPrefix сравнивает префикс по границе сегмента: /api подходит
+// для /api и /api/..., но не для /apix и /api-internal
+func matchPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+// routeByName маршрут прокси по имени или nil
+func (s *httpServer) routeByName(name string) *proxyRoute {
+	for i := range s.routes {
+		if s.routes[i].name == name {
+			return &s.routes[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package server
+
+import "testing"
+
+func TestMatchPrefix(t *testing.T) {
+	tests := []struct {
+		path   string
+		prefix string
+		want   bool
+	}{
+		{"/api", "/api", true},
+		{"/api/users", "/api", true},
+		{"/apix/users", "/api", false},
+		{"/api-internal", "/api", false},
+		{"/api/users", "/api/", true},
+		{"/api/", "/api/", true},
+		{"/apix", "/api/", false},
+		{"/anything", "/", true},
+		{"/", "/", true},
+	}
+	for _, tt := range tests {
+		if got := matchPrefix(tt.path, tt.prefix); got != tt.want {
+			t.Errorf("matchPrefix(%q, %q) = %v, want %v", tt.path, tt.prefix, got, tt.want)
+		}
+	}
+}
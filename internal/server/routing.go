@@ -1,39 +1,103 @@
 package server
 
-import "net/http"
+import (
+	"net/http"
+
+	"access-proxy/internal/reqctx"
+)
+
+// proxyRouteName имя маршрута для всех запросов, уходящих в прокси
+const proxyRouteName = "proxy"
+
+// Служебные маршруты входа OIDC, они не требуют сессии
+const (
+	oidcCallbackRoute = "oidc_callback"
+	oidcLogoutRoute   = "oidc_logout"
+)
+
+type endpoint struct {
+	name    string
+	handler http.HandlerFunc
+}
 
 type router struct {
-	handlers *infoHandlers
-	server   *httpServer
+	handlers  *infoHandlers
+	server    *httpServer
+	endpoints map[string]endpoint
 }
 
 func newRouter(server *httpServer, handlers *infoHandlers) *router {
-	return &router{
+	r := &router{
 		handlers: handlers,
 		server:   server,
+		endpoints: map[string]endpoint{
+			"/":               {"root", handlers.rootHandler},
+			"/health":         {"health", handlers.healthHandler},
+			"/ratelimit-info": {"ratelimit_info", handlers.rateLimitInfoHandler},
+			"/config":         {"config", handlers.configHandler},
+			"/client-info":    {"client_info", handlers.clientInfoHandler},
+			"/methods":        {"methods", handlers.methodsHandler},
+			"/domains":        {"domains", handlers.domainsHandler},
+		},
+	}
+
+	// Эндпоинты выключенных функций не регистрируются и не перекрывают пути upstream
+	if server.capture != nil {
+		r.endpoints["/capture"] = endpoint{"capture", handlers.captureHandler}
+	}
+	if len(server.canaries) > 0 {
+		r.endpoints["/canary"] = endpoint{"canary", handlers.canaryHandler}
+	}
+	if server.concurrency != nil {
+		r.endpoints["/concurrency"] = endpoint{"concurrency", handlers.concurrencyHandler}
+	}
+	if server.quota != nil {
+		r.endpoints["/quota"] = endpoint{"quota", handlers.quotaHandler}
+	}
+
+	if server.metrics != nil {
+		r.endpoints[server.metricsPath] = endpoint{"metrics", handlers.metricsHandler}
 	}
+
+	if server.oidc != nil {
+		r.endpoints[server.oidc.CallbackPath()] = endpoint{oidcCallbackRoute, server.oidc.Callback}
+		r.endpoints[server.oidcLogoutPath] = endpoint{oidcLogoutRoute, server.oidc.Logout}
+	}
+
+	return r
+}
+
+// routeName возвращает имя маршрута для запроса. Набор имён ограничен,
+// поэтому его можно использовать как метку метрик
+func (r *router) routeName(req *http.Request) string {
+	if ep, ok := r.endpoints[req.URL.Path]; ok {
+		return ep.name
+	}
+	if route := r.server.matchRoute(req); route != nil {
+		return route.name
+	}
+	return proxyRouteName
 }
 
 func (r *router) createMainHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		switch req.URL.Path {
-		case "/":
-			r.handlers.rootHandler(w, req)
-		case "/health":
-			r.handlers.healthHandler(w, req)
-		case "/ratelimit-info":
-			r.handlers.rateLimitInfoHandler(w, req)
-		case "/config":
-			r.handlers.configHandler(w, req)
-		case "/client-info":
-			r.handlers.clientInfoHandler(w, req)
-		case "/methods":
-			r.handlers.methodsHandler(w, req)
-		case "/domains":
-			r.handlers.domainsHandler(w, req)
-		default:
-			// Все остальные пути через прокси
-			r.server.proxy.ServeHTTP(w, req)
+		if ep, ok := r.endpoints[req.URL.Path]; ok {
+			ep.handler(w, req)
+			return
+		}
+
+		// Маршрут уже выбран в RequestInfoMiddleware; условия when повторно не вычисляются
+		if info := reqctx.FromRequest(req); info != nil {
+			if route := r.server.routeByName(info.Route); route != nil {
+				route.handler.ServeHTTP(w, req)
+				return
+			}
+		} else if route := r.server.matchRoute(req); route != nil {
+			route.handler.ServeHTTP(w, req)
+			return
 		}
+
+		// Все остальные пути через прокси
+		r.server.proxy.ServeHTTP(w, req)
 	})
-}
\ No newline at end of file
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"access-proxy/internal/metrics"
+	"access-proxy/internal/reqctx"
+)
+
+// upstreamTransport замеряет вызов upstream и записывает результат в reqctx.Info
+type upstreamTransport struct {
+	base http.RoundTripper
+}
+
+func newUpstreamTransport(base http.RoundTripper) *upstreamTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &upstreamTransport{base: base}
+}
+
+func (t *upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+
+	if info := reqctx.FromRequest(req); info != nil {
+		info.Upstream = req.URL.Scheme + "://" + req.URL.Host
+		info.UpstreamDuration = time.Since(start)
+		info.UpstreamError = metrics.ClassifyError(err)
+	}
+	return resp, err
+}